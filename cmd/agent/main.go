@@ -14,11 +14,13 @@ import (
 	"github.com/ophum/humstack/pkg/agents/system/image"
 	"github.com/ophum/humstack/pkg/agents/system/node"
 	"github.com/ophum/humstack/pkg/agents/system/nodenetwork"
+	"github.com/ophum/humstack/pkg/agents/system/scheduler"
 	"github.com/ophum/humstack/pkg/agents/system/virtualmachine"
 	"github.com/ophum/humstack/pkg/agents/system/virtualrouter"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
@@ -37,9 +39,23 @@ type Config struct {
 	ApiServerPort    int32     `yaml:"apiServerPort"`
 	LimitMemory      string    `yaml:"limitMemory"`
 	LimitVcpus       string    `yaml:"limitVcpus"`
+	LimitDisk        string    `yaml:"limitDisk"`
 	NodeAddress      string    `yaml:"nodeAddress"`
 	PollingSeconds   int       `yaml:"pollingSeconds"`
 
+	// VcpusOvercommitRatio, MemoryOvercommitRatio はホストのcpu数とメモリの何倍まで割り当てるか
+	VcpusOvercommitRatio  float64 `yaml:"vcpusOvercommitRatio"`
+	MemoryOvercommitRatio float64 `yaml:"memoryOvercommitRatio"`
+
+	// Scheduler をtrueにしたagentでVirtualMachine, BlockStorageのスケジューラを動かす
+	// 複数のagentで動かすと同じリソースを別々のnodeに割り当てることがあるので、クラスタ内で1つだけ指定する
+	Scheduler bool `yaml:"scheduler"`
+
+	// ServiceAccountToken はapiserverへのリクエストに付けるサービスアカウントのトークン
+	ServiceAccountToken string `yaml:"serviceAccountToken"`
+	// TLS を指定した場合はHTTPSでapiserverにアクセスする
+	TLS *tlsutil.ClientConfig `yaml:"tls"`
+
 	BlockStorageAgentConfig blockstorage.BlockStorageAgentConfig `yaml:"blockStorageAgentConfig"`
 
 	NetworkAgentConfig nodenetwork.NetworkAgentConfig `yaml:"networkAgentConfig"`
@@ -79,7 +95,15 @@ func main() {
 		log.Fatal(err)
 	}
 
-	client := client.NewClients(config.ApiServerAddress, config.ApiServerPort)
+	tlsConfig, err := config.TLS.TLSConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := client.NewClients(config.ApiServerAddress, config.ApiServerPort, tlsConfig)
+	if config.ServiceAccountToken != "" {
+		client.SetToken(config.ServiceAccountToken)
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		log.Fatal(err)
@@ -87,20 +111,37 @@ func main() {
 
 	pollingDuration := time.Second * time.Duration(config.PollingSeconds)
 
+	// cephBackendが設定されている場合はschedulerがCephのBlockStorageを割り当てる
+	cephBackend := "false"
+	if config.BlockStorageAgentConfig.CephBackend != nil {
+		cephBackend = "true"
+	}
+
+	// BlockStorageを作成しないCoreのみのノードではdiskの容量を取得しない
+	blockStorageDirPath := ""
+	if config.AgentMode == AgentModeAll || config.AgentMode == AgentModeSystem {
+		blockStorageDirPath = config.BlockStorageAgentConfig.BlockStorageDirPath
+	}
+
 	nodeAgent := node.NewNodeAgent(&system.Node{
 		Meta: meta.Meta{
 			ID:   hostname,
 			Name: hostname,
 			Annotations: map[string]string{
-				"agentMode": string(config.AgentMode),
+				"agentMode":   string(config.AgentMode),
+				"cephBackend": cephBackend,
 			},
 		},
 		Spec: system.NodeSpec{
 			Address:     config.NodeAddress,
 			LimitMemory: config.LimitMemory,
 			LimitVcpus:  config.LimitVcpus,
+			LimitDisk:   config.LimitDisk,
+
+			VcpusOvercommitRatio:  config.VcpusOvercommitRatio,
+			MemoryOvercommitRatio: config.MemoryOvercommitRatio,
 		},
-	}, client,
+	}, blockStorageDirPath, client,
 		logger.With(zap.Namespace("NodeAgent")),
 	)
 	go nodeAgent.Run(pollingDuration)
@@ -126,6 +167,16 @@ func main() {
 		go netAgent.Run(pollingDuration)
 	}
 
+	// schedulerはクラスタ全体で1つだけ動かすので、scheduler: trueを指定したagentでのみ動かす
+	if config.Scheduler {
+		schedulerAgent := scheduler.NewSchedulerAgent(
+			client,
+			scheduler.NewDefaultFramework(),
+			logger.With(zap.Namespace("SchedulerAgent")),
+		)
+		go schedulerAgent.Run(pollingDuration)
+	}
+
 	if config.AgentMode == AgentModeAll || config.AgentMode == AgentModeSystem {
 		nodeNetAgent := nodenetwork.NewNodeNetworkAgent(
 			client,
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	_ "github.com/ophum/humstack/cmd/apiserver/statik"
+	_ "github.com/ophum/humstack/pkg/api/admission/defaults"
+	_ "github.com/ophum/humstack/pkg/api/admission/validators"
+	_ "github.com/ophum/humstack/pkg/store/migrations"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admin"
+	adminv0 "github.com/ophum/humstack/pkg/api/admin/v0"
+	"github.com/ophum/humstack/pkg/api/auth"
+	authv0 "github.com/ophum/humstack/pkg/api/auth/v0"
 	"github.com/ophum/humstack/pkg/api/core/externalip"
 	eipv0 "github.com/ophum/humstack/pkg/api/core/externalip/v0"
 	"github.com/ophum/humstack/pkg/api/core/externalippool"
@@ -18,6 +30,16 @@ import (
 	nsv0 "github.com/ophum/humstack/pkg/api/core/namespace/v0"
 	"github.com/ophum/humstack/pkg/api/core/network"
 	netv0 "github.com/ophum/humstack/pkg/api/core/network/v0"
+	"github.com/ophum/humstack/pkg/api/core/resourcequota"
+	rqv0 "github.com/ophum/humstack/pkg/api/core/resourcequota/v0"
+	"github.com/ophum/humstack/pkg/api/core/rolebinding"
+	rbv0 "github.com/ophum/humstack/pkg/api/core/rolebinding/v0"
+	"github.com/ophum/humstack/pkg/api/core/serviceaccount"
+	sav0 "github.com/ophum/humstack/pkg/api/core/serviceaccount/v0"
+	"github.com/ophum/humstack/pkg/api/core/user"
+	userv0 "github.com/ophum/humstack/pkg/api/core/user/v0"
+	"github.com/ophum/humstack/pkg/api/replication"
+	replicationv0 "github.com/ophum/humstack/pkg/api/replication/v0"
 	"github.com/ophum/humstack/pkg/api/system/blockstorage"
 	bsv0 "github.com/ophum/humstack/pkg/api/system/blockstorage/v0"
 	"github.com/ophum/humstack/pkg/api/system/image"
@@ -28,12 +50,17 @@ import (
 	nodev0 "github.com/ophum/humstack/pkg/api/system/node/v0"
 	"github.com/ophum/humstack/pkg/api/system/nodenetwork"
 	nodenetv0 "github.com/ophum/humstack/pkg/api/system/nodenetwork/v0"
+	"github.com/ophum/humstack/pkg/api/system/storageclass"
+	scv0 "github.com/ophum/humstack/pkg/api/system/storageclass/v0"
 	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
 	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
 	"github.com/ophum/humstack/pkg/api/system/virtualrouter"
 	vrv0 "github.com/ophum/humstack/pkg/api/system/virtualrouter/v0"
 	"github.com/ophum/humstack/pkg/api/watch"
 	watchv0 "github.com/ophum/humstack/pkg/api/watch/v0"
+	storeif "github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/replicated"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"github.com/rakyll/statik/fs"
 
 	//store "github.com/ophum/humstack/pkg/store/memory"
@@ -43,41 +70,90 @@ import (
 )
 
 var (
-	listenAddress string
-	listenPort    int64
-	isDebug       bool
+	listenAddress     string
+	listenPort        int64
+	isDebug           bool
+	watchHistorySize  int
+	watchBufferSize   int
+	storeBackend      string
+	storeLeader       string
+	migrateDryRun     bool
+	enableAuth        bool
+	adminPassword     string
+	tokenTTL          time.Duration
+	corsAllowOrigins  string
+	storeLeaderToken  string
+	tlsCertFile       string
+	tlsKeyFile        string
+	tlsClientCAFile   string
+	tlsCAFile         string
+	tlsClientCertFile string
+	tlsClientKeyFile  string
 )
 
 func init() {
 	flag.StringVar(&listenAddress, "listen-address", "localhost", "listen address")
 	flag.Int64Var(&listenPort, "listen-port", 8080, "listen port")
 	flag.BoolVar(&isDebug, "debug", false, "debug mode true/false")
+	flag.IntVar(&watchBufferSize, "watch-buffer-size", 100, "number of watch events buffered per watcher")
+	flag.IntVar(&watchHistorySize, "watch-history-size", 1000, "number of watch events kept for resuming")
+	flag.StringVar(&storeBackend, "store-backend", "leveldb", "store backend leveldb/replicated")
+	flag.StringVar(&storeLeader, "store-leader", "", "leader apiserver address (e.g. http://192.168.0.1:8080) for replicated store. empty means this apiserver is the leader")
+	flag.BoolVar(&migrateDryRun, "migrate-dry-run", false, "print schema migrations of stored data and exit without applying them")
+	flag.BoolVar(&enableAuth, "auth", false, "require bearer token for /api/v0. /api/v0/store and /api/v0/admin always require an admin token")
+	flag.StringVar(&adminPassword, "admin-password", "", "password of the admin user created when no users exist. empty means generate random password")
+	flag.DurationVar(&tokenTTL, "token-ttl", authv0.DefaultTokenTTL, "lifetime of tokens issued by login")
+	flag.StringVar(&corsAllowOrigins, "cors-allow-origins", "*", "comma separated origins allowed by CORS")
+	flag.StringVar(&storeLeaderToken, "store-leader-token", "", "serviceaccount token used to access the leader apiserver for replicated store")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "", "certificate file to serve https")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "key file to serve https")
+	flag.StringVar(&tlsClientCAFile, "tls-client-ca-file", "", "CA certificate file to verify client certificates. node and status updates require a client certificate signed by this CA")
+	flag.StringVar(&tlsCAFile, "tls-ca-file", "", "CA certificate file to verify the leader apiserver and agent download APIs")
+	flag.StringVar(&tlsClientCertFile, "tls-client-cert-file", "", "client certificate file to access the leader apiserver and agent download APIs")
+	flag.StringVar(&tlsClientKeyFile, "tls-client-key-file", "", "client key file to access the leader apiserver and agent download APIs")
 	flag.Parse()
 }
 
 func main() {
 	r := gin.Default()
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"*"}
+	corsConfig.AllowOrigins = strings.Split(corsAllowOrigins, ",")
+	corsConfig.AddAllowHeaders("Authorization")
 	r.Use(cors.New(corsConfig))
 
 	notifier := make(chan string, 100)
 	//s := store.NewMemoryStore()
-	s, err := store.NewLevelDBStore("./database", notifier, isDebug)
+	ls, err := store.NewLevelDBStore("./database", notifier, isDebug)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer s.Close()
+	defer ls.Close()
+
+	serverTLSConfig, clientTLSConfig, err := newTLSConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := newStore(ls, clientTLSConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := migrate(s); err != nil {
+		log.Fatal(err)
+	}
+	if migrateDryRun {
+		return
+	}
+
+	if err := bootstrapAdmin(s); err != nil {
+		log.Fatal(err)
+	}
 
 	// bloadcasting
-	notifiers := map[string](chan string){}
-	go func() {
-		for n := range notifier {
-			for _, nn := range notifiers {
-				nn <- n
-			}
-		}
-	}()
+	history := watchv0.NewEventHistory(watchHistorySize, s.Revision())
+	hub := watchv0.NewHub(history, watchBufferSize)
+	go hub.Run(notifier)
 
 	statikFS, err := fs.New()
 	if err != nil {
@@ -98,23 +174,59 @@ func main() {
 	imh := imv0.NewImageHandler(s)
 	ieh := iev0.NewImageEntityHandler(s)
 	nodeh := nodev0.NewNodeHandler(s)
-	watchh := watchv0.NewWatchHandler(notifiers)
+	sch := scv0.NewStorageClassHandler(s)
+	watchh := watchv0.NewWatchHandler(hub)
+	replicationh := replicationv0.NewReplicationHandler(s)
+	adminh := adminv0.NewAdminHandler(s)
+	userh := userv0.NewUserHandler(s)
+	sah := sav0.NewServiceAccountHandler(s)
+	rbh := rbv0.NewRoleBindingHandler(s)
+	rqh := rqv0.NewResourceQuotaHandler(s)
+	authh := authv0.NewAuthHandler(s)
+	authh.TokenTTL = tokenTTL
+	if clientTLSConfig != nil {
+		bsh.ProxyTransport = &http.Transport{TLSClientConfig: clientTLSConfig}
+		imh.ProxyTransport = &http.Transport{TLSClientConfig: clientTLSConfig}
+	}
 
 	v0 := r.Group("/api/v0")
+	authi := auth.NewAuthHandler(v0, authh)
+	authi.RegisterHandlers()
+
+	// --authが指定された場合はlogin以外の全てのAPIでトークンを検証し、RoleBindingで認可する
+	api := v0
+	if enableAuth {
+		api = v0.Group("", authh.Authenticate, authh.Authorize)
+	}
+	if tlsClientCAFile != "" {
+		api.Use(requireAgentCert)
+	}
+	// storeを直接読み書きするreplicationとadminのAPIは--authを指定しない場合もadminのトークンを要求する
+	storeAPI := api
+	if !enableAuth {
+		storeAPI = v0.Group("", authh.Authenticate, authh.Authorize)
+	}
 	{
-		gri := group.NewGroupHandler(v0, grh)
-		nsi := namespace.NewNamespaceHandler(v0, nsh)
-		nwi := network.NewNetworkHandler(v0, nwh)
-		nnwi := nodenetwork.NewNodeNetworkHandler(v0, nnwh)
-		bsi := blockstorage.NewBlockStorageHandler(v0, bsh)
-		vmi := virtualmachine.NewVirtualMachineHandler(v0, vmh)
-		vri := virtualrouter.NewVirtualRouterHandler(v0, vrh)
-		eippooli := externalippool.NewExternalIPPoolHandler(v0, eippoolh)
-		eipi := externalip.NewExternalIPHandler(v0, eiph)
-		imi := image.NewImageHandler(v0, imh)
-		iei := imageentity.NewImageEntityHandler(v0, ieh)
-		nodei := node.NewNodeHandler(v0, nodeh)
-		watchi := watch.NewWatchHandler(v0, watchh)
+		gri := group.NewGroupHandler(api, grh)
+		nsi := namespace.NewNamespaceHandler(api, nsh)
+		nwi := network.NewNetworkHandler(api, nwh)
+		nnwi := nodenetwork.NewNodeNetworkHandler(api, nnwh)
+		bsi := blockstorage.NewBlockStorageHandler(api, bsh)
+		vmi := virtualmachine.NewVirtualMachineHandler(api, vmh)
+		vri := virtualrouter.NewVirtualRouterHandler(api, vrh)
+		eippooli := externalippool.NewExternalIPPoolHandler(api, eippoolh)
+		eipi := externalip.NewExternalIPHandler(api, eiph)
+		imi := image.NewImageHandler(api, imh)
+		iei := imageentity.NewImageEntityHandler(api, ieh)
+		nodei := node.NewNodeHandler(api, nodeh)
+		sci := storageclass.NewStorageClassHandler(api, sch)
+		watchi := watch.NewWatchHandler(api, watchh)
+		replicationi := replication.NewReplicationHandler(storeAPI, replicationh)
+		admini := admin.NewAdminHandler(storeAPI, adminh)
+		useri := user.NewUserHandler(api, userh)
+		sai := serviceaccount.NewServiceAccountHandler(api, sah)
+		rbi := rolebinding.NewRoleBindingHandler(api, rbh)
+		rqi := resourcequota.NewResourceQuotaHandler(api, rqh)
 
 		gri.RegisterHandlers()
 		nsi.RegisterHandlers()
@@ -128,11 +240,130 @@ func main() {
 		imi.RegisterHandlers()
 		iei.RegisterHandlers()
 		nodei.RegisterHandlers()
+		sci.RegisterHandlers()
 		watchi.RegisterHandlers()
+		replicationi.RegisterHandlers()
+		admini.RegisterHandlers()
+		useri.RegisterHandlers()
+		sai.RegisterHandlers()
+		rbi.RegisterHandlers()
+		rqi.RegisterHandlers()
 	}
 
-	if err := r.Run(fmt.Sprintf("%s:%d", listenAddress, listenPort)); err != nil {
+	if err := tlsutil.ListenAndServe(fmt.Sprintf("%s:%d", listenAddress, listenPort), r, serverTLSConfig); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// apiServerStore はhandlerとreplication, adminのAPIから使うstore
+type apiServerStore interface {
+	storeif.Store
+	replicationv0.ReplicationStore
+	adminv0.AdminStore
+}
+
+// newStore は--store-backendで指定されたstoreを作成する
+// tlsConfigはfollowerがleaderにアクセスする場合に使う
+func newStore(ls *store.LevelDBStore, tlsConfig *tls.Config) (apiServerStore, error) {
+	switch storeBackend {
+	case "leveldb":
+		return ls, nil
+	case "replicated":
+		rs, err := replicated.NewReplicatedStore(ls, storeLeader)
+		if err != nil {
+			return nil, err
+		}
+		if !rs.IsLeader() {
+			rs.SetToken(storeLeaderToken)
+			if tlsConfig != nil {
+				rs.SetTLSConfig(tlsConfig)
+			}
+			go func() {
+				if err := rs.Run(context.Background()); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+		return rs, nil
+	}
+	return nil, fmt.Errorf("Error: unknown store backend `%s`.", storeBackend)
+}
+
+// migrate は保存されているデータを現在のスキーマに書き換える
+// followerはleaderで書き換えたデータを複製するので何もしない
+func migrate(s apiServerStore) error {
+	if rs, ok := s.(*replicated.ReplicatedStore); ok && !rs.IsLeader() {
+		return nil
+	}
+
+	results, err := storeif.DefaultMigrationRegistry.RunMigrations(s, migrateDryRun)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		log.Printf("migrate %s (%s) from schema version %d to %d: %s\n",
+			r.Key, r.APIType, r.FromVersion, r.ToVersion, strings.Join(r.Descriptions, ", "))
+	}
+	if migrateDryRun {
+		log.Printf("dry-run: %d keys will be migrated\n", len(results))
+	}
+	return nil
+}
+
+// bootstrapAdmin はユーザーが1人もいない場合に管理者ユーザーを作成する
+// followerはleaderで作成したユーザーを複製するので何もしない
+func bootstrapAdmin(s apiServerStore) error {
+	if rs, ok := s.(*replicated.ReplicatedStore); ok && !rs.IsLeader() {
+		return nil
+	}
 
+	password, err := authv0.BootstrapAdmin(s, adminPassword)
+	if err != nil {
+		return err
+	}
+	if password != "" && adminPassword == "" {
+		log.Printf("created user `%s` with password `%s`\n", authv0.BootstrapUserID, password)
+	} else if password != "" {
+		log.Printf("created user `%s`\n", authv0.BootstrapUserID)
+	}
+	return nil
+}
+
+// newTLSConfigs はapiserverを公開するためのTLSの設定と、
+// leaderのapiserverやagentのダウンロードAPIにアクセスするためのTLSの設定を作成する
+// 指定されていない場合はnilを返す
+func newTLSConfigs() (*tls.Config, *tls.Config, error) {
+	var serverTLSConfig, clientTLSConfig *tls.Config
+	var err error
+	if tlsCertFile != "" {
+		// humcliなどクライアント証明書を持たないクライアントも利用できるようにする
+		serverTLSConfig, err = tlsutil.NewServerTLSConfig(tlsCertFile, tlsKeyFile, tlsClientCAFile, tls.VerifyClientCertIfGiven)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if tlsClientCAFile != "" {
+		return nil, nil, fmt.Errorf("Error: --tls-client-ca-file requires --tls-cert-file and --tls-key-file.")
+	}
+
+	if tlsCAFile != "" || tlsClientCertFile != "" {
+		clientTLSConfig, err = tlsutil.NewClientTLSConfig(tlsCAFile, tlsClientCertFile, tlsClientKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return serverTLSConfig, clientTLSConfig, nil
+}
+
+// requireAgentCert はagentのみが行うNodeの登録・更新とstatusの更新に
+// --tls-client-ca-fileで検証されたクライアント証明書を要求するmiddleware
+func requireAgentCert(ctx *gin.Context) {
+	method := ctx.Request.Method
+	path := ctx.FullPath()
+	isAgentRequest := (method == http.MethodPost || method == http.MethodPut) &&
+		(strings.HasPrefix(path, "/api/v0/nodes") || strings.HasSuffix(path, "/status"))
+	if !isAgentRequest {
+		ctx.Next()
+		return
+	}
+	auth.RequireClientCert(ctx)
 }
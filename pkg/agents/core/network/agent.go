@@ -83,7 +83,7 @@ func (a *NetworkAgent) Run(pollingDuration time.Duration) {
 							continue
 						}
 
-						_, err = a.client.CoreV0().Network().Update(net)
+						_, err = a.client.CoreV0().Network().UpdateStatus(net)
 						if err != nil {
 							a.logger.Error(
 								"update network",
@@ -189,7 +189,11 @@ func getNodeNetworkID(networkID, nodeID string) string {
 }
 func setHash(network *core.Network) error {
 	network.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := network.ResourceVersion
+	network.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(network)
+	network.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
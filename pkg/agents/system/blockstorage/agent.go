@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -58,26 +59,34 @@ func (a *BlockStorageAgent) Run(pollingDuration time.Duration) {
 			zap.Time("time", time.Now()))
 	}
 	// init
-	grList, err := a.client.CoreV0().Group().List()
+	bsList, err := a.client.SystemV0().BlockStorage().ListInCluster("", "")
 	if err != nil {
 		a.logger.Error(
-			"get group list",
+			"get blockstorage list",
 			zap.String("msg", err.Error()),
 			zap.Time("time", time.Now()),
 		)
 	}
-	for _, group := range grList {
-		nsList, err := a.client.CoreV0().Namespace().List(group.ID)
-		if err != nil {
-			a.logger.Error(
-				"get namespace list",
-				zap.String("msg", err.Error()),
-				zap.Time("time", time.Now()),
-			)
-			continue
+	for _, bs := range bsList {
+		switch bs.Status.State {
+		case system.BlockStorageStateCopying, system.BlockStorageStateDownloading, system.BlockStorageStateDeleting, system.BlockStorageStateQueued:
+			bs.Status.State = ""
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
+				a.logger.Panic(
+					"init state Copying or Downloading or Deleting or Queued => ``",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()))
+
+			}
 		}
-		for _, ns := range nsList {
-			bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			// 全てのグループ・ネームスペースからこのノードのBlockStorageのみを取得する
+			bsList, err := a.client.SystemV0().BlockStorage().ListInCluster("",
+				fmt.Sprintf("metadata.annotations[%s]=%s", BlockStorageV0AnnotationNodeName, nodeName))
 			if err != nil {
 				a.logger.Error(
 					"get blockstorage list",
@@ -86,221 +95,232 @@ func (a *BlockStorageAgent) Run(pollingDuration time.Duration) {
 				)
 				continue
 			}
-			for _, bs := range bsList {
-				switch bs.Status.State {
-				case system.BlockStorageStateCopying, system.BlockStorageStateDownloading, system.BlockStorageStateDeleting, system.BlockStorageStateQueued:
-					bs.Status.State = ""
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
-						a.logger.Panic(
-							"init state Copying or Downloading or Deleting or Queued => ``",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()))
 
-					}
-				}
+			vmList, err := a.client.SystemV0().VirtualMachine().ListInCluster("", "")
+			if err != nil {
+				a.logger.Error(
+					"get virtualmahine list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
 			}
-		}
-	}
 
-	for {
-		select {
-		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			scList, err := a.client.SystemV0().StorageClass().List()
 			if err != nil {
 				a.logger.Error(
-					"get group list",
+					"get storageclass list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
 				continue
 			}
+			scMap := map[string]*system.StorageClass{}
+			for _, sc := range scList {
+				scMap[sc.ID] = sc
+			}
+			// グループ・ネームスペースごとに起動中のVMが使用しているBlockStorageをまとめる
+			usedBSIDsMap := map[string][]string{}
+			for _, vm := range vmList {
+				if vm.Status.State == system.VirtualMachineStateRunning {
+					nsKey := filepath.Join(vm.Group, vm.Namespace)
+					usedBSIDsMap[nsKey] = append(usedBSIDsMap[nsKey], vm.Spec.BlockStorageIDs...)
+				}
+			}
 
 			wg := sync.WaitGroup{}
-			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+			for _, bs := range bsList {
+				usedBSIDs := usedBSIDsMap[filepath.Join(bs.Group, bs.Namespace)]
+				if bs.DeleteState != meta.DeleteStateDelete && bs.Status.State == system.BlockStorageStateQueued {
+					continue
+				}
+
+				err := a.parallelSemaphore.Acquire(context.TODO(), 1)
 				if err != nil {
 					a.logger.Error(
-						"get namespace list",
+						"acqure semaphre",
 						zap.String("msg", err.Error()),
 						zap.Time("time", time.Now()),
 					)
 					continue
 				}
 
-				for _, ns := range nsList {
-					bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get blockstorage list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
+				//if bs.Status.State == "" {
+				//	bs.Status.State = system.BlockStorageStateQueued
+				//	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				//		a.logger.Error(
+				//			"update blockstorage state",
+				//			zap.String("msg", err.Error()),
+				//			zap.Time("time", time.Now()),
+				//		)
+				//		continue
+				//	}
+				//}
+				wg.Add(1)
+				copiedBS := *bs
+				go func(usedBSIDs []string, bs *system.BlockStorage) {
+					defer func() {
+						a.parallelSemaphore.Release(1)
+						wg.Done()
+					}()
+					if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
+						return
 					}
+					oldHash := bs.ResourceHash
 
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+					// state check
+					if bs.Status.State != system.BlockStorageStateDeleting &&
+						bs.Status.State != system.BlockStorageStatePending &&
+						bs.Status.State != system.BlockStorageStateQueued {
+
+						isUsed := false
+						for i, usedID := range usedBSIDs {
+							if bs.ID == usedID {
+								isUsed = true
+								usedBSIDs = append(usedBSIDs[:i], usedBSIDs[i+1:]...)
+								break
+							}
+						}
+
+						if bs.Status.State != system.BlockStorageStateUsed && isUsed {
+							bs.Status.State = system.BlockStorageStateUsed
+							_, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs)
+							if err != nil {
+								a.logger.Error(
+									"update blockstorage",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+								return
+							}
+						} else if bs.Status.State == system.BlockStorageStateUsed && !isUsed {
+							bs.Status.State = system.BlockStorageStateActive
+							bs, err = a.client.SystemV0().BlockStorage().UpdateStatus(bs)
+							if err != nil {
+								a.logger.Error(
+									"update blockstorage",
+									zap.String("msg", err.Error()),
+									zap.Time("time", time.Now()),
+								)
+								return
+							}
+						}
+
+						if isUsed {
+							a.logger.Info(
+								"skip bs is used",
+								zap.String("bs", bs.Namespace+"/"+bs.ID),
+								zap.Time("time", time.Now()),
+							)
+							return
+						}
+					}
+
+					sc, err := a.getStorageClass(bs, scMap)
 					if err != nil {
 						a.logger.Error(
-							"get virtualmahine list",
+							"get storageclass",
+							zap.String("bs", bs.Namespace+"/"+bs.ID),
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
-						continue
-					}
-					usedBSIDs := []string{}
-					for _, vm := range vmList {
-						if vm.Status.State == system.VirtualMachineStateRunning {
-							usedBSIDs = append(usedBSIDs, vm.Spec.BlockStorageIDs...)
-						}
+						return
 					}
 
-					for _, bs := range bsList {
-						if bs.DeleteState != meta.DeleteStateDelete && bs.Status.State == system.BlockStorageStateQueued {
-							continue
+					switch sc.Spec.Driver {
+					case system.StorageClassDriverLocal:
+						if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
+							return
 						}
 
-						err := a.parallelSemaphore.Acquire(context.TODO(), 1)
+						err = a.syncLocalBlockStorage(bs, sc)
 						if err != nil {
 							a.logger.Error(
-								"acqure semaphre",
+								"sync local blockstorage",
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
-							continue
+							return
 						}
 
-						//if bs.Status.State == "" {
-						//	bs.Status.State = system.BlockStorageStateQueued
-						//	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
-						//		a.logger.Error(
-						//			"update blockstorage state",
-						//			zap.String("msg", err.Error()),
-						//			zap.Time("time", time.Now()),
-						//		)
-						//		continue
-						//	}
-						//}
-						wg.Add(1)
-						copiedBS := *bs
-						go func(usedBSIDs []string, bs *system.BlockStorage) {
-							defer func() {
-								a.parallelSemaphore.Release(1)
-								wg.Done()
-							}()
-							if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
-								return
-							}
-							oldHash := bs.ResourceHash
-
-							// state check
-							if bs.Status.State != system.BlockStorageStateDeleting &&
-								bs.Status.State != system.BlockStorageStatePending &&
-								bs.Status.State != system.BlockStorageStateQueued {
-
-								isUsed := false
-								for i, usedID := range usedBSIDs {
-									if bs.ID == usedID {
-										isUsed = true
-										usedBSIDs = append(usedBSIDs[:i], usedBSIDs[i+1:]...)
-										break
-									}
-								}
-
-								if bs.Status.State != system.BlockStorageStateUsed && isUsed {
-									bs.Status.State = system.BlockStorageStateUsed
-									_, err := a.client.SystemV0().BlockStorage().Update(bs)
-									if err != nil {
-										a.logger.Error(
-											"update blockstorage",
-											zap.String("msg", err.Error()),
-											zap.Time("time", time.Now()),
-										)
-										return
-									}
-								} else if bs.Status.State == system.BlockStorageStateUsed && !isUsed {
-									bs.Status.State = system.BlockStorageStateActive
-									bs, err = a.client.SystemV0().BlockStorage().Update(bs)
-									if err != nil {
-										a.logger.Error(
-											"update blockstorage",
-											zap.String("msg", err.Error()),
-											zap.Time("time", time.Now()),
-										)
-										return
-									}
-								}
-
-								if isUsed {
-									a.logger.Info(
-										"skip bs is used",
-										zap.String("bs", bs.Namespace+"/"+bs.ID),
-										zap.Time("time", time.Now()),
-									)
-									return
-								}
-							}
-
-							switch bs.Annotations[BlockStorageV0AnnotationType] {
-							case BlockStorageV0BlockStorageTypeLocal:
-								if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
-									return
-								}
-
-								err = a.syncLocalBlockStorage(bs)
-								if err != nil {
-									a.logger.Error(
-										"sync local blockstorage",
-										zap.String("msg", err.Error()),
-										zap.Time("time", time.Now()),
-									)
-									return
-								}
-
-							case BlockStorageV0BlockStorageTypeCeph:
-								if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
-									return
-								}
-
-								err = a.syncCephBlockStorage(bs)
-								if err != nil {
-									a.logger.Error(
-										"sync ceph blockstorage",
-										zap.String("msg", err.Error()),
-										zap.Time("time", time.Now()),
-									)
-									return
-								}
-							}
-
-							if bs.ResourceHash == oldHash {
-								return
-							}
+					case system.StorageClassDriverCeph:
+						if bs.Annotations[BlockStorageV0AnnotationNodeName] != nodeName {
+							return
+						}
 
-							_, err := a.client.SystemV0().BlockStorage().Update(bs)
-							if err != nil {
-								a.logger.Error(
-									"update blockstorage",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								return
-							}
-							a.logger.Info("sync because different hash",
-								zap.String("bs", bs.Namespace+"/"+bs.ID),
+						err = a.syncCephBlockStorage(bs, sc)
+						if err != nil {
+							a.logger.Error(
+								"sync ceph blockstorage",
+								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
-						}(usedBSIDs, &copiedBS)
+							return
+						}
 					}
-				}
+
+					if bs.ResourceHash == oldHash {
+						return
+					}
+
+					_, err = a.client.SystemV0().BlockStorage().UpdateStatus(bs)
+					if err != nil {
+						a.logger.Error(
+							"update blockstorage",
+							zap.String("msg", err.Error()),
+							zap.Time("time", time.Now()),
+						)
+						return
+					}
+					a.logger.Info("sync because different hash",
+						zap.String("bs", bs.Namespace+"/"+bs.ID),
+						zap.Time("time", time.Now()),
+					)
+				}(usedBSIDs, &copiedBS)
 			}
 			wg.Wait()
 		}
 	}
 }
 
+// getStorageClass はbsを保存するStorageClassを返す
+// StorageClassが指定されていない場合はblockstoragev0/typeとagentの設定から作る
+// 省略されたパラメータにはagentの設定を使う
+func (a *BlockStorageAgent) getStorageClass(bs *system.BlockStorage, scMap map[string]*system.StorageClass) (*system.StorageClass, error) {
+	sc := &system.StorageClass{}
+	if bs.Spec.StorageClassName != "" {
+		found, ok := scMap[bs.Spec.StorageClassName]
+		if !ok {
+			return nil, fmt.Errorf("Error: storageclass `%s` is not found.", bs.Spec.StorageClassName)
+		}
+		*sc = *found
+	} else {
+		sc.Spec.Driver = system.StorageClassDriver(bs.Annotations[BlockStorageV0AnnotationType])
+	}
+
+	switch sc.Spec.Driver {
+	case system.StorageClassDriverLocal:
+		if sc.Spec.Local.DirPath == "" {
+			sc.Spec.Local.DirPath = a.localBlockStorageDirectory
+		}
+	case system.StorageClassDriverCeph:
+		if a.config.CephBackend == nil {
+			return nil, fmt.Errorf("Error: cephBackend is not configured.")
+		}
+		if sc.Spec.Ceph.PoolName == "" {
+			sc.Spec.Ceph.PoolName = a.config.CephBackend.PoolName
+		}
+	}
+	return sc, nil
+}
+
 func setHash(bs *system.BlockStorage) error {
 	bs.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := bs.ResourceVersion
+	bs.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(bs)
+	bs.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
@@ -22,9 +22,10 @@ func getImageNameWithGroupAndNS(bs *system.BlockStorage) string {
 	return filepath.Join(bs.Group, bs.Namespace, bs.ID)
 }
 
-func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error {
+func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage, sc *system.StorageClass) error {
+	poolName := sc.Spec.Ceph.PoolName
 	// ex. rbd:pool-name/image-name
-	path := filepath.Join(fmt.Sprintf("rbd:%s", a.config.CephBackend.PoolName), bs.ID)
+	path := filepath.Join(fmt.Sprintf("rbd:%s", poolName), bs.ID)
 	imageNameWithGroupAndNS := getImageNameWithGroupAndNS(bs)
 
 	// コピー中・ダウンロード中の場合はskip
@@ -35,11 +36,11 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 
 	// 削除処理
 	if bs.DeleteState == meta.DeleteStateDelete {
-		return a.deleteCephBlockStorage(bs)
+		return a.deleteCephBlockStorage(bs, poolName)
 	}
 
 	// イメージが存在するならsukip
-	if a.cephImageIsExists(bs) {
+	if a.cephImageIsExists(bs, poolName) {
 		switch bs.Status.State {
 		case system.BlockStorageStateError:
 			// Stateがエラーなら存在するイメージは消す
@@ -51,7 +52,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 				}
 				defer conn.Shutdown()
 
-				ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+				ioctx, err := conn.OpenIOContext(poolName)
 				if err != nil {
 					return err
 				}
@@ -68,7 +69,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		case "", system.BlockStorageStatePending:
 			// 良くなさそう
 			bs.Status.State = system.BlockStorageStateActive
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return setHash(bs)
@@ -82,9 +83,9 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 			_, imageOk := bs.Annotations["ceph-image-name"]
 
 			if !poolOk || !imageOk {
-				bs.Annotations["ceph-pool-name"] = a.config.CephBackend.PoolName
+				bs.Annotations["ceph-pool-name"] = poolName
 				bs.Annotations["ceph-image-name"] = imageNameWithGroupAndNS
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 					return err
 				}
 			}
@@ -96,9 +97,10 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		bs.Annotations = map[string]string{}
 	}
 
-	bs.Annotations["ceph-pool-name"] = a.config.CephBackend.PoolName
+	bs.Annotations[BlockStorageV0AnnotationType] = BlockStorageV0BlockStorageTypeCeph
+	bs.Annotations["ceph-pool-name"] = poolName
 	bs.Annotations["ceph-image-name"] = imageNameWithGroupAndNS
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 
@@ -116,7 +118,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		cmd := exec.Command(command, args...)
 		if _, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -146,7 +148,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		defer conn.Shutdown()
 
 		// cephのpoolにイメージを作る
-		ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+		ioctx, err := conn.OpenIOContext(poolName)
 		if err != nil {
 			if err := a.setStateError(bs); err != nil {
 				return err
@@ -192,7 +194,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		}
 
 		// リサイズ
-		imageNameFull := filepath.Join(a.config.CephBackend.PoolName, imageNameWithGroupAndNS)
+		imageNameFull := filepath.Join(poolName, imageNameWithGroupAndNS)
 		command := "qemu-img"
 		args := []string{
 			"resize",
@@ -217,7 +219,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		image, err := a.client.SystemV0().Image().Get(bs.Group, bs.Spec.From.BaseImage.ImageName)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -226,7 +228,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		imageEntityID, ok := image.Spec.EntityMap[bs.Spec.From.BaseImage.Tag]
 		if !ok {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return fmt.Errorf("Image Entity not found")
@@ -243,7 +245,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		}
 		defer conn.Shutdown()
 
-		ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+		ioctx, err := conn.OpenIOContext(poolName)
 		if err != nil {
 			if err := a.setStateError(bs); err != nil {
 				return err
@@ -258,7 +260,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 			if !fileIsExists(srcDirPath) {
 				if err := os.MkdirAll(srcDirPath, 0755); err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 						return err
 					}
 					return err
@@ -342,7 +344,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 			}
 
 			// リサイズ
-			imageNameFull := filepath.Join(a.config.CephBackend.PoolName, imageNameWithGroupAndNS)
+			imageNameFull := filepath.Join(poolName, imageNameWithGroupAndNS)
 			command := "qemu-img"
 			args := []string{
 				"resize",
@@ -359,7 +361,20 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		} else if imageEntity.Spec.Type == "Ceph" {
 			snapName := imageEntity.Annotations["imageentityv0/ceph-snapname"]
 			imageName := imageEntity.Annotations["imageentityv0/ceph-imagename"]
-			cephImage, err := rbd.OpenImageReadOnly(ioctx, imageName, rbd.NoSnapshot)
+
+			// imageはagentに設定されたpoolにあるので、StorageClassのpoolが違う場合はpoolをまたいでcloneする
+			parentIoctx := ioctx
+			if poolName != a.config.CephBackend.PoolName {
+				parentIoctx, err = conn.OpenIOContext(a.config.CephBackend.PoolName)
+				if err != nil {
+					if err := a.setStateError(bs); err != nil {
+						return err
+					}
+					return err
+				}
+				defer parentIoctx.Destroy()
+			}
+			cephImage, err := rbd.OpenImageReadOnly(parentIoctx, imageName, rbd.NoSnapshot)
 			if err != nil {
 				return errors.Wrapf(err, "Failed to open image read only `%s`", imageName)
 			}
@@ -384,7 +399,7 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 				}
 			}
 			// ceph image内のqcow2リサイズ
-			imageNameFull := filepath.Join(a.config.CephBackend.PoolName, imageNameWithGroupAndNS)
+			imageNameFull := filepath.Join(poolName, imageNameWithGroupAndNS)
 			command := "qemu-img"
 			args := []string{
 				"resize",
@@ -408,14 +423,14 @@ func (a *BlockStorageAgent) syncCephBlockStorage(bs *system.BlockStorage) error
 		bs.Status.State == system.BlockStorageStateDownloading {
 		bs.Status.State = system.BlockStorageStateActive
 
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 			return err
 		}
 	}
 	return setHash(bs)
 }
 
-func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) error {
+func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage, poolName string) error {
 	imageNameWithGroupAndNS := filepath.Join(bs.Group, bs.Namespace, bs.ID)
 	if bs.Status.State != "" &&
 		bs.Status.State != system.BlockStorageStateError &&
@@ -425,7 +440,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 	}
 
 	bs.Status.State = system.BlockStorageStateDeleting
-	_, err := a.client.SystemV0().BlockStorage().Update(bs)
+	_, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs)
 	if err != nil {
 		return err
 	}
@@ -440,7 +455,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 	}
 	defer conn.Shutdown()
 
-	ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+	ioctx, err := conn.OpenIOContext(poolName)
 	if err != nil {
 		if err := a.setStateError(bs); err != nil {
 			return err
@@ -449,7 +464,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 	}
 	defer ioctx.Destroy()
 
-	if a.cephImageIsExists(bs) {
+	if a.cephImageIsExists(bs, poolName) {
 		if err := rbd.RemoveImage(ioctx, imageNameWithGroupAndNS); err != nil {
 			if err := a.setStateError(bs); err != nil {
 				return err
@@ -465,7 +480,7 @@ func (a *BlockStorageAgent) deleteCephBlockStorage(bs *system.BlockStorage) erro
 	return nil
 }
 
-func (a BlockStorageAgent) cephImageIsExists(bs *system.BlockStorage) bool {
+func (a BlockStorageAgent) cephImageIsExists(bs *system.BlockStorage, poolName string) bool {
 	// typeがCephでない
 	if t, ok := bs.Annotations[BlockStorageV0AnnotationType]; ok && t != BlockStorageV0BlockStorageTypeCeph {
 		return false
@@ -479,7 +494,7 @@ func (a BlockStorageAgent) cephImageIsExists(bs *system.BlockStorage) bool {
 	}
 	defer conn.Shutdown()
 
-	ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+	ioctx, err := conn.OpenIOContext(poolName)
 	if err != nil {
 		return false
 	}
@@ -512,7 +527,7 @@ func (a BlockStorageAgent) newCephConn() (*rados.Conn, error) {
 
 func (a BlockStorageAgent) setStateError(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateError
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 	return nil
@@ -520,7 +535,7 @@ func (a BlockStorageAgent) setStateError(bs *system.BlockStorage) error {
 
 func (a BlockStorageAgent) setStateCopying(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateCopying
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 	return nil
@@ -528,7 +543,7 @@ func (a BlockStorageAgent) setStateCopying(bs *system.BlockStorage) error {
 
 func (a BlockStorageAgent) setStateDownloading(bs *system.BlockStorage) error {
 	bs.Status.State = system.BlockStorageStateDownloading
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 	return nil
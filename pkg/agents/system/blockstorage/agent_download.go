@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 func (a *BlockStorageAgent) DownloadAPI(config *BlockStorageAgentDownloadAPIConfig) error {
@@ -27,7 +28,15 @@ func (a *BlockStorageAgent) DownloadAPI(config *BlockStorageAgentDownloadAPIConf
 		ctx.Header("Content-Type", "application/octet-stream")
 		ctx.Header("Content-Disposition", "attachment; filename= "+bsID)
 
-		file, err := os.Open(filepath.Join(a.localBlockStorageDirectory, groupID, namespaceID, bsID))
+		// StorageClassで保存先が指定されている場合はそのディレクトリから読む
+		path := filepath.Join(a.localBlockStorageDirectory, groupID, namespaceID, bsID)
+		if bs, err := a.client.SystemV0().BlockStorage().Get(groupID, namespaceID, bsID); err == nil && bs.Spec.StorageClassName != "" {
+			if sc, err := a.client.SystemV0().StorageClass().Get(bs.Spec.StorageClassName); err == nil && sc.Spec.Local.DirPath != "" {
+				path = filepath.Join(sc.Spec.Local.DirPath, groupID, namespaceID, bsID)
+			}
+		}
+
+		file, err := os.Open(path)
 		if err != nil {
 			ctx.String(http.StatusInternalServerError, "%v", err)
 			return
@@ -47,7 +56,12 @@ func (a *BlockStorageAgent) DownloadAPI(config *BlockStorageAgentDownloadAPIConf
 		}
 	})
 
-	if err := r.Run(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort)); err != nil {
+	tlsConfig, err := config.TLS.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := tlsutil.ListenAndServe(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort), r, tlsConfig); err != nil {
 		return err
 	}
 
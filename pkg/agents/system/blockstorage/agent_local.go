@@ -9,14 +9,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/utils/quantity"
 	"github.com/pkg/errors"
 )
 
-func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error {
-	dirPath := filepath.Join(a.localBlockStorageDirectory, bs.Group, bs.Namespace)
+func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage, sc *system.StorageClass) error {
+	dirPath := filepath.Join(sc.Spec.Local.DirPath, bs.Group, bs.Namespace)
 	path := filepath.Join(dirPath, bs.ID)
 
 	// コピー中・ダウンロード中の場合はskip
@@ -35,7 +37,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			return nil
 		}
 		bs.Status.State = system.BlockStorageStateDeleting
-		_, err := a.client.SystemV0().BlockStorage().Update(bs)
+		_, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs)
 		if err != nil {
 			return err
 		}
@@ -80,31 +82,25 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 	switch bs.Spec.From.Type {
 	case system.BlockStorageFromTypeEmpty:
 		command := "qemu-img"
-		args := []string{
-			"create",
-			"-f",
-			"qcow2",
-			path,
-			withUnitToWithoutUnit(bs.Spec.LimitSize),
-		}
+		args := qemuImgCreateArgs(sc, path, withUnitToWithoutUnit(bs.Spec.LimitSize))
 		cmd := exec.Command(command, args...)
 		if _, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
 		}
 	case system.BlockStorageFromTypeHTTP:
 		bs.Status.State = system.BlockStorageStateDownloading
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 			return err
 		}
 
 		res, err := http.Get(bs.Spec.From.HTTP.URL)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -114,7 +110,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		file, err := os.Create(path)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -129,7 +125,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		}
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -138,22 +134,18 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		err = file.Close()
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
 		}
 
 		command := "qemu-img"
-		args := []string{
-			"resize",
-			path,
-			withUnitToWithoutUnit(bs.Spec.LimitSize),
-		}
+		args := qemuImgResizeArgs(sc, path, withUnitToWithoutUnit(bs.Spec.LimitSize))
 		cmd := exec.Command(command, args...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return errors.Wrap(err, string(out))
@@ -161,14 +153,14 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 	case system.BlockStorageFromTypeBaseImage:
 
 		bs.Status.State = system.BlockStorageStateCopying
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 			return err
 		}
 
 		image, err := a.client.SystemV0().Image().Get(bs.Group, bs.Spec.From.BaseImage.ImageName)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -177,7 +169,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		imageEntity, ok := image.Spec.EntityMap[bs.Spec.From.BaseImage.Tag]
 		if !ok {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return fmt.Errorf("Image Entity not found")
@@ -188,7 +180,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			err := os.MkdirAll(srcDirPath, 0755)
 			if err != nil {
 				bs.Status.State = system.BlockStorageStateError
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 					return err
 				}
 				return err
@@ -203,7 +195,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 				src, err := os.Create(srcPath)
 				if err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 						return err
 					}
 					return err
@@ -213,7 +205,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 				stream, _, err := a.client.SystemV0().Image().Download(bs.Group, bs.Spec.From.BaseImage.ImageName, bs.Spec.From.BaseImage.Tag)
 				if err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 						return err
 					}
 					return err
@@ -222,7 +214,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 
 				if _, err := io.Copy(src, stream); err != nil {
 					bs.Status.State = system.BlockStorageStateError
-					if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+					if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 						return err
 					}
 					return err
@@ -231,7 +223,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 			}()
 			if err != nil {
 				bs.Status.State = system.BlockStorageStateError
-				if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+				if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 					return err
 				}
 				return err
@@ -240,7 +232,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		src, err := os.Open(srcPath)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -250,7 +242,7 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		dest, err := os.Create(path)
 		if err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -259,23 +251,19 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 
 		if _, err := io.Copy(dest, src); err != nil {
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
 		}
 
 		command := "qemu-img"
-		args := []string{
-			"resize",
-			path,
-			withUnitToWithoutUnit(bs.Spec.LimitSize),
-		}
+		args := qemuImgResizeArgs(sc, path, withUnitToWithoutUnit(bs.Spec.LimitSize))
 		cmd := exec.Command(command, args...)
 		if _, err := cmd.CombinedOutput(); err != nil {
 			log.Println(err.Error())
 			bs.Status.State = system.BlockStorageStateError
-			if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+			if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 				return err
 			}
 			return err
@@ -287,6 +275,8 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 	}
 
 	bs.Annotations["bs-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
+	bs.Annotations["bs-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
+	bs.Annotations[BlockStorageV0AnnotationType] = BlockStorageV0BlockStorageTypeLocal
 	// ここに来た時点で処理は終わっているのでActiveにする
 	if bs.Status.State == "" ||
 		bs.Status.State == system.BlockStorageStatePending ||
@@ -294,41 +284,57 @@ func (a *BlockStorageAgent) syncLocalBlockStorage(bs *system.BlockStorage) error
 		bs.Status.State == system.BlockStorageStateDownloading {
 		bs.Status.State = system.BlockStorageStateActive
 
-		if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+		if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 			return err
 		}
 	}
 	return setHash(bs)
 }
 
+// qemuImgCreateArgs はStorageClassのパラメータでqcow2を作成するqemu-imgの引数を返す
+func qemuImgCreateArgs(sc *system.StorageClass, path, size string) []string {
+	args := []string{
+		"create",
+		"-f",
+		"qcow2",
+	}
+
+	options := []string{}
+	if sc.Spec.Local.ClusterSize != "" {
+		options = append(options, "cluster_size="+withUnitToWithoutUnit(sc.Spec.Local.ClusterSize))
+	}
+	if sc.Spec.Provisioning == system.StorageClassProvisioningThick {
+		options = append(options, "preallocation=falloc")
+	}
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	return append(args, path, size)
+}
+
+// qemuImgResizeArgs はリサイズするqemu-imgの引数を返す
+// Thickの場合は増えた領域も確保する
+func qemuImgResizeArgs(sc *system.StorageClass, path, size string) []string {
+	args := []string{
+		"resize",
+	}
+	if sc.Spec.Provisioning == system.StorageClassProvisioningThick {
+		args = append(args, "--preallocation=falloc")
+	}
+	return append(args, path, size)
+}
+
 func fileIsExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
 
-const (
-	UnitGigabyte = 'G'
-	UnitMegabyte = 'M'
-	UnitKilobyte = 'K'
-)
-
-func withUnitToWithoutUnit(numberWithUnit string) string {
-	length := len(numberWithUnit)
-	if numberWithUnit[length-1] >= '0' && numberWithUnit[length-1] <= '9' {
-		return numberWithUnit
-	}
-
-	number, err := strconv.ParseInt(numberWithUnit[:length-1], 10, 64)
+// withUnitToWithoutUnit は8Gのようなサイズをqemu-imgやcephに渡すバイト単位の文字列にする
+// 不正なサイズはadmissionでエラーになるので0にする
+func withUnitToWithoutUnit(size string) string {
+	n, err := quantity.ParseBytes(size)
 	if err != nil {
 		return "0"
 	}
-	switch numberWithUnit[length-1] {
-	case UnitGigabyte:
-		return fmt.Sprintf("%d", number*1024*1024*1024)
-	case UnitMegabyte:
-		return fmt.Sprintf("%d", number*1024*1024)
-	case UnitKilobyte:
-		return fmt.Sprintf("%d", number*1024)
-	}
-	return "0"
+	return strconv.FormatInt(n, 10)
 }
@@ -1,9 +1,15 @@
 package blockstorage
 
+import "github.com/ophum/humstack/pkg/utils/tlsutil"
+
 type BlockStorageAgentDownloadAPIConfig struct {
 	AdvertiseAddress string `yaml:"advertiseAddress"`
 	ListenAddress    string `yaml:"listenAddress"`
 	ListenPort       int32  `yaml:"listenPort"`
+
+	// TLS を指定した場合はHTTPSで公開する
+	// clientCAFileを指定した場合はクライアント証明書を要求する
+	TLS *tlsutil.ServerConfig `yaml:"tls"`
 }
 
 type BlockStorageAgentCephBackendConfig struct {
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"github.com/ceph/go-ceph/rados"
@@ -64,172 +63,185 @@ func (a *ImageAgent) Run(pollingDuration time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			imageEntityList, err := a.client.SystemV0().ImageEntity().ListInCluster("", "")
 			if err != nil {
 				a.logger.Error(
-					"get group list",
+					"get imageentity list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
 				continue
 			}
 
-			for _, group := range grList {
-				imageEntityList, err := a.client.SystemV0().ImageEntity().List(group.ID)
-				if err != nil {
-					a.logger.Error(
-						"get imageentity list",
-						zap.String("msg", err.Error()),
-						zap.Time("time", time.Now()),
-					)
+			for _, imageEntity := range imageEntityList {
+				oldHash := imageEntity.ResourceHash
+
+				if imageEntity.DeleteState != meta.DeleteStateDelete && imageEntity.Status.State == system.ImageEntityStateAvailable {
 					continue
 				}
 
-				for _, imageEntity := range imageEntityList {
-					oldHash := imageEntity.ResourceHash
+				if imageEntity.Annotations == nil {
+					imageEntity.Annotations = map[string]string{}
+				}
 
-					if imageEntity.DeleteState != meta.DeleteStateDelete && imageEntity.Status.State == system.ImageEntityStateAvailable {
+				sourceType := imageEntity.Spec.Source.Type
+				if sourceType == "" {
+					sourceType = system.ImageEntitySourceTypeBlockStorage
+				}
+
+				entityType, ok := imageEntity.Annotations[ImageEntityV0AnnotationType]
+				if !ok {
+					// save local as default place
+					entityType = ImageEntityV0ImageEntityTypeLocal
+					if imageEntity.Spec.Type == "Ceph" {
+						entityType = ImageEntityV0ImageEntityTypeCeph
+					} else if imageEntity.Spec.Type == "Local" {
+						entityType = ImageEntityV0ImageEntityTypeLocal
+					} else {
+						errors.Errorf("Image type value is invalid: ", imageEntity.Spec.Type)
+					}
+				}
+				switch sourceType {
+				case system.ImageEntitySourceTypeBlockStorage:
+					bs, err := a.client.SystemV0().BlockStorage().Get(
+						imageEntity.Group,
+						imageEntity.Spec.Source.Namespace,
+						imageEntity.Spec.Source.BlockStorageID)
+
+					if err != nil {
+						a.logger.Error(
+							"get blockstorage list",
+							zap.String("msg", err.Error()),
+							zap.Time("time", time.Now()),
+						)
 						continue
 					}
 
-					if imageEntity.Annotations == nil {
-						imageEntity.Annotations = map[string]string{}
+					nodeName := bs.Annotations[blockstorage.BlockStorageV0AnnotationNodeName]
+					if imageEntity.DeleteState == meta.DeleteStateDelete {
+						nodeName = imageEntity.Annotations["imageentityv0/node_name"]
 					}
 
-					sourceType := imageEntity.Spec.Source.Type
-					if sourceType == "" {
-						sourceType = system.ImageEntitySourceTypeBlockStorage
+					// 別のノードのBSの場合は何もしない
+					if nodeName != a.nodeName {
+						continue
 					}
 
-					entityType, ok := imageEntity.Annotations[ImageEntityV0AnnotationType]
-					if !ok {
-						// save local as default place
-						entityType = ImageEntityV0ImageEntityTypeLocal
-						if imageEntity.Spec.Type == "Ceph" {
-							entityType = ImageEntityV0ImageEntityTypeCeph
-						} else if imageEntity.Spec.Type == "Local" {
-							entityType = ImageEntityV0ImageEntityTypeLocal
-						} else {
-							errors.Errorf("Image type value is invalid: ", imageEntity.Spec.Type)
+					imageEntity.Annotations["imageentityv0/node_name"] = nodeName
+					if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
+						continue
+					}
+
+					// ファイルがなければPENDINGとして扱う
+					if imageEntity.Spec.Type == "" || imageEntity.Spec.Type == ImageEntityV0ImageEntityTypeLocal {
+						if _, err := os.Stat(filepath.Join(a.localImageDirectory, imageEntity.Group, imageEntity.ID)); err != nil {
+							imageEntity.Status.State = system.ImageEntityStatePending
 						}
 					}
-					switch sourceType {
-					case system.ImageEntitySourceTypeBlockStorage:
-						bs, err := a.client.SystemV0().BlockStorage().Get(
-							imageEntity.Group,
-							imageEntity.Spec.Source.Namespace,
-							imageEntity.Spec.Source.BlockStorageID)
 
-						if err != nil {
+					// とりあえずPending以外になってたら何もしない
+					if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending && imageEntity.DeleteState != meta.DeleteStateDelete {
+						continue
+					}
+					switch entityType {
+					case ImageEntityV0ImageEntityTypeLocal:
+						if err := a.syncLocalImageEntityFromBlockStorage(imageEntity, bs); err != nil {
 							a.logger.Error(
-								"get blockstorage list",
+								"sync local imageentity",
 								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
 							continue
 						}
-
-						nodeName := bs.Annotations[blockstorage.BlockStorageV0AnnotationNodeName]
-						if imageEntity.DeleteState == meta.DeleteStateDelete {
-							nodeName = imageEntity.Annotations["imageentityv0/node_name"]
-						}
-
-						// 別のノードのBSの場合は何もしない
-						if nodeName != a.nodeName {
-							continue
-						}
-
-						imageEntity.Annotations["imageentityv0/node_name"] = nodeName
-						if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
-							continue
-						}
-
-						// ファイルがなければPENDINGとして扱う
-						if imageEntity.Spec.Type == "" || imageEntity.Spec.Type == ImageEntityV0ImageEntityTypeLocal {
-							if _, err := os.Stat(filepath.Join(a.localImageDirectory, imageEntity.Group, imageEntity.ID)); err != nil {
-								imageEntity.Status.State = system.ImageEntityStatePending
-							}
-						}
-
-						// とりあえずPending以外になってたら何もしない
-						if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending && imageEntity.DeleteState != meta.DeleteStateDelete {
-							continue
-						}
-						switch entityType {
-						case ImageEntityV0ImageEntityTypeLocal:
-							if err := a.syncLocalImageEntityFromBlockStorage(imageEntity, bs); err != nil {
-								a.logger.Error(
-									"sync local imageentity",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
-						case ImageEntityV0ImageEntityTypeCeph:
-							if err := a.syncCephImageEntityFromBlockStorage(imageEntity, bs); err != nil {
-								a.logger.Error(
-									"sync local imageentity",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
-						}
-					case system.ImageEntitySourceTypeImage:
-						nodeName, ok := imageEntity.Annotations["imageentityv0/node_name"]
-						if !ok {
-							continue
-						}
-
-						// 別のノードのBSの場合は何もしない
-						if nodeName != a.nodeName {
-							continue
-						}
-
-						// とりあえずPending以外になってたら何もしない
-						if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending && imageEntity.DeleteState != meta.DeleteStateDelete {
-							continue
-						}
-						switch entityType {
-						case ImageEntityV0ImageEntityTypeLocal:
-							a.logger.Warn(
-								"sync local imageentity from imageEntity not implements",
+					case ImageEntityV0ImageEntityTypeCeph:
+						if err := a.syncCephImageEntityFromBlockStorage(imageEntity, bs); err != nil {
+							a.logger.Error(
+								"sync local imageentity",
+								zap.String("msg", err.Error()),
 								zap.Time("time", time.Now()),
 							)
 							continue
-						case ImageEntityV0ImageEntityTypeCeph:
-							if err := a.syncCephImageEntityFromImage(imageEntity); err != nil {
-								a.logger.Error(
-									"sync ceph imageentity from imageEntity",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
 						}
 					}
+				case system.ImageEntitySourceTypeImage:
+					nodeName, ok := imageEntity.Annotations["imageentityv0/node_name"]
+					if !ok {
+						continue
+					}
 
-					if imageEntity.ResourceHash == oldHash {
+					// 別のノードのBSの場合は何もしない
+					if nodeName != a.nodeName {
 						continue
 					}
 
-					if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
-						a.logger.Error(
-							"update imageentity",
-							zap.String("msg", err.Error()),
+					// とりあえずPending以外になってたら何もしない
+					if imageEntity.Status.State != "" && imageEntity.Status.State != system.ImageEntityStatePending && imageEntity.DeleteState != meta.DeleteStateDelete {
+						continue
+					}
+					switch entityType {
+					case ImageEntityV0ImageEntityTypeLocal:
+						a.logger.Warn(
+							"sync local imageentity from imageEntity not implements",
 							zap.Time("time", time.Now()),
 						)
 						continue
+					case ImageEntityV0ImageEntityTypeCeph:
+						if err := a.syncCephImageEntityFromImage(imageEntity); err != nil {
+							a.logger.Error(
+								"sync ceph imageentity from imageEntity",
+								zap.String("msg", err.Error()),
+								zap.Time("time", time.Now()),
+							)
+							continue
+						}
 					}
 				}
+
+				if imageEntity.ResourceHash == oldHash {
+					continue
+				}
+
+				if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
+					a.logger.Error(
+						"update imageentity",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
+				}
 			}
 		}
 	}
 }
 
+// getBlockStorageClass はbsのStorageClassを取得する
+// StorageClassが指定されていない場合や省略されたパラメータにはagentの設定を使う
+func (a *ImageAgent) getBlockStorageClass(bs *system.BlockStorage) (*system.StorageClass, error) {
+	sc := &system.StorageClass{}
+	if bs.Spec.StorageClassName != "" {
+		found, err := a.client.SystemV0().StorageClass().Get(bs.Spec.StorageClassName)
+		if err != nil {
+			return nil, err
+		}
+		sc = found
+	}
+
+	if sc.Spec.Local.DirPath == "" {
+		sc.Spec.Local.DirPath = a.localBlockStorageDirectory
+	}
+	if sc.Spec.Ceph.PoolName == "" {
+		sc.Spec.Ceph.PoolName = a.config.CephBackend.PoolName
+	}
+	return sc, nil
+}
+
 func setHash(imageEntity *system.ImageEntity) error {
 	imageEntity.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := imageEntity.ResourceVersion
+	imageEntity.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(imageEntity)
+	imageEntity.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
@@ -260,30 +272,3 @@ func fileIsExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
-
-const (
-	UnitGigabyte = 'G'
-	UnitMegabyte = 'M'
-	UnitKilobyte = 'K'
-)
-
-func withUnitToWithoutUnit(numberWithUnit string) string {
-	length := len(numberWithUnit)
-	if numberWithUnit[length-1] >= '0' && numberWithUnit[length-1] <= '9' {
-		return numberWithUnit
-	}
-
-	number, err := strconv.ParseInt(numberWithUnit[:length-1], 10, 64)
-	if err != nil {
-		return "0"
-	}
-	switch numberWithUnit[length-1] {
-	case UnitGigabyte:
-		return fmt.Sprintf("%d", number*1024*1024*1024)
-	case UnitMegabyte:
-		return fmt.Sprintf("%d", number*1024*1024)
-	case UnitKilobyte:
-		return fmt.Sprintf("%d", number*1024)
-	}
-	return "0"
-}
@@ -32,7 +32,7 @@ func (a *ImageAgent) syncCephImageEntityFromImage(imageEntity *system.ImageEntit
 	}
 
 	imageEntity.Status.State = system.ImageEntityStateCopying
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
@@ -96,8 +96,9 @@ func (a *ImageAgent) syncCephImageEntityFromImage(imageEntity *system.ImageEntit
 	}
 
 	imageEntity.Annotations["image-entity-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
+	imageEntity.Annotations["image-entity-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
 	imageEntity.Status.State = system.ImageEntityStateAvailable
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
@@ -110,7 +111,7 @@ func (a *ImageAgent) syncCephImageEntityFromBlockStorage(imageEntity *system.Ima
 	}
 
 	imageEntity.Status.State = system.ImageEntityStatePending
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
@@ -119,11 +120,16 @@ func (a *ImageAgent) syncCephImageEntityFromBlockStorage(imageEntity *system.Ima
 	}
 
 	imageEntity.Status.State = system.ImageEntityStateCopying
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 	bs.Status.State = system.BlockStorageStateCopying
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
+		return err
+	}
+
+	sc, err := a.getBlockStorageClass(bs)
+	if err != nil {
 		return err
 	}
 
@@ -146,7 +152,14 @@ func (a *ImageAgent) syncCephImageEntityFromBlockStorage(imageEntity *system.Ima
 			return fmt.Errorf("ceph-image-name not found")
 		}
 
-		if fromImage, err := rbd.OpenImageReadOnly(ioctx, imageName, ""); err != nil {
+		// コピー元はStorageClassのpoolにある
+		srcIoctx, err := conn.OpenIOContext(sc.Spec.Ceph.PoolName)
+		if err != nil {
+			return errors.Wrap(err, "open source io context")
+		}
+		defer srcIoctx.Destroy()
+
+		if fromImage, err := rbd.OpenImageReadOnly(srcIoctx, imageName, ""); err != nil {
 			return errors.Wrapf(err, "open rbd image `%s`", imageName)
 		} else {
 			defer fromImage.Close()
@@ -171,7 +184,7 @@ func (a *ImageAgent) syncCephImageEntityFromBlockStorage(imageEntity *system.Ima
 			imageEntity.Annotations[ImageEntityV0AnnotationCephImageName] = destImageName
 		}
 	} else {
-		srcPath := filepath.Join(a.localBlockStorageDirectory, bs.Group, bs.Namespace, bs.ID)
+		srcPath := filepath.Join(sc.Spec.Local.DirPath, bs.Group, bs.Namespace, bs.ID)
 		s, err := os.Open(srcPath)
 		if err != nil {
 			return err
@@ -222,13 +235,14 @@ func (a *ImageAgent) syncCephImageEntityFromBlockStorage(imageEntity *system.Ima
 	}
 
 	imageEntity.Annotations["image-entity-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
+	imageEntity.Annotations["image-entity-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
 	imageEntity.Status.State = system.ImageEntityStateAvailable
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
 	bs.Status.State = system.BlockStorageStateActive
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 
@@ -283,14 +297,14 @@ func (a *ImageAgent) deleteCephImageEntity(imageEntity *system.ImageEntity) erro
 	}
 
 	imageEntity.Status.State = system.ImageEntityStateDeleting
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
 	conn, err := a.newCephConn()
 	if err != nil {
 		imageEntity.Status.State = ""
-		if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+		if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 			return err
 		}
 		return errors.Wrap(err, "Failed to create ceph connection.")
@@ -300,7 +314,7 @@ func (a *ImageAgent) deleteCephImageEntity(imageEntity *system.ImageEntity) erro
 	ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
 	if err != nil {
 		imageEntity.Status.State = ""
-		if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+		if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 			return err
 		}
 		return errors.Wrap(err, "Failed to open io context")
@@ -339,7 +353,7 @@ func (a *ImageAgent) deleteCephImageEntity(imageEntity *system.ImageEntity) erro
 		image.Close()
 		if err := image.Remove(); err != nil {
 			imageEntity.Status.State = ""
-			if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+			if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 				return err
 			}
 			return errors.Wrapf(err, "Failed to remove image `%s`", imageName)
@@ -348,7 +362,7 @@ func (a *ImageAgent) deleteCephImageEntity(imageEntity *system.ImageEntity) erro
 
 	if err := a.client.SystemV0().ImageEntity().Delete(imageEntity.Group, imageEntity.ID); err != nil {
 		imageEntity.Status.State = ""
-		if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+		if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 			return err
 		}
 		return err
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"go.uber.org/zap"
 )
 
@@ -64,7 +65,12 @@ func (a *ImageAgent) DownloadAPI(config *ImageAgentDownloadAPIConfig) error {
 		}
 	})
 
-	if err := r.Run(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort)); err != nil {
+	tlsConfig, err := config.TLS.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := tlsutil.ListenAndServe(fmt.Sprintf("%s:%d", config.AdvertiseAddress, config.ListenPort), r, tlsConfig); err != nil {
 		return err
 	}
 
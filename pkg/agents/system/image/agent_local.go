@@ -22,7 +22,7 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 		}
 
 		imageEntity.Status.State = system.ImageEntityStateDeleting
-		if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+		if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 			return err
 		}
 
@@ -41,7 +41,7 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 	}
 
 	imageEntity.Status.State = system.ImageEntityStatePending
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 
@@ -50,11 +50,16 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 	//}
 
 	imageEntity.Status.State = system.ImageEntityStateCopying
-	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
 		return err
 	}
 	bs.Status.State = system.BlockStorageStateCopying
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
+		return err
+	}
+
+	sc, err := a.getBlockStorageClass(bs)
+	if err != nil {
 		return err
 	}
 
@@ -72,7 +77,7 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 		}
 		defer conn.Shutdown()
 
-		ioctx, err := conn.OpenIOContext(a.config.CephBackend.PoolName)
+		ioctx, err := conn.OpenIOContext(sc.Spec.Ceph.PoolName)
 		if err != nil {
 			return errors.Wrap(err, "open io context")
 		}
@@ -125,7 +130,7 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 			size = int64(sum)
 		}
 	} else {
-		srcPath := filepath.Join(a.localBlockStorageDirectory, bs.Group, bs.Namespace, bs.ID)
+		srcPath := filepath.Join(sc.Spec.Local.DirPath, bs.Group, bs.Namespace, bs.ID)
 		s, err := os.Open(srcPath)
 		if err != nil {
 			return err
@@ -170,13 +175,19 @@ func (a *ImageAgent) syncLocalImageEntityFromBlockStorage(imageEntity *system.Im
 		imageEntity.Annotations = map[string]string{}
 	}
 	imageEntity.Annotations["image-entity-download-host"] = fmt.Sprintf("%s:%d", a.config.DownloadAPI.AdvertiseAddress, a.config.DownloadAPI.ListenPort)
-	imageEntity.Status.State = system.ImageEntityStateAvailable
+	imageEntity.Annotations["image-entity-download-scheme"] = a.config.DownloadAPI.TLS.Scheme()
+	// hashはspecなのでstatusとは別に保存する
 	if _, err := a.client.SystemV0().ImageEntity().Update(imageEntity); err != nil {
 		return err
 	}
 
+	imageEntity.Status.State = system.ImageEntityStateAvailable
+	if _, err := a.client.SystemV0().ImageEntity().UpdateStatus(imageEntity); err != nil {
+		return err
+	}
+
 	bs.Status.State = system.BlockStorageStateActive
-	if _, err := a.client.SystemV0().BlockStorage().Update(bs); err != nil {
+	if _, err := a.client.SystemV0().BlockStorage().UpdateStatus(bs); err != nil {
 		return err
 	}
 
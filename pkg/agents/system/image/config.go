@@ -1,8 +1,14 @@
 package image
 
+import "github.com/ophum/humstack/pkg/utils/tlsutil"
+
 type ImageAgentDownloadAPIConfig struct {
 	AdvertiseAddress string `yaml:"advertiseAddress"`
 	ListenPort       int32  `yaml:"listenPort"`
+
+	// TLS を指定した場合はHTTPSで公開する
+	// clientCAFileを指定した場合はクライアント証明書を要求する
+	TLS *tlsutil.ServerConfig `yaml:"tls"`
 }
 
 type ImageAgentCephBackendConfig struct {
@@ -1,12 +1,14 @@
 package node
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/utils/quantity"
 	"go.uber.org/zap"
 )
 
@@ -14,13 +16,23 @@ type NodeAgent struct {
 	client   *client.Clients
 	NodeInfo *system.Node
 	logger   *zap.Logger
+
+	// spec, annotations はconfigから設定した値で、登録済みのNodeと異なる場合は更新する
+	spec        system.NodeSpec
+	annotations map[string]string
+
+	// blockStorageDirPath はdiskのcapacityを取得するディレクトリ
+	blockStorageDirPath string
 }
 
-func NewNodeAgent(node *system.Node, client *client.Clients, logger *zap.Logger) *NodeAgent {
+func NewNodeAgent(node *system.Node, blockStorageDirPath string, client *client.Clients, logger *zap.Logger) *NodeAgent {
 	return &NodeAgent{
-		NodeInfo: node,
-		client:   client,
-		logger:   logger,
+		NodeInfo:            node,
+		client:              client,
+		logger:              logger,
+		spec:                node.Spec,
+		annotations:         node.Annotations,
+		blockStorageDirPath: blockStorageDirPath,
 	}
 }
 
@@ -32,7 +44,8 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 		select {
 		case <-ticker.C:
 			node, err := a.client.SystemV0().Node().Get(a.NodeInfo.Name)
-			if err != nil {
+			notFound := errors.Is(err, meta.ErrNotFound)
+			if err != nil && !notFound {
 				a.logger.Error(
 					"get node",
 					zap.String("msg", err.Error()),
@@ -41,7 +54,7 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 				continue
 			}
 
-			if node.ID == "" {
+			if notFound {
 				node, err = a.client.SystemV0().Node().Create(a.NodeInfo)
 				if err != nil {
 					a.logger.Error(
@@ -55,10 +68,37 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 				a.NodeInfo = node
 			}
 
+			if node.Spec != a.spec {
+				node.Spec = a.spec
+				node, err = a.client.SystemV0().Node().Update(node)
+				if err != nil {
+					a.logger.Error(
+						"update node spec",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
+				}
+			}
+
+			if !a.hasAnnotations(node) {
+				// statusの更新でannotationsもマージされる
+				node.Annotations = a.annotations
+				node, err = a.client.SystemV0().Node().UpdateStatus(node)
+				if err != nil {
+					a.logger.Error(
+						"update node annotations",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
+				}
+			}
+
 			if node.Status.State == system.NodeStateNotReady ||
 				node.Status.State == "" {
 				node.Status.State = system.NodeStateReady
-				node, err = a.client.SystemV0().Node().Update(node)
+				node, err = a.client.SystemV0().Node().UpdateStatus(node)
 				if err != nil {
 					a.logger.Error(
 						"update node",
@@ -69,6 +109,7 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 				}
 			}
 
+			status := node.Status
 			res, err := a.getUsedResources()
 			if err != nil {
 				a.logger.Error(
@@ -77,23 +118,29 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 					zap.Time("time", time.Now()),
 				)
 			} else {
-				if node.Status.RequestedVcpus != res[ResourceTypeRequestVcpus] ||
-					node.Status.RequestedMemory != res[ResourceTypeRequestMemory] ||
-					node.Status.RequestedDisk != res[ResourceTypeRequestDisk] {
-					node.Status.RequestedVcpus = res[ResourceTypeRequestVcpus]
-					node.Status.RequestedMemory = res[ResourceTypeRequestMemory]
-					node.Status.RequestedDisk = res[ResourceTypeRequestDisk]
-
-					node, err = a.client.SystemV0().Node().Update(node)
-					if err != nil {
-						a.logger.Error(
-							"update node",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
+				status.RequestedVcpus = res[ResourceTypeRequestVcpus]
+				status.RequestedMemory = res[ResourceTypeRequestMemory]
+				status.RequestedDisk = res[ResourceTypeRequestDisk]
+			}
 
+			if err := a.setCapacity(&status, node.Spec); err != nil {
+				a.logger.Error(
+					"get capacity",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+			}
+
+			if status != node.Status {
+				node.Status = status
+				node, err = a.client.SystemV0().Node().UpdateStatus(node)
+				if err != nil {
+					a.logger.Error(
+						"update node",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
 				}
 			}
 
@@ -103,6 +150,36 @@ func (a *NodeAgent) Run(pollingDuration time.Duration) {
 
 }
 
+// setCapacity はホストのリソース量からstatusのcapacityとallocatableを設定する
+func (a *NodeAgent) setCapacity(status *system.NodeStatus, spec system.NodeSpec) error {
+	c, err := getHostCapacity(a.blockStorageDirPath)
+	if err != nil {
+		return err
+	}
+
+	allocatable, err := c.allocatable(spec)
+	if err != nil {
+		return err
+	}
+
+	status.Capacity = c.capacity()
+	status.Allocatable = allocatable
+	status.FreeDisk = ""
+	if c.freeDisk >= 0 {
+		status.FreeDisk = quantity.Format(c.freeDisk, quantity.BytesUnits)
+	}
+	return nil
+}
+
+func (a *NodeAgent) hasAnnotations(node *system.Node) bool {
+	for k, v := range a.annotations {
+		if node.Annotations[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *NodeAgent) GetNodeInfo() *system.Node {
 	return a.NodeInfo
 }
@@ -120,143 +197,76 @@ const (
 
 func (a *NodeAgent) getUsedResources() (map[ResourceType]string, error) {
 
-	grList, err := a.client.CoreV0().Group().List()
-	if err != nil {
-		return nil, err
-	}
-
-	var vcpusRequests float64 = 0
-	var vcpusLimits float64 = 0
+	var vcpusRequests int64 = 0
+	var vcpusLimits int64 = 0
 	var memoryRequests int64 = 0
 	var memoryLimits int64 = 0
 	var diskRequests int64 = 0
 	var diskLimits int64 = 0
 
-	for _, group := range grList {
-		nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+	// 全てのグループ・ネームスペースからこのノードのVMのみを取得する
+	vmList, err := a.client.SystemV0().VirtualMachine().ListInCluster("",
+		fmt.Sprintf("metadata.annotations[virtualmachinev0/node_name]=%s", a.NodeInfo.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vm := range vmList {
+		if vm.Spec.ActionState == system.VirtualMachineActionStatePowerOff {
+			continue
+		}
+
+		vcpusRequest, err := quantity.ParseVcpus(vm.Spec.RequestVcpus)
 		if err != nil {
 			return nil, err
 		}
+		vcpusRequests += vcpusRequest
 
-		for _, ns := range nsList {
-			vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
-			if err != nil {
-				return nil, err
-			}
-
-			for _, vm := range vmList {
-				if vm.Annotations["virtualmachinev0/node_name"] != a.NodeInfo.ID {
-					continue
-				}
-				if vm.Spec.ActionState == system.VirtualMachineActionStatePowerOff {
-					continue
-				}
-
-				vcpusRequest, err := strconv.ParseFloat(withUnitToWithoutUnit(vm.Spec.RequestVcpus), 64)
-				if err != nil {
-					return nil, err
-				}
-				vcpusRequests += vcpusRequest
-
-				vcpusLimit, err := strconv.ParseFloat(withUnitToWithoutUnit(vm.Spec.LimitVcpus), 64)
-				if err != nil {
-					return nil, err
-				}
-				vcpusLimits += vcpusLimit
-
-				memoryRequest, err := strconv.ParseInt(withUnitToWithoutUnit(vm.Spec.RequestMemory), 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				memoryRequests += memoryRequest
-
-				memoryLimit, err := strconv.ParseInt(withUnitToWithoutUnit(vm.Spec.LimitMemory), 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				memoryLimits += memoryLimit
-			}
-
-			bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
-			if err != nil {
-				return nil, err
-			}
-
-			for _, bs := range bsList {
-				if bs.Annotations["blockstoragev0/node_name"] != a.NodeInfo.ID ||
-					bs.Annotations["blockstoragev0/type"] != "Local" {
-					continue
-				}
+		vcpusLimit, err := quantity.ParseVcpus(vm.Spec.LimitVcpus)
+		if err != nil {
+			return nil, err
+		}
+		vcpusLimits += vcpusLimit
 
-				diskRequest, err := strconv.ParseInt(withUnitToWithoutUnit(bs.Spec.RequestSize), 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				diskRequests += diskRequest
+		memoryRequest, err := quantity.ParseBytes(vm.Spec.RequestMemory)
+		if err != nil {
+			return nil, err
+		}
+		memoryRequests += memoryRequest
 
-				diskLimit, err := strconv.ParseInt(withUnitToWithoutUnit(bs.Spec.LimitSize), 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				diskLimits += diskLimit
-			}
+		memoryLimit, err := quantity.ParseBytes(vm.Spec.LimitMemory)
+		if err != nil {
+			return nil, err
 		}
+		memoryLimits += memoryLimit
 	}
 
-	return map[ResourceType]string{
-		ResourceTypeRequestVcpus:  parseVcpus(vcpusRequests),
-		ResourceTypeRequestMemory: parseMemory(memoryRequests),
-		ResourceTypeRequestDisk:   parseMemory(diskRequests),
-		ResourceTypeLimitVcpus:    parseVcpus(vcpusLimits),
-		ResourceTypeLimitMemory:   parseMemory(memoryLimits),
-		ResourceTypeLimitDisk:     parseMemory(diskLimits),
-	}, nil
-}
-
-func parseVcpus(n float64) string {
-	return fmt.Sprintf("%dm", int64(n*1000))
-}
-
-func parseMemory(b int64) string {
-	if b%(1024*1024*1024) == 0 {
-		return fmt.Sprintf("%dG", b/(1024*1024*1024))
-	} else if b%(1024*1024) == 0 {
-		return fmt.Sprintf("%dM", b/(1024*1024))
-	} else if b%1024 == 0 {
-		return fmt.Sprintf("%dK", b/1024)
-	} else {
-		return fmt.Sprintf("%d", b)
+	bsList, err := a.client.SystemV0().BlockStorage().ListInCluster("",
+		fmt.Sprintf("metadata.annotations[blockstoragev0/node_name]=%s,metadata.annotations[blockstoragev0/type]=Local", a.NodeInfo.ID))
+	if err != nil {
+		return nil, err
 	}
-}
-
-// copy from virtualmachine/agent.go
-const (
-	UnitGigabyte = 'G'
-	UnitMegabyte = 'M'
-	UnitKilobyte = 'K'
-	UnitMilli    = 'm'
-)
 
-func withUnitToWithoutUnit(numberWithUnit string) string {
-	length := len(numberWithUnit)
-	if numberWithUnit[length-1] >= '0' && numberWithUnit[length-1] <= '9' {
-		return numberWithUnit
-	}
+	for _, bs := range bsList {
+		diskRequest, err := quantity.ParseBytes(bs.Spec.RequestSize)
+		if err != nil {
+			return nil, err
+		}
+		diskRequests += diskRequest
 
-	number, err := strconv.ParseInt(numberWithUnit[:length-1], 10, 64)
-	if err != nil {
-		return "0"
+		diskLimit, err := quantity.ParseBytes(bs.Spec.LimitSize)
+		if err != nil {
+			return nil, err
+		}
+		diskLimits += diskLimit
 	}
 
-	switch numberWithUnit[length-1] {
-	case UnitGigabyte:
-		return fmt.Sprintf("%d", number*1024*1024*1024)
-	case UnitMegabyte:
-		return fmt.Sprintf("%d", number*1024*1024)
-	case UnitKilobyte:
-		return fmt.Sprintf("%d", number*1024)
-	case UnitMilli:
-		return fmt.Sprintf("%f", float64(number)/1000)
-	}
-	return "0"
+	return map[ResourceType]string{
+		ResourceTypeRequestVcpus:  quantity.Format(vcpusRequests, quantity.VcpusUnits),
+		ResourceTypeRequestMemory: quantity.Format(memoryRequests, quantity.BytesUnits),
+		ResourceTypeRequestDisk:   quantity.Format(diskRequests, quantity.BytesUnits),
+		ResourceTypeLimitVcpus:    quantity.Format(vcpusLimits, quantity.VcpusUnits),
+		ResourceTypeLimitMemory:   quantity.Format(memoryLimits, quantity.BytesUnits),
+		ResourceTypeLimitDisk:     quantity.Format(diskLimits, quantity.BytesUnits),
+	}, nil
 }
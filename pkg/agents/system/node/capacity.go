@@ -0,0 +1,150 @@
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+// hostCapacity はホストから取得したリソース量
+// vcpusはコア数、memory, disk, freeDiskはバイト単位で、取得しない場合は-1
+type hostCapacity struct {
+	vcpus    float64
+	memory   int64
+	disk     int64
+	freeDisk int64
+}
+
+// getHostCapacity はcpu数、/proc/meminfoのMemTotal、dirPathのファイルシステムの容量を取得する
+// dirPathが空の場合はdiskを取得しない
+func getHostCapacity(dirPath string) (*hostCapacity, error) {
+	c := &hostCapacity{
+		vcpus:    float64(runtime.NumCPU()),
+		disk:     -1,
+		freeDisk: -1,
+	}
+
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if c.memory, err = readMemTotal(f); err != nil {
+		return nil, err
+	}
+
+	if dirPath != "" {
+		// BlockStorageが作成される前でも容量を取得できるように作成しておく
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			return nil, err
+		}
+
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(dirPath, &stat); err != nil {
+			return nil, err
+		}
+		c.disk = int64(stat.Blocks) * int64(stat.Bsize)
+		c.freeDisk = int64(stat.Bavail) * int64(stat.Bsize)
+	}
+	return c, nil
+}
+
+// readMemTotal は/proc/meminfoの`MemTotal:  16314432 kB`をバイト単位で返す
+func readMemTotal(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			n *= 1024
+		}
+		return n, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal is not found")
+}
+
+// capacity はNodeStatusのCapacityの形式にする
+func (c *hostCapacity) capacity() system.NodeResources {
+	res := system.NodeResources{
+		Vcpus:  quantity.Format(int64(c.vcpus*1000), quantity.VcpusUnits),
+		Memory: quantity.Format(c.memory, quantity.BytesUnits),
+	}
+	if c.disk >= 0 {
+		res.Disk = quantity.Format(c.disk, quantity.BytesUnits)
+	}
+	return res
+}
+
+// allocatable はcapacityにspecのovercommit ratioをかけて、specのlimitが小さい場合はlimitにする
+func (c *hostCapacity) allocatable(spec system.NodeSpec) (system.NodeResources, error) {
+	vcpus := int64(c.vcpus * 1000 * overcommitRatio(spec.VcpusOvercommitRatio))
+	if spec.LimitVcpus != "" {
+		limit, err := quantity.ParseVcpus(spec.LimitVcpus)
+		if err != nil {
+			return system.NodeResources{}, err
+		}
+		if limit < vcpus {
+			vcpus = limit
+		}
+	}
+
+	memory := int64(float64(c.memory) * overcommitRatio(spec.MemoryOvercommitRatio))
+	// KB単位に切り捨てて表示しやすくする
+	memory -= memory % 1024
+	if spec.LimitMemory != "" {
+		limit, err := quantity.ParseBytes(spec.LimitMemory)
+		if err != nil {
+			return system.NodeResources{}, err
+		}
+		if limit < memory {
+			memory = limit
+		}
+	}
+
+	res := system.NodeResources{
+		Vcpus:  quantity.Format(vcpus, quantity.VcpusUnits),
+		Memory: quantity.Format(memory, quantity.BytesUnits),
+	}
+
+	// diskはovercommitせずにlimitDiskで制限する
+	disk := c.disk
+	if spec.LimitDisk != "" {
+		limit, err := quantity.ParseBytes(spec.LimitDisk)
+		if err != nil {
+			return system.NodeResources{}, err
+		}
+		if disk < 0 || limit < disk {
+			disk = limit
+		}
+	}
+	if disk >= 0 {
+		res.Disk = quantity.Format(disk, quantity.BytesUnits)
+	}
+	return res, nil
+}
+
+func overcommitRatio(ratio float64) float64 {
+	if ratio <= 0 {
+		return 1
+	}
+	return ratio
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func TestReadMemTotal(t *testing.T) {
+	meminfo := "MemTotal:       16314432 kB\nMemFree:         1234567 kB\n"
+	got, err := readMemTotal(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(16314432 * 1024); got != want {
+		t.Fatalf("want: %d, got: %d", want, got)
+	}
+
+	if _, err := readMemTotal(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Fatal("want: error, got: nil")
+	}
+}
+
+func TestAllocatable(t *testing.T) {
+	c := &hostCapacity{
+		vcpus:    4,
+		memory:   8 * 1024 * 1024 * 1024,
+		disk:     100 * 1024 * 1024 * 1024,
+		freeDisk: 50 * 1024 * 1024 * 1024,
+	}
+
+	tests := []struct {
+		name string
+		spec system.NodeSpec
+		want system.NodeResources
+	}{
+		{
+			name: "capacity",
+			spec: system.NodeSpec{},
+			want: system.NodeResources{Vcpus: "4", Memory: "8G", Disk: "100G"},
+		},
+		{
+			name: "overcommit",
+			spec: system.NodeSpec{VcpusOvercommitRatio: 4, MemoryOvercommitRatio: 1.5},
+			want: system.NodeResources{Vcpus: "16", Memory: "12G", Disk: "100G"},
+		},
+		{
+			name: "limited",
+			spec: system.NodeSpec{
+				LimitVcpus:           "8",
+				LimitMemory:          "4G",
+				LimitDisk:            "200G",
+				VcpusOvercommitRatio: 4,
+			},
+			want: system.NodeResources{Vcpus: "8", Memory: "4G", Disk: "100G"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := c.allocatable(test.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Fatalf("%s: want: %+v, got: %+v", test.name, test.want, got)
+		}
+	}
+}
@@ -52,141 +52,122 @@ func (a *NodeNetworkAgent) Run(pollingDuration time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			vmList, err := a.client.SystemV0().VirtualMachine().ListInCluster("", "")
 			if err != nil {
 				a.logger.Error(
-					"get group list",
+					"get virtualmachine list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
 				continue
 			}
 
-			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
-				if err != nil {
-					a.logger.Error(
-						"get namespace list",
-						zap.String("msg", err.Error()),
-						zap.Time("time", time.Now()),
-					)
+			// グループ・ネームスペースごとにNetworkに接続されているインターフェースをまとめる
+			attachedInterfaces := map[string]map[string]map[string]system.VirtualMachineNIC{}
+			attach := func(groupID, namespaceID, networkID, key string, nic system.VirtualMachineNIC) {
+				nsKey := filepath.Join(groupID, namespaceID)
+				if attachedInterfaces[nsKey] == nil {
+					attachedInterfaces[nsKey] = map[string]map[string]system.VirtualMachineNIC{}
+				}
+				if attachedInterfaces[nsKey][networkID] == nil {
+					attachedInterfaces[nsKey][networkID] = map[string]system.VirtualMachineNIC{}
+				}
+				attachedInterfaces[nsKey][networkID][key] = nic
+			}
+
+			for _, vm := range vmList {
+				if vm.Status.State != system.VirtualMachineStateRunning {
 					continue
 				}
 
-				for _, ns := range nsList {
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+				for _, nic := range vm.Spec.NICs {
+					attach(vm.Group, vm.Namespace, nic.NetworkID, filepath.Join("virtualmachinev0", vm.ID), *nic)
+				}
+			}
+
+			vrList, err := a.client.SystemV0().VirtualRouter().ListInCluster("", "")
+			if err != nil {
+				a.logger.Error(
+					"get virtualrouter list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+			for _, vr := range vrList {
+				if vr.Status.State != system.VirtualRouterStateRunning {
+					continue
+				}
+
+				for _, nic := range vr.Spec.NICs {
+					attach(vr.Group, vr.Namespace, nic.NetworkID, filepath.Join("virtualrouterv0", vr.ID), system.VirtualMachineNIC{
+						NetworkID:   nic.NetworkID,
+						IPv4Address: nic.IPv4Address,
+					})
+				}
+			}
+
+			netList, err := a.client.SystemV0().NodeNetwork().ListInCluster("", "")
+			if err != nil {
+				a.logger.Error(
+					"get network list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+
+			for _, net := range netList {
+				if nodeName, ok := net.Annotations[NodeNetworkV0AnnotationNodeName]; ok && nodeName != a.node {
+					continue
+				}
+				oldHash := net.ResourceHash
+				net.Status.AttachedInterfaces = attachedInterfaces[filepath.Join(net.Group, net.Namespace)][net.ID]
+				switch net.Annotations[NodeNetworkV0AnnotationNetworkType] {
+				case NodeNetworkV0NetworkTypeBridge:
+					err = a.syncBridgeNetwork(net)
 					if err != nil {
 						a.logger.Error(
-							"get virtualmachine list",
+							"sync bridge network",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
 						continue
 					}
-					attachedInterfacesToNet := map[string]map[string]system.VirtualMachineNIC{}
-					for _, vm := range vmList {
-						if vm.Status.State != system.VirtualMachineStateRunning {
-							continue
-						}
-
-						for _, nic := range vm.Spec.NICs {
-							if attachedInterfacesToNet[nic.NetworkID] == nil {
-								attachedInterfacesToNet[nic.NetworkID] = map[string]system.VirtualMachineNIC{}
-							}
-
-							attachedInterfacesToNet[nic.NetworkID][filepath.Join("virtualmachinev0", vm.ID)] = *nic
-						}
-					}
-
-					vrList, err := a.client.SystemV0().VirtualRouter().List(group.ID, ns.ID)
+				case NodeNetworkV0NetworkTypeVXLAN:
+					err = a.syncVXLANNetwork(net)
 					if err != nil {
 						a.logger.Error(
-							"get virtualrouter list",
+							"sync vxlan network",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
 						continue
 					}
-					for _, vr := range vrList {
-						if vr.Status.State != system.VirtualRouterStateRunning {
-							continue
-						}
-
-						for _, nic := range vr.Spec.NICs {
-							if attachedInterfacesToNet[nic.NetworkID] == nil {
-								attachedInterfacesToNet[nic.NetworkID] = map[string]system.VirtualMachineNIC{}
-							}
-
-							attachedInterfacesToNet[nic.NetworkID][filepath.Join("virtualrouterv0", vr.ID)] = system.VirtualMachineNIC{
-								NetworkID:   nic.NetworkID,
-								IPv4Address: nic.IPv4Address,
-							}
-						}
-					}
-
-					netList, err := a.client.SystemV0().NodeNetwork().List(group.ID, ns.ID)
+				case NodeNetworkV0NetworkTypeVLAN:
+					err = a.syncVLANNetwork(net)
 					if err != nil {
 						a.logger.Error(
-							"get network list",
+							"sync vlan network",
 							zap.String("msg", err.Error()),
 							zap.Time("time", time.Now()),
 						)
 						continue
 					}
 
-					for _, net := range netList {
-						if nodeName, ok := net.Annotations[NodeNetworkV0AnnotationNodeName]; ok && nodeName != a.node {
-							continue
-						}
-						oldHash := net.ResourceHash
-						net.Status.AttachedInterfaces = attachedInterfacesToNet[net.ID]
-						switch net.Annotations[NodeNetworkV0AnnotationNetworkType] {
-						case NodeNetworkV0NetworkTypeBridge:
-							err = a.syncBridgeNetwork(net)
-							if err != nil {
-								a.logger.Error(
-									"sync bridge network",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
-						case NodeNetworkV0NetworkTypeVXLAN:
-							err = a.syncVXLANNetwork(net)
-							if err != nil {
-								a.logger.Error(
-									"sync vxlan network",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
-						case NodeNetworkV0NetworkTypeVLAN:
-							err = a.syncVLANNetwork(net)
-							if err != nil {
-								a.logger.Error(
-									"sync vlan network",
-									zap.String("msg", err.Error()),
-									zap.Time("time", time.Now()),
-								)
-								continue
-							}
-
-						}
-
-						if net.ResourceHash == oldHash {
-							continue
-						}
-						_, err = a.client.SystemV0().NodeNetwork().Update(net)
-						if err != nil {
-							a.logger.Error(
-								"update network",
-								zap.String("msg", err.Error()),
-								zap.Time("time", time.Now()),
-							)
-							continue
-						}
-					}
+				}
+
+				if net.ResourceHash == oldHash {
+					continue
+				}
+				_, err = a.client.SystemV0().NodeNetwork().UpdateStatus(net)
+				if err != nil {
+					a.logger.Error(
+						"update network",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
 				}
 			}
 		}
@@ -195,7 +176,11 @@ func (a *NodeNetworkAgent) Run(pollingDuration time.Duration) {
 
 func setHash(network *system.NodeNetwork) error {
 	network.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := network.ResourceVersion
+	network.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(network)
+	network.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
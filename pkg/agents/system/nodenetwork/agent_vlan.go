@@ -44,7 +44,7 @@ func (a *NodeNetworkAgent) syncVLANNetwork(network *system.NodeNetwork) error {
 					Datetime: time.Now().String(),
 					Log:      fmt.Sprintf("vlan id `%s` is already used.", network.Spec.ID),
 				})
-				if _, err := a.client.SystemV0().NodeNetwork().Update(network); err != nil {
+				if _, err := a.client.SystemV0().NodeNetwork().UpdateStatus(network); err != nil {
 					return err
 				}
 				return fmt.Errorf("vlan id `%s` is already used.", network.Spec.ID)
@@ -0,0 +1,390 @@
+package scheduler
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"go.uber.org/zap"
+)
+
+const (
+	VirtualMachineV0AnnotationNodeName = "virtualmachinev0/node_name"
+	// VirtualMachineV0AnnotationScheduleResult はschedulerが選んだNodeと理由、
+	// または割り当てられなかった理由を記録する
+	VirtualMachineV0AnnotationScheduleResult = "virtualmachinev0/schedule_result"
+	// BlockStorageV0AnnotationScheduleResult はschedulerが選んだNodeと理由、
+	// または割り当てられなかった理由を記録する
+	BlockStorageV0AnnotationScheduleResult = "blockstoragev0/schedule_result"
+)
+
+// SchedulerAgent はvirtualmachinev0/node_nameが設定されていないVirtualMachineと
+// blockstoragev0/node_nameが設定されていないBlockStorageにNodeを割り当てる
+// クラスタ全体で1つだけ動かす
+type SchedulerAgent struct {
+	client    *client.Clients
+	framework *Framework
+	logger    *zap.Logger
+}
+
+func NewSchedulerAgent(client *client.Clients, framework *Framework, logger *zap.Logger) *SchedulerAgent {
+	return &SchedulerAgent{
+		client:    client,
+		framework: framework,
+		logger:    logger,
+	}
+}
+
+func (a *SchedulerAgent) Run(pollingDuration time.Duration) {
+	ticker := time.NewTicker(pollingDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.schedule(); err != nil {
+				a.logger.Error(
+					"schedule",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+			}
+		}
+	}
+}
+
+// schedule はVirtualMachineを割り当ててから、BlockStorageを参照しているVirtualMachineと同じNodeに割り当てる
+func (a *SchedulerAgent) schedule() error {
+	vmList, err := a.client.SystemV0().VirtualMachine().ListInCluster("", "")
+	if err != nil {
+		return err
+	}
+	bsList, err := a.client.SystemV0().BlockStorage().ListInCluster("", "")
+	if err != nil {
+		return err
+	}
+
+	pendingVMs := []*system.VirtualMachine{}
+	for _, vm := range vmList {
+		if vm.DeleteState == meta.DeleteStateDelete || vm.Annotations[VirtualMachineV0AnnotationNodeName] != "" {
+			continue
+		}
+		pendingVMs = append(pendingVMs, vm)
+	}
+	pendingBSs := []*system.BlockStorage{}
+	for _, bs := range bsList {
+		if bs.DeleteState == meta.DeleteStateDelete || bs.Annotations[BlockStorageV0AnnotationNodeName] != "" {
+			continue
+		}
+		pendingBSs = append(pendingBSs, bs)
+	}
+	if len(pendingVMs) == 0 && len(pendingBSs) == 0 {
+		return nil
+	}
+
+	nodeList, err := a.client.SystemV0().Node().List()
+	if err != nil {
+		return err
+	}
+	scList, err := a.client.SystemV0().StorageClass().List()
+	if err != nil {
+		return err
+	}
+
+	// StorageClassが指定されたBlockStorageはdriverをblockstoragev0/typeとして扱う
+	scMap := map[string]*system.StorageClass{}
+	for _, sc := range scList {
+		scMap[sc.ID] = sc
+	}
+	unresolved := map[*system.BlockStorage]error{}
+	for _, bs := range pendingBSs {
+		if err := setBlockStorageType(bs, scMap); err != nil {
+			unresolved[bs] = err
+		}
+	}
+
+	// 作成された順番が分からないのでIDの順番で割り当てる
+	sort.Slice(pendingVMs, func(i, j int) bool {
+		return getKey(&pendingVMs[i].Meta) < getKey(&pendingVMs[j].Meta)
+	})
+	sort.Slice(pendingBSs, func(i, j int) bool {
+		return getKey(&pendingBSs[i].Meta) < getKey(&pendingBSs[j].Meta)
+	})
+
+	bsMap := map[string]*system.BlockStorage{}
+	for _, bs := range bsList {
+		bsMap[getKey(&bs.Meta)] = bs
+	}
+	nodes := a.newNodeInfos(nodeList, vmList, bsList)
+	for _, vm := range pendingVMs {
+		if err := a.scheduleVirtualMachine(vm, nodes, bsMap); err != nil {
+			a.logger.Error(
+				"schedule virtualmachine",
+				zap.String("group", vm.Group),
+				zap.String("namespace", vm.Namespace),
+				zap.String("id", vm.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
+	}
+
+	// VirtualMachineに割り当てたdiskはBlockStorageを割り当てる時に加えるので計算し直す
+	vmMap := map[string]*system.VirtualMachine{}
+	for _, vm := range vmList {
+		if vm.DeleteState == meta.DeleteStateDelete {
+			continue
+		}
+		for _, bsID := range vm.Spec.BlockStorageIDs {
+			key := filepath.Join(vm.Group, vm.Namespace, bsID)
+			if _, ok := vmMap[key]; !ok {
+				vmMap[key] = vm
+			}
+		}
+	}
+	nodes = a.newNodeInfos(nodeList, vmList, bsList)
+	for _, bs := range pendingBSs {
+		var err error
+		if unresolvedErr, ok := unresolved[bs]; ok {
+			err = a.recordBlockStorageResult(bs, "", "", unresolvedErr.Error())
+		} else {
+			err = a.scheduleBlockStorage(bs, nodes, vmMap[getKey(&bs.Meta)])
+		}
+		if err != nil {
+			a.logger.Error(
+				"schedule blockstorage",
+				zap.String("group", bs.Group),
+				zap.String("namespace", bs.Namespace),
+				zap.String("id", bs.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+		}
+	}
+	return nil
+}
+
+// setBlockStorageType はbsのStorageClassのdriverをblockstoragev0/typeに設定する
+// StorageClassが指定されていない場合はblockstoragev0/typeをそのまま使う
+func setBlockStorageType(bs *system.BlockStorage, scMap map[string]*system.StorageClass) error {
+	if bs.Spec.StorageClassName == "" {
+		return nil
+	}
+	sc, ok := scMap[bs.Spec.StorageClassName]
+	if !ok {
+		return fmt.Errorf("storageclass `%s` is not found", bs.Spec.StorageClassName)
+	}
+	if bs.Annotations == nil {
+		bs.Annotations = map[string]string{}
+	}
+	bs.Annotations[BlockStorageV0AnnotationType] = string(sc.Spec.Driver)
+	return nil
+}
+
+func getKey(m *meta.Meta) string {
+	return filepath.Join(m.Group, m.Namespace, m.ID)
+}
+
+// newNodeInfos はNodeのリソース量を取得する
+// NodeのStatusはnode agentが定期的に更新するので、割り当て済みのVirtualMachine, BlockStorageから計算した方が多い場合はそちらを使う
+func (a *SchedulerAgent) newNodeInfos(nodeList []*system.Node, vmList []*system.VirtualMachine, bsList []*system.BlockStorage) []*NodeInfo {
+	nodes := []*NodeInfo{}
+	nodeMap := map[string]*NodeInfo{}
+	for _, node := range nodeList {
+		info, err := NewNodeInfo(node)
+		if err != nil {
+			a.logger.Error(
+				"parse node resources",
+				zap.String("node", node.ID),
+				zap.String("msg", err.Error()),
+				zap.Time("time", time.Now()),
+			)
+			continue
+		}
+		nodes = append(nodes, info)
+		nodeMap[node.ID] = info
+	}
+
+	assigned := map[string]*NodeInfo{}
+	add := func(nodeName string, req *Request) {
+		if _, ok := assigned[nodeName]; !ok {
+			assigned[nodeName] = &NodeInfo{}
+		}
+		assigned[nodeName].Add(req)
+	}
+	for _, vm := range vmList {
+		nodeName := vm.Annotations[VirtualMachineV0AnnotationNodeName]
+		if nodeName == "" || vm.Spec.ActionState == system.VirtualMachineActionStatePowerOff {
+			continue
+		}
+		req, err := NewRequest(vm, nil)
+		if err != nil {
+			continue
+		}
+		add(nodeName, req)
+	}
+	for _, bs := range bsList {
+		nodeName := bs.Annotations[BlockStorageV0AnnotationNodeName]
+		if nodeName == "" {
+			continue
+		}
+		req, err := NewBlockStorageRequest(bs, nil)
+		if err != nil {
+			continue
+		}
+		add(nodeName, req)
+	}
+
+	for nodeName, used := range assigned {
+		info, ok := nodeMap[nodeName]
+		if !ok {
+			continue
+		}
+		if used.RequestedVcpus > info.RequestedVcpus {
+			info.RequestedVcpus = used.RequestedVcpus
+		}
+		if used.RequestedMemory > info.RequestedMemory {
+			info.RequestedMemory = used.RequestedMemory
+		}
+		if used.RequestedDisk > info.RequestedDisk {
+			info.RequestedDisk = used.RequestedDisk
+		}
+	}
+	return nodes
+}
+
+func (a *SchedulerAgent) scheduleVirtualMachine(vm *system.VirtualMachine, nodes []*NodeInfo, bsMap map[string]*system.BlockStorage) error {
+	bsList := []*system.BlockStorage{}
+	for _, bsID := range vm.Spec.BlockStorageIDs {
+		if bs, ok := bsMap[filepath.Join(vm.Group, vm.Namespace, bsID)]; ok {
+			bsList = append(bsList, bs)
+		}
+	}
+
+	req, err := NewRequest(vm, bsList)
+	if err != nil {
+		return a.recordVirtualMachineResult(vm, "", err.Error())
+	}
+
+	node, score, err := a.framework.Schedule(req, nodes)
+	if err != nil {
+		return a.recordVirtualMachineResult(vm, "", err.Error())
+	}
+
+	if err := a.recordVirtualMachineResult(vm, node.Node.ID, fmt.Sprintf("scheduled to %s with score %d", node.Node.ID, score)); err != nil {
+		return err
+	}
+	// 同じ周期で割り当てるVirtualMachineのためにリソースを確保する
+	node.Add(req)
+
+	a.logger.Info(
+		"scheduled virtualmachine",
+		zap.String("group", vm.Group),
+		zap.String("namespace", vm.Namespace),
+		zap.String("id", vm.ID),
+		zap.String("node", node.Node.ID),
+		zap.Int64("score", score),
+		zap.Time("time", time.Now()),
+	)
+	return nil
+}
+
+// scheduleBlockStorage はbsを割り当てるNodeを選ぶ
+// vmはbsを参照しているVirtualMachineで、Nodeが決まっている場合は同じNodeに割り当てる
+func (a *SchedulerAgent) scheduleBlockStorage(bs *system.BlockStorage, nodes []*NodeInfo, vm *system.VirtualMachine) error {
+	// typeが指定されていない場合はLocalにする
+	bsType := bs.Annotations[BlockStorageV0AnnotationType]
+	if bsType == "" {
+		bsType = BlockStorageV0BlockStorageTypeLocal
+	}
+
+	// 参照しているVirtualMachineのNodeが決まるまで待つ
+	if vm != nil && vm.Annotations[VirtualMachineV0AnnotationNodeName] == "" {
+		return a.recordBlockStorageResult(bs, "", "", fmt.Sprintf("waiting for virtualmachine `%s` to be scheduled", vm.ID))
+	}
+
+	req, err := NewBlockStorageRequest(bs, vm)
+	if err != nil {
+		return a.recordBlockStorageResult(bs, "", "", err.Error())
+	}
+
+	node, score, err := a.framework.Schedule(req, nodes)
+	if err != nil {
+		return a.recordBlockStorageResult(bs, "", "", err.Error())
+	}
+
+	if err := a.recordBlockStorageResult(bs, node.Node.ID, bsType, fmt.Sprintf("scheduled to %s with score %d", node.Node.ID, score)); err != nil {
+		return err
+	}
+	node.Add(req)
+
+	a.logger.Info(
+		"scheduled blockstorage",
+		zap.String("group", bs.Group),
+		zap.String("namespace", bs.Namespace),
+		zap.String("id", bs.ID),
+		zap.String("type", bsType),
+		zap.String("node", node.Node.ID),
+		zap.Int64("score", score),
+		zap.Time("time", time.Now()),
+	)
+	return nil
+}
+
+// recordVirtualMachineResult はnodeNameと結果をannotationsに書き込む
+// 結果が変わっていない場合は更新しない
+func (a *SchedulerAgent) recordVirtualMachineResult(vm *system.VirtualMachine, nodeName, result string) error {
+	if nodeName == "" && vm.Annotations[VirtualMachineV0AnnotationScheduleResult] == result {
+		return nil
+	}
+
+	copied := *vm
+	copied.Annotations = copyAnnotations(vm.Annotations)
+	if nodeName != "" {
+		copied.Annotations[VirtualMachineV0AnnotationNodeName] = nodeName
+	}
+	copied.Annotations[VirtualMachineV0AnnotationScheduleResult] = result
+
+	// resourceVersionが古い場合は次の周期で割り当て直す
+	updated, err := a.client.SystemV0().VirtualMachine().Update(&copied)
+	if err != nil {
+		return err
+	}
+	*vm = *updated
+	return nil
+}
+
+// recordBlockStorageResult はnodeName, bsTypeと結果をannotationsに書き込む
+// 結果が変わっていない場合は更新しない
+func (a *SchedulerAgent) recordBlockStorageResult(bs *system.BlockStorage, nodeName, bsType, result string) error {
+	if nodeName == "" && bs.Annotations[BlockStorageV0AnnotationScheduleResult] == result {
+		return nil
+	}
+
+	copied := *bs
+	copied.Annotations = copyAnnotations(bs.Annotations)
+	if nodeName != "" {
+		copied.Annotations[BlockStorageV0AnnotationNodeName] = nodeName
+		copied.Annotations[BlockStorageV0AnnotationType] = bsType
+	}
+	copied.Annotations[BlockStorageV0AnnotationScheduleResult] = result
+
+	updated, err := a.client.SystemV0().BlockStorage().Update(&copied)
+	if err != nil {
+		return err
+	}
+	*bs = *updated
+	return nil
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	copied := map[string]string{}
+	for k, v := range annotations {
+		copied[k] = v
+	}
+	return copied
+}
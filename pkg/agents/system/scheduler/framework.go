@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+const (
+	// MaxNodeScore はScorePluginが返す最大のスコア
+	MaxNodeScore int64 = 100
+
+	// Unlimited はNodeのリソース量が設定されていないことを表す
+	Unlimited int64 = -1
+)
+
+// NodeInfo はスケジュールに使うNodeのリソース量
+// vcpusはミリ単位、memory, diskはバイト単位
+// diskはLocalのBlockStorageに使う量
+type NodeInfo struct {
+	Node *system.Node
+
+	AllocatableVcpus  int64
+	AllocatableMemory int64
+	AllocatableDisk   int64
+
+	RequestedVcpus  int64
+	RequestedMemory int64
+	RequestedDisk   int64
+
+	// FreeDisk はNodeのファイルシステムの空き容量から割り当てた分を引いた量
+	FreeDisk int64
+}
+
+// NewNodeInfo はNodeのSpecとStatusからNodeInfoを作成する
+// allocatableはagentが報告したstatus.allocatableを使い、ない場合はspecのlimitを使う
+// どちらも空の場合はUnlimitedにする
+func NewNodeInfo(node *system.Node) (*NodeInfo, error) {
+	info := &NodeInfo{
+		Node: node,
+	}
+
+	allocatable := node.Status.Allocatable
+	for _, q := range []struct {
+		value string
+		parse func(string) (int64, error)
+		v     *int64
+	}{
+		{firstNonEmpty(allocatable.Vcpus, node.Spec.LimitVcpus), quantity.ParseVcpus, &info.AllocatableVcpus},
+		{firstNonEmpty(allocatable.Memory, node.Spec.LimitMemory), quantity.ParseBytes, &info.AllocatableMemory},
+		{firstNonEmpty(allocatable.Disk, node.Spec.LimitDisk), quantity.ParseBytes, &info.AllocatableDisk},
+		{node.Status.FreeDisk, quantity.ParseBytes, &info.FreeDisk},
+	} {
+		*q.v = Unlimited
+		if q.value == "" {
+			continue
+		}
+		n, err := q.parse(q.value)
+		if err != nil {
+			return nil, err
+		}
+		*q.v = n
+	}
+
+	var err error
+	if node.Status.RequestedVcpus != "" {
+		if info.RequestedVcpus, err = quantity.ParseVcpus(node.Status.RequestedVcpus); err != nil {
+			return nil, err
+		}
+	}
+	if node.Status.RequestedMemory != "" {
+		if info.RequestedMemory, err = quantity.ParseBytes(node.Status.RequestedMemory); err != nil {
+			return nil, err
+		}
+	}
+	if node.Status.RequestedDisk != "" {
+		if info.RequestedDisk, err = quantity.ParseBytes(node.Status.RequestedDisk); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Add はreqをこのNodeに割り当てた分のリソースを加える
+func (n *NodeInfo) Add(req *Request) {
+	n.RequestedVcpus += req.Vcpus
+	n.RequestedMemory += req.Memory
+	n.RequestedDisk += req.Disk
+	if n.FreeDisk != Unlimited {
+		n.FreeDisk -= req.Disk
+	}
+}
+
+// Request はスケジュールするVirtualMachineまたはBlockStorage
+type Request struct {
+	// VirtualMachine はスケジュールするVirtualMachine
+	// BlockStorageをスケジュールする場合は参照しているVirtualMachine(ない場合はnil)
+	VirtualMachine *system.VirtualMachine
+	// BlockStorages はVirtualMachineが参照しているBlockStorage、またはスケジュールするBlockStorage
+	BlockStorages []*system.BlockStorage
+
+	// Vcpus はミリ単位、Memory, Disk はバイト単位のrequest
+	Vcpus  int64
+	Memory int64
+	Disk   int64
+}
+
+// NewRequest はVirtualMachineをスケジュールするRequestを作成する
+// まだNodeが決まっていないLocalのBlockStorageはVirtualMachineと同じNodeに配置されるのでDiskに含める
+func NewRequest(vm *system.VirtualMachine, bsList []*system.BlockStorage) (*Request, error) {
+	vcpus, err := quantity.ParseVcpus(vm.Spec.RequestVcpus)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := quantity.ParseBytes(vm.Spec.RequestMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	var disk int64
+	for _, bs := range bsList {
+		if bs.Annotations[BlockStorageV0AnnotationNodeName] != "" {
+			continue
+		}
+		size, err := localDiskSize(bs)
+		if err != nil {
+			return nil, err
+		}
+		disk += size
+	}
+
+	return &Request{
+		VirtualMachine: vm,
+		BlockStorages:  bsList,
+		Vcpus:          vcpus,
+		Memory:         memory,
+		Disk:           disk,
+	}, nil
+}
+
+// NewBlockStorageRequest はBlockStorageをスケジュールするRequestを作成する
+// vmはbsを参照しているVirtualMachineで、ない場合はnil
+func NewBlockStorageRequest(bs *system.BlockStorage, vm *system.VirtualMachine) (*Request, error) {
+	disk, err := localDiskSize(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		VirtualMachine: vm,
+		BlockStorages:  []*system.BlockStorage{bs},
+		Disk:           disk,
+	}, nil
+}
+
+// localDiskSize はbsがNodeのdiskを使う量を返す
+// CephのBlockStorageはNodeのdiskを使わないので0を返す
+func localDiskSize(bs *system.BlockStorage) (int64, error) {
+	if bs.Annotations[BlockStorageV0AnnotationType] == BlockStorageV0BlockStorageTypeCeph {
+		return 0, nil
+	}
+	return quantity.ParseBytes(bs.Spec.RequestSize)
+}
+
+// FilterPlugin はreqを割り当てられないNodeを除外する
+// 割り当てられない場合は理由をerrorで返す
+type FilterPlugin interface {
+	Name() string
+	Filter(req *Request, node *NodeInfo) error
+}
+
+// ScorePlugin はreqを割り当てるNodeの優先度を0からMaxNodeScoreで返す
+type ScorePlugin interface {
+	Name() string
+	Score(req *Request, node *NodeInfo) int64
+}
+
+// Framework はFilterPluginで残ったNodeの中からScorePluginの合計が最も高いNodeを選ぶ
+type Framework struct {
+	filters []FilterPlugin
+	scores  []ScorePlugin
+}
+
+func NewFramework(filters []FilterPlugin, scores []ScorePlugin) *Framework {
+	return &Framework{
+		filters: filters,
+		scores:  scores,
+	}
+}
+
+// NewDefaultFramework は標準のpluginを使うFrameworkを作成する
+func NewDefaultFramework() *Framework {
+	return NewFramework(
+		[]FilterPlugin{
+			&NodeReady{},
+			&NodeAgentMode{},
+			&NodeResourcesFit{},
+			&BlockStorageLocality{},
+			&VirtualMachineLocality{},
+			&NodeCephBackend{},
+		},
+		[]ScorePlugin{
+			&LeastAllocated{},
+		},
+	)
+}
+
+// UnschedulableError は割り当てられるNodeがない場合に返る
+type UnschedulableError struct {
+	NumNodes int
+	// Reasons はNodeのIDごとの除外された理由
+	Reasons map[string]string
+}
+
+func (e *UnschedulableError) Error() string {
+	ids := []string{}
+	for id := range e.Reasons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	reasons := []string{}
+	for _, id := range ids {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", id, e.Reasons[id]))
+	}
+	return fmt.Sprintf("0/%d nodes are available: %s", e.NumNodes, strings.Join(reasons, ", "))
+}
+
+// Schedule はreqを割り当てるNodeを選ぶ
+// スコアが同じ場合はIDの順番で先のNodeを選ぶ
+func (f *Framework) Schedule(req *Request, nodes []*NodeInfo) (*NodeInfo, int64, error) {
+	sorted := make([]*NodeInfo, len(nodes))
+	copy(sorted, nodes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Node.ID < sorted[j].Node.ID
+	})
+
+	unschedulable := &UnschedulableError{
+		NumNodes: len(nodes),
+		Reasons:  map[string]string{},
+	}
+	var best *NodeInfo
+	var bestScore int64
+	for _, node := range sorted {
+		if err := f.filter(req, node); err != nil {
+			unschedulable.Reasons[node.Node.ID] = err.Error()
+			continue
+		}
+
+		score := f.score(req, node)
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+
+	if best == nil {
+		return nil, 0, unschedulable
+	}
+	return best, bestScore, nil
+}
+
+func (f *Framework) filter(req *Request, node *NodeInfo) error {
+	for _, plugin := range f.filters {
+		if err := plugin.Filter(req, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Framework) score(req *Request, node *NodeInfo) int64 {
+	var score int64
+	for _, plugin := range f.scores {
+		s := plugin.Score(req, node)
+		if s < 0 {
+			s = 0
+		} else if s > MaxNodeScore {
+			s = MaxNodeScore
+		}
+		score += s
+	}
+	return score
+}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func newNode(t *testing.T, id, limitVcpus, limitMemory, requestedVcpus, requestedMemory string) *NodeInfo {
+	node, err := NewNodeInfo(&system.Node{
+		Meta: meta.Meta{
+			ID:          id,
+			Annotations: map[string]string{NodeAnnotationAgentMode: "System"},
+		},
+		Spec: system.NodeSpec{
+			LimitVcpus:  limitVcpus,
+			LimitMemory: limitMemory,
+		},
+		Status: system.NodeStatus{
+			State:           system.NodeStateReady,
+			RequestedVcpus:  requestedVcpus,
+			RequestedMemory: requestedMemory,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func newRequest(t *testing.T, vcpus, memory string, bsList ...*system.BlockStorage) *Request {
+	req, err := NewRequest(&system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1"},
+		Spec: system.VirtualMachineSpec{
+			RequestVcpus:  vcpus,
+			RequestMemory: memory,
+		},
+	}, bsList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func newBlockStorage(id, bsType, nodeName string) *system.BlockStorage {
+	return &system.BlockStorage{
+		Meta: meta.Meta{
+			ID: id,
+			Annotations: map[string]string{
+				BlockStorageV0AnnotationType:     bsType,
+				BlockStorageV0AnnotationNodeName: nodeName,
+			},
+		},
+		Spec: system.BlockStorageSpec{
+			RequestSize: "1G",
+		},
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	notReady := newNode(t, "node4", "8", "8G", "", "")
+	notReady.Node.Status.State = system.NodeStateNotReady
+	coreOnly := newNode(t, "node5", "8", "8G", "", "")
+	coreOnly.Node.Annotations[NodeAnnotationAgentMode] = "Core"
+
+	nodes := []*NodeInfo{
+		newNode(t, "node1", "4000m", "8G", "3000m", "2G"),
+		newNode(t, "node2", "8", "8G", "2000m", "4G"),
+		newNode(t, "node3", "8", "16G", "", ""),
+		notReady,
+		coreOnly,
+	}
+
+	tests := []struct {
+		name      string
+		req       *Request
+		want      string
+		wantError string
+	}{
+		{
+			name: "least allocated",
+			req:  newRequest(t, "1000m", "1G"),
+			want: "node3",
+		},
+		{
+			name: "local blockstorage",
+			req:  newRequest(t, "1000m", "1G", newBlockStorage("bs1", "Local", "node2")),
+			want: "node2",
+		},
+		{
+			name: "ceph and unscheduled blockstorage",
+			req: newRequest(t, "1000m", "1G",
+				newBlockStorage("bs1", "Ceph", "node1"),
+				newBlockStorage("bs2", "", ""),
+			),
+			want: "node3",
+		},
+		{
+			name: "insufficient resources",
+			req:  newRequest(t, "7000m", "1G", newBlockStorage("bs1", "Local", "node2")),
+			wantError: "0/5 nodes are available: node1: insufficient vcpus, node2: insufficient vcpus, " +
+				"node3: blockstorage `bs1` is on node `node2`, node4: node is not ready, node5: node does not run system agents",
+		},
+	}
+
+	framework := NewDefaultFramework()
+	for _, test := range tests {
+		node, _, err := framework.Schedule(test.req, nodes)
+		if test.wantError != "" {
+			var unschedulable *UnschedulableError
+			if !errors.As(err, &unschedulable) {
+				t.Fatalf("%s: want: UnschedulableError, got: %v", test.name, err)
+			}
+			if err.Error() != test.wantError {
+				t.Fatalf("%s: want: %s, got: %s", test.name, test.wantError, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if node.Node.ID != test.want {
+			t.Fatalf("%s: want: %s, got: %s", test.name, test.want, node.Node.ID)
+		}
+	}
+}
+
+func TestScheduleAddsRequests(t *testing.T) {
+	nodes := []*NodeInfo{
+		newNode(t, "node1", "2", "4G", "", ""),
+		newNode(t, "node2", "2", "4G", "", ""),
+	}
+
+	// 割り当てたリソースを加えると次は空いている方のNodeが選ばれる
+	framework := NewDefaultFramework()
+	got := []string{}
+	for i := 0; i < 4; i++ {
+		req := newRequest(t, "1000m", "1G")
+		node, _, err := framework.Schedule(req, nodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.Add(req)
+		got = append(got, node.Node.ID)
+	}
+	want := []string{"node1", "node2", "node1", "node2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want: %v, got: %v", want, got)
+		}
+	}
+
+	if _, _, err := framework.Schedule(newRequest(t, "1000m", "1G"), nodes); err == nil {
+		t.Fatal("want: error, got: nil")
+	}
+}
+
+func TestScheduleBlockStorage(t *testing.T) {
+	withDisk := func(node *NodeInfo, limitDisk, requestedDisk string) *NodeInfo {
+		node.Node.Spec.LimitDisk = limitDisk
+		node.Node.Status.RequestedDisk = requestedDisk
+		info, err := NewNodeInfo(node.Node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	cephNode := withDisk(newNode(t, "node3", "8", "8G", "", ""), "10G", "9G")
+	cephNode.Node.Annotations[NodeAnnotationCephBackend] = "true"
+
+	nodes := []*NodeInfo{
+		withDisk(newNode(t, "node1", "8", "8G", "", ""), "100G", "90G"),
+		withDisk(newNode(t, "node2", "8", "8G", "", ""), "100G", "20G"),
+		cephNode,
+	}
+
+	newBS := func(bsType, size string) *system.BlockStorage {
+		bs := newBlockStorage("bs1", bsType, "")
+		bs.Spec.RequestSize = size
+		return bs
+	}
+	newVM := func(nodeName string) *system.VirtualMachine {
+		return &system.VirtualMachine{
+			Meta: meta.Meta{
+				ID:          "vm1",
+				Annotations: map[string]string{VirtualMachineV0AnnotationNodeName: nodeName},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		bs        *system.BlockStorage
+		vm        *system.VirtualMachine
+		want      string
+		wantError string
+	}{
+		{
+			name: "most free disk",
+			bs:   newBS("", "10G"),
+			want: "node2",
+		},
+		{
+			name: "same node as virtualmachine",
+			bs:   newBS("Local", "10G"),
+			vm:   newVM("node1"),
+			want: "node1",
+		},
+		{
+			name: "ceph does not use local disk",
+			bs:   newBS("Ceph", "100G"),
+			want: "node3",
+		},
+		{
+			name: "insufficient disk",
+			bs:   newBS("Local", "11G"),
+			vm:   newVM("node1"),
+			wantError: "0/3 nodes are available: node1: insufficient disk, " +
+				"node2: virtualmachine `vm1` is on node `node1`, node3: insufficient disk",
+		},
+	}
+
+	framework := NewDefaultFramework()
+	for _, test := range tests {
+		req, err := NewBlockStorageRequest(test.bs, test.vm)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		node, _, err := framework.Schedule(req, nodes)
+		if test.wantError != "" {
+			if err == nil || err.Error() != test.wantError {
+				t.Fatalf("%s: want: %s, got: %v", test.name, test.wantError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if node.Node.ID != test.want {
+			t.Fatalf("%s: want: %s, got: %s", test.name, test.want, node.Node.ID)
+		}
+	}
+}
+
+func TestScheduleAllocatable(t *testing.T) {
+	// agentが報告したallocatableはspecのlimitより優先する
+	overcommitted := newNode(t, "node1", "4", "8G", "6000m", "")
+	overcommitted.Node.Status.Allocatable = system.NodeResources{Vcpus: "16000m", Memory: "8G"}
+	noFreeDisk := newNode(t, "node2", "8", "8G", "", "")
+	noFreeDisk.Node.Status.FreeDisk = "5G"
+
+	nodes := []*NodeInfo{}
+	for _, node := range []*NodeInfo{overcommitted, noFreeDisk} {
+		info, err := NewNodeInfo(node.Node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, info)
+	}
+
+	framework := NewDefaultFramework()
+	node, _, err := framework.Schedule(newRequest(t, "8000m", "1G"), nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Node.ID != "node1" {
+		t.Fatalf("want: node1, got: %s", node.Node.ID)
+	}
+
+	bs := newBlockStorage("bs1", "", "")
+	bs.Spec.RequestSize = "10G"
+	_, _, err = framework.Schedule(newRequest(t, "1000m", "1G", bs), nodes[1:])
+	want := "0/1 nodes are available: node2: insufficient free disk"
+	if err == nil || err.Error() != want {
+		t.Fatalf("want: %s, got: %v", want, err)
+	}
+}
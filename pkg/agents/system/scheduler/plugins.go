@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+const (
+	BlockStorageV0AnnotationType     = "blockstoragev0/type"
+	BlockStorageV0AnnotationNodeName = "blockstoragev0/node_name"
+
+	BlockStorageV0BlockStorageTypeLocal = "Local"
+	BlockStorageV0BlockStorageTypeCeph  = "Ceph"
+
+	// NodeAnnotationAgentMode はagentが起動時に設定するagentのモード
+	NodeAnnotationAgentMode = "agentMode"
+	// NodeAnnotationCephBackend はCephのBlockStorageを扱えるNodeに"true"が設定される
+	NodeAnnotationCephBackend = "cephBackend"
+)
+
+// NodeReady はReadyでないNodeを除外する
+type NodeReady struct{}
+
+func (p *NodeReady) Name() string {
+	return "NodeReady"
+}
+
+func (p *NodeReady) Filter(req *Request, node *NodeInfo) error {
+	if node.Node.Status.State != system.NodeStateReady {
+		return fmt.Errorf("node is not ready")
+	}
+	return nil
+}
+
+// NodeAgentMode はVirtualMachineを起動するSystemのagentが動いていないNodeを除外する
+type NodeAgentMode struct{}
+
+func (p *NodeAgentMode) Name() string {
+	return "NodeAgentMode"
+}
+
+func (p *NodeAgentMode) Filter(req *Request, node *NodeInfo) error {
+	switch node.Node.Annotations[NodeAnnotationAgentMode] {
+	// agentModeがない場合は以前のagentなので除外しない
+	case "", "System", "All":
+		return nil
+	}
+	return fmt.Errorf("node does not run system agents")
+}
+
+// NodeResourcesFit はrequestのvcpus, memory, diskが空いていないNodeを除外する
+type NodeResourcesFit struct{}
+
+func (p *NodeResourcesFit) Name() string {
+	return "NodeResourcesFit"
+}
+
+func (p *NodeResourcesFit) Filter(req *Request, node *NodeInfo) error {
+	if node.AllocatableVcpus != Unlimited && node.RequestedVcpus+req.Vcpus > node.AllocatableVcpus {
+		return fmt.Errorf("insufficient vcpus")
+	}
+	if node.AllocatableMemory != Unlimited && node.RequestedMemory+req.Memory > node.AllocatableMemory {
+		return fmt.Errorf("insufficient memory")
+	}
+	if node.AllocatableDisk != Unlimited && node.RequestedDisk+req.Disk > node.AllocatableDisk {
+		return fmt.Errorf("insufficient disk")
+	}
+	// thinで作成したBlockStorageがあるとrequestedより先に空き容量がなくなることがある
+	if node.FreeDisk != Unlimited && req.Disk > node.FreeDisk {
+		return fmt.Errorf("insufficient free disk")
+	}
+	return nil
+}
+
+// BlockStorageLocality はLocalのBlockStorageが配置されていないNodeを除外する
+// まだNodeが決まっていないBlockStorageとCephのBlockStorageはどのNodeからでも使える
+type BlockStorageLocality struct{}
+
+func (p *BlockStorageLocality) Name() string {
+	return "BlockStorageLocality"
+}
+
+func (p *BlockStorageLocality) Filter(req *Request, node *NodeInfo) error {
+	for _, bs := range req.BlockStorages {
+		if bs.Annotations[BlockStorageV0AnnotationType] == BlockStorageV0BlockStorageTypeCeph {
+			continue
+		}
+		nodeName := bs.Annotations[BlockStorageV0AnnotationNodeName]
+		if nodeName != "" && nodeName != node.Node.ID {
+			return fmt.Errorf("blockstorage `%s` is on node `%s`", bs.ID, nodeName)
+		}
+	}
+	return nil
+}
+
+// VirtualMachineLocality はBlockStorageを参照しているVirtualMachineと違うNodeを除外する
+type VirtualMachineLocality struct{}
+
+func (p *VirtualMachineLocality) Name() string {
+	return "VirtualMachineLocality"
+}
+
+func (p *VirtualMachineLocality) Filter(req *Request, node *NodeInfo) error {
+	if req.VirtualMachine == nil {
+		return nil
+	}
+	nodeName := req.VirtualMachine.Annotations[VirtualMachineV0AnnotationNodeName]
+	if nodeName != "" && nodeName != node.Node.ID {
+		return fmt.Errorf("virtualmachine `%s` is on node `%s`", req.VirtualMachine.ID, nodeName)
+	}
+	return nil
+}
+
+// NodeCephBackend はまだNodeが決まっていないCephのBlockStorageを扱えないNodeを除外する
+type NodeCephBackend struct{}
+
+func (p *NodeCephBackend) Name() string {
+	return "NodeCephBackend"
+}
+
+func (p *NodeCephBackend) Filter(req *Request, node *NodeInfo) error {
+	for _, bs := range req.BlockStorages {
+		if bs.Annotations[BlockStorageV0AnnotationType] != BlockStorageV0BlockStorageTypeCeph ||
+			bs.Annotations[BlockStorageV0AnnotationNodeName] != "" {
+			continue
+		}
+		if node.Node.Annotations[NodeAnnotationCephBackend] != "true" {
+			return fmt.Errorf("node does not have ceph backend")
+		}
+	}
+	return nil
+}
+
+// LeastAllocated は割り当てた後の空きリソースの割合が大きいNodeを優先する
+type LeastAllocated struct{}
+
+func (p *LeastAllocated) Name() string {
+	return "LeastAllocated"
+}
+
+func (p *LeastAllocated) Score(req *Request, node *NodeInfo) int64 {
+	vcpus := freeRatioScore(node.AllocatableVcpus, node.RequestedVcpus+req.Vcpus)
+	memory := freeRatioScore(node.AllocatableMemory, node.RequestedMemory+req.Memory)
+	disk := freeRatioScore(node.AllocatableDisk, node.RequestedDisk+req.Disk)
+	return (vcpus + memory + disk) / 3
+}
+
+func freeRatioScore(allocatable, requested int64) int64 {
+	if allocatable == Unlimited {
+		return MaxNodeScore
+	}
+	if allocatable <= 0 || requested >= allocatable {
+		return 0
+	}
+	return (allocatable - requested) * MaxNodeScore / allocatable
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"github.com/ophum/humstack/pkg/utils/cloudinit"
+	"github.com/ophum/humstack/pkg/utils/quantity"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -52,34 +53,21 @@ func NewVirtualMachineAgent(client *client.Clients, logger *zap.Logger) *Virtual
 	}
 }
 
-func (a *VirtualMachineAgent) syncVNCDisplayNumber(grList []*core.Group) error {
-	usedDisplayMap := map[int32]bool{}
-	for _, group := range grList {
-		nsList, err := a.client.CoreV0().Namespace().List(group.ID)
-		if err != nil {
-			return errors.Wrap(err, "syncVNCDisplayNumber() get ns list")
-		}
+// nodeFieldSelector はこのノードに割り当てられたVMのみを取得するためのfieldSelector
+func (a *VirtualMachineAgent) nodeFieldSelector() string {
+	return fmt.Sprintf("metadata.annotations[%s]=%s", VirtualMachineV0AnnotationNodeName, a.nodeName)
+}
 
-		for _, ns := range nsList {
-			vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
+func (a *VirtualMachineAgent) syncVNCDisplayNumber(vmList []*system.VirtualMachine) error {
+	usedDisplayMap := map[int32]bool{}
+	for _, vm := range vmList {
+		// 使用しているVNCディスプレイ番号のmapを作成
+		if _, ok := vm.Annotations["virtualmachinev0/vnc_display_number"]; ok {
+			usedDisplayNumber, err := strconv.ParseInt(vm.Annotations["virtualmachinev0/vnc_display_number"], 10, 32)
 			if err != nil {
-				return errors.Wrap(err, "syncVNCDisplayNumber() get vmList")
+				return errors.Wrap(err, "parse int used dispaly number")
 			}
-
-			for _, vm := range vmList {
-				if vm.Annotations[VirtualMachineV0AnnotationNodeName] != a.nodeName {
-					continue
-				}
-				// 使用しているVNCディスプレイ番号のmapを作成
-				if _, ok := vm.Annotations["virtualmachinev0/vnc_display_number"]; ok {
-					usedDisplayNumber, err := strconv.ParseInt(vm.Annotations["virtualmachinev0/vnc_display_number"], 10, 32)
-					if err != nil {
-						return errors.Wrap(err, "parse int used dispaly number")
-					}
-					usedDisplayMap[int32(usedDisplayNumber)] = true
-				}
-			}
-
+			usedDisplayMap[int32(usedDisplayNumber)] = true
 		}
 	}
 	// 使用VNCディスプレイ番号の情報をsync
@@ -106,10 +94,11 @@ func (a *VirtualMachineAgent) Run(pollingDuration time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			// 全てのグループ・ネームスペースからこのノードのVMのみを取得する
+			vmList, err := a.client.SystemV0().VirtualMachine().ListInCluster("", a.nodeFieldSelector())
 			if err != nil {
 				a.logger.Error(
-					"get group list",
+					"get virtualmachine list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
@@ -117,7 +106,7 @@ func (a *VirtualMachineAgent) Run(pollingDuration time.Duration) {
 			}
 
 			// 先にvnc番号を取得しておく
-			if err := a.syncVNCDisplayNumber(grList); err != nil {
+			if err := a.syncVNCDisplayNumber(vmList); err != nil {
 				a.logger.Error(
 					"sync VNC Display Number",
 					zap.String("msg", err.Error()),
@@ -126,93 +115,72 @@ func (a *VirtualMachineAgent) Run(pollingDuration time.Duration) {
 				continue
 			}
 
-			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+			if len(vmList) == 0 {
+				continue
+			}
+
+			bsList, err := a.client.SystemV0().BlockStorage().ListInCluster("", "")
+			if err != nil {
+				a.logger.Error(
+					"get bs list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+			bsMap := createBSMap(bsList)
+
+			netList, err := a.client.CoreV0().Network().ListInCluster("", "")
+			if err != nil {
+				a.logger.Error(
+					"get network list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+			netMap := createNetMap(netList)
+
+			nodeNetList, err := a.client.SystemV0().NodeNetwork().ListInCluster("",
+				fmt.Sprintf("metadata.annotations[nodenetworkv0/node_name]=%s", a.nodeName))
+			if err != nil {
+				a.logger.Error(
+					"get nodenetwork list",
+					zap.String("msg", err.Error()),
+					zap.Time("time", time.Now()),
+				)
+				continue
+			}
+			nodeNetMap := createNodeNetMap(nodeNetList)
+
+			for _, vm := range vmList {
+				oldHash := vm.ResourceHash
+
+				nsKey := namespaceKey(vm.Group, vm.Namespace)
+				err = a.syncVirtualMachine(vm, bsMap[nsKey], netMap[nsKey], nodeNetMap[nsKey])
 				if err != nil {
 					a.logger.Error(
-						"get namespace list",
+						"sync virtualmachine",
 						zap.String("msg", err.Error()),
 						zap.Time("time", time.Now()),
 					)
 					continue
 				}
 
-				for _, ns := range nsList {
-					vmList, err := a.client.SystemV0().VirtualMachine().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get virtualmachine list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
-					bsList, err := a.client.SystemV0().BlockStorage().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get bs list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
-					bsMap := createBSMap(bsList)
-
-					netList, err := a.client.CoreV0().Network().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get bs list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
-					netMap := createNetMap(netList)
-
-					nodeNetList, err := a.client.SystemV0().NodeNetwork().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get bs list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
-					nodeNetMap := createNodeNetMap(nodeNetList)
-
-					for _, vm := range vmList {
-						oldHash := vm.ResourceHash
-						if vm.Annotations[VirtualMachineV0AnnotationNodeName] != a.nodeName {
-							continue
-						}
-
-						err = a.syncVirtualMachine(vm, bsMap, netMap, nodeNetMap)
-						if err != nil {
-							a.logger.Error(
-								"sync virtualmachine",
-								zap.String("msg", err.Error()),
-								zap.Time("time", time.Now()),
-							)
-							continue
-						}
-
-						if vm.ResourceHash == oldHash {
-							continue
-						}
-
-						_, err := a.client.SystemV0().VirtualMachine().Update(vm)
-						if err != nil {
-							a.logger.Error(
-								"update virtualmachine",
-								zap.String("msg", err.Error()),
-								zap.Time("time", time.Now()),
-							)
-							continue
-						}
-					}
+				if vm.ResourceHash == oldHash {
+					continue
 				}
-			}
 
+				_, err := a.client.SystemV0().VirtualMachine().UpdateStatus(vm)
+				if err != nil {
+					a.logger.Error(
+						"update virtualmachine",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
+				}
+			}
 		}
 	}
 }
@@ -225,7 +193,7 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	if pid == -1 {
 		if vm.Status.State != system.VirtualMachineStateStopped {
 			vm.Status.State = system.VirtualMachineStateStopped
-			_, err := a.client.SystemV0().VirtualMachine().Update(vm)
+			_, err := a.client.SystemV0().VirtualMachine().UpdateStatus(vm)
 			if err != nil {
 				return err
 			}
@@ -234,7 +202,7 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	}
 
 	vm.Status.State = system.VirtualMachineStateStopping
-	_, err = a.client.SystemV0().VirtualMachine().Update(vm)
+	_, err = a.client.SystemV0().VirtualMachine().UpdateStatus(vm)
 	if err != nil {
 		return err
 	}
@@ -253,30 +221,50 @@ func (a *VirtualMachineAgent) powerOffVirtualMachine(vm *system.VirtualMachine)
 	displayNumber, err := strconv.ParseInt(displayNumberString, 10, 64)
 	delete(a.vncDisplayMap, int32(displayNumber))
 	vm.Status.State = system.VirtualMachineStateStopped
-	_, err = a.client.SystemV0().VirtualMachine().Update(vm)
+	_, err = a.client.SystemV0().VirtualMachine().UpdateStatus(vm)
 	return err
 }
 
-func createBSMap(bsList []*system.BlockStorage) map[string]system.BlockStorage {
-	m := map[string]system.BlockStorage{}
+// namespaceKey はグループ・ネームスペースごとにリソースをまとめるためのkey
+func namespaceKey(groupID, namespaceID string) string {
+	return filepath.Join(groupID, namespaceID)
+}
+
+// createBSMap はグループ・ネームスペースごとのBlockStorageのmapを作成する
+func createBSMap(bsList []*system.BlockStorage) map[string]map[string]system.BlockStorage {
+	m := map[string]map[string]system.BlockStorage{}
 	for _, bs := range bsList {
-		m[bs.ID] = *bs
+		nsKey := namespaceKey(bs.Group, bs.Namespace)
+		if _, ok := m[nsKey]; !ok {
+			m[nsKey] = map[string]system.BlockStorage{}
+		}
+		m[nsKey][bs.ID] = *bs
 	}
 	return m
 }
 
-func createNetMap(netList []*core.Network) map[string]core.Network {
-	m := map[string]core.Network{}
+// createNetMap はグループ・ネームスペースごとのNetworkのmapを作成する
+func createNetMap(netList []*core.Network) map[string]map[string]core.Network {
+	m := map[string]map[string]core.Network{}
 	for _, net := range netList {
-		m[net.ID] = *net
+		nsKey := namespaceKey(net.Group, net.Namespace)
+		if _, ok := m[nsKey]; !ok {
+			m[nsKey] = map[string]core.Network{}
+		}
+		m[nsKey][net.ID] = *net
 	}
 	return m
 }
 
-func createNodeNetMap(nodeNetList []*system.NodeNetwork) map[string]system.NodeNetwork {
-	m := map[string]system.NodeNetwork{}
+// createNodeNetMap はグループ・ネームスペースごとのNodeNetworkのmapを作成する
+func createNodeNetMap(nodeNetList []*system.NodeNetwork) map[string]map[string]system.NodeNetwork {
+	m := map[string]map[string]system.NodeNetwork{}
 	for _, nodeNet := range nodeNetList {
-		m[nodeNet.ID] = *nodeNet
+		nsKey := namespaceKey(nodeNet.Group, nodeNet.Namespace)
+		if _, ok := m[nsKey]; !ok {
+			m[nsKey] = map[string]system.NodeNetwork{}
+		}
+		m[nsKey][nodeNet.ID] = *nodeNet
 	}
 	return m
 }
@@ -297,7 +285,7 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(
 		// stateがRunning以外ならRunningにする
 		if vm.Status.State != system.VirtualMachineStateRunning {
 			vm.Status.State = system.VirtualMachineStateRunning
-			if _, err := a.client.SystemV0().VirtualMachine().Update(vm); err != nil {
+			if _, err := a.client.SystemV0().VirtualMachine().UpdateStatus(vm); err != nil {
 				return errors.Wrap(err, "update vm state")
 			}
 		}
@@ -306,7 +294,7 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(
 
 	if vm.Status.State != system.VirtualMachineStatePending {
 		vm.Status.State = system.VirtualMachineStatePending
-		if _, err = a.client.SystemV0().VirtualMachine().Update(vm); err != nil {
+		if _, err = a.client.SystemV0().VirtualMachine().UpdateStatus(vm); err != nil {
 			return err
 		}
 	}
@@ -342,23 +330,37 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(
 				fmt.Sprintf("file=rbd:%s,format=qcow2", filepath.Join(cephPoolName, cephImageName)),
 			)
 		} else { // Cephでない場合はLocalになる
+			// StorageClassでディレクトリが指定されている場合はそこに作られている
+			dirPath := "./blockstorages"
+			if bs.Spec.StorageClassName != "" {
+				sc, err := a.client.SystemV0().StorageClass().Get(bs.Spec.StorageClassName)
+				if err != nil {
+					return err
+				}
+				if sc.Spec.Local.DirPath != "" {
+					dirPath = sc.Spec.Local.DirPath
+				}
+			}
 			disks = append(disks,
 				"-drive",
-				fmt.Sprintf("file=./blockstorages/%s/%s/%s,format=qcow2", vm.Group, vm.Namespace, bs.ID),
+				fmt.Sprintf("file=%s,format=qcow2", filepath.Join(dirPath, vm.Group, vm.Namespace, bs.ID)),
 			)
 		}
 	}
 
-	vcpus := withUnitToWithoutUnit(vm.Spec.LimitVcpus)
-	vcpusInt, err := strconv.ParseInt(vcpus, 10, 64)
+	limitVcpus, err := quantity.ParseVcpus(vm.Spec.LimitVcpus)
 	if err != nil {
 		return err
 	}
+	// ミリ単位なのでコア数にする
+	vcpusInt := limitVcpus / 1000
+	vcpus := strconv.FormatInt(vcpusInt, 10)
 	nics := []string{}
 	tapNames := []string{}
 	brNames := []string{}
 	usedNetdevIDs := map[string]struct{}{}
 	for i, nic := range vm.Spec.NICs {
+		// MACアドレスとUUIDは作成時にapiserverで設定されるが、それ以前に作成されたものは起動時に決める
 		if nic.MacAddress == "" {
 			nic.MacAddress = generateMacAddress(vm.ID + nic.NetworkID)
 		}
@@ -417,6 +419,10 @@ func (a *VirtualMachineAgent) powerOnVirtualMachine(
 		}
 
 		vm.Spec.UUID = id.String()
+		// UUIDはspecなのでstatusとは別に保存する
+		if _, err := a.client.SystemV0().VirtualMachine().Update(vm); err != nil {
+			return err
+		}
 	}
 
 	metaData := cloudinit.MetaData{
@@ -701,7 +707,11 @@ func (a *VirtualMachineAgent) getNodeNetwork(nodeNetMap map[string]system.NodeNe
 
 func setHash(vm *system.VirtualMachine) error {
 	vm.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := vm.ResourceVersion
+	vm.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(vm)
+	vm.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
@@ -711,37 +721,6 @@ func setHash(vm *system.VirtualMachine) error {
 	return nil
 }
 
-const (
-	UnitGigabyte = 'G'
-	UnitMegabyte = 'M'
-	UnitKilobyte = 'K'
-	UnitMilli    = 'm'
-)
-
-func withUnitToWithoutUnit(numberWithUnit string) string {
-	length := len(numberWithUnit)
-	if numberWithUnit[length-1] >= '0' && numberWithUnit[length-1] <= '9' {
-		return numberWithUnit
-	}
-
-	number, err := strconv.ParseInt(numberWithUnit[:length-1], 10, 64)
-	if err != nil {
-		return "0"
-	}
-
-	switch numberWithUnit[length-1] {
-	case UnitGigabyte:
-		return fmt.Sprintf("%d", number*1024*1024*1024)
-	case UnitMegabyte:
-		return fmt.Sprintf("%d", number*1024*1024)
-	case UnitKilobyte:
-		return fmt.Sprintf("%d", number*1024)
-	case UnitMilli:
-		return fmt.Sprintf("%d", number/1000)
-	}
-	return "0"
-}
-
 func generateMacAddress(id string) string {
 	addr := "52:54"
 	for i := 0; i < 4; i++ {
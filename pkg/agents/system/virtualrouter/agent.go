@@ -57,68 +57,43 @@ func (a *VirtualRouterAgent) Run(pollingDuration time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			grList, err := a.client.CoreV0().Group().List()
+			// 全てのグループ・ネームスペースからこのノードのVirtualRouterのみを取得する
+			vrList, err := a.client.SystemV0().VirtualRouter().ListInCluster("",
+				fmt.Sprintf("metadata.annotations[%s]=%s", VirtualRouterV0AnnotationNodeName, nodeName))
 			if err != nil {
 				a.logger.Error(
-					"get group list",
+					"get virtualrouter list",
 					zap.String("msg", err.Error()),
 					zap.Time("time", time.Now()),
 				)
 				continue
 			}
 
-			for _, group := range grList {
-				nsList, err := a.client.CoreV0().Namespace().List(group.ID)
+			for _, vr := range vrList {
+				oldHash := vr.ResourceHash
+
+				err = a.syncVirtualRouter(vr)
 				if err != nil {
 					a.logger.Error(
-						"get namespace list",
+						"sync virtualrouter",
 						zap.String("msg", err.Error()),
 						zap.Time("time", time.Now()),
 					)
 					continue
 				}
 
-				for _, ns := range nsList {
-					vrList, err := a.client.SystemV0().VirtualRouter().List(group.ID, ns.ID)
-					if err != nil {
-						a.logger.Error(
-							"get virtualrouter list",
-							zap.String("msg", err.Error()),
-							zap.Time("time", time.Now()),
-						)
-						continue
-					}
-
-					for _, vr := range vrList {
-						oldHash := vr.ResourceHash
-						if vr.Annotations[VirtualRouterV0AnnotationNodeName] != nodeName {
-							continue
-						}
-
-						err = a.syncVirtualRouter(vr)
-						if err != nil {
-							a.logger.Error(
-								"sync virtualrouter",
-								zap.String("msg", err.Error()),
-								zap.Time("time", time.Now()),
-							)
-							continue
-						}
-
-						if vr.ResourceHash == oldHash {
-							continue
-						}
-
-						_, err := a.client.SystemV0().VirtualRouter().Update(vr)
-						if err != nil {
-							a.logger.Error(
-								"update virtualrouter",
-								zap.String("msg", err.Error()),
-								zap.Time("time", time.Now()),
-							)
-							continue
-						}
-					}
+				if vr.ResourceHash == oldHash {
+					continue
+				}
+
+				_, err := a.client.SystemV0().VirtualRouter().UpdateStatus(vr)
+				if err != nil {
+					a.logger.Error(
+						"update virtualrouter",
+						zap.String("msg", err.Error()),
+						zap.Time("time", time.Now()),
+					)
+					continue
 				}
 			}
 		}
@@ -290,7 +265,11 @@ func netnsExec(name string, command []string) error {
 
 func setHash(vr *system.VirtualRouter) error {
 	vr.ResourceHash = ""
+	// resourceVersionは書き込みのたびに変わるのでhashに含めない
+	resourceVersion := vr.ResourceVersion
+	vr.ResourceVersion = 0
 	resourceJSON, err := json.Marshal(vr)
+	vr.ResourceVersion = resourceVersion
 	if err != nil {
 		return err
 	}
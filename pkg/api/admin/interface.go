@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandlerInterface interface {
+	Backup(ctx *gin.Context)
+	Restore(ctx *gin.Context)
+}
+
+type AdminHandler struct {
+	router *gin.RouterGroup
+	ahi    AdminHandlerInterface
+}
+
+const (
+	basePath = "admin"
+)
+
+func NewAdminHandler(router *gin.RouterGroup, ahi AdminHandlerInterface) *AdminHandler {
+	return &AdminHandler{
+		router: router,
+		ahi:    ahi,
+	}
+}
+
+func (h *AdminHandler) RegisterHandlers() {
+	ad := h.router.Group(basePath)
+	{
+		ad.GET("/backup", h.ahi.Backup)
+		ad.POST("/restore", h.ahi.Restore)
+	}
+}
@@ -0,0 +1,20 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/ophum/humstack/pkg/store"
+)
+
+const (
+	// ArchiveVersionV0 はArchiveの形式のバージョン
+	ArchiveVersionV0 = "v0"
+)
+
+// Archive はapiserverのstoreのバックアップ
+// 全てのkeyとそのAPITypeを含むので、別のapiserverにそのままリストアできる
+type Archive struct {
+	Version   string         `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Snapshot  store.Snapshot `json:"snapshot"`
+}
@@ -0,0 +1,112 @@
+package v0
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// AdminStore はバックアップとリストアに使うstore
+type AdminStore interface {
+	Snapshot() (*store.Snapshot, error)
+	Restore(snapshot *store.Snapshot) error
+	Revision() int64
+}
+
+type AdminHandler struct {
+	admin.AdminHandlerInterface
+
+	store AdminStore
+}
+
+func NewAdminHandler(store AdminStore) *AdminHandler {
+	return &AdminHandler{
+		store: store,
+	}
+}
+
+// Backup はある時点の全てのデータをArchiveとして返す
+// leveldbのsnapshotから読み込むので、apiserverを止めずに一貫したバックアップが取れる
+func (h *AdminHandler) Backup(ctx *gin.Context) {
+	snapshot, err := h.store.Snapshot()
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"archive": admin.Archive{
+			Version:   admin.ArchiveVersionV0,
+			CreatedAt: time.Now(),
+			Snapshot:  *snapshot,
+		},
+	})
+}
+
+// bootstrapKeyPrefixes は起動時のadminユーザーの作成とloginで書き込まれるkey
+// リストアにはadminのトークンが必要なので、これらのkeyしかない場合は空のstoreとみなす
+var bootstrapKeyPrefixes = []string{"user/", "rolebinding/", "token/"}
+
+// Restore はArchiveを空のstoreに読み込む
+// 既存のデータを誤って消さないように、adminユーザーとトークン以外のデータがある場合は409を返す
+// ユーザーとトークンもArchiveの内容で置き換えるので、リストア後はArchiveのユーザーでloginし直す
+func (h *AdminHandler) Restore(ctx *gin.Context) {
+	var archive admin.Archive
+	err := ctx.Bind(&archive)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if archive.Version != admin.ArchiveVersionV0 {
+		meta.ResponseJSON(ctx, http.StatusBadRequest,
+			fmt.Errorf("Error: unsupported archive version `%s`.", archive.Version), nil)
+		return
+	}
+	for _, entry := range archive.Snapshot.Entries {
+		if entry.Key == "" || len(entry.Value) == 0 {
+			meta.ResponseJSON(ctx, http.StatusBadRequest,
+				fmt.Errorf("Error: archive has an invalid entry `%s`.", entry.Key), nil)
+			return
+		}
+	}
+
+	current, err := h.store.Snapshot()
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	for _, entry := range current.Entries {
+		if !isBootstrapKey(entry.Key) {
+			meta.ResponseJSON(ctx, http.StatusConflict,
+				fmt.Errorf("Error: store is not empty. restore can only be run on an empty store. `%s` exists.", entry.Key), nil)
+			return
+		}
+	}
+
+	if err := h.store.Restore(&archive.Snapshot); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// revisionはArchiveのrevisionから戻さずに進めるので、リストア後のrevisionを返す
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"revision": h.store.Revision(),
+		"entries":  len(archive.Snapshot.Entries),
+	})
+}
+
+func isBootstrapKey(key string) bool {
+	for _, prefix := range bootstrapKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
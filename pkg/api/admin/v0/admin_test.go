@@ -0,0 +1,130 @@
+package v0_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admin"
+	adminv0 "github.com/ophum/humstack/pkg/api/admin/v0"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+type data struct {
+	meta.Meta `json:"meta"`
+	Data      string `json:"data"`
+}
+
+func newRouter(s adminv0.AdminStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	admin.NewAdminHandler(r.Group("/api/v0"), adminv0.NewAdminHandler(s)).RegisterHandlers()
+	return r
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := leveldb.NewLevelDBStore(filepath.Join(dir, "src"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := leveldb.NewLevelDBStore(filepath.Join(dir, "dst"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	src.Put("core/network/a", &data{Meta: meta.Meta{ID: "a", APIType: meta.APITypeNetworkV0}, Data: "a"})
+	src.Put("core/network/b", &data{Meta: meta.Meta{ID: "b", APIType: meta.APITypeNetworkV0}, Data: "b"})
+
+	w := httptest.NewRecorder()
+	newRouter(src).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v0/admin/backup", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d %s", w.Code, w.Body.String())
+	}
+
+	backupResp := struct {
+		Data struct {
+			Archive admin.Archive `json:"archive"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &backupResp); err != nil {
+		t.Fatal(err)
+	}
+	archive := backupResp.Data.Archive
+	if archive.Version != admin.ArchiveVersionV0 || archive.Snapshot.Revision != 2 || len(archive.Snapshot.Entries) != 2 {
+		t.Fatalf("unexpected archive: %+v", archive)
+	}
+	if archive.Snapshot.Entries[0].APIType != meta.APITypeNetworkV0 {
+		t.Fatalf("want: %s, got: %s", meta.APITypeNetworkV0, archive.Snapshot.Entries[0].APIType)
+	}
+
+	archiveJSON, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v0/admin/restore", bytes.NewReader(archiveJSON))
+		req.Header.Set("Content-Type", "application/json")
+		newRouter(dst).ServeHTTP(w, req)
+		return w
+	}
+
+	// 起動時に作成されるadminユーザーとloginのトークンがあってもリストアできる
+	dst.Put("user/admin", &data{Meta: meta.Meta{ID: "admin", APIType: meta.APITypeUserV0}})
+	dst.Put("rolebinding/admin", &data{Meta: meta.Meta{ID: "admin", APIType: meta.APITypeRoleBindingV0}})
+	dst.Put("token/xxxx", &data{Meta: meta.Meta{ID: "xxxx"}})
+
+	// revisionはArchiveのrevisionまで戻さずに、リストア前のrevisionから進める
+	want := dst.Revision() + 1
+	w = restore()
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d %s", w.Code, w.Body.String())
+	}
+	if dst.Revision() != want {
+		t.Fatalf("want: %d, got: %d", want, dst.Revision())
+	}
+	res := struct {
+		Data struct {
+			Revision int64 `json:"revision"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Data.Revision != want {
+		t.Fatalf("want: revision %d in response, got: %d", want, res.Data.Revision)
+	}
+	// ユーザーとトークンもArchiveの内容で置き換えられる
+	if err := dst.Get("token/xxxx", &data{}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("want: %v, got: %v", store.ErrNotFound, err)
+	}
+	b := data{}
+	if err := dst.Get("core/network/b", &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Data != "b" || b.ResourceVersion != 2 {
+		t.Fatalf("want: b at revision 2, got: %s at revision %d", b.Data, b.ResourceVersion)
+	}
+
+	// 空でないstoreにはリストアできない
+	if w := restore(); w.Code != http.StatusConflict {
+		t.Fatalf("want: 409, got: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,215 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type Operation string
+
+const (
+	OperationCreate Operation = "Create"
+	OperationUpdate Operation = "Update"
+)
+
+// FieldError はリソースのどのフィールドが不正かを表す
+// Fieldは`spec.nics[1].ipv4Address`のようなjsonのパス
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type FieldErrors []*FieldError
+
+func (errs FieldErrors) Error() string {
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, ", ")
+}
+
+// Add はfieldのエラーを追加する
+func (errs *FieldErrors) Add(field, format string, a ...interface{}) {
+	*errs = append(*errs, &FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// Request はadmissionで検証する書き込みの内容
+type Request struct {
+	APIType   meta.APIType
+	Operation Operation
+
+	// Group, Namespace はURLで指定されたリソースのグループとネームスペース
+	// グループやネームスペースに属さないリソースの場合は空
+	Group     string
+	Namespace string
+
+	// Object は書き込むリソース、OldObject は更新前のリソース
+	// OldObject はOperationがCreateの場合はnil
+	Object    interface{}
+	OldObject interface{}
+
+	// Compares, Ops はValidatorが追加する、リソースの書き込みと同じTxnで確認・反映する条件と操作
+	// 検証に使った他のリソースが書き込みまでに変更されていないことを保証する場合に使う
+	Compares []store.Compare
+	Ops      []store.Op
+}
+
+// ErrConflict はValidatorが追加した条件が成り立たずに書き込めなかった場合に返る
+var ErrConflict = errors.New("Error: other write was committed during admission. please retry.")
+
+// Validator はRequestのリソースを検証して不正なフィールドを返す
+// 他のリソースを参照する場合はsから取得する
+type Validator func(s store.Store, req *Request) FieldErrors
+
+// Mutator はRequestのリソースにデフォルト値を設定する
+// Validatorより前に実行されるので、Validatorは設定後のリソースを検証する
+type Mutator func(s store.Store, req *Request) error
+
+// Registry はAPITypeごとのMutatorとValidatorを管理する
+type Registry struct {
+	locker     *sync.RWMutex
+	mutators   map[meta.APIType][]Mutator
+	validators map[meta.APIType][]Validator
+}
+
+// DefaultRegistry はapiserverの各handlerが作成と更新の前に使う
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		locker:     &sync.RWMutex{},
+		mutators:   map[meta.APIType][]Mutator{},
+		validators: map[meta.APIType][]Validator{},
+	}
+}
+
+// Register はapiTypeのValidatorを追加する
+// Validatorは登録した順番に全て実行される
+func (r *Registry) Register(apiType meta.APIType, validator Validator) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if apiType == "" || validator == nil {
+		return fmt.Errorf("Error: admission requires apiType and validator.")
+	}
+
+	r.validators[apiType] = append(r.validators[apiType], validator)
+	return nil
+}
+
+// MustRegister はRegisterに失敗した場合にpanicする
+func (r *Registry) MustRegister(apiType meta.APIType, validator Validator) {
+	if err := r.Register(apiType, validator); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterMutator はapiTypeのMutatorを追加する
+// Mutatorは登録した順番に全て実行される
+func (r *Registry) RegisterMutator(apiType meta.APIType, mutator Mutator) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if apiType == "" || mutator == nil {
+		return fmt.Errorf("Error: admission requires apiType and mutator.")
+	}
+
+	r.mutators[apiType] = append(r.mutators[apiType], mutator)
+	return nil
+}
+
+// MustRegisterMutator はRegisterMutatorに失敗した場合にpanicする
+func (r *Registry) MustRegisterMutator(apiType meta.APIType, mutator Mutator) {
+	if err := r.RegisterMutator(apiType, mutator); err != nil {
+		panic(err)
+	}
+}
+
+// Mutate はreq.APITypeの全てのMutatorでreq.Objectを書き換える
+func (r *Registry) Mutate(s store.Store, req *Request) error {
+	r.locker.RLock()
+	mutators := r.mutators[req.APIType]
+	r.locker.RUnlock()
+
+	for _, mutator := range mutators {
+		if err := mutator(s, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate はreq.APITypeの全てのValidatorを実行してエラーをまとめて返す
+// エラーがない場合はnilを返す
+func (r *Registry) Validate(s store.Store, req *Request) FieldErrors {
+	r.locker.RLock()
+	validators := r.validators[req.APIType]
+	r.locker.RUnlock()
+
+	errs := FieldErrors{}
+	for _, validator := range validators {
+		errs = append(errs, validator(s, req)...)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Admit はDefaultRegistryでreq.Objectにデフォルト値を設定してから検証する
+// 不正なフィールドがある場合は422とエラーの一覧を返してfalseを返す
+func Admit(ctx *gin.Context, s store.Store, req *Request) bool {
+	if err := DefaultRegistry.Mutate(s, req); err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError,
+			fmt.Errorf("Error: failed to set defaults of %s: %s", req.APIType, err.Error()), nil)
+		return false
+	}
+
+	errs := DefaultRegistry.Validate(s, req)
+	if errs == nil {
+		return true
+	}
+
+	meta.ResponseJSON(ctx, http.StatusUnprocessableEntity,
+		fmt.Errorf("Error: %s is invalid: %s", req.APIType, errs.Error()),
+		gin.H{
+			"errors": errs,
+		})
+	return false
+}
+
+// Txn はcompares, opsにreq.Compares, req.Opsを加えてsに書き込む
+// req.Comparesが成り立たなかった場合はErrConflictを返す
+func Txn(s store.Store, req *Request, compares []store.Compare, ops []store.Op) error {
+	err := s.Txn(append(compares, req.Compares...), append(ops, req.Ops...))
+	if !errors.Is(err, store.ErrConflict) {
+		return err
+	}
+
+	for _, c := range req.Compares {
+		var obj meta.Object
+		if err := s.Get(c.Key, &obj); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if obj.Meta.ResourceVersion != c.ResourceVersion {
+			return ErrConflict
+		}
+	}
+	return err
+}
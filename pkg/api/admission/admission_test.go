@@ -0,0 +1,73 @@
+package admission_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
+	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestAdmit(t *testing.T) {
+	const apiType = meta.APITypeVirtualMachineV0
+	admission.DefaultRegistry.MustRegister(apiType, func(s store.Store, req *admission.Request) admission.FieldErrors {
+		errs := admission.FieldErrors{}
+		vm := req.Object.(*system.VirtualMachine)
+		if req.Operation == admission.OperationCreate && vm.Spec.LimitVcpus == "" {
+			errs.Add("spec.limitVcpus", "is required")
+		}
+		if old, ok := req.OldObject.(*system.VirtualMachine); ok && old.Spec.UUID != vm.Spec.UUID {
+			errs.Add("spec.uuid", "can't be changed")
+		}
+		return errs
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(memory.NewMemoryStore())).RegisterHandlers()
+
+	request := func(method, body string) (int, map[string]interface{}) {
+		path := "/api/v0/groups/group1/namespaces/ns1/virtualmachines"
+		if method == http.MethodPut {
+			path += "/vm1"
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		resp := map[string]interface{}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, resp
+	}
+
+	code, resp := request(http.MethodPost, `{"meta":{"id":"vm1"},"spec":{"uuid":"a"}}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("want: %d, got: %d", http.StatusUnprocessableEntity, code)
+	}
+	errs := resp["data"].(map[string]interface{})["errors"].([]interface{})
+	if len(errs) != 1 {
+		t.Fatalf("want: 1 error, got: %v", errs)
+	}
+	if field := errs[0].(map[string]interface{})["field"]; field != "spec.limitVcpus" {
+		t.Fatalf("want: spec.limitVcpus, got: %v", field)
+	}
+
+	if code, resp := request(http.MethodPost, `{"meta":{"id":"vm1"},"spec":{"uuid":"a","limitVcpus":"1"}}`); code != http.StatusCreated {
+		t.Fatalf("want: %d, got: %d %v", http.StatusCreated, code, resp)
+	}
+	if code, resp := request(http.MethodPut, `{"meta":{"id":"vm1"},"spec":{"uuid":"b","limitVcpus":"1"}}`); code != http.StatusUnprocessableEntity {
+		t.Fatalf("want: %d, got: %d %v", http.StatusUnprocessableEntity, code, resp)
+	}
+}
@@ -0,0 +1,138 @@
+package defaults
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+const (
+	imageEntityTypeLocal = "Local"
+)
+
+func init() {
+	for _, apiType := range []meta.APIType{
+		meta.APITypeNodeV0,
+		meta.APITypeNodeNetworkV0,
+		meta.APITypeBlockStorageV0,
+		meta.APITypeVirtualMachineV0,
+		meta.APITypeVirtualRouterV0,
+		meta.APITypeImageV0,
+		meta.APITypeImageEntityV0,
+		meta.APITypeStorageClassV0,
+		meta.APITypeNamespaceV0,
+		meta.APITypeGroupV0,
+		meta.APITypeExternalIPPoolV0,
+		meta.APITypeExternalIPV0,
+		meta.APITypeNetworkV0,
+		meta.APITypeUserV0,
+		meta.APITypeServiceAccountV0,
+		meta.APITypeRoleBindingV0,
+		meta.APITypeResourceQuotaV0,
+	} {
+		admission.DefaultRegistry.MustRegisterMutator(apiType, setMetaDefaults)
+	}
+
+	admission.DefaultRegistry.MustRegisterMutator(meta.APITypeVirtualMachineV0, setVirtualMachineDefaults)
+	admission.DefaultRegistry.MustRegisterMutator(meta.APITypeImageV0, setImageDefaults)
+	admission.DefaultRegistry.MustRegisterMutator(meta.APITypeImageEntityV0, setImageEntityDefaults)
+}
+
+// setMetaDefaults はagentがnilを確認せずに書き込めるようにannotationsとlabelsを空のmapにする
+func setMetaDefaults(s store.Store, req *admission.Request) error {
+	obj, ok := req.Object.(meta.MetaAccessor)
+	if !ok {
+		return nil
+	}
+
+	m := obj.GetMeta()
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	if m.Labels == nil {
+		m.Labels = map[string]string{}
+	}
+	return nil
+}
+
+// setVirtualMachineDefaults はagentが起動時に決めていたUUIDとMACアドレスを作成時に決める
+// 更新で空にされた場合は更新前の値を引き継ぐ
+func setVirtualMachineDefaults(s store.Store, req *admission.Request) error {
+	vm, ok := req.Object.(*system.VirtualMachine)
+	if !ok {
+		return nil
+	}
+	old, _ := req.OldObject.(*system.VirtualMachine)
+
+	if vm.Spec.UUID == "" && old != nil {
+		vm.Spec.UUID = old.Spec.UUID
+	}
+	if vm.Spec.UUID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		vm.Spec.UUID = id.String()
+	}
+
+	if vm.Spec.ActionState == "" {
+		vm.Spec.ActionState = system.VirtualMachineActionStatePowerOn
+		if old != nil && old.Spec.ActionState != "" {
+			vm.Spec.ActionState = old.Spec.ActionState
+		}
+	}
+
+	for i, nic := range vm.Spec.NICs {
+		if nic == nil || nic.MacAddress != "" {
+			continue
+		}
+		if old != nil && i < len(old.Spec.NICs) && old.Spec.NICs[i] != nil &&
+			old.Spec.NICs[i].NetworkID == nic.NetworkID {
+			nic.MacAddress = old.Spec.NICs[i].MacAddress
+		}
+		if nic.MacAddress == "" {
+			nic.MacAddress = generateMacAddress(req.Group, req.Namespace, vm.ID, nic.NetworkID, i)
+		}
+	}
+	return nil
+}
+
+func setImageDefaults(s store.Store, req *admission.Request) error {
+	image, ok := req.Object.(*system.Image)
+	if !ok {
+		return nil
+	}
+
+	if image.Spec.EntityMap == nil {
+		image.Spec.EntityMap = map[string]string{}
+	}
+	return nil
+}
+
+// setImageEntityDefaults はimage agentが空の場合に使っていた値を設定する
+func setImageEntityDefaults(s store.Store, req *admission.Request) error {
+	imageEntity, ok := req.Object.(*system.ImageEntity)
+	if !ok {
+		return nil
+	}
+
+	if imageEntity.Spec.Type == "" {
+		imageEntity.Spec.Type = imageEntityTypeLocal
+	}
+	if imageEntity.Spec.Source.Type == "" {
+		imageEntity.Spec.Source.Type = system.ImageEntitySourceTypeBlockStorage
+	}
+	return nil
+}
+
+// generateMacAddress はQEMUのベンダープレフィックス52:54:00を使い、
+// VirtualMachineとNICから同じMACアドレスを生成する
+func generateMacAddress(groupID, nsID, vmID, networkID string, index int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s/%d", groupID, nsID, vmID, networkID, index)))
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", sum[0], sum[1], sum[2])
+}
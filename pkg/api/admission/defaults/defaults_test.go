@@ -0,0 +1,106 @@
+package defaults
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+func TestSetVirtualMachineDefaults(t *testing.T) {
+	newVM := func() *system.VirtualMachine {
+		return &system.VirtualMachine{
+			Meta: meta.Meta{ID: "vm1"},
+			Spec: system.VirtualMachineSpec{
+				NICs: []*system.VirtualMachineNIC{
+					{NetworkID: "net1"},
+					{NetworkID: "net1"},
+					{NetworkID: "net2", MacAddress: "52:54:00:00:00:01"},
+				},
+			},
+		}
+	}
+	newRequest := func(vm, old *system.VirtualMachine) *admission.Request {
+		req := &admission.Request{
+			APIType:   meta.APITypeVirtualMachineV0,
+			Operation: admission.OperationCreate,
+			Group:     "group1",
+			Namespace: "ns1",
+			Object:    vm,
+		}
+		if old != nil {
+			req.Operation = admission.OperationUpdate
+			req.OldObject = old
+		}
+		return req
+	}
+
+	created := newVM()
+	if err := admission.DefaultRegistry.Mutate(nil, newRequest(created, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uuid.Parse(created.Spec.UUID); err != nil {
+		t.Fatalf("want: uuid, got: %s", created.Spec.UUID)
+	}
+	if created.Spec.ActionState != system.VirtualMachineActionStatePowerOn {
+		t.Fatalf("want: %s, got: %s", system.VirtualMachineActionStatePowerOn, created.Spec.ActionState)
+	}
+	if created.Annotations == nil || created.Labels == nil {
+		t.Fatal("want: empty annotations and labels, got: nil")
+	}
+	for i, nic := range created.Spec.NICs {
+		if _, err := net.ParseMAC(nic.MacAddress); err != nil {
+			t.Fatalf("nics[%d]: want: mac address, got: %s", i, nic.MacAddress)
+		}
+	}
+	if created.Spec.NICs[0].MacAddress == created.Spec.NICs[1].MacAddress {
+		t.Fatalf("want: different mac addresses, got: %s", created.Spec.NICs[0].MacAddress)
+	}
+	if created.Spec.NICs[2].MacAddress != "52:54:00:00:00:01" {
+		t.Fatalf("want: keep mac address, got: %s", created.Spec.NICs[2].MacAddress)
+	}
+
+	// 同じVirtualMachineからは同じMACアドレスが生成される
+	recreated := newVM()
+	if err := admission.DefaultRegistry.Mutate(nil, newRequest(recreated, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if recreated.Spec.NICs[0].MacAddress != created.Spec.NICs[0].MacAddress {
+		t.Fatalf("want: %s, got: %s", created.Spec.NICs[0].MacAddress, recreated.Spec.NICs[0].MacAddress)
+	}
+
+	// 更新で空にされた値は更新前の値を引き継ぐ
+	created.Spec.ActionState = system.VirtualMachineActionStatePowerOff
+	created.Spec.NICs[0].MacAddress = "52:54:00:00:00:02"
+	updated := newVM()
+	if err := admission.DefaultRegistry.Mutate(nil, newRequest(updated, created)); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.UUID != created.Spec.UUID {
+		t.Fatalf("want: %s, got: %s", created.Spec.UUID, updated.Spec.UUID)
+	}
+	if updated.Spec.ActionState != system.VirtualMachineActionStatePowerOff {
+		t.Fatalf("want: %s, got: %s", system.VirtualMachineActionStatePowerOff, updated.Spec.ActionState)
+	}
+	if updated.Spec.NICs[0].MacAddress != "52:54:00:00:00:02" {
+		t.Fatalf("want: 52:54:00:00:00:02, got: %s", updated.Spec.NICs[0].MacAddress)
+	}
+}
+
+func TestSetImageEntityDefaults(t *testing.T) {
+	imageEntity := &system.ImageEntity{Meta: meta.Meta{ID: "ie1"}}
+	req := &admission.Request{
+		APIType:   meta.APITypeImageEntityV0,
+		Operation: admission.OperationCreate,
+		Object:    imageEntity,
+	}
+	if err := admission.DefaultRegistry.Mutate(nil, req); err != nil {
+		t.Fatal(err)
+	}
+	if imageEntity.Spec.Type != "Local" || imageEntity.Spec.Source.Type != system.ImageEntitySourceTypeBlockStorage {
+		t.Fatalf("want: Local and BlockStorage, got: %s and %s", imageEntity.Spec.Type, imageEntity.Spec.Source.Type)
+	}
+}
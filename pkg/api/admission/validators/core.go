@@ -0,0 +1,93 @@
+package validators
+
+import (
+	"net"
+	"path/filepath"
+
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+func init() {
+	admission.DefaultRegistry.MustRegister(meta.APITypeNetworkV0, validateNetwork)
+	admission.DefaultRegistry.MustRegister(meta.APITypeExternalIPPoolV0, validateExternalIPPool)
+	admission.DefaultRegistry.MustRegister(meta.APITypeExternalIPV0, validateExternalIP)
+}
+
+func validateNetwork(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	n, ok := req.Object.(*core.Network)
+	if !ok {
+		return errs
+	}
+
+	// VirtualMachineのNICのアドレスはこのCIDRの中から割り当てる
+	validateCIDR(&errs, "spec.template.spec.ipv4CIDR", n.Spec.Template.Spec.IPv4CIDR, true)
+	validateCIDR(&errs, "spec.template.spec.ipv6CIDR", n.Spec.Template.Spec.IPv6CIDR, false)
+	return errs
+}
+
+func validateExternalIPPool(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	pool, ok := req.Object.(*core.ExternalIPPool)
+	if !ok {
+		return errs
+	}
+
+	ipnet := validateCIDR(&errs, "spec.ipv4CIDR", pool.Spec.IPv4CIDR, true)
+	validateCIDR(&errs, "spec.ipv6CIDR", pool.Spec.IPv6CIDR, false)
+	gateway := validateIP(&errs, "spec.defaultGateway", pool.Spec.DefaultGateway, false)
+	validateInNetwork(&errs, "spec.defaultGateway", gateway, ipnet)
+	return errs
+}
+
+func validateExternalIP(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	eip, ok := req.Object.(*core.ExternalIP)
+	if !ok {
+		return errs
+	}
+
+	oldKeys := map[string]bool{}
+	if old, ok := req.OldObject.(*core.ExternalIP); ok {
+		oldKeys[filepath.Join("externalippool", old.Spec.PoolID)] = true
+		oldKeys[filepath.Join("group", old.Group)] = true
+		oldKeys[filepath.Join("namespace", old.Group, old.Namespace)] = true
+	}
+
+	// ResourceQuotaはmeta.groupとmeta.namespaceに対して適用するので省略できない
+	if eip.Group == "" {
+		errs.Add("meta.group", "is required")
+	} else {
+		var group core.Group
+		validateReference(s, &errs, "meta.group", filepath.Join("group", eip.Group), &group, oldKeys)
+		if eip.Namespace != "" {
+			var ns core.Namespace
+			validateReference(s, &errs, "meta.namespace", filepath.Join("namespace", eip.Group, eip.Namespace), &ns, oldKeys)
+		}
+	}
+
+	var ipnet *net.IPNet
+	if eip.Spec.PoolID == "" {
+		errs.Add("spec.poolID", "is required")
+	} else {
+		var pool core.ExternalIPPool
+		if validateReference(s, &errs, "spec.poolID", filepath.Join("externalippool", eip.Spec.PoolID), &pool, oldKeys) {
+			_, ipnet, _ = net.ParseCIDR(pool.Spec.IPv4CIDR)
+		}
+	}
+
+	if ip := validateIP(&errs, "spec.ipv4Address", eip.Spec.IPv4Address, false); ip != nil {
+		validateInNetwork(&errs, "spec.ipv4Address", ip, ipnet)
+	}
+	if eip.Spec.IPv4Prefix < 0 || eip.Spec.IPv4Prefix > 32 {
+		errs.Add("spec.ipv4Prefix", "%d is out of range", eip.Spec.IPv4Prefix)
+	}
+	validateIP(&errs, "spec.ipv6Address", eip.Spec.IPv6Address, false)
+	if eip.Spec.IPv6Prefix < 0 || eip.Spec.IPv6Prefix > 128 {
+		errs.Add("spec.ipv6Prefix", "%d is out of range", eip.Spec.IPv6Prefix)
+	}
+	return errs
+}
@@ -0,0 +1,279 @@
+package validators
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+func init() {
+	admission.DefaultRegistry.MustRegister(meta.APITypeResourceQuotaV0, validateResourceQuota)
+	for _, apiType := range []meta.APIType{
+		meta.APITypeVirtualMachineV0,
+		meta.APITypeBlockStorageV0,
+		meta.APITypeNetworkV0,
+		meta.APITypeVirtualRouterV0,
+		meta.APITypeExternalIPV0,
+	} {
+		admission.DefaultRegistry.MustRegister(apiType, validateResourceQuotaLimits)
+	}
+}
+
+// countUnits はリソースの数の倍率
+var countUnits = map[string]int64{
+	"": 1,
+}
+
+// quotaResource はResourceQuotaで制限するリソース
+type quotaResource struct {
+	// name はspec.hardのフィールド名
+	name string
+	// field は制限を超えた場合にエラーにするリソースのフィールド
+	field string
+	units map[string]int64
+	get   func(r *core.ResourceQuotaResources) *string
+}
+
+var quotaResources = []quotaResource{
+	{"requestVcpus", "spec.requestVcpus", quantity.VcpusUnits, func(r *core.ResourceQuotaResources) *string { return &r.RequestVcpus }},
+	{"requestMemory", "spec.requestMemory", quantity.BytesUnits, func(r *core.ResourceQuotaResources) *string { return &r.RequestMemory }},
+	{"requestSize", "spec.requestSize", quantity.BytesUnits, func(r *core.ResourceQuotaResources) *string { return &r.RequestSize }},
+	{"virtualMachines", "meta.id", countUnits, func(r *core.ResourceQuotaResources) *string { return &r.VirtualMachines }},
+	{"blockStorages", "meta.id", countUnits, func(r *core.ResourceQuotaResources) *string { return &r.BlockStorages }},
+	{"networks", "meta.id", countUnits, func(r *core.ResourceQuotaResources) *string { return &r.Networks }},
+	{"virtualRouters", "meta.id", countUnits, func(r *core.ResourceQuotaResources) *string { return &r.VirtualRouters }},
+	{"externalIPs", "meta.id", countUnits, func(r *core.ResourceQuotaResources) *string { return &r.ExternalIPs }},
+}
+
+func validateResourceQuota(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	rq, ok := req.Object.(*core.ResourceQuota)
+	if !ok {
+		return errs
+	}
+
+	oldKeys := map[string]bool{}
+	if old, ok := req.OldObject.(*core.ResourceQuota); ok {
+		oldKeys[filepath.Join("group", old.Group)] = true
+		oldKeys[filepath.Join("namespace", old.Group, old.Namespace)] = true
+	}
+
+	if rq.Group == "" {
+		errs.Add("meta.group", "is required")
+	} else {
+		var group core.Group
+		validateReference(s, &errs, "meta.group", filepath.Join("group", rq.Group), &group, oldKeys)
+		if rq.Namespace != "" {
+			var ns core.Namespace
+			validateReference(s, &errs, "meta.namespace", filepath.Join("namespace", rq.Group, rq.Namespace), &ns, oldKeys)
+		}
+	}
+
+	for _, r := range quotaResources {
+		validateQuantity(&errs, "spec.hard."+r.name, *r.get(&rq.Spec.Hard), r.units, false)
+	}
+	return errs
+}
+
+// validateResourceQuotaLimits は書き込むリソースがgroupとnamespaceのResourceQuotaを超えないかを確認する
+// 更新の場合は増えたリソースのみ確認するので、ResourceQuotaを超えていても減らす更新はできる
+// 同時に書き込まれて合計が超えないように、リソースが増える場合はgroupのquotaRevisionKeyの更新をTxnに加える
+func validateResourceQuotaLimits(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+
+	// ExternalIPのようにパスにgroupを含まないリソースはhandlerが保存されているgroupを設定する
+	group, ns := req.Group, req.Namespace
+	if group == "" {
+		return errs
+	}
+
+	requested := quotaAmounts(req.Object)
+	old := quotaAmounts(req.OldObject)
+	// groupが空だったExternalIPにgroupを設定した場合は、使用量に含まれていないので作成として確認する
+	if eip, ok := req.OldObject.(*core.ExternalIP); ok && (eip.Group != group || eip.Namespace != ns) {
+		old = map[string]int64{}
+	}
+	increased := false
+	for name, n := range requested {
+		if n > old[name] {
+			increased = true
+		}
+	}
+	if !increased {
+		return errs
+	}
+
+	// 使用量を計算する前にrevisionを読み込むので、計算後に他の書き込みがあった場合はTxnが失敗する
+	revisionKey := quotaRevisionKey(group)
+	revision := &meta.Object{}
+	if err := s.Get(revisionKey, revision); err != nil && !errors.Is(err, store.ErrNotFound) {
+		errs.Add("meta", "failed to get %s: %s", revisionKey, err.Error())
+		return errs
+	}
+
+	rqList, err := listResourceQuotas(s, group, ns)
+	if err != nil {
+		errs.Add("meta", "failed to list resourcequota: %s", err.Error())
+		return errs
+	}
+	if len(rqList) == 0 {
+		return errs
+	}
+
+	// namespaceが空のResourceQuotaはgroup全体の使用量で確認する
+	usedByNamespace := map[string]map[string]int64{}
+	for _, rq := range rqList {
+		used, ok := usedByNamespace[rq.Namespace]
+		if !ok {
+			used, err = quotaUsed(s, group, rq.Namespace)
+			if err != nil {
+				errs.Add("meta", "failed to calculate usage of resourcequota `%s`: %s", rq.ID, err.Error())
+				return errs
+			}
+			usedByNamespace[rq.Namespace] = used
+		}
+
+		for _, r := range quotaResources {
+			hard, err := quantity.Parse(*r.get(&rq.Spec.Hard), r.units)
+			if err != nil || requested[r.name] <= old[r.name] {
+				continue
+			}
+
+			// 更新前のリソースは使用量に含まれている
+			others := used[r.name] - old[r.name]
+			if others+requested[r.name] > hard {
+				errs.Add(r.field, "exceeds resourcequota `%s`. %s: requested %s, used %s, hard %s",
+					rq.ID, r.name,
+					quantity.Format(requested[r.name], r.units),
+					quantity.Format(others, r.units),
+					quantity.Format(hard, r.units))
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		req.Compares = append(req.Compares, store.CompareResourceVersion(revisionKey, revision.Meta.ResourceVersion))
+		req.Ops = append(req.Ops, store.OpPut(revisionKey, &meta.Object{Meta: meta.Meta{ID: group, Group: group}}))
+	}
+	return errs
+}
+
+// quotaRevisionKey はgroupのResourceQuotaで確認した書き込みごとに更新するkey
+func quotaRevisionKey(group string) string {
+	return filepath.Join("resourcequotarevision", group)
+}
+
+// ResourceQuotaUsed はrqのgroupとnamespaceで現在使用しているリソース量を返す
+func ResourceQuotaUsed(s store.Store, rq *core.ResourceQuota) (core.ResourceQuotaResources, error) {
+	res := core.ResourceQuotaResources{}
+	used, err := quotaUsed(s, rq.Group, rq.Namespace)
+	if err != nil {
+		return res, err
+	}
+
+	for _, r := range quotaResources {
+		*r.get(&res) = quantity.Format(used[r.name], r.units)
+	}
+	return res, nil
+}
+
+// listResourceQuotas はgroupとnamespaceに適用されるResourceQuotaを返す
+func listResourceQuotas(s store.Store, group, ns string) ([]*core.ResourceQuota, error) {
+	// group全体のものとnamespaceのものはどちらもresourcequota/<group>/以下にある
+	objs, err := listObjects(s, filepath.Join("resourcequota", group)+"/", func() interface{} { return &core.ResourceQuota{} })
+	if err != nil {
+		return nil, err
+	}
+
+	rqList := []*core.ResourceQuota{}
+	for _, obj := range objs {
+		rq := obj.(*core.ResourceQuota)
+		if rq.Namespace == "" || rq.Namespace == ns {
+			rqList = append(rqList, rq)
+		}
+	}
+	return rqList, nil
+}
+
+// quotaUsed はgroupとnamespaceのリソースの使用量を合計する
+// namespaceが空の場合はgroup内の全てのnamespaceを合計する
+func quotaUsed(s store.Store, group, ns string) (map[string]int64, error) {
+	used := map[string]int64{}
+	add := func(obj interface{}) {
+		for name, n := range quotaAmounts(obj) {
+			used[name] += n
+		}
+	}
+
+	for kind, newObj := range map[string]func() interface{}{
+		"virtualmachine": func() interface{} { return &system.VirtualMachine{} },
+		"blockstorage":   func() interface{} { return &system.BlockStorage{} },
+		"network":        func() interface{} { return &core.Network{} },
+		"virtualrouter":  func() interface{} { return &system.VirtualRouter{} },
+	} {
+		objs, err := listObjects(s, filepath.Join(kind, group, ns)+"/", newObj)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			add(obj)
+		}
+	}
+
+	eips, err := listObjects(s, "externalip/", func() interface{} { return &core.ExternalIP{} })
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range eips {
+		eip := obj.(*core.ExternalIP)
+		if eip.Group == group && (ns == "" || eip.Namespace == ns) {
+			add(eip)
+		}
+	}
+	return used, nil
+}
+
+// quotaAmounts はobjが使用するリソース量を返す
+// 不正な値は他のValidatorでエラーになるので0として扱う
+func quotaAmounts(obj interface{}) map[string]int64 {
+	amounts := map[string]int64{}
+	switch o := obj.(type) {
+	case *system.VirtualMachine:
+		amounts["requestVcpus"], _ = quantity.Parse(o.Spec.RequestVcpus, quantity.VcpusUnits)
+		amounts["requestMemory"], _ = quantity.Parse(o.Spec.RequestMemory, quantity.BytesUnits)
+		amounts["virtualMachines"] = 1
+	case *system.BlockStorage:
+		amounts["requestSize"], _ = quantity.Parse(o.Spec.RequestSize, quantity.BytesUnits)
+		amounts["blockStorages"] = 1
+	case *core.Network:
+		amounts["networks"] = 1
+	case *system.VirtualRouter:
+		amounts["virtualRouters"] = 1
+	case *core.ExternalIP:
+		amounts["externalIPs"] = 1
+	}
+	return amounts
+}
+
+func listObjects(s store.Store, prefix string, newObj func() interface{}) ([]interface{}, error) {
+	objs := []interface{}{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			obj := newObj()
+			objs = append(objs, obj)
+			m = append(m, obj)
+		}
+		return m
+	}
+	if err := s.List(prefix, f); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
@@ -0,0 +1,397 @@
+package validators
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+func init() {
+	admission.DefaultRegistry.MustRegister(meta.APITypeVirtualMachineV0, validateVirtualMachine)
+	admission.DefaultRegistry.MustRegister(meta.APITypeBlockStorageV0, validateBlockStorage)
+	admission.DefaultRegistry.MustRegister(meta.APITypeNodeV0, validateNode)
+	admission.DefaultRegistry.MustRegister(meta.APITypeNodeNetworkV0, validateNodeNetwork)
+	admission.DefaultRegistry.MustRegister(meta.APITypeVirtualRouterV0, validateVirtualRouter)
+	admission.DefaultRegistry.MustRegister(meta.APITypeStorageClassV0, validateStorageClass)
+}
+
+func validateVirtualMachine(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	vm, ok := req.Object.(*system.VirtualMachine)
+	if !ok {
+		return errs
+	}
+
+	// 1vcpu未満はagentで0vcpuになってしまう
+	validateRequestLimit(&errs, "spec.requestVcpus", vm.Spec.RequestVcpus, "spec.limitVcpus", vm.Spec.LimitVcpus, quantity.VcpusUnits)
+	if limit, err := quantity.Parse(vm.Spec.LimitVcpus, quantity.VcpusUnits); err == nil && limit < quantity.VcpusUnits[""] {
+		errs.Add("spec.limitVcpus", "must be at least 1000m")
+	}
+	validateRequestLimit(&errs, "spec.requestMemory", vm.Spec.RequestMemory, "spec.limitMemory", vm.Spec.LimitMemory, quantity.BytesUnits)
+
+	switch vm.Spec.ActionState {
+	case "", system.VirtualMachineActionStatePowerOn, system.VirtualMachineActionStatePowerOff:
+	default:
+		errs.Add("spec.actionState", "unknown action state `%s`", vm.Spec.ActionState)
+	}
+
+	// 更新前から参照しているBlockStorageとNetwork
+	oldKeys := map[string]bool{}
+	if old, ok := req.OldObject.(*system.VirtualMachine); ok {
+		for _, bsID := range old.Spec.BlockStorageIDs {
+			oldKeys[filepath.Join("blockstorage", req.Group, req.Namespace, bsID)] = true
+		}
+		for _, nic := range old.Spec.NICs {
+			oldKeys[filepath.Join("network", req.Group, req.Namespace, nic.NetworkID)] = true
+		}
+	}
+
+	usedBSIDs := map[string]bool{}
+	for i, bsID := range vm.Spec.BlockStorageIDs {
+		field := fmt.Sprintf("spec.blockStorageIDs[%d]", i)
+		if bsID == "" {
+			errs.Add(field, "is required")
+			continue
+		}
+		if usedBSIDs[bsID] {
+			errs.Add(field, "blockstorage `%s` is duplicated", bsID)
+			continue
+		}
+		usedBSIDs[bsID] = true
+
+		var bs system.BlockStorage
+		validateReference(s, &errs, field, filepath.Join("blockstorage", req.Group, req.Namespace, bsID), &bs, oldKeys)
+	}
+
+	for i, nic := range vm.Spec.NICs {
+		field := fmt.Sprintf("spec.nics[%d]", i)
+		if nic == nil {
+			errs.Add(field, "is required")
+			continue
+		}
+
+		var ipnet *net.IPNet
+		if nic.NetworkID == "" {
+			errs.Add(field+".networkID", "is required")
+		} else {
+			var n core.Network
+			key := filepath.Join("network", req.Group, req.Namespace, nic.NetworkID)
+			if validateReference(s, &errs, field+".networkID", key, &n, oldKeys) {
+				_, ipnet, _ = net.ParseCIDR(n.Spec.Template.Spec.IPv4CIDR)
+			}
+		}
+
+		if nic.MacAddress != "" {
+			if _, err := net.ParseMAC(nic.MacAddress); err != nil {
+				errs.Add(field+".macAddress", "`%s` is not a valid mac address", nic.MacAddress)
+			}
+		}
+
+		if ip := validateIP(&errs, field+".ipv4Address", nic.IPv4Address, false); ip != nil {
+			if ip.To4() == nil {
+				errs.Add(field+".ipv4Address", "`%s` is not an ipv4 address", nic.IPv4Address)
+			} else {
+				validateInNetwork(&errs, field+".ipv4Address", ip, ipnet)
+			}
+		}
+		if ip := validateIP(&errs, field+".ipv6Address", nic.IPv6Address, false); ip != nil && ip.To4() != nil {
+			errs.Add(field+".ipv6Address", "`%s` is not an ipv6 address", nic.IPv6Address)
+		}
+		for j, nameserver := range nic.Nameservers {
+			validateIP(&errs, fmt.Sprintf("%s.nameservers[%d]", field, j), nameserver, true)
+		}
+		validateIP(&errs, field+".defaultGateway", nic.DefaultGateway, false)
+	}
+
+	for i, user := range vm.Spec.LoginUsers {
+		if user == nil || user.Username == "" {
+			errs.Add(fmt.Sprintf("spec.loginUsers[%d].username", i), "is required")
+		}
+	}
+
+	old, _ := req.OldObject.(*system.VirtualMachine)
+	validateNodeAllocatable(s, &errs, vm, old)
+	return errs
+}
+
+// validateNodeAllocatable はnodeを指定したVirtualMachineがnodeのallocatableに収まるかを確認する
+// nodeが変わらずrequestも増えない更新は確認しない
+func validateNodeAllocatable(s store.Store, errs *admission.FieldErrors, vm, old *system.VirtualMachine) {
+	const annotationNodeName = "virtualmachinev0/node_name"
+	nodeName := vm.Annotations[annotationNodeName]
+	if nodeName == "" {
+		return
+	}
+
+	vcpus, verr := quantity.Parse(vm.Spec.RequestVcpus, quantity.VcpusUnits)
+	memory, merr := quantity.Parse(vm.Spec.RequestMemory, quantity.BytesUnits)
+	if verr != nil || merr != nil {
+		return
+	}
+
+	// 同じnodeの更新では更新前のrequestがnodeのrequestedに含まれている
+	var oldVcpus, oldMemory int64
+	if old != nil && old.Annotations[annotationNodeName] == nodeName {
+		oldVcpus, _ = quantity.Parse(old.Spec.RequestVcpus, quantity.VcpusUnits)
+		oldMemory, _ = quantity.Parse(old.Spec.RequestMemory, quantity.BytesUnits)
+		if vcpus <= oldVcpus && memory <= oldMemory {
+			return
+		}
+	}
+
+	var node system.Node
+	if err := s.Get(filepath.Join("node", nodeName), &node); err != nil {
+		// nodeが登録されていない場合はスケジューラが割り当てられない理由を記録する
+		return
+	}
+
+	field := "meta.annotations[" + annotationNodeName + "]"
+	for _, r := range []struct {
+		name        string
+		units       map[string]int64
+		allocatable string
+		requested   string
+		request     int64
+		old         int64
+	}{
+		{"vcpus", quantity.VcpusUnits, firstNonEmpty(node.Status.Allocatable.Vcpus, node.Spec.LimitVcpus), node.Status.RequestedVcpus, vcpus, oldVcpus},
+		{"memory", quantity.BytesUnits, firstNonEmpty(node.Status.Allocatable.Memory, node.Spec.LimitMemory), node.Status.RequestedMemory, memory, oldMemory},
+	} {
+		allocatable, err := quantity.Parse(r.allocatable, r.units)
+		if err != nil {
+			continue
+		}
+		requested, _ := quantity.Parse(r.requested, r.units)
+		if requested-r.old+r.request > allocatable {
+			errs.Add(field, "insufficient %s on node `%s`. requested %s, used %s, allocatable %s",
+				r.name, nodeName,
+				quantity.Format(r.request, r.units),
+				quantity.Format(requested-r.old, r.units),
+				quantity.Format(allocatable, r.units))
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func validateBlockStorage(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	bs, ok := req.Object.(*system.BlockStorage)
+	if !ok {
+		return errs
+	}
+
+	validateRequestLimit(&errs, "spec.requestSize", bs.Spec.RequestSize, "spec.limitSize", bs.Spec.LimitSize, quantity.BytesUnits)
+
+	from := bs.Spec.From
+	switch from.Type {
+	case system.BlockStorageFromTypeEmpty:
+	case system.BlockStorageFromTypeBaseImage:
+		if from.BaseImage.ImageName == "" {
+			errs.Add("spec.from.baseImage.imageName", "is required")
+		}
+	case system.BlockStorageFromTypeBlockStorage:
+		if from.BlockStorage.Name == "" {
+			errs.Add("spec.from.blockStorage.name", "is required")
+		}
+	case system.BlockStorageFromTypeHTTP:
+		if from.HTTP.URL == "" {
+			errs.Add("spec.from.http.url", "is required")
+		} else if u, err := url.Parse(from.HTTP.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.Add("spec.from.http.url", "`%s` is not a valid http url", from.HTTP.URL)
+		}
+	case "":
+		errs.Add("spec.from.type", "is required")
+	default:
+		errs.Add("spec.from.type", "unknown type `%s`", from.Type)
+	}
+
+	// 省略した場合はschedulerがLocalにする
+	switch t := bs.Annotations["blockstoragev0/type"]; t {
+	case "", "Local", "Ceph":
+	default:
+		errs.Add("meta.annotations[blockstoragev0/type]", "unknown type `%s`", t)
+	}
+
+	if old, ok := req.OldObject.(*system.BlockStorage); ok {
+		// 作成後に保存する場所は変えられない
+		if bs.Spec.StorageClassName != old.Spec.StorageClassName {
+			errs.Add("spec.storageClassName", "can't be changed")
+		}
+	} else if bs.Spec.StorageClassName != "" {
+		var sc system.StorageClass
+		validateReference(s, &errs, "spec.storageClassName", filepath.Join("storageclass", bs.Spec.StorageClassName), &sc, nil)
+	}
+
+	return errs
+}
+
+func validateNode(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	node, ok := req.Object.(*system.Node)
+	if !ok {
+		return errs
+	}
+
+	validateQuantity(&errs, "spec.limitVcpus", node.Spec.LimitVcpus, quantity.VcpusUnits, false)
+	validateQuantity(&errs, "spec.limitMemory", node.Spec.LimitMemory, quantity.BytesUnits, false)
+	validateQuantity(&errs, "spec.limitDisk", node.Spec.LimitDisk, quantity.BytesUnits, false)
+	if node.Spec.VcpusOvercommitRatio < 0 {
+		errs.Add("spec.vcpusOvercommitRatio", "must be greater than or equal to 0")
+	}
+	if node.Spec.MemoryOvercommitRatio < 0 {
+		errs.Add("spec.memoryOvercommitRatio", "must be greater than or equal to 0")
+	}
+	return errs
+}
+
+func validateStorageClass(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	sc, ok := req.Object.(*system.StorageClass)
+	if !ok {
+		return errs
+	}
+
+	switch sc.Spec.Driver {
+	case system.StorageClassDriverLocal:
+		validateQuantity(&errs, "spec.local.clusterSize", sc.Spec.Local.ClusterSize, quantity.BytesUnits, false)
+	case system.StorageClassDriverCeph:
+		if sc.Spec.Provisioning == system.StorageClassProvisioningThick {
+			errs.Add("spec.provisioning", "`%s` is not supported by driver `%s`", sc.Spec.Provisioning, sc.Spec.Driver)
+		}
+	case "":
+		errs.Add("spec.driver", "is required")
+	default:
+		errs.Add("spec.driver", "unknown driver `%s`", sc.Spec.Driver)
+	}
+
+	switch sc.Spec.Provisioning {
+	case "", system.StorageClassProvisioningThin, system.StorageClassProvisioningThick:
+	default:
+		errs.Add("spec.provisioning", "unknown provisioning `%s`", sc.Spec.Provisioning)
+	}
+
+	// 既存のBlockStorageの場所が変わらないようにdriverと保存先は変えられない
+	if old, ok := req.OldObject.(*system.StorageClass); ok {
+		if sc.Spec.Driver != old.Spec.Driver {
+			errs.Add("spec.driver", "can't be changed")
+		}
+		if sc.Spec.Local.DirPath != old.Spec.Local.DirPath {
+			errs.Add("spec.local.dirPath", "can't be changed")
+		}
+		if sc.Spec.Ceph.PoolName != old.Spec.Ceph.PoolName {
+			errs.Add("spec.ceph.poolName", "can't be changed")
+		}
+	}
+	return errs
+}
+
+func validateNodeNetwork(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	nodeNet, ok := req.Object.(*system.NodeNetwork)
+	if !ok {
+		return errs
+	}
+
+	validateCIDR(&errs, "spec.ipv4CIDR", nodeNet.Spec.IPv4CIDR, false)
+	validateCIDR(&errs, "spec.ipv6CIDR", nodeNet.Spec.IPv6CIDR, false)
+	return errs
+}
+
+func validateVirtualRouter(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	vr, ok := req.Object.(*system.VirtualRouter)
+	if !ok {
+		return errs
+	}
+
+	validateIP(&errs, "spec.externalGateway", vr.Spec.ExternalGateway, false)
+	validateIPOrCIDR(&errs, "spec.natGatewayIP", vr.Spec.NATGatewayIP)
+
+	// 更新前から参照しているNetworkとExternalIP
+	oldKeys := map[string]bool{}
+	if old, ok := req.OldObject.(*system.VirtualRouter); ok {
+		for _, nic := range old.Spec.NICs {
+			oldKeys[filepath.Join("network", req.Group, req.Namespace, nic.NetworkID)] = true
+		}
+		for _, e := range old.Spec.ExternalIPs {
+			oldKeys[filepath.Join("externalip", e.ExternalIPID)] = true
+		}
+	}
+
+	for i, nic := range vr.Spec.NICs {
+		field := fmt.Sprintf("spec.nics[%d]", i)
+
+		var ipnet *net.IPNet
+		if nic.NetworkID == "" {
+			errs.Add(field+".networkID", "is required")
+		} else {
+			var n core.Network
+			key := filepath.Join("network", req.Group, req.Namespace, nic.NetworkID)
+			if validateReference(s, &errs, field+".networkID", key, &n, oldKeys) {
+				_, ipnet, _ = net.ParseCIDR(n.Spec.Template.Spec.IPv4CIDR)
+			}
+		}
+
+		// ルーターのアドレスは10.0.0.254/24のようにプレフィックス付きで指定する
+		if nic.IPv4Address == "" {
+			errs.Add(field+".ipv4Address", "is required")
+		} else if ip, _, err := net.ParseCIDR(nic.IPv4Address); err != nil {
+			errs.Add(field+".ipv4Address", "`%s` is not a valid address with prefix like 10.0.0.254/24", nic.IPv4Address)
+		} else {
+			validateInNetwork(&errs, field+".ipv4Address", ip, ipnet)
+		}
+	}
+
+	for i, e := range vr.Spec.ExternalIPs {
+		field := fmt.Sprintf("spec.externalIPs[%d]", i)
+		if e.ExternalIPID == "" {
+			errs.Add(field+".externalIPID", "is required")
+		} else {
+			var eip core.ExternalIP
+			validateReference(s, &errs, field+".externalIPID", filepath.Join("externalip", e.ExternalIPID), &eip, oldKeys)
+		}
+		validateIP(&errs, field+".bindInternalIPv4Address", e.BindInternalIPv4Address, true)
+	}
+
+	for i, rule := range vr.Spec.DNATRules {
+		field := fmt.Sprintf("spec.dnatRules[%d]", i)
+		validateIP(&errs, field+".destAddress", rule.DestAddress, true)
+		validateIP(&errs, field+".toDestAddress", rule.ToDestAddress, true)
+		validatePort(&errs, field+".destPort", rule.DestPort)
+		validatePort(&errs, field+".toDestPort", rule.ToDestPort)
+	}
+
+	return errs
+}
+
+// validateIPOrCIDR はvalueがIPアドレスかプレフィックス付きのアドレスであるかを確認する
+func validateIPOrCIDR(errs *admission.FieldErrors, field, value string) {
+	if value == "" || net.ParseIP(value) != nil {
+		return
+	}
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		errs.Add(field, "`%s` is not a valid ip address", value)
+	}
+}
+
+func validatePort(errs *admission.FieldErrors, field string, port int32) {
+	if port < 0 || port > 65535 {
+		errs.Add(field, "%d is out of range", port)
+	}
+}
@@ -0,0 +1,156 @@
+package validators
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/utils/quantity"
+)
+
+func init() {
+	for _, apiType := range []meta.APIType{
+		meta.APITypeNodeV0,
+		meta.APITypeNodeNetworkV0,
+		meta.APITypeBlockStorageV0,
+		meta.APITypeVirtualMachineV0,
+		meta.APITypeVirtualRouterV0,
+		meta.APITypeImageV0,
+		meta.APITypeImageEntityV0,
+		meta.APITypeStorageClassV0,
+		meta.APITypeNamespaceV0,
+		meta.APITypeGroupV0,
+		meta.APITypeExternalIPPoolV0,
+		meta.APITypeExternalIPV0,
+		meta.APITypeNetworkV0,
+		meta.APITypeUserV0,
+		meta.APITypeServiceAccountV0,
+		meta.APITypeRoleBindingV0,
+		meta.APITypeResourceQuotaV0,
+	} {
+		admission.DefaultRegistry.MustRegister(apiType, validateMeta)
+	}
+}
+
+// validateMeta はIDがstoreのkeyとして使えるかを確認する
+func validateMeta(s store.Store, req *admission.Request) admission.FieldErrors {
+	errs := admission.FieldErrors{}
+	obj, ok := req.Object.(meta.MetaAccessor)
+	if !ok {
+		return errs
+	}
+
+	id := obj.GetMeta().ID
+	switch {
+	case id == "":
+		errs.Add("meta.id", "is required")
+	case id == "." || id == ".." || strings.ContainsAny(id, "/\\"):
+		errs.Add("meta.id", "`%s` is not a valid id", id)
+	}
+	return errs
+}
+
+// validateQuantity はvalueがunitsの単位の数値であるかを確認する
+// 空の場合はrequiredの場合のみエラーにする
+func validateQuantity(errs *admission.FieldErrors, field, value string, units map[string]int64, required bool) (int64, bool) {
+	if value == "" {
+		if required {
+			errs.Add(field, "is required")
+		}
+		return 0, false
+	}
+
+	n, err := quantity.Parse(value, units)
+	if err != nil {
+		errs.Add(field, "`%s` is not a valid quantity. use a number with unit %s", value, unitNames(units))
+		return 0, false
+	}
+	return n, true
+}
+
+// validateRequestLimit はrequestがlimitを超えていないかを確認する
+func validateRequestLimit(errs *admission.FieldErrors, requestField, request, limitField, limit string, units map[string]int64) {
+	r, rok := validateQuantity(errs, requestField, request, units, true)
+	l, lok := validateQuantity(errs, limitField, limit, units, true)
+	if rok && lok && r > l {
+		errs.Add(requestField, "must be less than or equal to %s", limitField)
+	}
+}
+
+func unitNames(units map[string]int64) string {
+	names := []string{}
+	for _, unit := range []string{"m", "K", "M", "G"} {
+		if _, ok := units[unit]; ok {
+			names = append(names, "`"+unit+"`")
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// validateIP はvalueがIPアドレスであるかを確認する
+func validateIP(errs *admission.FieldErrors, field, value string, required bool) net.IP {
+	if value == "" {
+		if required {
+			errs.Add(field, "is required")
+		}
+		return nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		errs.Add(field, "`%s` is not a valid ip address", value)
+	}
+	return ip
+}
+
+// validateCIDR はvalueが10.0.0.0/24のようなCIDRであるかを確認する
+func validateCIDR(errs *admission.FieldErrors, field, value string, required bool) *net.IPNet {
+	if value == "" {
+		if required {
+			errs.Add(field, "is required")
+		}
+		return nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(value)
+	if err != nil {
+		errs.Add(field, "`%s` is not a valid cidr", value)
+		return nil
+	}
+	return ipnet
+}
+
+// validateInNetwork はipがipnetに含まれるかを確認する
+func validateInNetwork(errs *admission.FieldErrors, field string, ip net.IP, ipnet *net.IPNet) {
+	if ip == nil || ipnet == nil {
+		return
+	}
+	if !ipnet.Contains(ip) {
+		errs.Add(field, "not in network %s", ipnet.String())
+	}
+}
+
+// validateReference はkeyのリソースが存在するかを確認する
+// 更新前から参照しているリソースは削除されていても更新できるように確認しない
+// 存在する場合はvに取得する
+func validateReference(s store.Store, errs *admission.FieldErrors, field, key string, v interface{}, oldKeys map[string]bool) bool {
+	err := s.Get(key, v)
+	if err == nil {
+		return true
+	}
+	if oldKeys[key] {
+		return false
+	}
+
+	kind := strings.Split(key, "/")[0]
+	if errors.Is(err, store.ErrNotFound) {
+		errs.Add(field, "%s `%s` is not found", kind, filepath.Base(key))
+	} else {
+		errs.Add(field, "failed to get %s `%s`: %s", kind, filepath.Base(key), err.Error())
+	}
+	return false
+}
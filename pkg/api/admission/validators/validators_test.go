@@ -0,0 +1,431 @@
+package validators
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestValidateVirtualMachine(t *testing.T) {
+	s := memory.NewMemoryStore()
+	net := &core.Network{
+		Meta: meta.Meta{ID: "net1"},
+		Spec: core.NetworkSpec{
+			Template: system.NodeNetwork{
+				Spec: system.NodeNetworkSpec{IPv4CIDR: "10.0.0.0/24"},
+			},
+		},
+	}
+	if err := s.Put(filepath.Join("network", "group1", "ns1", "net1"), net); err != nil {
+		t.Fatal(err)
+	}
+	bs := &system.BlockStorage{Meta: meta.Meta{ID: "bs1"}}
+	if err := s.Put(filepath.Join("blockstorage", "group1", "ns1", "bs1"), bs); err != nil {
+		t.Fatal(err)
+	}
+
+	newVM := func() *system.VirtualMachine {
+		return &system.VirtualMachine{
+			Meta: meta.Meta{ID: "vm1"},
+			Spec: system.VirtualMachineSpec{
+				RequestVcpus:    "1000m",
+				LimitVcpus:      "2000m",
+				RequestMemory:   "1G",
+				LimitMemory:     "1G",
+				BlockStorageIDs: []string{"bs1"},
+				NICs: []*system.VirtualMachineNIC{
+					{NetworkID: "net1", IPv4Address: "10.0.0.1", MacAddress: "52:54:00:00:00:01"},
+					{NetworkID: "net1", IPv4Address: "10.0.0.2", Nameservers: []string{"8.8.8.8"}},
+				},
+			},
+		}
+	}
+	newRequest := func(vm, old *system.VirtualMachine) *admission.Request {
+		req := &admission.Request{
+			APIType:   meta.APITypeVirtualMachineV0,
+			Operation: admission.OperationCreate,
+			Group:     "group1",
+			Namespace: "ns1",
+			Object:    vm,
+		}
+		if old != nil {
+			req.Operation = admission.OperationUpdate
+			req.OldObject = old
+		}
+		return req
+	}
+
+	if errs := validateVirtualMachine(s, newRequest(newVM(), nil)); len(errs) != 0 {
+		t.Fatalf("want: no errors, got: %v", errs)
+	}
+
+	invalid := newVM()
+	invalid.Spec.LimitVcpus = "8G"
+	invalid.Spec.RequestMemory = "2G"
+	invalid.Spec.BlockStorageIDs = []string{"bs1", "bs2"}
+	invalid.Spec.NICs[0].MacAddress = "52:54:00"
+	invalid.Spec.NICs[1].IPv4Address = "10.0.1.2"
+	invalid.Spec.NICs = append(invalid.Spec.NICs, &system.VirtualMachineNIC{NetworkID: "net2"})
+
+	got := []string{}
+	for _, e := range validateVirtualMachine(s, newRequest(invalid, nil)) {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"spec.limitVcpus: `8G` is not a valid quantity. use a number with unit `m`",
+		"spec.requestMemory: must be less than or equal to spec.limitMemory",
+		"spec.blockStorageIDs[1]: blockstorage `bs2` is not found",
+		"spec.nics[0].macAddress: `52:54:00` is not a valid mac address",
+		"spec.nics[1].ipv4Address: not in network 10.0.0.0/24",
+		"spec.nics[2].networkID: network `net2` is not found",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %q, got: %q", want, got)
+	}
+
+	// 更新前から参照しているリソースは削除されていても更新できる
+	old := newVM()
+	old.Spec.BlockStorageIDs = []string{"bs1", "bs2"}
+	updated := newVM()
+	updated.Spec.BlockStorageIDs = []string{"bs1", "bs2"}
+	if errs := validateVirtualMachine(s, newRequest(updated, old)); len(errs) != 0 {
+		t.Fatalf("want: no errors, got: %v", errs)
+	}
+}
+
+func TestValidateStorageClass(t *testing.T) {
+	newStorageClass := func(driver system.StorageClassDriver, provisioning system.StorageClassProvisioning) *system.StorageClass {
+		return &system.StorageClass{
+			Meta: meta.Meta{ID: "sc1"},
+			Spec: system.StorageClassSpec{
+				Driver:       driver,
+				Provisioning: provisioning,
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		sc   *system.StorageClass
+		old  *system.StorageClass
+		want []string
+	}{
+		{
+			name: "local thick",
+			sc:   newStorageClass(system.StorageClassDriverLocal, system.StorageClassProvisioningThick),
+		},
+		{
+			name: "ceph thin",
+			sc:   newStorageClass(system.StorageClassDriverCeph, system.StorageClassProvisioningThin),
+		},
+		{
+			name: "ceph thick",
+			sc:   newStorageClass(system.StorageClassDriverCeph, system.StorageClassProvisioningThick),
+			want: []string{"spec.provisioning: `Thick` is not supported by driver `Ceph`"},
+		},
+		{
+			name: "unknown driver",
+			sc:   newStorageClass("NFS", "Lazy"),
+			want: []string{
+				"spec.driver: unknown driver `NFS`",
+				"spec.provisioning: unknown provisioning `Lazy`",
+			},
+		},
+		{
+			name: "change driver",
+			sc:   newStorageClass(system.StorageClassDriverCeph, ""),
+			old:  newStorageClass(system.StorageClassDriverLocal, ""),
+			want: []string{"spec.driver: can't be changed"},
+		},
+	}
+
+	for _, test := range tests {
+		req := &admission.Request{
+			APIType:   meta.APITypeStorageClassV0,
+			Operation: admission.OperationCreate,
+			Object:    test.sc,
+		}
+		if test.old != nil {
+			req.Operation = admission.OperationUpdate
+			req.OldObject = test.old
+		}
+
+		got := []string{}
+		for _, e := range validateStorageClass(nil, req) {
+			got = append(got, e.Error())
+		}
+		if len(got) != len(test.want) || (len(got) != 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Fatalf("%s: want: %q, got: %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestValidateMeta(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"vm1":    true,
+		"":       false,
+		"..":     false,
+		"a/b":    false,
+		"../vm1": false,
+	} {
+		req := &admission.Request{
+			APIType: meta.APITypeVirtualMachineV0,
+			Object:  &system.VirtualMachine{Meta: meta.Meta{ID: id}},
+		}
+		if errs := validateMeta(nil, req); (len(errs) == 0) != valid {
+			t.Errorf("%q: want valid: %v, got: %v", id, valid, errs)
+		}
+	}
+}
+
+func TestValidateResourceQuotaLimits(t *testing.T) {
+	s := memory.NewMemoryStore()
+	put := func(key string, v interface{}) {
+		if err := s.Put(key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(filepath.Join("resourcequota", "group1", "rq-group"), &core.ResourceQuota{
+		Meta: meta.Meta{ID: "rq-group", Group: "group1"},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{RequestVcpus: "4", VirtualMachines: "3"},
+		},
+	})
+	put(filepath.Join("resourcequota", "group1", "ns1", "rq-ns"), &core.ResourceQuota{
+		Meta: meta.Meta{ID: "rq-ns", Group: "group1", Namespace: "ns1"},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{RequestMemory: "4G", RequestSize: "10G", ExternalIPs: "1"},
+		},
+	})
+	// 別のgroup, namespaceのResourceQuotaは適用されない
+	put(filepath.Join("resourcequota", "group1", "ns3", "rq-other-ns"), &core.ResourceQuota{
+		Meta: meta.Meta{ID: "rq-other-ns", Group: "group1", Namespace: "ns3"},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{VirtualMachines: "0", BlockStorages: "0"},
+		},
+	})
+	put(filepath.Join("resourcequota", "group2", "rq-other-group"), &core.ResourceQuota{
+		Meta: meta.Meta{ID: "rq-other-group", Group: "group2"},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{VirtualMachines: "0", BlockStorages: "0"},
+		},
+	})
+
+	newVM := func(id, vcpus, memory string) *system.VirtualMachine {
+		return &system.VirtualMachine{
+			Meta: meta.Meta{ID: id},
+			Spec: system.VirtualMachineSpec{RequestVcpus: vcpus, RequestMemory: memory},
+		}
+	}
+	put(filepath.Join("virtualmachine", "group1", "ns1", "vm1"), newVM("vm1", "2", "2G"))
+	put(filepath.Join("virtualmachine", "group1", "ns2", "vm2"), newVM("vm2", "1", "2G"))
+	put(filepath.Join("blockstorage", "group1", "ns1", "bs1"), &system.BlockStorage{
+		Meta: meta.Meta{ID: "bs1"},
+		Spec: system.BlockStorageSpec{RequestSize: "8G"},
+	})
+	put(filepath.Join("externalip", "eip1"), &core.ExternalIP{
+		Meta: meta.Meta{ID: "eip1", Group: "group1", Namespace: "ns1"},
+	})
+
+	tests := []struct {
+		name    string
+		apiType meta.APIType
+		ns      string
+		obj     interface{}
+		old     interface{}
+		want    []string
+	}{
+		{
+			name:    "within quota",
+			apiType: meta.APITypeVirtualMachineV0,
+			ns:      "ns1",
+			obj:     newVM("vm3", "1", "2G"),
+		},
+		{
+			name:    "group and namespace quota",
+			apiType: meta.APITypeVirtualMachineV0,
+			ns:      "ns1",
+			obj:     newVM("vm3", "2", "4G"),
+			want: []string{
+				"spec.requestMemory: exceeds resourcequota `rq-ns`. requestMemory: requested 4G, used 2G, hard 4G",
+				"spec.requestVcpus: exceeds resourcequota `rq-group`. requestVcpus: requested 2, used 3, hard 4",
+			},
+		},
+		{
+			name:    "other namespace",
+			apiType: meta.APITypeVirtualMachineV0,
+			ns:      "ns2",
+			obj:     newVM("vm3", "1", "4G"),
+		},
+		{
+			name:    "update without increase",
+			apiType: meta.APITypeVirtualMachineV0,
+			ns:      "ns1",
+			obj:     newVM("vm1", "2", "1G"),
+			old:     newVM("vm1", "2", "2G"),
+		},
+		{
+			name:    "update with increase",
+			apiType: meta.APITypeBlockStorageV0,
+			ns:      "ns1",
+			obj:     &system.BlockStorage{Meta: meta.Meta{ID: "bs1"}, Spec: system.BlockStorageSpec{RequestSize: "12G"}},
+			old:     &system.BlockStorage{Meta: meta.Meta{ID: "bs1"}, Spec: system.BlockStorageSpec{RequestSize: "8G"}},
+			want:    []string{"spec.requestSize: exceeds resourcequota `rq-ns`. requestSize: requested 12G, used 0, hard 10G"},
+		},
+		{
+			name:    "externalip",
+			apiType: meta.APITypeExternalIPV0,
+			ns:      "ns1",
+			obj:     &core.ExternalIP{Meta: meta.Meta{ID: "eip2", Group: "group1", Namespace: "ns1"}},
+			want:    []string{"meta.id: exceeds resourcequota `rq-ns`. externalIPs: requested 1, used 1, hard 1"},
+		},
+		{
+			name:    "externalip without group",
+			apiType: meta.APITypeExternalIPV0,
+			ns:      "ns1",
+			obj:     &core.ExternalIP{Meta: meta.Meta{ID: "eip2", Group: "group1", Namespace: "ns1"}},
+			old:     &core.ExternalIP{Meta: meta.Meta{ID: "eip2"}},
+			want:    []string{"meta.id: exceeds resourcequota `rq-ns`. externalIPs: requested 1, used 1, hard 1"},
+		},
+	}
+
+	for _, test := range tests {
+		req := &admission.Request{
+			APIType:   test.apiType,
+			Operation: admission.OperationCreate,
+			Group:     "group1",
+			Namespace: test.ns,
+			Object:    test.obj,
+		}
+		if test.old != nil {
+			req.Operation = admission.OperationUpdate
+			req.OldObject = test.old
+		}
+
+		got := []string{}
+		for _, e := range validateResourceQuotaLimits(s, req) {
+			got = append(got, e.Error())
+		}
+		if len(got) != len(test.want) || (len(got) != 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Fatalf("%s: want: %q, got: %q", test.name, test.want, got)
+		}
+	}
+}
+
+// TestValidateResourceQuotaLimitsConcurrent は同時に検証された書き込みの両方が
+// ResourceQuotaを超えて保存されないことを確認する
+func TestValidateResourceQuotaLimitsConcurrent(t *testing.T) {
+	s := memory.NewMemoryStore()
+	if err := s.Put(filepath.Join("resourcequota", "group1", "rq1"), &core.ResourceQuota{
+		Meta: meta.Meta{ID: "rq1", Group: "group1"},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{VirtualMachines: "1"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*admission.Request{}
+	for _, id := range []string{"vm1", "vm2"} {
+		req := &admission.Request{
+			APIType:   meta.APITypeVirtualMachineV0,
+			Operation: admission.OperationCreate,
+			Group:     "group1",
+			Namespace: "ns1",
+			Object:    &system.VirtualMachine{Meta: meta.Meta{ID: id}},
+		}
+		if errs := validateResourceQuotaLimits(s, req); len(errs) != 0 {
+			t.Fatalf("%s: want: no errors, got: %s", id, errs.Error())
+		}
+		reqs = append(reqs, req)
+	}
+
+	for i, req := range reqs {
+		key := filepath.Join("virtualmachine", "group1", "ns1", req.Object.(*system.VirtualMachine).ID)
+		err := admission.Txn(s, req,
+			[]store.Compare{store.CompareNotExists(key)},
+			[]store.Op{store.OpPut(key, req.Object)},
+		)
+		if i == 0 && err != nil {
+			t.Fatalf("first write: want: nil, got: %s", err)
+		}
+		if i == 1 && !errors.Is(err, admission.ErrConflict) {
+			t.Fatalf("second write: want: %s, got: %v", admission.ErrConflict, err)
+		}
+	}
+}
+
+func TestValidateNodeAllocatable(t *testing.T) {
+	s := memory.NewMemoryStore()
+	if err := s.Put(filepath.Join("node", "node1"), &system.Node{
+		Meta: meta.Meta{ID: "node1"},
+		Spec: system.NodeSpec{LimitVcpus: "4", VcpusOvercommitRatio: 4},
+		Status: system.NodeStatus{
+			RequestedVcpus:  "14000m",
+			RequestedMemory: "6G",
+			Allocatable:     system.NodeResources{Vcpus: "16000m", Memory: "8G"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	newVM := func(nodeName, vcpus, memory string) *system.VirtualMachine {
+		return &system.VirtualMachine{
+			Meta: meta.Meta{
+				ID:          "vm1",
+				Annotations: map[string]string{"virtualmachinev0/node_name": nodeName},
+			},
+			Spec: system.VirtualMachineSpec{RequestVcpus: vcpus, RequestMemory: memory},
+		}
+	}
+
+	tests := []struct {
+		name string
+		vm   *system.VirtualMachine
+		old  *system.VirtualMachine
+		want []string
+	}{
+		{
+			name: "fits overcommitted node",
+			vm:   newVM("node1", "2000m", "2G"),
+		},
+		{
+			name: "insufficient",
+			vm:   newVM("node1", "4000m", "4G"),
+			want: []string{
+				"meta.annotations[virtualmachinev0/node_name]: insufficient vcpus on node `node1`. requested 4, used 14, allocatable 16",
+				"meta.annotations[virtualmachinev0/node_name]: insufficient memory on node `node1`. requested 4G, used 6G, allocatable 8G",
+			},
+		},
+		{
+			name: "update on same node",
+			vm:   newVM("node1", "4000m", "2G"),
+			old:  newVM("node1", "2000m", "2G"),
+		},
+		{
+			name: "unknown node",
+			vm:   newVM("node2", "64", "64G"),
+		},
+	}
+
+	for _, test := range tests {
+		errs := admission.FieldErrors{}
+		validateNodeAllocatable(s, &errs, test.vm, test.old)
+
+		got := []string{}
+		for _, e := range errs {
+			got = append(got, e.Error())
+		}
+		if len(got) != len(test.want) || (len(got) != 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Fatalf("%s: want: %q, got: %q", test.name, test.want, got)
+		}
+	}
+}
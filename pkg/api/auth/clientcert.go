@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// RequireClientCert はapiserverのクライアントCAで検証されたクライアント証明書がない場合に
+// 403を返してリクエストを中断するmiddleware
+// agentのみが利用するAPIを登録されたagent以外から利用できないようにする
+func RequireClientCert(ctx *gin.Context) {
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+		meta.ResponseJSON(ctx, http.StatusForbidden, fmt.Errorf("Error: client certificate is required."), nil)
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandlerInterface interface {
+	Login(ctx *gin.Context)
+	Logout(ctx *gin.Context)
+	WhoAmI(ctx *gin.Context)
+
+	// Authenticate はBearerトークンを検証するmiddleware
+	Authenticate(ctx *gin.Context)
+	// Authorize はRoleBindingでリクエストが許可されているかを確認するmiddleware
+	Authorize(ctx *gin.Context)
+}
+
+type AuthHandler struct {
+	router *gin.RouterGroup
+	ahi    AuthHandlerInterface
+}
+
+const (
+	basePath = "auth"
+)
+
+func NewAuthHandler(router *gin.RouterGroup, ahi AuthHandlerInterface) *AuthHandler {
+	return &AuthHandler{
+		router: router,
+		ahi:    ahi,
+	}
+}
+
+// RegisterHandlers はloginのみ認証なしで登録する
+func (h *AuthHandler) RegisterHandlers() {
+	au := h.router.Group(basePath)
+	{
+		au.POST("/login", h.ahi.Login)
+		au.POST("/logout", h.ahi.Authenticate, h.ahi.Logout)
+		au.GET("/whoami", h.ahi.Authenticate, h.ahi.WhoAmI)
+	}
+}
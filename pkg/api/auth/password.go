@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptはこれより長いpasswordを切り捨てるのでエラーにする
+const passwordMaxLength = 72
+
+// HashPassword はpasswordをbcryptでハッシュ化する
+func HashPassword(password string) (string, error) {
+	if len(password) > passwordMaxLength {
+		return "", fmt.Errorf("Error: password is too long. max %d bytes.", passwordMaxLength)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// CheckPassword はpasswordがHashPasswordでハッシュ化したhashedと一致するかを返す
+func CheckPassword(hashed, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+}
+
+// IsHashedPassword はpasswordがHashPasswordでハッシュ化された形式かを返す
+func IsHashedPassword(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hashed, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHashedPassword(hashed) {
+		t.Fatalf("unexpected format: %s", hashed)
+	}
+	if !CheckPassword(hashed, "secret") {
+		t.Fatal("want: match")
+	}
+	if CheckPassword(hashed, "Secret") {
+		t.Fatal("want: not match")
+	}
+
+	// 同じパスワードでもソルトが異なる
+	other, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hashed {
+		t.Fatal("want: different hash")
+	}
+
+	for _, invalid := range []string{"", "secret", "$2a$10$", "md5$1$c2FsdA$aGFzaA"} {
+		if CheckPassword(invalid, "secret") {
+			t.Fatalf("%s: want: not match", invalid)
+		}
+	}
+
+	// 切り捨てられないように長すぎるpasswordはエラーにする
+	if _, err := HashPassword(strings.Repeat("a", 73)); err == nil {
+		t.Fatal("want: error")
+	}
+}
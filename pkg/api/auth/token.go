@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// GenerateToken はBearerトークンに使うランダムな文字列を生成する
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// TokenID はtokenを保存するときのIDを返す
+// 保存されたデータからトークンを復元できないようにハッシュを使う
+func TokenID(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// NewToken はsubjectのトークンと保存するデータを作成する
+// expiresAtがnilの場合は期限なしのトークンになる
+func NewToken(subject Subject, expiresAt *time.Time) (string, *Token, error) {
+	rawToken, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return rawToken, &Token{
+		Meta: meta.Meta{
+			ID:      TokenID(rawToken),
+			APIType: meta.APITypeTokenV0,
+		},
+		Spec: TokenSpec{
+			Subject:   subject,
+			ExpiresAt: expiresAt,
+		},
+	}, nil
+}
+
+// TokenKey はトークンを保存するstoreのkeyを返す
+func TokenKey(tokenID string) string {
+	return "token/" + tokenID
+}
+
+// queryTokenPathSuffixes はtokenクエリでトークンを渡せるパス
+// ヘッダを付けられないブラウザのEventSource(watch)やWebSocket(コンソール)のみで、
+// URLはアクセスログに残るのでそれ以外のパスでは使わない
+var queryTokenPathSuffixes = []string{
+	"/watches",
+	"/ws",
+}
+
+// GetRequestToken はリクエストのAuthorizationヘッダからBearerトークンを取得する
+// watchとコンソールのパスのみヘッダがない場合にtokenクエリを使う
+func GetRequestToken(ctx *gin.Context) string {
+	header := ctx.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	for _, suffix := range queryTokenPathSuffixes {
+		if strings.HasSuffix(ctx.FullPath(), suffix) {
+			return ctx.Query("token")
+		}
+	}
+	return ""
+}
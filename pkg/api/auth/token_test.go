@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetRequestToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var got string
+	handler := func(ctx *gin.Context) {
+		got = GetRequestToken(ctx)
+	}
+	r.GET("/api/v0/watches", handler)
+	r.GET("/api/v0/groups/:group_id/namespaces/:namespace_id/virtualmachines/:virtual_machine_id/ws", handler)
+	r.GET("/api/v0/groups", handler)
+
+	tests := []struct {
+		path   string
+		header string
+		want   string
+	}{
+		{"/api/v0/groups", "Bearer header-token", "header-token"},
+		{"/api/v0/watches?token=query-token", "Bearer header-token", "header-token"},
+		{"/api/v0/watches?token=query-token", "", "query-token"},
+		{"/api/v0/groups/g1/namespaces/ns1/virtualmachines/vm1/ws?token=query-token", "", "query-token"},
+		// URLがログに残らないようにwatchとコンソール以外ではtokenクエリを使わない
+		{"/api/v0/groups?token=query-token", "", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		got = "unset"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if got != test.want {
+			t.Fatalf("%s: want: `%s`, got: `%s`", test.path, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type SubjectType string
+
+const (
+	SubjectTypeUser           SubjectType = "User"
+	SubjectTypeServiceAccount SubjectType = "ServiceAccount"
+)
+
+// Subject はトークンで認証されたユーザーまたはサービスアカウント
+type Subject struct {
+	Type SubjectType `json:"type" yaml:"type"`
+	ID   string      `json:"id" yaml:"id"`
+}
+
+type Role string
+
+const (
+	// RoleAdmin は全てのリソースを操作できる
+	RoleAdmin Role = "admin"
+	// RoleGroupOwner はGroupとGroup内の全てのリソースを操作できる
+	RoleGroupOwner Role = "group-owner"
+	// RoleNamespaceEditor はNamespace内のリソースを操作できる
+	RoleNamespaceEditor Role = "namespace-editor"
+	// RoleViewer はGroupまたはNamespace内のリソースを取得できる
+	RoleViewer Role = "viewer"
+)
+
+type TokenSpec struct {
+	Subject Subject `json:"subject" yaml:"subject"`
+	// ExpiresAt がnilの場合は期限なし
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+}
+
+// Token は発行したBearerトークン
+// トークンそのものは保存せず、IDにトークンのハッシュを使う
+type Token struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec TokenSpec `json:"spec" yaml:"spec"`
+}
+
+// IsExpired はnowの時点でトークンの期限が切れているかを返す
+func (t *Token) IsExpired(now time.Time) bool {
+	return t.Spec.ExpiresAt != nil && !now.Before(*t.Spec.ExpiresAt)
+}
+
+type LoginRequest struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+}
+
+const subjectContextKey = "auth/subject"
+
+// SetSubject は認証されたSubjectをリクエストのcontextに設定する
+func SetSubject(ctx *gin.Context, subject Subject) {
+	ctx.Set(subjectContextKey, subject)
+}
+
+// GetSubject はリクエストのcontextから認証されたSubjectを取得する
+// 認証が無効の場合はfalseを返す
+func GetSubject(ctx *gin.Context) (Subject, bool) {
+	v, ok := ctx.Get(subjectContextKey)
+	if !ok {
+		return Subject{}, false
+	}
+	subject, ok := v.(Subject)
+	return subject, ok
+}
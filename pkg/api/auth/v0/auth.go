@@ -0,0 +1,290 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// DefaultTokenTTL はloginで発行するトークンの有効期間
+const DefaultTokenTTL = 24 * time.Hour
+
+// BootstrapUserID はユーザーが1人もいない場合に作成する管理者のID
+const BootstrapUserID = "admin"
+
+type AuthHandler struct {
+	auth.AuthHandlerInterface
+
+	store store.Store
+
+	// TokenTTL はloginで発行するトークンの有効期間
+	TokenTTL time.Duration
+}
+
+func NewAuthHandler(store store.Store) *AuthHandler {
+	return &AuthHandler{
+		store:    store,
+		TokenTTL: DefaultTokenTTL,
+	}
+}
+
+func (h *AuthHandler) Login(ctx *gin.Context) {
+	var request auth.LoginRequest
+	if err := ctx.Bind(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// ユーザーが存在しない場合とパスワードが違う場合を区別しない
+	errInvalid := fmt.Errorf("Error: invalid id or password.")
+
+	var user core.User
+	if err := h.store.Get(getUserKey(request.ID), &user); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusUnauthorized, errInvalid, nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	if request.ID == "" || !auth.CheckPassword(user.Spec.Password, request.Password) {
+		meta.ResponseJSON(ctx, http.StatusUnauthorized, errInvalid, nil)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.TokenTTL)
+	token, err := issueToken(h.store, auth.Subject{
+		Type: auth.SubjectTypeUser,
+		ID:   user.ID,
+	}, &expiresAt)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+func (h *AuthHandler) Logout(ctx *gin.Context) {
+	subject, _ := auth.GetSubject(ctx)
+	if subject.Type != auth.SubjectTypeUser {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: only user tokens can be revoked by logout."), nil)
+		return
+	}
+
+	if err := h.store.Delete(auth.TokenKey(auth.TokenID(auth.GetRequestToken(ctx)))); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, nil)
+}
+
+func (h *AuthHandler) WhoAmI(ctx *gin.Context) {
+	subject, _ := auth.GetSubject(ctx)
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"subject": subject,
+	})
+}
+
+// Authenticate はBearerトークンを検証し、認証されたSubjectをcontextに設定する
+// 検証できない場合は401を返してリクエストを中断する
+func (h *AuthHandler) Authenticate(ctx *gin.Context) {
+	subject, err := h.authenticate(auth.GetRequestToken(ctx))
+	if err != nil {
+		if errors.Is(err, errUnauthorized) {
+			ctx.Header("WWW-Authenticate", `Bearer realm="humstack"`)
+			meta.ResponseJSON(ctx, http.StatusUnauthorized, err, nil)
+		} else {
+			meta.ResponseStoreError(ctx, err)
+		}
+		ctx.Abort()
+		return
+	}
+
+	auth.SetSubject(ctx, subject)
+	ctx.Next()
+}
+
+var errUnauthorized = errors.New("Error: unauthorized")
+
+func (h *AuthHandler) authenticate(rawToken string) (auth.Subject, error) {
+	if rawToken == "" {
+		return auth.Subject{}, fmt.Errorf("%w: bearer token is required.", errUnauthorized)
+	}
+
+	tokenKey := auth.TokenKey(auth.TokenID(rawToken))
+	var token auth.Token
+	if err := h.store.Get(tokenKey, &token); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return auth.Subject{}, fmt.Errorf("%w: invalid token.", errUnauthorized)
+		}
+		return auth.Subject{}, err
+	}
+
+	if token.IsExpired(time.Now()) {
+		if err := h.store.Delete(tokenKey); err != nil {
+			log.Println("auth:", "Failed to delete expired token", err.Error())
+		}
+		return auth.Subject{}, fmt.Errorf("%w: token is expired.", errUnauthorized)
+	}
+
+	// 削除されたユーザーやサービスアカウント、再発行前のトークンは使えない
+	subject := token.Spec.Subject
+	switch subject.Type {
+	case auth.SubjectTypeUser:
+		var user core.User
+		if err := h.store.Get(getUserKey(subject.ID), &user); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return auth.Subject{}, fmt.Errorf("%w: user `%s` is not found.", errUnauthorized, subject.ID)
+			}
+			return auth.Subject{}, err
+		}
+	case auth.SubjectTypeServiceAccount:
+		var sa core.ServiceAccount
+		if err := h.store.Get(getServiceAccountKey(subject.ID), &sa); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return auth.Subject{}, fmt.Errorf("%w: serviceaccount `%s` is not found.", errUnauthorized, subject.ID)
+			}
+			return auth.Subject{}, err
+		}
+		if sa.Status.TokenID != token.ID {
+			return auth.Subject{}, fmt.Errorf("%w: token of serviceaccount `%s` has been rotated.", errUnauthorized, subject.ID)
+		}
+	default:
+		return auth.Subject{}, fmt.Errorf("%w: unknown subject type `%s`.", errUnauthorized, subject.Type)
+	}
+
+	return subject, nil
+}
+
+// BootstrapAdmin はユーザーが1人もいない場合に管理者ユーザーとadminのRoleBindingを作成する
+// passwordが空の場合はランダムなパスワードを生成して返す
+// 作成しなかった場合は空を返す
+func BootstrapAdmin(s store.Store, password string) (string, error) {
+	users, err := countKeys(s, getUserKey(""), func() interface{} { return &core.User{} })
+	if err != nil {
+		return "", err
+	}
+	if users > 0 {
+		// RoleBindingが追加される前に作成されたユーザーのみの場合
+		return "", bootstrapAdminRoleBinding(s)
+	}
+
+	if password == "" {
+		password, err = auth.GenerateToken()
+		if err != nil {
+			return "", err
+		}
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	userKey := getUserKey(BootstrapUserID)
+	user := &core.User{
+		Meta: meta.Meta{
+			ID:      BootstrapUserID,
+			Name:    BootstrapUserID,
+			APIType: meta.APITypeUserV0,
+		},
+		Spec: core.UserSpec{
+			Password: hashed,
+		},
+	}
+	rbKey := getRoleBindingKey(BootstrapUserID)
+	err = s.Txn(
+		[]store.Compare{store.CompareNotExists(userKey), store.CompareNotExists(rbKey)},
+		[]store.Op{store.OpPut(userKey, user), store.OpPut(rbKey, newAdminRoleBinding())},
+	)
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// bootstrapAdminRoleBinding はRoleBindingが1つもない場合に管理者ユーザーにadminを割り当てる
+func bootstrapAdminRoleBinding(s store.Store) error {
+	bindings, err := countKeys(s, getRoleBindingKey(""), func() interface{} { return &core.RoleBinding{} })
+	if err != nil || bindings > 0 {
+		return err
+	}
+
+	userKey := getUserKey(BootstrapUserID)
+	var user core.User
+	if err := s.Get(userKey, &user); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	rbKey := getRoleBindingKey(BootstrapUserID)
+	return s.Txn([]store.Compare{store.CompareNotExists(rbKey)}, []store.Op{store.OpPut(rbKey, newAdminRoleBinding())})
+}
+
+func newAdminRoleBinding() *core.RoleBinding {
+	return &core.RoleBinding{
+		Meta: meta.Meta{
+			ID:      BootstrapUserID,
+			Name:    BootstrapUserID,
+			APIType: meta.APITypeRoleBindingV0,
+		},
+		Spec: core.RoleBindingSpec{
+			Role: auth.RoleAdmin,
+			Subjects: []auth.Subject{
+				{Type: auth.SubjectTypeUser, ID: BootstrapUserID},
+			},
+		},
+	}
+}
+
+func countKeys(s store.Store, prefix string, newItem func() interface{}) (int, error) {
+	count := 0
+	err := s.List(prefix, func(n int) []interface{} {
+		count = n
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			m = append(m, newItem())
+		}
+		return m
+	})
+	return count, err
+}
+
+// issueToken はsubjectのトークンを発行して保存し、トークンを返す
+func issueToken(s store.Store, subject auth.Subject, expiresAt *time.Time) (string, error) {
+	rawToken, token, err := auth.NewToken(subject, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Put(auth.TokenKey(token.ID), token); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+func getUserKey(userID string) string {
+	return "user/" + userID
+}
+
+func getServiceAccountKey(serviceAccountID string) string {
+	return "serviceaccount/" + serviceAccountID
+}
+
+func getRoleBindingKey(roleBindingID string) string {
+	return "rolebinding/" + roleBindingID
+}
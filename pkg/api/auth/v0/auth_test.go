@@ -0,0 +1,178 @@
+package v0_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	authv0 "github.com/ophum/humstack/pkg/api/auth/v0"
+	"github.com/ophum/humstack/pkg/api/core/serviceaccount"
+	sav0 "github.com/ophum/humstack/pkg/api/core/serviceaccount/v0"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+type response struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+}
+
+func newTestRouter(t *testing.T, ttl time.Duration) *gin.Engine {
+	s := memory.NewMemoryStore()
+	if _, err := authv0.BootstrapAdmin(s, "password"); err != nil {
+		t.Fatal(err)
+	}
+	// 2回目は作成しない
+	if password, err := authv0.BootstrapAdmin(s, "other"); err != nil || password != "" {
+		t.Fatalf("want: no user created, got: %q %v", password, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authh := authv0.NewAuthHandler(s)
+	authh.TokenTTL = ttl
+	v0 := r.Group("/api/v0")
+	auth.NewAuthHandler(v0, authh).RegisterHandlers()
+	serviceaccount.NewServiceAccountHandler(v0.Group("", authh.Authenticate), sav0.NewServiceAccountHandler(s)).RegisterHandlers()
+	return r
+}
+
+func request(t *testing.T, r *gin.Engine, method, path, token string, body interface{}) (int, json.RawMessage) {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res := response{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil && w.Body.Len() > 0 {
+		t.Fatal(err)
+	}
+	return w.Code, res.Data
+}
+
+func login(t *testing.T, r *gin.Engine, id, password string) (int, string) {
+	code, data := request(t, r, http.MethodPost, "/api/v0/auth/login", "", auth.LoginRequest{ID: id, Password: password})
+	res := struct {
+		Token string `json:"token"`
+	}{}
+	if code == http.StatusOK {
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return code, res.Token
+}
+
+func TestLogin(t *testing.T) {
+	r := newTestRouter(t, time.Hour)
+
+	if code, _ := login(t, r, "admin", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401, got: %d", code)
+	}
+	if code, _ := login(t, r, "nobody", "password"); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401, got: %d", code)
+	}
+
+	code, token := login(t, r, "admin", "password")
+	if code != http.StatusOK || token == "" {
+		t.Fatalf("want: 200 with token, got: %d", code)
+	}
+
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/auth/whoami", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401 without token, got: %d", code)
+	}
+	code, data := request(t, r, http.MethodGet, "/api/v0/auth/whoami", token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d", code)
+	}
+	res := struct {
+		Subject auth.Subject `json:"subject"`
+	}{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Subject.Type != auth.SubjectTypeUser || res.Subject.ID != "admin" {
+		t.Fatalf("want: user admin, got: %+v", res.Subject)
+	}
+
+	if code, _ := request(t, r, http.MethodPost, "/api/v0/auth/logout", token, nil); code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d", code)
+	}
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/auth/whoami", token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401 after logout, got: %d", code)
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	r := newTestRouter(t, -time.Second)
+
+	code, token := login(t, r, "admin", "password")
+	if code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d", code)
+	}
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/auth/whoami", token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401 with expired token, got: %d", code)
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	r := newTestRouter(t, time.Hour)
+	_, userToken := login(t, r, "admin", "password")
+
+	tokenOf := func(data json.RawMessage) string {
+		res := struct {
+			Token string `json:"token"`
+		}{}
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Token
+	}
+
+	code, data := request(t, r, http.MethodPost, "/api/v0/serviceaccounts", userToken, map[string]interface{}{
+		"meta": map[string]string{"id": "agent"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d", code)
+	}
+	saToken := tokenOf(data)
+
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/serviceaccounts", saToken, nil); code != http.StatusOK {
+		t.Fatalf("want: 200 with serviceaccount token, got: %d", code)
+	}
+
+	code, data = request(t, r, http.MethodPost, "/api/v0/serviceaccounts/agent/token", userToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d", code)
+	}
+	rotated := tokenOf(data)
+
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/serviceaccounts", saToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401 with rotated token, got: %d", code)
+	}
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/serviceaccounts", rotated, nil); code != http.StatusOK {
+		t.Fatalf("want: 200 with new token, got: %d", code)
+	}
+
+	if code, _ := request(t, r, http.MethodDelete, "/api/v0/serviceaccounts/agent", userToken, nil); code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d", code)
+	}
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/serviceaccounts", rotated, nil); code != http.StatusUnauthorized {
+		t.Fatalf("want: 401 after delete, got: %d", code)
+	}
+}
@@ -0,0 +1,120 @@
+package v0
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// Authorize はAuthenticateで認証されたSubjectのRoleBindingで、
+// パスの:group_idと:namespace_idに対する操作が許可されているかを確認する
+// :group_idを含まないパスのリソース(Node, ExternalIPPoolなど)はadminのみ操作できる
+// ResourceQuotaは:group_idを含むパスでも取得以外はadminのみ行える
+func (h *AuthHandler) Authorize(ctx *gin.Context) {
+	subject, ok := auth.GetSubject(ctx)
+	if !ok {
+		meta.ResponseJSON(ctx, http.StatusUnauthorized, fmt.Errorf("%w: not authenticated.", errUnauthorized), nil)
+		ctx.Abort()
+		return
+	}
+
+	bindings, err := listRoleBindings(h.store)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		ctx.Abort()
+		return
+	}
+
+	readOnly := ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead
+	groupID, namespaceID := ctx.Param("group_id"), ctx.Param("namespace_id")
+	// ResourceQuotaはgroupのパスにあるが、group-ownerなどが自分で制限を変更できないようにadminのみ変更できる
+	if !readOnly && isAdminOnlyWrite(ctx.FullPath()) {
+		groupID, namespaceID = "", ""
+	}
+	if !isAllowed(bindings, subject, groupID, namespaceID, readOnly) &&
+		!isSelf(subject, ctx.Param("user_id"), ctx.Request.Method) {
+		meta.ResponseJSON(ctx, http.StatusForbidden,
+			fmt.Errorf("Error: %s `%s` is not allowed to %s %s.", subject.Type, subject.ID, ctx.Request.Method, ctx.Request.URL.Path), nil)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Next()
+}
+
+// isAllowed はsubjectに割り当てられたRoleでgroupIDとnamespaceIDに対する操作が許可されているかを返す
+// groupIDが空の場合はクラスタ全体に対する操作になる
+func isAllowed(bindings []*core.RoleBinding, subject auth.Subject, groupID, namespaceID string, readOnly bool) bool {
+	for _, rb := range bindings {
+		if !hasSubject(rb, subject) {
+			continue
+		}
+
+		spec := rb.Spec
+		if spec.Role == auth.RoleAdmin {
+			return true
+		}
+		if groupID == "" || spec.Group != groupID {
+			continue
+		}
+
+		// imageなどNamespaceに属さないGroup内のリソースはGroup内のRoleがあれば取得できる
+		inNamespace := spec.Namespace == "" || spec.Namespace == namespaceID
+		switch spec.Role {
+		case auth.RoleGroupOwner:
+			return true
+		case auth.RoleNamespaceEditor:
+			if namespaceID != "" && spec.Namespace == namespaceID {
+				return true
+			}
+			if namespaceID == "" && readOnly {
+				return true
+			}
+		case auth.RoleViewer:
+			if readOnly && (namespaceID == "" || inNamespace) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAdminOnlyWrite はgroupのパスでも取得以外はadminのみ行えるリソースのパスかを返す
+func isAdminOnlyWrite(path string) bool {
+	return strings.Contains(path, "/resourcequotas")
+}
+
+// isSelf はユーザーが自分自身の取得とパスワードの変更をしようとしているかを返す
+func isSelf(subject auth.Subject, userID, method string) bool {
+	return subject.Type == auth.SubjectTypeUser && userID != "" && subject.ID == userID &&
+		(method == http.MethodGet || method == http.MethodPut)
+}
+
+func hasSubject(rb *core.RoleBinding, subject auth.Subject) bool {
+	for _, s := range rb.Spec.Subjects {
+		if s == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func listRoleBindings(s store.Store) ([]*core.RoleBinding, error) {
+	bindings := []*core.RoleBinding{}
+	err := s.List(getRoleBindingKey(""), func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			rb := &core.RoleBinding{}
+			bindings = append(bindings, rb)
+			m = append(m, rb)
+		}
+		return m
+	})
+	return bindings, err
+}
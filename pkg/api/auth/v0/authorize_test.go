@@ -0,0 +1,229 @@
+package v0_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	authv0 "github.com/ophum/humstack/pkg/api/auth/v0"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/externalippool"
+	eippoolv0 "github.com/ophum/humstack/pkg/api/core/externalippool/v0"
+	"github.com/ophum/humstack/pkg/api/core/namespace"
+	nsv0 "github.com/ophum/humstack/pkg/api/core/namespace/v0"
+	"github.com/ophum/humstack/pkg/api/core/resourcequota"
+	rqv0 "github.com/ophum/humstack/pkg/api/core/resourcequota/v0"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/image"
+	imv0 "github.com/ophum/humstack/pkg/api/system/image/v0"
+	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
+	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestAuthorize(t *testing.T) {
+	s := memory.NewMemoryStore()
+	if _, err := authv0.BootstrapAdmin(s, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"owner", "editor", "viewer"} {
+		hashed, err := auth.HashPassword("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("user/"+id, &core.User{Meta: meta.Meta{ID: id}, Spec: core.UserSpec{Password: hashed}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rb := range []*core.RoleBinding{
+		{Meta: meta.Meta{ID: "owner"}, Spec: core.RoleBindingSpec{Role: auth.RoleGroupOwner, Group: "g1"}},
+		{Meta: meta.Meta{ID: "editor"}, Spec: core.RoleBindingSpec{Role: auth.RoleNamespaceEditor, Group: "g1", Namespace: "ns1"}},
+		{Meta: meta.Meta{ID: "viewer"}, Spec: core.RoleBindingSpec{Role: auth.RoleViewer, Group: "g1"}},
+	} {
+		rb.Spec.Subjects = []auth.Subject{{Type: auth.SubjectTypeUser, ID: rb.ID}}
+		if err := s.Put("rolebinding/"+rb.ID, rb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rq := range []*core.ResourceQuota{
+		{Meta: meta.Meta{ID: "rq1", Group: "g1"}},
+		{Meta: meta.Meta{ID: "rq2", Group: "g2"}},
+	} {
+		if err := s.Put("resourcequota/"+rq.Group+"/"+rq.ID, rq); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authh := authv0.NewAuthHandler(s)
+	v0 := r.Group("/api/v0")
+	auth.NewAuthHandler(v0, authh).RegisterHandlers()
+	api := v0.Group("", authh.Authenticate, authh.Authorize)
+	namespace.NewNamespaceHandler(api, nsv0.NewNamespaceHandler(s)).RegisterHandlers()
+	image.NewImageHandler(api, imv0.NewImageHandler(s)).RegisterHandlers()
+	externalippool.NewExternalIPPoolHandler(api, eippoolv0.NewExternalIPPoolHandler(s)).RegisterHandlers()
+	resourcequota.NewResourceQuotaHandler(api, rqv0.NewResourceQuotaHandler(s)).RegisterHandlers()
+
+	tokens := map[string]string{}
+	for _, id := range []string{"admin", "owner", "editor", "viewer"} {
+		code, token := login(t, r, id, "password")
+		if code != http.StatusOK {
+			t.Fatalf("%s: want: 200, got: %d", id, code)
+		}
+		tokens[id] = token
+	}
+
+	tests := []struct {
+		user   string
+		method string
+		path   string
+		want   int
+	}{
+		{"admin", http.MethodGet, "/api/v0/externalippools", http.StatusOK},
+		{"owner", http.MethodGet, "/api/v0/externalippools", http.StatusForbidden},
+
+		{"owner", http.MethodGet, "/api/v0/groups/g1/namespaces/ns2", http.StatusNotFound},
+		{"owner", http.MethodDelete, "/api/v0/groups/g1/namespaces/ns2", http.StatusOK},
+		{"owner", http.MethodGet, "/api/v0/groups/g2/namespaces/ns1", http.StatusForbidden},
+
+		{"editor", http.MethodDelete, "/api/v0/groups/g1/namespaces/ns1", http.StatusOK},
+		{"editor", http.MethodDelete, "/api/v0/groups/g1/namespaces/ns2", http.StatusForbidden},
+		{"editor", http.MethodGet, "/api/v0/groups/g1/images", http.StatusOK},
+		{"editor", http.MethodDelete, "/api/v0/groups/g1/images/im1", http.StatusForbidden},
+
+		{"viewer", http.MethodGet, "/api/v0/groups/g1/namespaces/ns2", http.StatusNotFound},
+		{"viewer", http.MethodDelete, "/api/v0/groups/g1/namespaces/ns2", http.StatusForbidden},
+		{"viewer", http.MethodGet, "/api/v0/groups/g2/images", http.StatusForbidden},
+
+		// ResourceQuotaは自分のgroupのもののみ取得できる
+		{"owner", http.MethodGet, "/api/v0/resourcequotas", http.StatusForbidden},
+		{"owner", http.MethodGet, "/api/v0/groups/g1/resourcequotas/rq1", http.StatusOK},
+		{"owner", http.MethodGet, "/api/v0/groups/g1/resourcequotas/rq2", http.StatusNotFound},
+		{"owner", http.MethodGet, "/api/v0/groups/g2/resourcequotas/rq2", http.StatusForbidden},
+		{"editor", http.MethodGet, "/api/v0/groups/g1/resourcequotas", http.StatusOK},
+		{"viewer", http.MethodGet, "/api/v0/groups/g1/resourcequotas/rq1", http.StatusOK},
+		// ResourceQuotaの変更はadminのみ行える
+		{"owner", http.MethodPost, "/api/v0/groups/g1/resourcequotas", http.StatusForbidden},
+		{"owner", http.MethodDelete, "/api/v0/groups/g1/resourcequotas/rq1", http.StatusForbidden},
+		{"editor", http.MethodDelete, "/api/v0/groups/g1/namespaces/ns1/resourcequotas/rq1", http.StatusForbidden},
+		{"admin", http.MethodDelete, "/api/v0/groups/g2/resourcequotas/rq2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code, _ := request(t, r, tt.method, tt.path, tokens[tt.user], nil); code != tt.want {
+			t.Errorf("%s %s %s: want: %d, got: %d", tt.user, tt.method, tt.path, tt.want, code)
+		}
+	}
+
+	// 許可されたgroupのパスでもmeta.groupで別のgroupには作成できない
+	evil := &core.Namespace{Meta: meta.Meta{ID: "evil", Group: "g2"}}
+	if code, _ := request(t, r, http.MethodPost, "/api/v0/groups/g1/namespaces", tokens["owner"], evil); code != http.StatusBadRequest {
+		t.Errorf("owner POST g1 namespace with meta.group g2: want: 400, got: %d", code)
+	}
+	if err := s.Get("namespace/g2/evil", &core.Namespace{}); err == nil {
+		t.Errorf("namespace/g2/evil should not be created")
+	}
+}
+
+// TestAuthorizePrefixCollision は許可されたgroup, namespaceのIDで始まる別のgroup, namespaceの
+// リソースが一覧に含まれないことを確認する
+func TestAuthorizePrefixCollision(t *testing.T) {
+	s := memory.NewMemoryStore()
+	for _, id := range []string{"owner", "editor"} {
+		hashed, err := auth.HashPassword("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("user/"+id, &core.User{Meta: meta.Meta{ID: id}, Spec: core.UserSpec{Password: hashed}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rb := range []*core.RoleBinding{
+		{Meta: meta.Meta{ID: "owner"}, Spec: core.RoleBindingSpec{Role: auth.RoleGroupOwner, Group: "g"}},
+		{Meta: meta.Meta{ID: "editor"}, Spec: core.RoleBindingSpec{Role: auth.RoleNamespaceEditor, Group: "g", Namespace: "dev"}},
+	} {
+		rb.Spec.Subjects = []auth.Subject{{Type: auth.SubjectTypeUser, ID: rb.ID}}
+		if err := s.Put("rolebinding/"+rb.ID, rb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ns := range []*core.Namespace{
+		{Meta: meta.Meta{ID: "dev", Group: "g"}},
+		{Meta: meta.Meta{ID: "dev2", Group: "g"}},
+		{Meta: meta.Meta{ID: "dev", Group: "g2"}},
+	} {
+		if err := s.Put("namespace/"+ns.Group+"/"+ns.ID, ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rq := range []*core.ResourceQuota{
+		{Meta: meta.Meta{ID: "rq1", Group: "g"}},
+		{Meta: meta.Meta{ID: "rq2", Group: "g2"}},
+	} {
+		if err := s.Put("resourcequota/"+rq.Group+"/"+rq.ID, rq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, vm := range []*system.VirtualMachine{
+		{Meta: meta.Meta{ID: "vm1", Group: "g", Namespace: "dev"}},
+		{Meta: meta.Meta{ID: "vm2", Group: "g", Namespace: "dev2"}},
+	} {
+		if err := s.Put("virtualmachine/"+vm.Group+"/"+vm.Namespace+"/"+vm.ID, vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authh := authv0.NewAuthHandler(s)
+	v0 := r.Group("/api/v0")
+	auth.NewAuthHandler(v0, authh).RegisterHandlers()
+	api := v0.Group("", authh.Authenticate, authh.Authorize)
+	namespace.NewNamespaceHandler(api, nsv0.NewNamespaceHandler(s)).RegisterHandlers()
+	virtualmachine.NewVirtualMachineHandler(api, vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+	resourcequota.NewResourceQuotaHandler(api, rqv0.NewResourceQuotaHandler(s)).RegisterHandlers()
+
+	tests := []struct {
+		user string
+		path string
+		key  string
+		want []string
+	}{
+		{"owner", "/api/v0/groups/g/namespaces", "namespaces", []string{"g/dev", "g/dev2"}},
+		{"editor", "/api/v0/groups/g/namespaces/dev/virtualmachines", "virtualmachines", []string{"g/vm1"}},
+		{"owner", "/api/v0/groups/g/resourcequotas", "resourcequotas", []string{"g/rq1"}},
+	}
+	for _, tt := range tests {
+		_, token := login(t, r, tt.user, "password")
+		code, data := request(t, r, http.MethodGet, tt.path, token, nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s %s: want: 200, got: %d", tt.user, tt.path, code)
+		}
+
+		res := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatal(err)
+		}
+		objs := []meta.Object{}
+		if err := json.Unmarshal(res[tt.key], &objs); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, obj := range objs {
+			got = append(got, obj.Meta.Group+"/"+obj.Meta.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s %s: want: %v, got: %v", tt.user, tt.path, tt.want, got)
+		}
+	}
+
+	_, token := login(t, r, "editor", "password")
+	if code, _ := request(t, r, http.MethodGet, "/api/v0/groups/g/namespaces/dev2/virtualmachines", token, nil); code != http.StatusForbidden {
+		t.Errorf("editor GET dev2: want: 403, got: %d", code)
+	}
+}
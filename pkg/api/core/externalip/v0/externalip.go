@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/externalip"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -38,10 +40,21 @@ func (h *ExternalIPHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey("")+"/", f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(getKey("")+"/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&eipList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalips": eipList,
+		"continue":    meta.EncodeContinueToken(next),
 	})
 }
 
@@ -50,8 +63,12 @@ func (h *ExternalIPHandler) Find(ctx *gin.Context) {
 
 	var eip core.ExternalIP
 	err := h.store.Get(getKey(eipID), &eip)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIP `%s` is not found.", eipID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIP `%s` is not found.", eipID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -76,19 +93,40 @@ func (h *ExternalIPHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	key := getKey(request.ID)
-	var eip core.ExternalIP
-	err = h.store.Get(key, &eip)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: externalip `%s` is already exists.", request.Name), nil)
+	// パスにgroupを含まないので、ResourceQuotaはmeta.groupとmeta.namespaceに対して適用する
+	// meta.groupはadmissionで必須にしている
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeExternalIPV0,
+		Operation: admission.OperationCreate,
+		Group:     request.Group,
+		Namespace: request.Namespace,
+		Object:    &request,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
 		return
 	}
 
+	key := getKey(request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeExternalIPV0
-	h.store.Put(key, request)
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: externalip `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"externalip": request,
@@ -111,17 +149,61 @@ func (h *ExternalIPHandler) Update(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eip core.ExternalIP
 	err = h.store.Get(key, &eip)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: externalip `%s` is not found.", request.ID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: externalip `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// groupを変更するとResourceQuotaの使用量を移せてしまうので変更できないようにする
+	// groupを必須にする前に作成されたExternalIPはgroupが空なので、空の場合のみ設定できる
+	if eip.Group != "" && (request.Group != eip.Group || request.Namespace != eip.Namespace) {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change group and namespace."), nil)
+		return
+	}
 
-	h.store.Put(key, request)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = eip.ResourceVersion
+	}
+
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeExternalIPV0,
+		Operation: admission.OperationUpdate,
+		Group:     request.Group,
+		Namespace: request.Namespace,
+		Object:    &request,
+		OldObject: &eip,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ExternalIP `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalip": request,
@@ -135,7 +217,10 @@ func (h *ExternalIPHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 }
 
 func getExternalIPID(ctx *gin.Context) string {
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/externalippool"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -38,10 +40,21 @@ func (h *ExternalIPPoolHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey("")+"/", f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(getKey("")+"/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&eippoolList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalippools": eippoolList,
+		"continue":        meta.EncodeContinueToken(next),
 	})
 }
 
@@ -50,8 +63,12 @@ func (h *ExternalIPPoolHandler) Find(ctx *gin.Context) {
 
 	var eippool core.ExternalIPPool
 	err := h.store.Get(getKey(eippoolID), &eippool)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIPPool `%s` is not found.", eippoolID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ExternalIPPool `%s` is not found.", eippoolID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -76,19 +93,31 @@ func (h *ExternalIPPoolHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	key := getKey(request.ID)
-	var eippool core.ExternalIPPool
-	err = h.store.Get(key, &eippool)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: externalippool `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeExternalIPPoolV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeExternalIPPoolV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: externalippool `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"externalippool": request,
@@ -111,17 +140,47 @@ func (h *ExternalIPPoolHandler) Update(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var eippool core.ExternalIPPool
 	err = h.store.Get(key, &eippool)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: externalippool `%s` is not found.", request.ID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: externalippool `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = eippool.ResourceVersion
+	}
 
-	h.store.Put(key, request)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeExternalIPPoolV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &eippool,
+	}) {
+		return
+	}
+
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ExternalIPPool `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"externalippool": request,
@@ -135,7 +194,10 @@ func (h *ExternalIPPoolHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 }
 
 func getExternalIPPoolID(ctx *gin.Context) string {
@@ -1,11 +1,13 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/group"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -35,10 +37,22 @@ func (h *GroupHandler) FindAll(ctx *gin.Context) {
 		}
 		return m
 	}
-	h.store.List("group/", f)
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage("group/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&groupList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"groups": groupList,
+		"groups":   groupList,
+		"continue": meta.EncodeContinueToken(next),
 	})
 }
 
@@ -46,8 +60,12 @@ func (h *GroupHandler) Find(ctx *gin.Context) {
 	groupID := ctx.Param("group_id")
 	var group core.Group
 	err := h.store.Get(getKey(groupID), &group)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Group `%s` is not found.", groupID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Group `%s` is not found.", groupID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -72,19 +90,31 @@ func (h *GroupHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	key := getKey(request.ID)
-	var group core.Group
-	err = h.store.Get(key, &group)
-	if err != nil && err.Error() != "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: group `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeGroupV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeGroupV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: group `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"group": request,
@@ -107,17 +137,47 @@ func (h *GroupHandler) Update(ctx *gin.Context) {
 	}
 
 	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var group core.Group
 	err = h.store.Get(key, &group)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: group `%s` is not found.", request.ID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: group `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = group.ResourceVersion
+	}
 
-	h.store.Put(key, request)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeGroupV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &group,
+	}) {
+		return
+	}
+
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Group `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"group": request,
@@ -131,7 +191,10 @@ func (h *GroupHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 }
 
 func getKey(id string) string {
@@ -6,6 +6,7 @@ import (
 
 type NamespaceHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
@@ -14,6 +15,9 @@ type NamespaceHandlerInterface interface {
 
 const (
 	basePath = "groups/:group_id/namespaces"
+
+	// clusterPath は全てのグループのリソースを取得する
+	clusterPath = "namespaces"
 )
 
 type NamespaceHandler struct {
@@ -37,4 +41,6 @@ func (h *NamespaceHandler) RegisterHandlers() {
 		ns.PUT("/:namespace_id", h.nhi.Update)
 		ns.DELETE("/:namespace_id", h.nhi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.nhi.FindAllInCluster)
 }
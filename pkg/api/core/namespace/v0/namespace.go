@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/namespace"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -28,6 +30,15 @@ func NewNamespaceHandler(store store.Store) *NamespaceHandler {
 func (h *NamespaceHandler) FindAll(ctx *gin.Context) {
 	groupID := getGroupID(ctx)
 
+	h.findAll(ctx, getKey(groupID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループのNamespaceを返す
+func (h *NamespaceHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "")+"/")
+}
+
+func (h *NamespaceHandler) findAll(ctx *gin.Context, prefix string) {
 	nsList := []*core.Namespace{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -38,10 +49,22 @@ func (h *NamespaceHandler) FindAll(ctx *gin.Context) {
 		}
 		return m
 	}
-	h.store.List(getKey(groupID, ""), f)
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&nsList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"namespaces": nsList,
+		"continue":   meta.EncodeContinueToken(next),
 	})
 }
 
@@ -51,8 +74,12 @@ func (h *NamespaceHandler) Find(ctx *gin.Context) {
 
 	var ns core.Namespace
 	err := h.store.Get(getKey(groupID, nsID), &ns)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Namespace `%s` is not found.", nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Namespace `%s` is not found.", nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -62,6 +89,8 @@ func (h *NamespaceHandler) Find(ctx *gin.Context) {
 }
 
 func (h *NamespaceHandler) Create(ctx *gin.Context) {
+	groupID := getGroupID(ctx)
+
 	var request core.Namespace
 
 	err := ctx.Bind(&request)
@@ -71,25 +100,43 @@ func (h *NamespaceHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		log.Println("id is empty")
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: ID is empty."), nil)
 		return
 	}
 
-	key := getKey(request.Group, request.ID)
-	var ns core.Namespace
-	err = h.store.Get(key, &ns)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: namespace `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNamespaceV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(groupID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNamespaceV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: namespace `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"namespace": request,
@@ -108,27 +155,59 @@ func (h *NamespaceHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID != nsID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change id."), nil)
 		return
 	}
 
-	if request.Group != groupID {
-		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change group"), nil)
-	}
+	key := getKey(groupID, request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
 
-	key := getKey(request.Group, request.ID)
 	var ns core.Namespace
 	err = h.store.Get(key, &ns)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace `%s` is not found.", request.ID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = ns.ResourceVersion
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNamespaceV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Object:    &request,
+		OldObject: &ns,
+	}) {
+		return
+	}
 
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Namespace `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"namespace": request,
@@ -143,7 +222,10 @@ func (h *NamespaceHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 }
 
 func getGroupID(ctx *gin.Context) string {
@@ -6,9 +6,11 @@ import (
 
 type NetworkHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 }
 
@@ -19,6 +21,9 @@ type NetworkHandler struct {
 
 const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/networks"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "networks"
 )
 
 func NewNetworkHandler(router *gin.RouterGroup, nhi NetworkHandlerInterface) *NetworkHandler {
@@ -34,7 +39,10 @@ func (h *NetworkHandler) RegisterHandlers() {
 		ns.GET("", h.nhi.FindAll)
 		ns.GET("/:network_id", h.nhi.Find)
 		ns.POST("", h.nhi.Create)
+		ns.PUT("/:network_id/status", h.nhi.UpdateStatus)
 		ns.PUT("/:network_id", h.nhi.Update)
 		ns.DELETE("/:network_id", h.nhi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.nhi.FindAllInCluster)
 }
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/core/network"
 	"github.com/ophum/humstack/pkg/api/meta"
@@ -28,6 +30,15 @@ func NewNetworkHandler(store store.Store) *NetworkHandler {
 func (h *NetworkHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのNetworkを返す
+func (h *NetworkHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *NetworkHandler) findAll(ctx *gin.Context, prefix string) {
 	netList := []*core.Network{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -39,10 +50,21 @@ func (h *NetworkHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, nsID, ""), f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&netList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"networks": netList,
+		"continue": meta.EncodeContinueToken(next),
 	})
 }
 
@@ -51,8 +73,12 @@ func (h *NetworkHandler) Find(ctx *gin.Context) {
 
 	var net core.Network
 	err := h.store.Get(getKey(groupID, nsID, netID), &net)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Network `%s` is not found.", nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Network `%s` is not found.", nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -72,6 +98,11 @@ func (h *NetworkHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
@@ -79,25 +110,48 @@ func (h *NetworkHandler) Create(ctx *gin.Context) {
 
 	var ns core.Namespace
 	err = h.store.Get(filepath.Join("namespace", groupID, nsID), &ns)
-	if err != nil && err.Error() == "Not Found" {
-		log.Println("error")
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			log.Println("error")
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	key := getKey(groupID, nsID, request.ID)
-	var net core.Network
-	err = h.store.Get(key, &net)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Network `%s` is already exists.", request.Name), nil)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeNetworkV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
 		return
 	}
 
+	key := getKey(groupID, nsID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNetworkV0
-	h.store.Put(key, request)
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Network `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"network": request,
@@ -114,26 +168,129 @@ func (h *NetworkHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if netID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Network Name."), nil)
 		return
 	}
 
 	key := getKey(groupID, nsID, netID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var net core.Network
 	err = h.store.Get(key, &net)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Network `%s` is not found in Namespace `%s`.", netID, nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Network `%s` is not found in Namespace `%s`.", netID, nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = net.ResourceVersion
+	}
+
+	// statusは/statusからのみ更新する
+	request.Status = net.Status
+
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeNetworkV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+		OldObject: &net,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Network `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"network": request,
+	})
+}
+
+func (h *NetworkHandler) UpdateStatus(ctx *gin.Context) {
+	groupID, nsID, netID := getIDs(ctx)
+
+	var request core.Network
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if netID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Network Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, netID)
+
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var net core.Network
+	err = h.store.Get(key, &net)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Network `%s` is not found.", netID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = net.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	net.Status = request.Status
+	net.ResourceHash = request.ResourceHash
+	net.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &net)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Network `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"network": request,
+		"network": net,
 	})
 }
 
@@ -144,7 +301,10 @@ func (h *NetworkHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"network": nil,
 	})
@@ -0,0 +1,51 @@
+package resourcequota
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type ResourceQuotaHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	// groupPath はgroup全体、namespacePath はnamespaceのResourceQuota
+	// groupPathの一覧にはgroup内の全てのnamespaceのResourceQuotaも含まれる
+	groupPath     = "groups/:group_id/resourcequotas"
+	namespacePath = "groups/:group_id/namespaces/:namespace_id/resourcequotas"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "resourcequotas"
+)
+
+type ResourceQuotaHandler struct {
+	router *gin.RouterGroup
+	rqhi   ResourceQuotaHandlerInterface
+}
+
+func NewResourceQuotaHandler(router *gin.RouterGroup, rqhi ResourceQuotaHandlerInterface) *ResourceQuotaHandler {
+	return &ResourceQuotaHandler{
+		router: router,
+		rqhi:   rqhi,
+	}
+}
+
+func (h *ResourceQuotaHandler) RegisterHandlers() {
+	for _, path := range []string{groupPath, namespacePath} {
+		rq := h.router.Group(path)
+		{
+			rq.GET("", h.rqhi.FindAll)
+			rq.GET("/:resource_quota_id", h.rqhi.Find)
+			rq.POST("", h.rqhi.Create)
+			rq.PUT("/:resource_quota_id", h.rqhi.Update)
+			rq.DELETE("/:resource_quota_id", h.rqhi.Delete)
+		}
+	}
+
+	h.router.GET(clusterPath, h.rqhi.FindAllInCluster)
+}
@@ -0,0 +1,267 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/admission/validators"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/resourcequota"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type ResourceQuotaHandler struct {
+	resourcequota.ResourceQuotaHandlerInterface
+
+	store store.Store
+}
+
+func NewResourceQuotaHandler(store store.Store) *ResourceQuotaHandler {
+	return &ResourceQuotaHandler{
+		store: store,
+	}
+}
+
+func (h *ResourceQuotaHandler) FindAll(ctx *gin.Context) {
+	groupID, nsID, _ := getIDs(ctx)
+
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのResourceQuotaを返す
+func (h *ResourceQuotaHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *ResourceQuotaHandler) findAll(ctx *gin.Context, prefix string) {
+	rqList := []*core.ResourceQuota{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			rq := &core.ResourceQuota{}
+			rqList = append(rqList, rq)
+			m = append(m, rq)
+		}
+		return m
+	}
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&rqList)
+
+	for _, rq := range rqList {
+		if !h.setUsed(ctx, rq) {
+			return
+		}
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"resourcequotas": rqList,
+		"continue":       meta.EncodeContinueToken(next),
+	})
+}
+
+func (h *ResourceQuotaHandler) Find(ctx *gin.Context) {
+	groupID, nsID, rqID := getIDs(ctx)
+
+	var rq core.ResourceQuota
+	err := h.store.Get(getKey(groupID, nsID, rqID), &rq)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: ResourceQuota `%s` is not found.", rqID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	if !h.setUsed(ctx, &rq) {
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"resourcequota": rq,
+	})
+}
+
+func (h *ResourceQuotaHandler) Create(ctx *gin.Context) {
+	groupID, nsID, _ := getIDs(ctx)
+
+	var request core.ResourceQuota
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: ID is empty."), nil)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeResourceQuotaV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}) {
+		return
+	}
+
+	key := getKey(groupID, nsID, request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	// statusは取得時に計算するので保存しない
+	request.Status = core.ResourceQuotaStatus{}
+	request.APIType = meta.APITypeResourceQuotaV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ResourceQuota `%s` is already exists.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"resourcequota": request,
+	})
+}
+
+func (h *ResourceQuotaHandler) Update(ctx *gin.Context) {
+	groupID, nsID, rqID := getIDs(ctx)
+
+	var request core.ResourceQuota
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID != rqID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change id."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var rq core.ResourceQuota
+	err = h.store.Get(key, &rq)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: ResourceQuota `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = rq.ResourceVersion
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeResourceQuotaV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+		OldObject: &rq,
+	}) {
+		return
+	}
+
+	// statusは取得時に計算するので保存しない
+	request.Status = core.ResourceQuotaStatus{}
+	request.APIType = meta.APITypeResourceQuotaV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ResourceQuota `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"resourcequota": request,
+	})
+}
+
+func (h *ResourceQuotaHandler) Delete(ctx *gin.Context) {
+	groupID, nsID, rqID := getIDs(ctx)
+
+	key := getKey(groupID, nsID, rqID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"resourcequota": nil,
+	})
+}
+
+// setUsed はrqのstatusに現在の使用量を設定する
+func (h *ResourceQuotaHandler) setUsed(ctx *gin.Context, rq *core.ResourceQuota) bool {
+	used, err := validators.ResourceQuotaUsed(h.store, rq)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return false
+	}
+	rq.Status.Used = used
+	return true
+}
+
+func getIDs(ctx *gin.Context) (groupID, nsID, rqID string) {
+	groupID = ctx.Param("group_id")
+	nsID = ctx.Param("namespace_id")
+	rqID = ctx.Param("resource_quota_id")
+	return groupID, nsID, rqID
+}
+
+// getKey はnamespaceが空の場合はgroup全体のResourceQuotaのkeyになる
+func getKey(groupID, nsID, id string) string {
+	return filepath.Join("resourcequota", groupID, nsID, id)
+}
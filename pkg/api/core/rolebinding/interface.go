@@ -0,0 +1,40 @@
+package rolebinding
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type RoleBindingHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "rolebindings"
+)
+
+type RoleBindingHandler struct {
+	router *gin.RouterGroup
+	rbi    RoleBindingHandlerInterface
+}
+
+func NewRoleBindingHandler(router *gin.RouterGroup, rbi RoleBindingHandlerInterface) *RoleBindingHandler {
+	return &RoleBindingHandler{
+		router: router,
+		rbi:    rbi,
+	}
+}
+
+func (h *RoleBindingHandler) RegisterHandlers() {
+	ns := h.router.Group(basePath)
+	{
+		ns.GET("", h.rbi.FindAll)
+		ns.GET("/:role_binding_id", h.rbi.Find)
+		ns.POST("", h.rbi.Create)
+		ns.PUT("/:role_binding_id", h.rbi.Update)
+		ns.DELETE("/:role_binding_id", h.rbi.Delete)
+	}
+}
@@ -0,0 +1,254 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/rolebinding"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type RoleBindingHandler struct {
+	rolebinding.RoleBindingHandlerInterface
+
+	store store.Store
+}
+
+func NewRoleBindingHandler(store store.Store) *RoleBindingHandler {
+	return &RoleBindingHandler{
+		store: store,
+	}
+}
+
+func (h *RoleBindingHandler) FindAll(ctx *gin.Context) {
+	rbList := []*core.RoleBinding{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			rb := &core.RoleBinding{}
+			rbList = append(rbList, rb)
+			m = append(m, rb)
+		}
+		return m
+	}
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(getKey("")+"/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&rbList)
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"rolebindings": rbList,
+		"continue":     meta.EncodeContinueToken(next),
+	})
+}
+
+func (h *RoleBindingHandler) Find(ctx *gin.Context) {
+	rbID := getRoleBindingID(ctx)
+
+	var rb core.RoleBinding
+	err := h.store.Get(getKey(rbID), &rb)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("RoleBinding `%s` is not found.", rbID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"rolebinding": rb,
+	})
+}
+
+func (h *RoleBindingHandler) Create(ctx *gin.Context) {
+	var request core.RoleBinding
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: ID is empty."), nil)
+		return
+	}
+
+	if err := validateRoleBinding(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeRoleBindingV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
+		return
+	}
+
+	key := getKey(request.ID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	request.APIType = meta.APITypeRoleBindingV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: rolebinding `%s` is already exists.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"rolebinding": request,
+	})
+}
+
+func (h *RoleBindingHandler) Update(ctx *gin.Context) {
+	rbID := getRoleBindingID(ctx)
+	var request core.RoleBinding
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID != rbID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change id."), nil)
+		return
+	}
+
+	if err := validateRoleBinding(&request); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var rb core.RoleBinding
+	err = h.store.Get(key, &rb)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: rolebinding `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = rb.ResourceVersion
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeRoleBindingV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &rb,
+	}) {
+		return
+	}
+
+	request.APIType = meta.APITypeRoleBindingV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: RoleBinding `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"rolebinding": request,
+	})
+}
+
+func (h *RoleBindingHandler) Delete(ctx *gin.Context) {
+	rbID := getRoleBindingID(ctx)
+
+	key := getKey(rbID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{})
+}
+
+// validateRoleBinding はRoleごとにGroupとNamespaceの指定が正しいかを確認する
+func validateRoleBinding(rb *core.RoleBinding) error {
+	spec := rb.Spec
+	switch spec.Role {
+	case auth.RoleAdmin:
+		if spec.Group != "" || spec.Namespace != "" {
+			return fmt.Errorf("Error: role `%s` can't have group or namespace.", spec.Role)
+		}
+	case auth.RoleGroupOwner:
+		if spec.Group == "" || spec.Namespace != "" {
+			return fmt.Errorf("Error: role `%s` requires group and can't have namespace.", spec.Role)
+		}
+	case auth.RoleNamespaceEditor:
+		if spec.Group == "" || spec.Namespace == "" {
+			return fmt.Errorf("Error: role `%s` requires group and namespace.", spec.Role)
+		}
+	case auth.RoleViewer:
+		if spec.Group == "" {
+			return fmt.Errorf("Error: role `%s` requires group.", spec.Role)
+		}
+	default:
+		return fmt.Errorf("Error: unknown role `%s`.", spec.Role)
+	}
+
+	for _, subject := range spec.Subjects {
+		if subject.Type != auth.SubjectTypeUser && subject.Type != auth.SubjectTypeServiceAccount {
+			return fmt.Errorf("Error: unknown subject type `%s`.", subject.Type)
+		}
+		if subject.ID == "" {
+			return fmt.Errorf("Error: subject id is empty.")
+		}
+	}
+	return nil
+}
+
+func getRoleBindingID(ctx *gin.Context) string {
+	return ctx.Param("role_binding_id")
+}
+
+func getKey(id string) string {
+	return filepath.Join("rolebinding", id)
+}
@@ -0,0 +1,40 @@
+package serviceaccount
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	RotateToken(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "serviceaccounts"
+)
+
+type ServiceAccountHandler struct {
+	router *gin.RouterGroup
+	sahi   ServiceAccountHandlerInterface
+}
+
+func NewServiceAccountHandler(router *gin.RouterGroup, sahi ServiceAccountHandlerInterface) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		router: router,
+		sahi:   sahi,
+	}
+}
+
+func (h *ServiceAccountHandler) RegisterHandlers() {
+	sa := h.router.Group(basePath)
+	{
+		sa.GET("", h.sahi.FindAll)
+		sa.GET("/:service_account_id", h.sahi.Find)
+		sa.POST("", h.sahi.Create)
+		sa.POST("/:service_account_id/token", h.sahi.RotateToken)
+		sa.DELETE("/:service_account_id", h.sahi.Delete)
+	}
+}
@@ -0,0 +1,228 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/serviceaccount"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type ServiceAccountHandler struct {
+	serviceaccount.ServiceAccountHandlerInterface
+
+	store store.Store
+}
+
+func NewServiceAccountHandler(store store.Store) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		store: store,
+	}
+}
+
+func (h *ServiceAccountHandler) FindAll(ctx *gin.Context) {
+	saList := []*core.ServiceAccount{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			sa := &core.ServiceAccount{}
+			saList = append(saList, sa)
+			m = append(m, sa)
+		}
+		return m
+	}
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage("serviceaccount/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&saList)
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"serviceaccounts": saList,
+		"continue":        meta.EncodeContinueToken(next),
+	})
+}
+
+func (h *ServiceAccountHandler) Find(ctx *gin.Context) {
+	saID := ctx.Param("service_account_id")
+
+	var sa core.ServiceAccount
+	err := h.store.Get(getKey(saID), &sa)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ServiceAccount `%s` is not found.", saID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"serviceaccount": sa,
+	})
+}
+
+// Create はサービスアカウントを作成して期限なしのトークンを発行する
+// トークンは保存しないのでレスポンスでのみ取得できる
+func (h *ServiceAccountHandler) Create(ctx *gin.Context) {
+	var request core.ServiceAccount
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeServiceAccountV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
+		return
+	}
+
+	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	rawToken, token, err := newServiceAccountToken(request.ID)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	request.APIType = meta.APITypeServiceAccountV0
+	request.Status.TokenID = token.ID
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{
+			store.OpPut(auth.TokenKey(token.ID), token),
+			store.OpPut(key, &request),
+		},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: serviceaccount `%s` is already exists.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"serviceaccount": request,
+		"token":          rawToken,
+	})
+}
+
+// RotateToken はトークンを再発行する
+// 以前のトークンは使えなくなる
+func (h *ServiceAccountHandler) RotateToken(ctx *gin.Context) {
+	saID := ctx.Param("service_account_id")
+
+	key := getKey(saID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var sa core.ServiceAccount
+	err := h.store.Get(key, &sa)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: serviceaccount `%s` is not found.", saID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	rawToken, token, err := newServiceAccountToken(saID)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	oldTokenID := sa.Status.TokenID
+	sa.Status.TokenID = token.ID
+	ops := []store.Op{
+		store.OpPut(auth.TokenKey(token.ID), token),
+		store.OpPut(key, &sa),
+	}
+	if oldTokenID != "" {
+		ops = append(ops, store.OpDelete(auth.TokenKey(oldTokenID)))
+	}
+	err = h.store.Txn([]store.Compare{store.CompareResourceVersion(key, sa.ResourceVersion)}, ops)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ServiceAccount `%s` has been modified. resourceVersion is stale.", saID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"serviceaccount": sa,
+		"token":          rawToken,
+	})
+}
+
+// Delete はサービスアカウントとそのトークンを削除する
+func (h *ServiceAccountHandler) Delete(ctx *gin.Context) {
+	saID := ctx.Param("service_account_id")
+
+	key := getKey(saID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var sa core.ServiceAccount
+	err := h.store.Get(key, &sa)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{})
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	ops := []store.Op{store.OpDelete(key)}
+	if sa.Status.TokenID != "" {
+		ops = append(ops, store.OpDelete(auth.TokenKey(sa.Status.TokenID)))
+	}
+	if err := h.store.Txn(nil, ops); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{})
+}
+
+func newServiceAccountToken(saID string) (string, *auth.Token, error) {
+	return auth.NewToken(auth.Subject{
+		Type: auth.SubjectTypeServiceAccount,
+		ID:   saID,
+	}, nil)
+}
+
+func getKey(saID string) string {
+	return "serviceaccount/" + saID
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"github.com/ophum/humstack/pkg/api/auth"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 )
@@ -28,7 +29,9 @@ type Group struct {
 }
 
 type UserSpec struct {
-	Password string `json:"password" yaml:"password"`
+	// Password は作成・更新時に平文で受け取り、ハッシュ化して保存する
+	// レスポンスには含めない
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
 }
 type User struct {
 	meta.Meta `json:"meta" yaml:"meta"`
@@ -36,6 +39,40 @@ type User struct {
 	Spec UserSpec `json:"spec" yaml:"spec"`
 }
 
+type ServiceAccountSpec struct {
+}
+
+type ServiceAccountStatus struct {
+	// TokenID は発行したトークンのID
+	TokenID string `json:"tokenID" yaml:"tokenID"`
+}
+
+// ServiceAccount はagentなどのプログラムがapiserverにアクセスするためのアカウント
+type ServiceAccount struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   ServiceAccountSpec   `json:"spec" yaml:"spec"`
+	Status ServiceAccountStatus `json:"status" yaml:"status"`
+}
+
+type RoleBindingSpec struct {
+	Role auth.Role `json:"role" yaml:"role"`
+
+	// Group が空の場合はクラスタ全体に対するRoleになる(adminのみ)
+	Group string `json:"group" yaml:"group"`
+	// Namespace が空の場合はGroup全体に対するRoleになる
+	Namespace string `json:"namespace" yaml:"namespace"`
+
+	Subjects []auth.Subject `json:"subjects" yaml:"subjects"`
+}
+
+// RoleBinding はユーザーやサービスアカウントにRoleを割り当てる
+type RoleBinding struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec RoleBindingSpec `json:"spec" yaml:"spec"`
+}
+
 type ExternalIPPoolSpec struct {
 	IPv4CIDR string `json:"ipv4CIDR" yaml:"ipv4CIDR"`
 	IPv6CIDR string `json:"ipv6CIDR" yaml:"ipv6CIDR"`
@@ -74,6 +111,41 @@ type ExternalIP struct {
 	Spec ExternalIPSpec `json:"spec" yaml:"spec"`
 }
 
+// ResourceQuotaResources はResourceQuotaで制限するリソース量
+// 空の場合は制限しない
+type ResourceQuotaResources struct {
+	// RequestVcpus, RequestMemory はVirtualMachineのrequestVcpus, requestMemoryの合計
+	RequestVcpus  string `json:"requestVcpus" yaml:"requestVcpus"`
+	RequestMemory string `json:"requestMemory" yaml:"requestMemory"`
+	// RequestSize はBlockStorageのrequestSizeの合計
+	RequestSize string `json:"requestSize" yaml:"requestSize"`
+
+	// 以下はリソースの数
+	VirtualMachines string `json:"virtualMachines" yaml:"virtualMachines"`
+	BlockStorages   string `json:"blockStorages" yaml:"blockStorages"`
+	Networks        string `json:"networks" yaml:"networks"`
+	VirtualRouters  string `json:"virtualRouters" yaml:"virtualRouters"`
+	ExternalIPs     string `json:"externalIPs" yaml:"externalIPs"`
+}
+
+type ResourceQuotaSpec struct {
+	Hard ResourceQuotaResources `json:"hard" yaml:"hard"`
+}
+
+type ResourceQuotaStatus struct {
+	// Used は取得時に計算した現在の使用量
+	Used ResourceQuotaResources `json:"used" yaml:"used"`
+}
+
+// ResourceQuota はmeta.groupとmeta.namespaceのリソースの使用量を制限する
+// namespaceが空の場合はgroup内の全てのnamespaceの合計を制限する
+type ResourceQuota struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec   ResourceQuotaSpec   `json:"spec" yaml:"spec"`
+	Status ResourceQuotaStatus `json:"status" yaml:"status"`
+}
+
 type NetworkSpec struct {
 	Template system.NodeNetwork `json:"template" yaml:"template"`
 }
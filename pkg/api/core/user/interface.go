@@ -0,0 +1,40 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "users"
+)
+
+type UserHandler struct {
+	router *gin.RouterGroup
+	uhi    UserHandlerInterface
+}
+
+func NewUserHandler(router *gin.RouterGroup, uhi UserHandlerInterface) *UserHandler {
+	return &UserHandler{
+		router: router,
+		uhi:    uhi,
+	}
+}
+
+func (h *UserHandler) RegisterHandlers() {
+	u := h.router.Group(basePath)
+	{
+		u.GET("", h.uhi.FindAll)
+		u.GET("/:user_id", h.uhi.Find)
+		u.POST("", h.uhi.Create)
+		u.PUT("/:user_id", h.uhi.Update)
+		u.DELETE("/:user_id", h.uhi.Delete)
+	}
+}
@@ -0,0 +1,235 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/core/user"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type UserHandler struct {
+	user.UserHandlerInterface
+
+	store store.Store
+}
+
+func NewUserHandler(store store.Store) *UserHandler {
+	return &UserHandler{
+		store: store,
+	}
+}
+
+func (h *UserHandler) FindAll(ctx *gin.Context) {
+	userList := []*core.User{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			user := &core.User{}
+			userList = append(userList, user)
+			m = append(m, user)
+		}
+		return m
+	}
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage("user/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	// パスワードのハッシュで絞り込めないように先に取り除く
+	for _, user := range userList {
+		user.Spec.Password = ""
+	}
+	options.Filter(&userList)
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"users":    userList,
+		"continue": meta.EncodeContinueToken(next),
+	})
+}
+
+func (h *UserHandler) Find(ctx *gin.Context) {
+	userID := ctx.Param("user_id")
+
+	var user core.User
+	err := h.store.Get(getKey(userID), &user)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("User `%s` is not found.", userID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	user.Spec.Password = ""
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"user": user,
+	})
+}
+
+func (h *UserHandler) Create(ctx *gin.Context) {
+	var request core.User
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
+		return
+	}
+	if request.Spec.Password == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: password is empty."), nil)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeUserV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
+		return
+	}
+
+	key := getKey(request.ID)
+	hashed, err := auth.HashPassword(request.Spec.Password)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+		return
+	}
+	request.Spec.Password = hashed
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	request.APIType = meta.APITypeUserV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: user `%s` is already exists.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	request.Spec.Password = ""
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"user": request,
+	})
+}
+
+// Update はpasswordが空の場合は現在のパスワードのまま更新する
+func (h *UserHandler) Update(ctx *gin.Context) {
+	userID := ctx.Param("user_id")
+
+	var request core.User
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID != userID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change id."), nil)
+		return
+	}
+
+	key := getKey(userID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var user core.User
+	err = h.store.Get(key, &user)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: user `%s` is not found.", userID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = user.ResourceVersion
+	}
+
+	if request.Spec.Password == "" {
+		request.Spec.Password = user.Spec.Password
+	} else {
+		hashed, err := auth.HashPassword(request.Spec.Password)
+		if err != nil {
+			meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+			return
+		}
+		request.Spec.Password = hashed
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeUserV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &user,
+	}) {
+		return
+	}
+
+	request.APIType = meta.APITypeUserV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: User `%s` has been modified. resourceVersion is stale.", userID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	request.Spec.Password = ""
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"user": request,
+	})
+}
+
+// Delete はユーザーを削除する
+// 発行済みのトークンはユーザーが存在しないため使えなくなる
+func (h *UserHandler) Delete(ctx *gin.Context) {
+	userID := ctx.Param("user_id")
+
+	key := getKey(userID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{})
+}
+
+func getKey(userID string) string {
+	return "user/" + userID
+}
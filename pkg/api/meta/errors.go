@@ -0,0 +1,51 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConflict はerrors.Isで競合による失敗かどうかを判定するために使う
+var ErrConflict = errors.New("conflict")
+
+// ConflictError はapiserverが409 Conflictを返した場合にclientが返すエラー
+// resourceVersionが古い場合に返るので、最新のリソースを取得し直してから再試行する
+type ConflictError struct {
+	Message string
+}
+
+func NewConflictError(message interface{}) *ConflictError {
+	return &ConflictError{
+		Message: fmt.Sprint(message),
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// ErrNotFound はerrors.Isでリソースが存在しないかどうかを判定するために使う
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError はapiserverが404 Not Foundを返した場合にclientが返すエラー
+type NotFoundError struct {
+	Message string
+}
+
+func NewNotFoundError(message interface{}) *NotFoundError {
+	return &NotFoundError{
+		Message: fmt.Sprint(message),
+	}
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
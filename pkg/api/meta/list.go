@@ -0,0 +1,135 @@
+package meta
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultListLimit はclientがListで1回に取得する件数
+const DefaultListLimit int64 = 500
+
+// ListOptions はFindAllのlimit, continue, labelSelector, fieldSelectorのクエリ
+type ListOptions struct {
+	// Limit が0の場合は全て取得する
+	// セレクタで絞り込む前の件数なので、返す件数はLimitより少なくなることがある
+	Limit int64
+	// StartKey はcontinueのtokenから取り出した次に取得するstoreのkey
+	StartKey string
+
+	LabelSelector LabelSelector
+	FieldSelector FieldSelector
+}
+
+// GetListOptions はlimit, continue, labelSelector, fieldSelectorのクエリを取得する
+func GetListOptions(ctx *gin.Context) (ListOptions, error) {
+	options := ListOptions{}
+
+	ls, err := ParseLabelSelector(ctx.Query("labelSelector"))
+	if err != nil {
+		return options, err
+	}
+	options.LabelSelector = ls
+
+	fs, err := ParseFieldSelector(ctx.Query("fieldSelector"))
+	if err != nil {
+		return options, err
+	}
+	options.FieldSelector = fs
+
+	if limit := ctx.Query("limit"); limit != "" {
+		l, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || l < 0 {
+			return options, fmt.Errorf("Error: invalid limit `%s`.", limit)
+		}
+		options.Limit = l
+	}
+
+	if continueToken := ctx.Query("continue"); continueToken != "" {
+		startKey, err := base64.RawURLEncoding.DecodeString(continueToken)
+		if err != nil || len(startKey) == 0 {
+			return options, fmt.Errorf("Error: invalid continue token `%s`.", continueToken)
+		}
+		options.StartKey = string(startKey)
+	}
+
+	return options, nil
+}
+
+// EncodeContinueToken はstoreのkeyをcontinueのtokenにする
+// keyが空の場合は続きがないので空を返す
+func EncodeContinueToken(key string) string {
+	if key == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// Filter はlistPtrが指すスライスからセレクタに一致しない要素を取り除く
+// 要素はmetaを持つリソースのポインタであること
+func (o ListOptions) Filter(listPtr interface{}) {
+	if len(o.LabelSelector) == 0 && len(o.FieldSelector) == 0 {
+		return
+	}
+
+	list := reflect.ValueOf(listPtr).Elem()
+	filtered := reflect.MakeSlice(list.Type(), 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		if o.matches(list.Index(i).Interface()) {
+			filtered = reflect.Append(filtered, list.Index(i))
+		}
+	}
+	list.Set(filtered)
+}
+
+func (o ListOptions) matches(item interface{}) bool {
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return false
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(itemJSON, &obj); err != nil {
+		return false
+	}
+
+	labels := map[string]string{}
+	if m, ok := obj["meta"].(map[string]interface{}); ok {
+		if l, ok := m["labels"].(map[string]interface{}); ok {
+			for k, v := range l {
+				labels[k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return o.LabelSelector.Matches(labels) && o.FieldSelector.Matches(obj)
+}
+
+// ListQuery はclientがFindAllに付けるクエリ
+// 空の項目はクエリに含めない
+type ListQuery struct {
+	Limit         int64
+	Continue      string
+	LabelSelector string
+	FieldSelector string
+}
+
+func (q ListQuery) Params() map[string]string {
+	params := map[string]string{}
+	if q.Limit > 0 {
+		params["limit"] = strconv.FormatInt(q.Limit, 10)
+	}
+	if q.Continue != "" {
+		params["continue"] = q.Continue
+	}
+	if q.LabelSelector != "" {
+		params["labelSelector"] = q.LabelSelector
+	}
+	if q.FieldSelector != "" {
+		params["fieldSelector"] = q.FieldSelector
+	}
+	return params
+}
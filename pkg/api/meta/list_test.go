@@ -0,0 +1,86 @@
+package meta
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetListOptions(t *testing.T) {
+	newContext := func(query string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("GET", "/?"+query, nil)
+		return ctx
+	}
+
+	token := EncodeContinueToken("core/network/group1/ns1/net2")
+	options, err := GetListOptions(newContext("limit=10&continue=" + token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Limit != 10 || options.StartKey != "core/network/group1/ns1/net2" {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+
+	options, err = GetListOptions(newContext(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Limit != 0 || options.StartKey != "" {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+
+	for _, query := range []string{"limit=-1", "limit=a", "continue=%21%21"} {
+		if _, err := GetListOptions(newContext(query)); err == nil {
+			t.Fatalf("%s: want: error", query)
+		}
+	}
+
+	if EncodeContinueToken("") != "" {
+		t.Fatal("want: empty token")
+	}
+}
+
+func TestListOptionsFilter(t *testing.T) {
+	type item struct {
+		Meta   `json:"meta"`
+		Status struct {
+			State string `json:"state"`
+		} `json:"status"`
+	}
+	newItem := func(id, env, state string) *item {
+		i := &item{Meta: Meta{ID: id, Labels: map[string]string{}}}
+		if env != "" {
+			i.Labels["env"] = env
+		}
+		i.Status.State = state
+		return i
+	}
+
+	ls, err := ParseLabelSelector("env in (prod,stg)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := ParseFieldSelector("status.state=Running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := ListOptions{LabelSelector: ls, FieldSelector: fs}
+
+	list := []*item{
+		newItem("a", "prod", "Running"),
+		newItem("b", "prod", "Stopped"),
+		newItem("c", "dev", "Running"),
+		newItem("d", "", "Running"),
+		newItem("e", "stg", "Running"),
+	}
+	options.Filter(&list)
+	if len(list) != 2 || list[0].ID != "a" || list[1].ID != "e" {
+		ids := []string{}
+		for _, i := range list {
+			ids = append(ids, i.ID)
+		}
+		t.Fatalf("unexpected items: %v", ids)
+	}
+}
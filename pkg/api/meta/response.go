@@ -1,9 +1,19 @@
 package meta
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
+type ErrorReason string
+
+const (
+	// ErrorReasonStore はstoreへの読み書きに失敗したことを表す
+	ErrorReasonStore ErrorReason = "StoreError"
+)
+
 func ResponseJSON(ctx *gin.Context, code int, err error, data interface{}) {
 	if err != nil {
 		ctx.JSON(code, gin.H{
@@ -19,3 +29,13 @@ func ResponseJSON(ctx *gin.Context, code int, err error, data interface{}) {
 		"data":  data,
 	})
 }
+
+// ResponseStoreError はstoreへの読み書きに失敗した場合に500を返す
+// 保存されていないことをclientが判別できるようにdataにreasonを入れる
+func ResponseStoreError(ctx *gin.Context, err error) {
+	ResponseJSON(ctx, http.StatusInternalServerError,
+		fmt.Errorf("Error: failed to access store: %s", err.Error()),
+		gin.H{
+			"reason": ErrorReasonStore,
+		})
+}
@@ -0,0 +1,267 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type LabelSelectorOperator string
+
+const (
+	LabelSelectorOperatorEquals       LabelSelectorOperator = "="
+	LabelSelectorOperatorNotEquals    LabelSelectorOperator = "!="
+	LabelSelectorOperatorIn           LabelSelectorOperator = "in"
+	LabelSelectorOperatorNotIn        LabelSelectorOperator = "notin"
+	LabelSelectorOperatorExists       LabelSelectorOperator = "exists"
+	LabelSelectorOperatorDoesNotExist LabelSelectorOperator = "!"
+)
+
+type LabelSelectorRequirement struct {
+	Key      string
+	Operator LabelSelectorOperator
+	Value    string
+	// Values はin, notinの値
+	Values []string
+}
+
+// LabelSelector はカンマ区切りの条件を全て満たすLabelsにマッチする
+// 例: env=prod,tier!=db,region in (tokyo,osaka),owner,!deprecated
+type LabelSelector []LabelSelectorRequirement
+
+var setBasedTermRegexp = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\((.*)\)$`)
+
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	ls := LabelSelector{}
+	for _, term := range splitSelector(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req LabelSelectorRequirement
+		if m := setBasedTermRegexp.FindStringSubmatch(term); m != nil {
+			req = LabelSelectorRequirement{Key: m[1], Operator: LabelSelectorOperator(m[2])}
+			for _, v := range strings.Split(m[3], ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					req.Values = append(req.Values, v)
+				}
+			}
+			if len(req.Values) == 0 {
+				return nil, fmt.Errorf("Error: invalid label selector `%s`.", term)
+			}
+		} else {
+			switch {
+			case strings.Contains(term, "!="):
+				kv := strings.SplitN(term, "!=", 2)
+				req = LabelSelectorRequirement{Key: kv[0], Operator: LabelSelectorOperatorNotEquals, Value: kv[1]}
+			case strings.Contains(term, "=="):
+				kv := strings.SplitN(term, "==", 2)
+				req = LabelSelectorRequirement{Key: kv[0], Operator: LabelSelectorOperatorEquals, Value: kv[1]}
+			case strings.Contains(term, "="):
+				kv := strings.SplitN(term, "=", 2)
+				req = LabelSelectorRequirement{Key: kv[0], Operator: LabelSelectorOperatorEquals, Value: kv[1]}
+			case strings.HasPrefix(term, "!"):
+				req = LabelSelectorRequirement{Key: term[1:], Operator: LabelSelectorOperatorDoesNotExist}
+			default:
+				req = LabelSelectorRequirement{Key: term, Operator: LabelSelectorOperatorExists}
+			}
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if req.Key == "" || strings.ContainsAny(req.Key, " !=()") {
+			return nil, fmt.Errorf("Error: invalid label selector `%s`.", term)
+		}
+		ls = append(ls, req)
+	}
+	return ls, nil
+}
+
+// splitSelector は括弧の外にあるカンマで区切る
+func splitSelector(selector string) []string {
+	terms := []string{}
+	depth := 0
+	start := 0
+	for i, c := range selector {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+func (ls LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range ls {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r LabelSelectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case LabelSelectorOperatorEquals:
+		return ok && value == r.Value
+	case LabelSelectorOperatorNotEquals:
+		return !ok || value != r.Value
+	case LabelSelectorOperatorIn:
+		return ok && contains(r.Values, value)
+	case LabelSelectorOperatorNotIn:
+		return !ok || !contains(r.Values, value)
+	case LabelSelectorOperatorExists:
+		return ok
+	case LabelSelectorOperatorDoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type FieldSelectorRequirement struct {
+	// Path はJSONのkeyを順番に並べたもの
+	Path     []string
+	Operator LabelSelectorOperator
+	Value    string
+}
+
+// FieldSelector はカンマ区切りの条件を全て満たすオブジェクトにマッチする
+// フィールドはJSONのkeyを.でつなげて指定し、mapのkeyは[]で指定する
+// metaはmetadataとも書ける
+// 例: status.state=Running,metadata.annotations[virtualmachinev0/node_name]=node1
+type FieldSelector []FieldSelectorRequirement
+
+func ParseFieldSelector(selector string) (FieldSelector, error) {
+	fs := FieldSelector{}
+	for _, term := range splitSelector(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		// mapのkeyに=が含まれていても区切れるように]より後ろから演算子を探す
+		offset := strings.LastIndex(term, "]") + 1
+		var field, value string
+		var op LabelSelectorOperator
+		switch rest := term[offset:]; {
+		case strings.Contains(rest, "!="):
+			i := offset + strings.Index(rest, "!=")
+			field, op, value = term[:i], LabelSelectorOperatorNotEquals, term[i+2:]
+		case strings.Contains(rest, "=="):
+			i := offset + strings.Index(rest, "==")
+			field, op, value = term[:i], LabelSelectorOperatorEquals, term[i+2:]
+		case strings.Contains(rest, "="):
+			i := offset + strings.Index(rest, "=")
+			field, op, value = term[:i], LabelSelectorOperatorEquals, term[i+1:]
+		default:
+			return nil, fmt.Errorf("Error: invalid field selector `%s`.", term)
+		}
+
+		path, err := parseFieldPath(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("Error: invalid field selector `%s`.", term)
+		}
+		fs = append(fs, FieldSelectorRequirement{
+			Path:     path,
+			Operator: op,
+			Value:    strings.TrimSpace(value),
+		})
+	}
+	return fs, nil
+}
+
+// parseFieldPath はmetadata.annotations[key]のようなフィールドをkeyの配列にする
+func parseFieldPath(field string) ([]string, error) {
+	path := []string{}
+	for field != "" {
+		switch {
+		case field[0] == '[':
+			end := strings.Index(field, "]")
+			if end < 2 {
+				return nil, fmt.Errorf("invalid field")
+			}
+			path = append(path, field[1:end])
+			field = field[end+1:]
+			field = strings.TrimPrefix(field, ".")
+		default:
+			end := strings.IndexAny(field, ".[")
+			if end == 0 {
+				return nil, fmt.Errorf("invalid field")
+			}
+			if end < 0 {
+				end = len(field)
+			}
+			path = append(path, field[:end])
+			field = strings.TrimPrefix(field[end:], ".")
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid field")
+	}
+	if path[0] == "metadata" {
+		path[0] = "meta"
+	}
+	return path, nil
+}
+
+// Matches はJSONをデコードしたobjが条件を全て満たすかを返す
+func (fs FieldSelector) Matches(obj map[string]interface{}) bool {
+	for _, req := range fs {
+		if !req.Matches(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches は存在しないフィールドを空文字として比較する
+func (r FieldSelectorRequirement) Matches(obj map[string]interface{}) bool {
+	var v interface{} = obj
+	for _, key := range r.Path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			v = nil
+			break
+		}
+		v = m[key]
+	}
+
+	value := ""
+	switch vv := v.(type) {
+	case nil:
+	case string:
+		value = vv
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(vv)
+		value = string(b)
+	default:
+		value = fmt.Sprint(vv)
+	}
+
+	switch r.Operator {
+	case LabelSelectorOperatorEquals:
+		return value == r.Value
+	case LabelSelectorOperatorNotEquals:
+		return value != r.Value
+	}
+	return false
+}
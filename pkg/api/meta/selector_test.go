@@ -0,0 +1,101 @@
+package meta
+
+import (
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{
+		"env":  "prod",
+		"tier": "web",
+	}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=dev", false},
+		{"env=prod,tier!=db", true},
+		{"env=prod,tier!=web", false},
+		{"owner!=hum", true},
+		{"owner=hum", false},
+		{"env in (prod,dev)", true},
+		{"env in (dev, stg)", false},
+		{"env notin (dev),tier in (web)", true},
+		{"owner notin (hum)", true},
+		{"owner in (hum)", false},
+		{"env", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!env", false},
+		{"env in (prod),!owner,tier=web", true},
+	}
+
+	for _, tt := range tests {
+		ls, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.selector, err)
+		}
+		if got := ls.Matches(labels); got != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.selector, tt.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"=prod", "env!prod", "env in ()", "!", "env in prod"} {
+		if _, err := ParseLabelSelector(invalid); err == nil {
+			t.Errorf("%s: want error", invalid)
+		}
+	}
+}
+
+func TestFieldSelector(t *testing.T) {
+	obj := map[string]interface{}{
+		"meta": map[string]interface{}{
+			"id": "vm1",
+			"annotations": map[string]interface{}{
+				"virtualmachinev0/node_name": "node1",
+				"a.b":                        "c",
+			},
+		},
+		"spec": map[string]interface{}{
+			"limitVcpus": float64(2),
+		},
+		"status": map[string]interface{}{
+			"state": "Running",
+		},
+	}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"status.state=Running", true},
+		{"status.state!=Running", false},
+		{"metadata.annotations[virtualmachinev0/node_name]=node1", true},
+		{"meta.annotations[virtualmachinev0/node_name]==node2", false},
+		{"metadata.annotations[a.b]=c,meta.id=vm1", true},
+		{"spec.limitVcpus=2", true},
+		{"status.notfound=", true},
+		{"status.notfound!=", false},
+	}
+
+	for _, tt := range tests {
+		fs, err := ParseFieldSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.selector, err)
+		}
+		if got := fs.Matches(obj); got != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.selector, tt.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"status.state", "=Running", "meta.annotations[]=a", "meta..id=a"} {
+		if _, err := ParseFieldSelector(invalid); err == nil {
+			t.Errorf("%s: want error", invalid)
+		}
+	}
+}
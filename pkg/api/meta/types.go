@@ -1,5 +1,7 @@
 package meta
 
+import "fmt"
+
 type APIType string
 
 const (
@@ -11,11 +13,17 @@ const (
 	APITypeImageV0          APIType = "systemv0/image"
 	APITypeImageEntityV0    APIType = "systemv0/imageentity"
 	APITypeImageTagV0       APIType = "systemv0/imagetag"
+	APITypeStorageClassV0   APIType = "systemv0/storageclass"
 	APITypeNamespaceV0      APIType = "corev0/namespace"
 	APITypeGroupV0          APIType = "corev0/group"
 	APITypeExternalIPPoolV0 APIType = "corev0/externalippool"
 	APITypeExternalIPV0     APIType = "corev0/externalip"
 	APITypeNetworkV0        APIType = "corev0/network"
+	APITypeUserV0           APIType = "corev0/user"
+	APITypeServiceAccountV0 APIType = "corev0/serviceaccount"
+	APITypeRoleBindingV0    APIType = "corev0/rolebinding"
+	APITypeResourceQuotaV0  APIType = "corev0/resourcequota"
+	APITypeTokenV0          APIType = "authv0/token"
 )
 
 type ResourceType string
@@ -40,9 +48,34 @@ type Meta struct {
 	Annotations     map[string]string `json:"annotations" yaml:"annotations"`
 	Labels          map[string]string `json:"labels" yaml:"labels"`
 	ResourceHash    string            `json:"resourceHash" yaml:"resourceHash"`
+	ResourceVersion int64             `json:"resourceVersion" yaml:"resourceVersion"`
 	DeleteState     DeleteState       `json:"deleteState" yaml:"deleteState"`
 	APIType         APIType           `json:"apiType" yaml:"apiType"`
 	OwnerReferences []OwnerReference  `json:"ownerReferences" yaml:"ownerReferences"`
+
+	// SchemaVersion は保存されているデータのスキーマのバージョン
+	// storeへの書き込み時に設定される
+	SchemaVersion int64 `json:"schemaVersion,omitempty" yaml:"schemaVersion,omitempty"`
+}
+
+// ResourceVersioner はstoreへの書き込み時にresourceVersionを受け取る
+// meta.Metaを埋め込んだリソースのポインタが満たす
+type ResourceVersioner interface {
+	SetResourceVersion(resourceVersion int64)
+}
+
+func (m *Meta) SetResourceVersion(resourceVersion int64) {
+	m.ResourceVersion = resourceVersion
+}
+
+// MetaAccessor はリソースの種類によらずMetaを参照するために使う
+// meta.Metaを埋め込んだリソースのポインタが満たす
+type MetaAccessor interface {
+	GetMeta() *Meta
+}
+
+func (m *Meta) GetMeta() *Meta {
+	return m
 }
 
 type Object struct {
@@ -50,3 +83,31 @@ type Object struct {
 	Spec   interface{}
 	Status interface{}
 }
+
+// SetScope はパスで指定されたgroupとnamespaceをmetaに設定する
+// 省略された場合はパスの値にし、パスと異なる値が指定された場合はエラーを返す
+// namespaceに属さないリソースはnamespaceに空を指定する
+func (m *Meta) SetScope(group, namespace string) error {
+	if m.Group != "" && m.Group != group {
+		return fmt.Errorf("Error: meta.group `%s` does not match group `%s` in the path.", m.Group, group)
+	}
+	if m.Namespace != "" && m.Namespace != namespace {
+		return fmt.Errorf("Error: meta.namespace `%s` does not match namespace `%s` in the path.", m.Namespace, namespace)
+	}
+	m.Group, m.Namespace = group, namespace
+	return nil
+}
+
+// MergeAnnotations はannotationsをm.Annotationsに上書きする
+// annotationsに含まれないkeyは残す
+func (m *Meta) MergeAnnotations(annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		m.Annotations[k] = v
+	}
+}
@@ -0,0 +1,34 @@
+package replication
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type ReplicationHandlerInterface interface {
+	Txn(ctx *gin.Context)
+	Snapshot(ctx *gin.Context)
+}
+
+type ReplicationHandler struct {
+	router *gin.RouterGroup
+	rhi    ReplicationHandlerInterface
+}
+
+const (
+	basePath = "store"
+)
+
+func NewReplicationHandler(router *gin.RouterGroup, rhi ReplicationHandlerInterface) *ReplicationHandler {
+	return &ReplicationHandler{
+		router: router,
+		rhi:    rhi,
+	}
+}
+
+func (h *ReplicationHandler) RegisterHandlers() {
+	rs := h.router.Group(basePath)
+	{
+		rs.POST("/txn", h.rhi.Txn)
+		rs.GET("/snapshot", h.rhi.Snapshot)
+	}
+}
@@ -0,0 +1,20 @@
+package replication
+
+import (
+	"encoding/json"
+
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// TxnRequest はfollowerのapiserverがleaderに転送する書き込み
+type TxnRequest struct {
+	Compares []store.Compare `json:"compares"`
+	Ops      []TxnOp         `json:"ops"`
+}
+
+// TxnOp はstore.OpのDataをJSONのまま転送するためのもの
+type TxnOp struct {
+	Type store.OpType    `json:"type"`
+	Key  string          `json:"key"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
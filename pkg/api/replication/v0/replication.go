@@ -0,0 +1,86 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/replication"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+// ReplicationStore はreplicationのAPIから操作するstore
+type ReplicationStore interface {
+	Txn(compares []store.Compare, ops []store.Op) error
+	Snapshot() (*store.Snapshot, error)
+	Revision() int64
+}
+
+type ReplicationHandler struct {
+	replication.ReplicationHandlerInterface
+
+	store ReplicationStore
+}
+
+func NewReplicationHandler(store ReplicationStore) *ReplicationHandler {
+	return &ReplicationHandler{
+		store: store,
+	}
+}
+
+// Txn はfollowerから転送された書き込みを反映し、反映後のrevisionを返す
+// followerはこのrevisionまで追従してから呼び出し元に結果を返す
+func (h *ReplicationHandler) Txn(ctx *gin.Context) {
+	var request replication.TxnRequest
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	ops := []store.Op{}
+	for _, op := range request.Ops {
+		switch op.Type {
+		case store.OpTypePut:
+			if len(op.Data) == 0 {
+				meta.ResponseJSON(ctx, http.StatusBadRequest,
+					fmt.Errorf("Error: data of `%s` is empty.", op.Key), nil)
+				return
+			}
+			ops = append(ops, store.OpPut(op.Key, op.Data))
+		case store.OpTypeDelete:
+			ops = append(ops, store.OpDelete(op.Key))
+		default:
+			meta.ResponseJSON(ctx, http.StatusBadRequest,
+				fmt.Errorf("Error: unknown op type `%s`.", op.Type), nil)
+			return
+		}
+	}
+
+	if err := h.store.Txn(request.Compares, ops); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"revision": h.store.Revision(),
+	})
+}
+
+func (h *ReplicationHandler) Snapshot(ctx *gin.Context) {
+	snapshot, err := h.store.Snapshot()
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"snapshot": snapshot,
+	})
+}
@@ -4,6 +4,7 @@ import "github.com/gin-gonic/gin"
 
 type BlockStorageHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
@@ -19,6 +20,9 @@ type BlockStorageHandler struct {
 
 const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/blockstorages"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "blockstorages"
 )
 
 func NewBlockStorageHandler(router *gin.RouterGroup, bshi BlockStorageHandlerInterface) *BlockStorageHandler {
@@ -39,4 +43,6 @@ func (h *BlockStorageHandler) RegisterHandlers() {
 		bs.DELETE("/:block_storage_id", h.bshi.Delete)
 		bs.GET("/:block_storage_id/download", h.bshi.ProxyDownloadAPI)
 	}
+
+	h.router.GET(clusterPath, h.bshi.FindAllInCluster)
 }
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
@@ -18,6 +20,10 @@ type BlockStorageHandler struct {
 	blockstorage.BlockStorageHandlerInterface
 
 	store store.Store
+
+	// ProxyTransport はagentのダウンロードAPIへのプロキシに使う
+	// nilの場合はhttp.DefaultTransportを使う
+	ProxyTransport http.RoundTripper
 }
 
 func NewBlockStorageHandler(store store.Store) *BlockStorageHandler {
@@ -29,6 +35,15 @@ func NewBlockStorageHandler(store store.Store) *BlockStorageHandler {
 func (h *BlockStorageHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのBlockStorageを返す
+func (h *BlockStorageHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *BlockStorageHandler) findAll(ctx *gin.Context, prefix string) {
 	bsList := []*system.BlockStorage{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -40,10 +55,21 @@ func (h *BlockStorageHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, nsID, ""), f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&bsList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"blockstorages": bsList,
+		"continue":      meta.EncodeContinueToken(next),
 	})
 
 }
@@ -53,8 +79,12 @@ func (h *BlockStorageHandler) Find(ctx *gin.Context) {
 
 	var bs system.BlockStorage
 	err := h.store.Get(getKey(groupID, nsID, bsID), &bs)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("BlockStorage `%s` is not found.", bsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("BlockStorage `%s` is not found.", bsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -68,8 +98,12 @@ func (h *BlockStorageHandler) Create(ctx *gin.Context) {
 
 	var ns core.Namespace
 	err := h.store.Get(filepath.Join("namespace", groupID, nsID), &ns)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -80,32 +114,48 @@ func (h *BlockStorageHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
 	}
-	if request.Spec.RequestSize == "" {
-		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: requestSize is empty."), nil)
-		return
+
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeBlockStorageV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
 	}
-	if request.Spec.LimitSize == "" {
-		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: limitSize is empty."), nil)
+	if !admission.Admit(ctx, h.store, admissionRequest) {
 		return
 	}
 
 	key := getKey(groupID, nsID, request.ID)
-	var bs system.BlockStorage
-	err = h.store.Get(key, &bs)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: BlockStorage `%s` is already exists.", request.Name), nil)
-		return
-	}
-
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeBlockStorageV0
-	h.store.Put(key, request)
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: BlockStorage `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"blockstorage": request,
@@ -122,6 +172,11 @@ func (h *BlockStorageHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if bsID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change BlockStorage Name."), nil)
 		return
@@ -130,21 +185,55 @@ func (h *BlockStorageHandler) Update(ctx *gin.Context) {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: name is empty."), nil)
 		return
 	}
-	if request.Spec.RequestSize == "" {
-		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: requestSize is empty."), nil)
-		return
-	}
-	if request.Spec.LimitSize == "" {
-		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: limitSize is empty."), nil)
-		return
-	}
 
 	key := getKey(groupID, nsID, request.ID)
 
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeBlockStorageV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}
+	var bs system.BlockStorage
+	if err := h.store.Get(key, &bs); err == nil {
+		// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+		if request.ResourceVersion == 0 {
+			request.ResourceVersion = bs.ResourceVersion
+		}
+
+		// statusは/statusからのみ更新する
+		request.Status = bs.Status
+		admissionRequest.Operation = admission.OperationUpdate
+		admissionRequest.OldObject = &bs
+	} else if !errors.Is(err, store.ErrNotFound) {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: BlockStorage `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"blockstorage": request,
@@ -152,29 +241,59 @@ func (h *BlockStorageHandler) Update(ctx *gin.Context) {
 }
 
 func (h *BlockStorageHandler) UpdateStatus(ctx *gin.Context) {
-	groupID, nsID, _ := getIDs(ctx)
+	groupID, nsID, bsID := getIDs(ctx)
 
 	var request system.BlockStorage
-	if err := ctx.Bind(&request); err != nil {
+	err := ctx.Bind(&request)
+	if err != nil {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
 	}
 
-	key := getKey(groupID, nsID, request.ID)
+	if bsID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change BlockStorage Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, bsID)
 
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	var bs system.BlockStorage
-	if err := h.store.Get(key, &bs); err != nil {
-		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+	err = h.store.Get(key, &bs)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: BlockStorage `%s` is not found.", bsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = bs.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
 	bs.Status = request.Status
-	h.store.Put(key, bs)
+	bs.ResourceHash = request.ResourceHash
+	bs.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &bs)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: BlockStorage `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
-	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"blockstorage": bs,
 	})
 }
@@ -186,7 +305,10 @@ func (h *BlockStorageHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"blockstorage": nil,
@@ -197,12 +319,14 @@ func (h *BlockStorageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 	groupID, nsID, bsID := getIDs(ctx)
 
 	key := getKey(groupID, nsID, bsID)
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
 
 	var bs system.BlockStorage
 	if err := h.store.Get(key, &bs); err != nil {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("blockstorage not found"), gin.H{})
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("blockstorage not found"), gin.H{})
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -212,13 +336,24 @@ func (h *BlockStorageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 		return
 	}
 
+	// ダウンロードAPIがHTTPSの場合はagentがbs-download-schemeにhttpsを設定する
+	scheme, ok := bs.Annotations["bs-download-scheme"]
+	if !ok {
+		scheme = "http"
+	}
+
 	director := func(req *http.Request) {
-		req.URL.Scheme = "http"
+		req.URL.Scheme = scheme
 		req.URL.Host = target
 		req.Host = target
+		// apiserverのトークンはagentに渡さない
+		req.Header.Del("Authorization")
+		query := req.URL.Query()
+		query.Del("token")
+		req.URL.RawQuery = query.Encode()
 	}
 
-	proxy := &httputil.ReverseProxy{Director: director}
+	proxy := &httputil.ReverseProxy{Director: director, Transport: h.ProxyTransport}
 	proxy.ServeHTTP(ctx.Writer, ctx.Request)
 }
 
@@ -4,6 +4,7 @@ import "github.com/gin-gonic/gin"
 
 type ImageHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
@@ -18,6 +19,9 @@ type ImageHandler struct {
 
 const (
 	basePath = "groups/:group_id/images"
+
+	// clusterPath は全てのグループのリソースを取得する
+	clusterPath = "images"
 )
 
 func NewImageHandler(router *gin.RouterGroup, imhi ImageHandlerInterface) *ImageHandler {
@@ -37,4 +41,6 @@ func (h *ImageHandler) RegisterHandlers() {
 		im.DELETE("/:image_id", h.imhi.Delete)
 		im.GET("/:image_id/tags/:tag/download", h.imhi.ProxyDownloadAPI)
 	}
+
+	h.router.GET(clusterPath, h.imhi.FindAllInCluster)
 }
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/image"
@@ -17,6 +19,10 @@ type ImageHandler struct {
 	image.ImageHandlerInterface
 
 	store store.Store
+
+	// ProxyTransport はagentのダウンロードAPIへのプロキシに使う
+	// nilの場合はhttp.DefaultTransportを使う
+	ProxyTransport http.RoundTripper
 }
 
 func NewImageHandler(store store.Store) *ImageHandler {
@@ -28,6 +34,15 @@ func NewImageHandler(store store.Store) *ImageHandler {
 func (h *ImageHandler) FindAll(ctx *gin.Context) {
 	groupID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループのImageを返す
+func (h *ImageHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "")+"/")
+}
+
+func (h *ImageHandler) findAll(ctx *gin.Context, prefix string) {
 	imList := []*system.Image{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -39,10 +54,21 @@ func (h *ImageHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, "")+"/", f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&imList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"images": imList,
+		"images":   imList,
+		"continue": meta.EncodeContinueToken(next),
 	})
 
 }
@@ -52,8 +78,12 @@ func (h *ImageHandler) Find(ctx *gin.Context) {
 
 	var im system.Image
 	err := h.store.Get(getKey(groupID, imID), &im)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Image `%s` is not found.", imID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Image `%s` is not found.", imID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -72,24 +102,42 @@ func (h *ImageHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
 	}
 
-	key := getKey(groupID, request.ID)
-	var im system.Image
-	err = h.store.Get(key, &im)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Image `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeImageV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(groupID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeImageV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Image `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"image": request,
@@ -106,6 +154,11 @@ func (h *ImageHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if imID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Image Name."), nil)
 		return
@@ -120,7 +173,42 @@ func (h *ImageHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeImageV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Object:    &request,
+	}
+
+	var im system.Image
+	if err := h.store.Get(key, &im); err == nil {
+		// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+		if request.ResourceVersion == 0 {
+			request.ResourceVersion = im.ResourceVersion
+		}
+		admissionRequest.Operation = admission.OperationUpdate
+		admissionRequest.OldObject = &im
+	} else if !errors.Is(err, store.ErrNotFound) {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Image `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"image": request,
@@ -134,7 +222,10 @@ func (h *ImageHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"image": nil,
@@ -146,12 +237,14 @@ func (h *ImageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 	tag := ctx.Param("tag")
 
 	key := getKey(groupID, imID)
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
 
 	var image system.Image
 	if err := h.store.Get(key, &image); err != nil {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("image not found"), gin.H{})
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("image not found"), gin.H{})
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -164,7 +257,11 @@ func (h *ImageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 	imageEntityKey := filepath.Join("imageentities", groupID, imageEntityID)
 	var imageEntity system.ImageEntity
 	if err := h.store.Get(imageEntityKey, &imageEntity); err != nil {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("image entity not found"), gin.H{})
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("image entity not found"), gin.H{})
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -174,13 +271,24 @@ func (h *ImageHandler) ProxyDownloadAPI(ctx *gin.Context) {
 		return
 	}
 
+	// ダウンロードAPIがHTTPSの場合はagentがimage-entity-download-schemeにhttpsを設定する
+	scheme, ok := imageEntity.Annotations["image-entity-download-scheme"]
+	if !ok {
+		scheme = "http"
+	}
+
 	director := func(req *http.Request) {
-		req.URL.Scheme = "http"
+		req.URL.Scheme = scheme
 		req.URL.Host = target
 		req.Host = target
+		// apiserverのトークンはagentに渡さない
+		req.Header.Del("Authorization")
+		query := req.URL.Query()
+		query.Del("token")
+		req.URL.RawQuery = query.Encode()
 	}
 
-	proxy := &httputil.ReverseProxy{Director: director}
+	proxy := &httputil.ReverseProxy{Director: director, Transport: h.ProxyTransport}
 	proxy.ServeHTTP(ctx.Writer, ctx.Request)
 
 }
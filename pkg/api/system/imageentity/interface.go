@@ -4,9 +4,11 @@ import "github.com/gin-gonic/gin"
 
 type ImageEntityHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 }
 
@@ -17,6 +19,9 @@ type ImageEntityHandler struct {
 
 const (
 	basePath = "groups/:group_id/imageentities"
+
+	// clusterPath は全てのグループのリソースを取得する
+	clusterPath = "imageentities"
 )
 
 func NewImageEntityHandler(router *gin.RouterGroup, iehi ImageEntityHandlerInterface) *ImageEntityHandler {
@@ -32,7 +37,10 @@ func (h *ImageEntityHandler) RegisterHandlers() {
 		ie.GET("", h.iehi.FindAll)
 		ie.GET("/:image_entity_id", h.iehi.Find)
 		ie.POST("", h.iehi.Create)
+		ie.PUT("/:image_entity_id/status", h.iehi.UpdateStatus)
 		ie.PUT("/:image_entity_id", h.iehi.Update)
 		ie.DELETE("/:image_entity_id", h.iehi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.iehi.FindAllInCluster)
 }
@@ -1,11 +1,13 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/imageentity"
@@ -27,6 +29,15 @@ func NewImageEntityHandler(store store.Store) *ImageEntityHandler {
 func (h *ImageEntityHandler) FindAll(ctx *gin.Context) {
 	groupID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループのImageEntityを返す
+func (h *ImageEntityHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "")+"/")
+}
+
+func (h *ImageEntityHandler) findAll(ctx *gin.Context, prefix string) {
 	imList := []*system.ImageEntity{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -38,10 +49,21 @@ func (h *ImageEntityHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, ""), f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&imList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"imageentities": imList,
+		"continue":      meta.EncodeContinueToken(next),
 	})
 
 }
@@ -51,8 +73,12 @@ func (h *ImageEntityHandler) Find(ctx *gin.Context) {
 
 	var im system.ImageEntity
 	err := h.store.Get(getKey(groupID, imID), &im)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ImageEntity `%s` is not found.", imID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("ImageEntity `%s` is not found.", imID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -71,24 +97,42 @@ func (h *ImageEntityHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
 	}
 
-	key := getKey(groupID, request.ID)
-	var im system.ImageEntity
-	err = h.store.Get(key, &im)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageEntity `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeImageEntityV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(groupID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeImageEntityV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageEntity `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"imageentity": request,
@@ -105,6 +149,11 @@ func (h *ImageEntityHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, ""); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if imID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change ImageEntity Name."), nil)
 		return
@@ -119,13 +168,109 @@ func (h *ImageEntityHandler) Update(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeImageEntityV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Object:    &request,
+	}
+
+	var im system.ImageEntity
+	if err := h.store.Get(key, &im); err == nil {
+		// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+		if request.ResourceVersion == 0 {
+			request.ResourceVersion = im.ResourceVersion
+		}
+
+		// statusは/statusからのみ更新する
+		request.Status = im.Status
+		admissionRequest.Operation = admission.OperationUpdate
+		admissionRequest.OldObject = &im
+	} else if !errors.Is(err, store.ErrNotFound) {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageEntity `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"imageentity": request,
 	})
 }
 
+func (h *ImageEntityHandler) UpdateStatus(ctx *gin.Context) {
+	groupID, imID := getIDs(ctx)
+
+	var request system.ImageEntity
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if imID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change ImageEntity Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, imID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var im system.ImageEntity
+	err = h.store.Get(key, &im)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: ImageEntity `%s` is not found.", imID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = im.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	im.Status = request.Status
+	im.ResourceHash = request.ResourceHash
+	im.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &im)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: ImageEntity `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"imageentity": im,
+	})
+}
+
 func (h *ImageEntityHandler) Delete(ctx *gin.Context) {
 	groupID, imID := getIDs(ctx)
 
@@ -133,7 +278,10 @@ func (h *ImageEntityHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"imageentity": nil,
@@ -9,6 +9,7 @@ type NodeHandlerInterface interface {
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 }
 
@@ -34,6 +35,7 @@ func (h *NodeHandler) RegisterHandlers() {
 		node.GET("", h.nhi.FindAll)
 		node.GET("/:node_id", h.nhi.Find)
 		node.POST("", h.nhi.Create)
+		node.PUT("/:node_id/status", h.nhi.UpdateStatus)
 		node.PUT("/:node_id", h.nhi.Update)
 		node.DELETE("/:node_id", h.nhi.Delete)
 	}
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/node"
@@ -38,10 +40,21 @@ func (h *NodeHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey("")+"/", f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(getKey("")+"/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&nodeList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"nodes": nodeList,
+		"nodes":    nodeList,
+		"continue": meta.EncodeContinueToken(next),
 	})
 
 }
@@ -51,8 +64,12 @@ func (h *NodeHandler) Find(ctx *gin.Context) {
 
 	var node system.Node
 	err := h.store.Get(getKey(nodeID), &node)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Node `%s` is not found.", nodeID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Node `%s` is not found.", nodeID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -75,19 +92,31 @@ func (h *NodeHandler) Create(ctx *gin.Context) {
 		return
 	}
 
-	key := getKey(request.ID)
-	var node system.Node
-	err = h.store.Get(key, &node)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNodeV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNodeV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"node": request,
@@ -120,23 +149,114 @@ func (h *NodeHandler) Update(ctx *gin.Context) {
 	}
 
 	key := getKey(nodeID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var node system.Node
 	err = h.store.Get(key, &node)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` is not found.", request.Name), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` is not found.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = node.ResourceVersion
+	}
+
+	// statusは/statusからのみ更新する
+	request.Status = node.Status
 
-	h.store.Put(key, request)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNodeV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &node,
+	}) {
+		return
+	}
+
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"node": request,
 	})
 }
 
+func (h *NodeHandler) UpdateStatus(ctx *gin.Context) {
+	nodeID := getNodeID(ctx)
+
+	var request system.Node
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if nodeID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change Node Name."), nil)
+		return
+	}
+
+	key := getKey(nodeID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var node system.Node
+	err = h.store.Get(key, &node)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: Node `%s` is not found.", nodeID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = node.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	node.Status = request.Status
+	node.ResourceHash = request.ResourceHash
+	node.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &node)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: Node `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"node": node,
+	})
+}
+
 func (h *NodeHandler) Delete(ctx *gin.Context) {
 	nodeID := getNodeID(ctx)
 
@@ -144,7 +264,10 @@ func (h *NodeHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"node": nil,
@@ -162,7 +285,7 @@ func (h *NodeHandler) isIDDuplicate(node *system.Node) bool {
 		}
 		return m
 	}
-	h.store.List(getKey(""), f)
+	h.store.List(getKey("")+"/", f)
 	for _, n := range list {
 		if n.ID == node.ID {
 			return true
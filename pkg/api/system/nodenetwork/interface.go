@@ -6,9 +6,11 @@ import (
 
 type NodeNetworkHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 }
 
@@ -19,6 +21,9 @@ type NodeNetworkHandler struct {
 
 const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/nodenetworks"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "nodenetworks"
 )
 
 func NewNodeNetworkHandler(router *gin.RouterGroup, nhi NodeNetworkHandlerInterface) *NodeNetworkHandler {
@@ -34,7 +39,10 @@ func (h *NodeNetworkHandler) RegisterHandlers() {
 		ns.GET("", h.nhi.FindAll)
 		ns.GET("/:node_network_id", h.nhi.Find)
 		ns.POST("", h.nhi.Create)
+		ns.PUT("/:node_network_id/status", h.nhi.UpdateStatus)
 		ns.PUT("/:node_network_id", h.nhi.Update)
 		ns.DELETE("/:node_network_id", h.nhi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.nhi.FindAllInCluster)
 }
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
@@ -29,6 +31,15 @@ func NewNodeNetworkHandler(store store.Store) *NodeNetworkHandler {
 func (h *NodeNetworkHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのNodeNetworkを返す
+func (h *NodeNetworkHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *NodeNetworkHandler) findAll(ctx *gin.Context, prefix string) {
 	netList := []*system.NodeNetwork{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -40,10 +51,21 @@ func (h *NodeNetworkHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, nsID, ""), f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&netList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"nodenetworks": netList,
+		"continue":     meta.EncodeContinueToken(next),
 	})
 }
 
@@ -52,8 +74,12 @@ func (h *NodeNetworkHandler) Find(ctx *gin.Context) {
 
 	var net system.NodeNetwork
 	err := h.store.Get(getKey(groupID, nsID, netID), &net)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("NodeNetwork `%s` is not found.", nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("NodeNetwork `%s` is not found.", nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -73,6 +99,11 @@ func (h *NodeNetworkHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
@@ -80,25 +111,43 @@ func (h *NodeNetworkHandler) Create(ctx *gin.Context) {
 
 	var ns core.Namespace
 	err = h.store.Get(filepath.Join("namespace", groupID, nsID), &ns)
-	if err != nil && err.Error() == "Not Found" {
-		log.Println("error")
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			log.Println("error")
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	key := getKey(groupID, nsID, request.ID)
-	var net system.NodeNetwork
-	err = h.store.Get(key, &net)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: NodeNetwork `%s` is already exists.", request.Name), nil)
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNodeNetworkV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}) {
 		return
 	}
 
+	key := getKey(groupID, nsID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeNodeNetworkV0
-	h.store.Put(key, request)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: NodeNetwork `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"nodenetwork": request,
@@ -115,26 +164,124 @@ func (h *NodeNetworkHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if netID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change NodeNetwork Name."), nil)
 		return
 	}
 
 	key := getKey(groupID, nsID, netID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var net system.NodeNetwork
 	err = h.store.Get(key, &net)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: NodeNetwork `%s` is not found in Namespace `%s`.", netID, nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: NodeNetwork `%s` is not found in Namespace `%s`.", netID, nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = net.ResourceVersion
+	}
+
+	// statusは/statusからのみ更新する
+	request.Status = net.Status
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeNodeNetworkV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+		OldObject: &net,
+	}) {
 		return
 	}
 
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: NodeNetwork `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"nodenetwork": request,
+	})
+}
+
+func (h *NodeNetworkHandler) UpdateStatus(ctx *gin.Context) {
+	groupID, nsID, netID := getIDs(ctx)
+
+	var request system.NodeNetwork
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if netID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change NodeNetwork Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, netID)
+
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var net system.NodeNetwork
+	err = h.store.Get(key, &net)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: NodeNetwork `%s` is not found.", netID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = net.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	net.Status = request.Status
+	net.ResourceHash = request.ResourceHash
+	net.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &net)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: NodeNetwork `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"nodenetwork": request,
+		"nodenetwork": net,
 	})
 }
 
@@ -145,7 +292,10 @@ func (h *NodeNetworkHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"nodenetwork": nil,
 	})
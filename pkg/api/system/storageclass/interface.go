@@ -0,0 +1,40 @@
+package storageclass
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type StorageClassHandlerInterface interface {
+	FindAll(ctx *gin.Context)
+	Find(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+const (
+	basePath = "storageclasses"
+)
+
+type StorageClassHandler struct {
+	router *gin.RouterGroup
+	schi   StorageClassHandlerInterface
+}
+
+func NewStorageClassHandler(router *gin.RouterGroup, schi StorageClassHandlerInterface) *StorageClassHandler {
+	return &StorageClassHandler{
+		router: router,
+		schi:   schi,
+	}
+}
+
+func (h *StorageClassHandler) RegisterHandlers() {
+	sc := h.router.Group(basePath)
+	{
+		sc.GET("", h.schi.FindAll)
+		sc.GET("/:storage_class_id", h.schi.Find)
+		sc.POST("", h.schi.Create)
+		sc.PUT("/:storage_class_id", h.schi.Update)
+		sc.DELETE("/:storage_class_id", h.schi.Delete)
+	}
+}
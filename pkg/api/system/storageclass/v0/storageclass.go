@@ -0,0 +1,233 @@
+package v0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/storageclass"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type StorageClassHandler struct {
+	storageclass.StorageClassHandlerInterface
+
+	store store.Store
+}
+
+func NewStorageClassHandler(store store.Store) *StorageClassHandler {
+	return &StorageClassHandler{
+		store: store,
+	}
+}
+
+func (h *StorageClassHandler) FindAll(ctx *gin.Context) {
+	scList := []*system.StorageClass{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			sc := &system.StorageClass{}
+			scList = append(scList, sc)
+			m = append(m, sc)
+		}
+		return m
+	}
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(getKey("")+"/", options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&scList)
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"storageclasses": scList,
+		"continue":       meta.EncodeContinueToken(next),
+	})
+}
+
+func (h *StorageClassHandler) Find(ctx *gin.Context) {
+	scID := getStorageClassID(ctx)
+
+	var sc system.StorageClass
+	err := h.store.Get(getKey(scID), &sc)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: StorageClass `%s` is not found.", scID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"storageclass": sc,
+	})
+}
+
+func (h *StorageClassHandler) Create(ctx *gin.Context) {
+	var request system.StorageClass
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID == "" {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: ID is empty."), nil)
+		return
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeStorageClassV0,
+		Operation: admission.OperationCreate,
+		Object:    &request,
+	}) {
+		return
+	}
+
+	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	request.APIType = meta.APITypeStorageClassV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: StorageClass `%s` is already exists.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
+		"storageclass": request,
+	})
+}
+
+func (h *StorageClassHandler) Update(ctx *gin.Context) {
+	scID := getStorageClassID(ctx)
+
+	var request system.StorageClass
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if request.ID != scID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: can't change id."), nil)
+		return
+	}
+
+	key := getKey(request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var sc system.StorageClass
+	err = h.store.Get(key, &sc)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: StorageClass `%s` is not found.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = sc.ResourceVersion
+	}
+
+	if !admission.Admit(ctx, h.store, &admission.Request{
+		APIType:   meta.APITypeStorageClassV0,
+		Operation: admission.OperationUpdate,
+		Object:    &request,
+		OldObject: &sc,
+	}) {
+		return
+	}
+
+	request.APIType = meta.APITypeStorageClassV0
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: StorageClass `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"storageclass": request,
+	})
+}
+
+// Delete はBlockStorageから参照されていないStorageClassを削除する
+func (h *StorageClassHandler) Delete(ctx *gin.Context) {
+	scID := getStorageClassID(ctx)
+
+	key := getKey(scID)
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	bsList := []*system.BlockStorage{}
+	f := func(n int) []interface{} {
+		m := []interface{}{}
+		for i := 0; i < n; i++ {
+			bs := &system.BlockStorage{}
+			bsList = append(bsList, bs)
+			m = append(m, bs)
+		}
+		return m
+	}
+	if err := h.store.List("blockstorage/", f); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	for _, bs := range bsList {
+		if bs.Spec.StorageClassName == scID {
+			meta.ResponseJSON(ctx, http.StatusConflict,
+				fmt.Errorf("Error: StorageClass `%s` is used by blockstorage `%s`.", scID, filepath.Join(bs.Group, bs.Namespace, bs.ID)), nil)
+			return
+		}
+	}
+
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"storageclass": nil,
+	})
+}
+
+func getStorageClassID(ctx *gin.Context) string {
+	return ctx.Param("storage_class_id")
+}
+
+func getKey(id string) string {
+	return filepath.Join("storageclass", id)
+}
@@ -124,6 +124,9 @@ type BlockStorageSpec struct {
 	RequestSize string           `json:"requestSize" yaml:"requestSize"`
 	LimitSize   string           `json:"limitSize" yaml:"limitSize"`
 	From        BlockStorageFrom `json:"from" yaml:"from"`
+	// StorageClassName はBlockStorageを保存するStorageClass
+	// 空の場合はblockstoragev0/typeのannotationとagentの設定を使う
+	StorageClassName string `json:"storageClassName" yaml:"storageClassName"`
 }
 
 type BlockStorageState string
@@ -150,6 +153,47 @@ type BlockStorage struct {
 	Status BlockStorageStatus `json:"status" yaml:"status"`
 }
 
+type StorageClassDriver string
+
+const (
+	StorageClassDriverLocal StorageClassDriver = "Local"
+	StorageClassDriverCeph  StorageClassDriver = "Ceph"
+)
+
+type StorageClassProvisioning string
+
+const (
+	StorageClassProvisioningThin  StorageClassProvisioning = "Thin"
+	StorageClassProvisioningThick StorageClassProvisioning = "Thick"
+)
+
+type StorageClassLocalParameters struct {
+	// DirPath はBlockStorageを保存するディレクトリ。空の場合はagentのblockStorageDirPath
+	DirPath string `json:"dirPath" yaml:"dirPath"`
+	// ClusterSize はqcow2のcluster_size。空の場合はqemu-imgのデフォルト
+	ClusterSize string `json:"clusterSize" yaml:"clusterSize"`
+}
+
+type StorageClassCephParameters struct {
+	// PoolName はBlockStorageを作成するpool。空の場合はagentのcephBackend.poolName
+	PoolName string `json:"poolName" yaml:"poolName"`
+}
+
+type StorageClassSpec struct {
+	Driver StorageClassDriver `json:"driver" yaml:"driver"`
+	// Provisioning が空の場合はThin
+	Provisioning StorageClassProvisioning `json:"provisioning" yaml:"provisioning"`
+
+	Local StorageClassLocalParameters `json:"local" yaml:"local"`
+	Ceph  StorageClassCephParameters  `json:"ceph" yaml:"ceph"`
+}
+
+type StorageClass struct {
+	meta.Meta `json:"meta" yaml:"meta"`
+
+	Spec StorageClassSpec `json:"spec" yaml:"spec"`
+}
+
 type VirtualMachineLoginUser struct {
 	Username          string   `json:"username" yaml:"username"`
 	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys" yaml:"sshAuthorizedKeys"`
@@ -214,6 +258,13 @@ type NodeSpec struct {
 	Address     string `json:"address" yaml:"address"`
 	LimitVcpus  string `json:"limitVcpus" yaml:"limitVcpus"`
 	LimitMemory string `json:"limitMemory" yaml:"limitMemory"`
+	// LimitDisk はLocalのBlockStorageに使えるdiskの量
+	LimitDisk string `json:"limitDisk" yaml:"limitDisk"`
+
+	// VcpusOvercommitRatio, MemoryOvercommitRatio はcapacityの何倍まで割り当てるか
+	// 0の場合は1倍
+	VcpusOvercommitRatio  float64 `json:"vcpusOvercommitRatio" yaml:"vcpusOvercommitRatio"`
+	MemoryOvercommitRatio float64 `json:"memoryOvercommitRatio" yaml:"memoryOvercommitRatio"`
 }
 
 type NodeState string
@@ -223,11 +274,24 @@ const (
 	NodeStateReady    NodeState = "Ready"
 )
 
+type NodeResources struct {
+	Vcpus  string `json:"vcpus" yaml:"vcpus"`
+	Memory string `json:"memory" yaml:"memory"`
+	Disk   string `json:"disk" yaml:"disk"`
+}
+
 type NodeStatus struct {
 	State           NodeState `json:"state" yaml:"state"`
 	RequestedVcpus  string    `json:"requestedVcpus" yaml:"requestedVcpus"`
 	RequestedMemory string    `json:"requestedMemory" yaml:"requestedMemory"`
 	RequestedDisk   string    `json:"requestedDisk" yaml:"requestedDisk"`
+
+	// Capacity はagentがホストから取得したcpu数、MemTotal、blockStorageDirPathのファイルシステムの容量
+	Capacity NodeResources `json:"capacity" yaml:"capacity"`
+	// Allocatable はCapacityにovercommit ratioをかけてspecのlimitで制限した割り当て可能な量
+	Allocatable NodeResources `json:"allocatable" yaml:"allocatable"`
+	// FreeDisk はblockStorageDirPathのファイルシステムの空き容量
+	FreeDisk string `json:"freeDisk" yaml:"freeDisk"`
 }
 
 type Node struct {
@@ -6,9 +6,11 @@ import (
 
 type VirtualMachineHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 	OpenConsole(ctx *gin.Context)
 	ConsoleWebSocketProxy(ctx *gin.Context)
@@ -16,6 +18,9 @@ type VirtualMachineHandlerInterface interface {
 
 const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/virtualmachines"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "virtualmachines"
 )
 
 type VirtualMachineHandler struct {
@@ -38,7 +43,10 @@ func (h *VirtualMachineHandler) RegisterHandlers() {
 		vm.GET("/:virtual_machine_id/console", h.vmhi.OpenConsole)
 		vm.GET("/:virtual_machine_id", h.vmhi.Find)
 		vm.POST("", h.vmhi.Create)
+		vm.PUT("/:virtual_machine_id/status", h.vmhi.UpdateStatus)
 		vm.PUT("/:virtual_machine_id", h.vmhi.Update)
 		vm.DELETE("/:virtual_machine_id", h.vmhi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.vmhi.FindAllInCluster)
 }
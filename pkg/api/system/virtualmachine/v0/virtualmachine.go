@@ -1,6 +1,7 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/koding/websocketproxy"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
@@ -30,6 +32,15 @@ func NewVirtualMachineHandler(store store.Store) *VirtualMachineHandler {
 func (h *VirtualMachineHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのVirtualMachineを返す
+func (h *VirtualMachineHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *VirtualMachineHandler) findAll(ctx *gin.Context, prefix string) {
 	vmList := []*system.VirtualMachine{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -40,10 +51,22 @@ func (h *VirtualMachineHandler) FindAll(ctx *gin.Context) {
 		}
 		return m
 	}
-	h.store.List(getKey(groupID, nsID, ""), f)
+
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&vmList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualmachines": vmList,
+		"continue":        meta.EncodeContinueToken(next),
 	})
 
 }
@@ -53,8 +76,12 @@ func (h *VirtualMachineHandler) Find(ctx *gin.Context) {
 
 	var vm system.VirtualMachine
 	err := h.store.Get(getKey(groupID, nsID, vmID), &vm)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualMachine `%s` is not found.", vmID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualMachine `%s` is not found.", vmID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -73,24 +100,48 @@ func (h *VirtualMachineHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
 	}
 
-	key := getKey(groupID, nsID, request.ID)
-	var vm system.VirtualMachine
-	err = h.store.Get(key, &vm)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` is already exists.", request.Name), nil)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeVirtualMachineV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
 		return
 	}
 
+	key := getKey(groupID, nsID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeVirtualMachineV0
-	h.store.Put(key, request)
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"virtualmachine": request,
@@ -107,29 +158,132 @@ func (h *VirtualMachineHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if vmID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change VirtualMachine Name."), nil)
 		return
 	}
 
 	key := getKey(groupID, nsID, request.ID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var vm system.VirtualMachine
 	err = h.store.Get(key, &vm)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` is not found.", request.Name), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` is not found.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	h.store.Lock(key)
-	defer h.store.Unlock(key)
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = vm.ResourceVersion
+	}
+
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeVirtualMachineV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+		OldObject: &vm,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
 
-	h.store.Put(key, request)
+	// statusは/statusからのみ更新する
+	request.Status = vm.Status
+
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"virtualmachine": request,
 	})
 }
 
+func (h *VirtualMachineHandler) UpdateStatus(ctx *gin.Context) {
+	groupID, nsID, vmID := getIDs(ctx)
+
+	var request system.VirtualMachine
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if vmID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change VirtualMachine Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, vmID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
+	var vm system.VirtualMachine
+	err = h.store.Get(key, &vm)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: VirtualMachine `%s` is not found.", vmID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = vm.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	vm.Status = request.Status
+	vm.ResourceHash = request.ResourceHash
+	vm.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &vm)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualMachine `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"virtualmachine": vm,
+	})
+}
+
 func (h *VirtualMachineHandler) Delete(ctx *gin.Context) {
 	groupID, nsID, vmID := getIDs(ctx)
 
@@ -137,7 +291,10 @@ func (h *VirtualMachineHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualmachine": nil,
@@ -156,7 +313,7 @@ func (h *VirtualMachineHandler) ConsoleWebSocketProxy(ctx *gin.Context) {
 
 	vm := system.VirtualMachine{}
 	if err := h.store.Get(key, &vm); err != nil {
-		if err.Error() == "Not Found" {
+		if errors.Is(err, store.ErrNotFound) {
 			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualMachine `%s` is not found.", vmID), nil)
 			return
 		} else {
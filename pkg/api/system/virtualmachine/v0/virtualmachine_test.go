@@ -0,0 +1,317 @@
+package v0_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/virtualmachine"
+	vmv0 "github.com/ophum/humstack/pkg/api/system/virtualmachine/v0"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+func TestFindAllInCluster(t *testing.T) {
+	s := memory.NewMemoryStore()
+	for _, vm := range []*system.VirtualMachine{
+		{Meta: meta.Meta{ID: "vm1", Group: "g1", Namespace: "ns1", Annotations: map[string]string{"virtualmachinev0/node_name": "node1"}}},
+		{Meta: meta.Meta{ID: "vm2", Group: "g1", Namespace: "ns2", Annotations: map[string]string{"virtualmachinev0/node_name": "node2"}}},
+		{Meta: meta.Meta{ID: "vm3", Group: "g2", Namespace: "ns1", Annotations: map[string]string{"virtualmachinev0/node_name": "node1"}}},
+	} {
+		if err := s.Put("virtualmachine/"+vm.Group+"/"+vm.Namespace+"/"+vm.ID, vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// prefixが同じ別のリソースは含まれない
+	if err := s.Put("virtualmachineset/g1/ns1/x", &system.VirtualMachine{Meta: meta.Meta{ID: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+
+	findAll := func(query string) []string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v0/virtualmachines?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("want: 200, got: %d %s", w.Code, w.Body.String())
+		}
+
+		res := struct {
+			Data struct {
+				VirtualMachines []*system.VirtualMachine `json:"virtualmachines"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, vm := range res.Data.VirtualMachines {
+			ids = append(ids, vm.ID)
+		}
+		return ids
+	}
+
+	if ids := findAll(""); len(ids) != 3 {
+		t.Fatalf("want: 3 virtualmachines, got: %v", ids)
+	}
+
+	ids := findAll("fieldSelector=" + url.QueryEscape("metadata.annotations[virtualmachinev0/node_name]=node1"))
+	if len(ids) != 2 || ids[0] != "vm1" || ids[1] != "vm3" {
+		t.Fatalf("want: [vm1 vm3], got: %v", ids)
+	}
+}
+
+// racingStore はGetの後に別のapiserverからの書き込みがあった状態を再現する
+type racingStore struct {
+	store.Store
+	afterGet func()
+}
+
+func (s *racingStore) Get(key string, data interface{}) error {
+	err := s.Store.Get(key, data)
+	if s.afterGet != nil {
+		f := s.afterGet
+		s.afterGet = nil
+		f()
+	}
+	return err
+}
+
+func serveJSON(r http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConflict(t *testing.T) {
+	s := &racingStore{Store: memory.NewMemoryStore()}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+
+	const path = "/api/v0/groups/g1/namespaces/ns1/virtualmachines"
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", Name: "vm1", Group: "g1", Namespace: "ns1"},
+		Spec: system.VirtualMachineSpec{RequestVcpus: "1"},
+	}
+	if w := serveJSON(r, http.MethodPost, path, vm); w.Code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d %s", w.Code, w.Body.String())
+	}
+	if w := serveJSON(r, http.MethodPost, path, vm); w.Code != http.StatusConflict {
+		t.Fatalf("create existing: want: 409, got: %d %s", w.Code, w.Body.String())
+	}
+
+	var stored system.VirtualMachine
+	if err := s.Get("virtualmachine/g1/ns1/vm1", &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// 保存されているものと異なるresourceVersionでの更新は反映されない
+	stale := stored
+	stale.ResourceVersion = stored.ResourceVersion + 1
+	stale.Spec.RequestVcpus = "2"
+	for _, p := range []string{path + "/vm1", path + "/vm1/status"} {
+		if w := serveJSON(r, http.MethodPut, p, &stale); w.Code != http.StatusConflict {
+			t.Fatalf("PUT %s with stale resourceVersion: want: 409, got: %d %s", p, w.Code, w.Body.String())
+		}
+	}
+
+	// resourceVersionを省略しても、読み込んだ後に更新されていれば反映されない
+	omitted := stored
+	omitted.ResourceVersion = 0
+	omitted.Spec.RequestVcpus = "2"
+	for _, p := range []string{path + "/vm1", path + "/vm1/status"} {
+		s.afterGet = func() {
+			other := stored
+			other.Spec.RequestVcpus = "4"
+			if err := s.Store.Put("virtualmachine/g1/ns1/vm1", &other); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if w := serveJSON(r, http.MethodPut, p, &omitted); w.Code != http.StatusConflict {
+			t.Fatalf("PUT %s modified after read: want: 409, got: %d %s", p, w.Code, w.Body.String())
+		}
+	}
+
+	if err := s.Get("virtualmachine/g1/ns1/vm1", &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Spec.RequestVcpus != "4" {
+		t.Fatalf("want: 4, got: %s", stored.Spec.RequestVcpus)
+	}
+
+	// 最新のresourceVersionであれば更新できる
+	stored.Spec.RequestVcpus = "2"
+	if w := serveJSON(r, http.MethodPut, path+"/vm1", &stored); w.Code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	s := memory.NewMemoryStore()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+
+	const path = "/api/v0/groups/g1/namespaces/ns1/virtualmachines"
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", Name: "vm1", Group: "g1", Namespace: "ns1"},
+		Spec: system.VirtualMachineSpec{RequestVcpus: "1"},
+	}
+	if w := serveJSON(r, http.MethodPost, path, vm); w.Code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d %s", w.Code, w.Body.String())
+	}
+
+	get := func() system.VirtualMachine {
+		var stored system.VirtualMachine
+		if err := s.Get("virtualmachine/g1/ns1/vm1", &stored); err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	// statusの更新ではspecは変更されない
+	req := get()
+	req.Spec.RequestVcpus = "8"
+	req.Status.State = system.VirtualMachineStateRunning
+	if w := serveJSON(r, http.MethodPut, path+"/vm1/status", &req); w.Code != http.StatusOK {
+		t.Fatalf("want: 200, got: %d %s", w.Code, w.Body.String())
+	}
+	stored := get()
+	if stored.Spec.RequestVcpus != "1" || stored.Status.State != system.VirtualMachineStateRunning {
+		t.Fatalf("want: requestVcpus 1, state Running, got: requestVcpus %s, state %s", stored.Spec.RequestVcpus, stored.Status.State)
+	}
+
+	// specの更新ではstatusは変更されない
+	req = get()
+	req.Spec.RequestVcpus = "2"
+	req.Status.State = system.VirtualMachineStateStopped
+	if w := serveJSON(r, http.MethodPut, path+"/vm1", &req); w.Code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d %s", w.Code, w.Body.String())
+	}
+	stored = get()
+	if stored.Spec.RequestVcpus != "2" || stored.Status.State != system.VirtualMachineStateRunning {
+		t.Fatalf("want: requestVcpus 2, state Running, got: requestVcpus %s, state %s", stored.Spec.RequestVcpus, stored.Status.State)
+	}
+}
+
+// failingStore は読み込みはできるが書き込みに失敗するstore
+type failingStore struct {
+	store.Store
+}
+
+func (s *failingStore) Put(key string, data interface{}) error {
+	return fmt.Errorf("put failed")
+}
+
+func (s *failingStore) Delete(key string) error {
+	return fmt.Errorf("delete failed")
+}
+
+func (s *failingStore) Txn(compares []store.Compare, ops []store.Op) error {
+	return fmt.Errorf("txn failed")
+}
+
+func TestStoreError(t *testing.T) {
+	s := &failingStore{Store: memory.NewMemoryStore()}
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", Name: "vm1", Group: "g1", Namespace: "ns1"},
+		Spec: system.VirtualMachineSpec{RequestVcpus: "1"},
+	}
+	if err := s.Store.Put("virtualmachine/g1/ns1/vm1", vm); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+
+	const path = "/api/v0/groups/g1/namespaces/ns1/virtualmachines"
+	created := *vm
+	created.ID, created.Name = "vm2", "vm2"
+	tests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodPost, path, &created},
+		{http.MethodPut, path + "/vm1", vm},
+		{http.MethodPut, path + "/vm1/status", vm},
+		{http.MethodDelete, path + "/vm1", nil},
+	}
+	for _, tt := range tests {
+		w := serveJSON(r, tt.method, tt.path, tt.body)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("%s %s: want: 500, got: %d %s", tt.method, tt.path, w.Code, w.Body.String())
+		}
+
+		res := struct {
+			Error string `json:"error"`
+			Data  struct {
+				Reason meta.ErrorReason `json:"reason"`
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Data.Reason != meta.ErrorReasonStore || res.Error == "" {
+			t.Fatalf("%s %s: want: reason %s with error, got: %s", tt.method, tt.path, meta.ErrorReasonStore, w.Body.String())
+		}
+	}
+}
+
+func TestScopeMismatch(t *testing.T) {
+	s := memory.NewMemoryStore()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	virtualmachine.NewVirtualMachineHandler(r.Group("/api/v0"), vmv0.NewVirtualMachineHandler(s)).RegisterHandlers()
+
+	const path = "/api/v0/groups/g1/namespaces/ns1/virtualmachines"
+
+	// パスと異なるgroupやnamespaceは指定できない
+	for _, m := range []meta.Meta{
+		{ID: "vm1", Name: "vm1", Group: "g2", Namespace: "ns1"},
+		{ID: "vm1", Name: "vm1", Group: "g1", Namespace: "ns2"},
+	} {
+		vm := &system.VirtualMachine{Meta: m, Spec: system.VirtualMachineSpec{RequestVcpus: "1"}}
+		if w := serveJSON(r, http.MethodPost, path, vm); w.Code != http.StatusBadRequest {
+			t.Fatalf("create in %s/%s: want: 400, got: %d %s", m.Group, m.Namespace, w.Code, w.Body.String())
+		}
+	}
+
+	// 省略した場合はパスのgroupとnamespaceになる
+	vm := &system.VirtualMachine{
+		Meta: meta.Meta{ID: "vm1", Name: "vm1"},
+		Spec: system.VirtualMachineSpec{RequestVcpus: "1"},
+	}
+	if w := serveJSON(r, http.MethodPost, path, vm); w.Code != http.StatusCreated {
+		t.Fatalf("want: 201, got: %d %s", w.Code, w.Body.String())
+	}
+	var stored system.VirtualMachine
+	if err := s.Get("virtualmachine/g1/ns1/vm1", &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Group != "g1" || stored.Namespace != "ns1" {
+		t.Fatalf("want: g1/ns1, got: %s/%s", stored.Group, stored.Namespace)
+	}
+
+	stored.Group = "g2"
+	if w := serveJSON(r, http.MethodPut, path+"/vm1", &stored); w.Code != http.StatusBadRequest {
+		t.Fatalf("update to g2: want: 400, got: %d %s", w.Code, w.Body.String())
+	}
+}
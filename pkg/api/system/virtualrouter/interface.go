@@ -6,9 +6,11 @@ import (
 
 type VirtualRouterHandlerInterface interface {
 	FindAll(ctx *gin.Context)
+	FindAllInCluster(ctx *gin.Context)
 	Find(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	UpdateStatus(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 }
 
@@ -19,6 +21,9 @@ type VirtualRouterHandler struct {
 
 const (
 	basePath = "groups/:group_id/namespaces/:namespace_id/virtualrouters"
+
+	// clusterPath は全てのグループ・ネームスペースのリソースを取得する
+	clusterPath = "virtualrouters"
 )
 
 func NewVirtualRouterHandler(router *gin.RouterGroup, vrhi VirtualRouterHandlerInterface) *VirtualRouterHandler {
@@ -34,7 +39,10 @@ func (h *VirtualRouterHandler) RegisterHandlers() {
 		ns.GET("", h.vrhi.FindAll)
 		ns.GET("/:virtualrouter_id", h.vrhi.Find)
 		ns.POST("", h.vrhi.Create)
+		ns.PUT("/:virtualrouter_id/status", h.vrhi.UpdateStatus)
 		ns.PUT("/:virtualrouter_id", h.vrhi.Update)
 		ns.DELETE("/:virtualrouter_id", h.vrhi.Delete)
 	}
+
+	h.router.GET(clusterPath, h.vrhi.FindAllInCluster)
 }
@@ -1,12 +1,14 @@
 package v0
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/admission"
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
@@ -29,6 +31,15 @@ func NewVirtualRouterHandler(store store.Store) *VirtualRouterHandler {
 func (h *VirtualRouterHandler) FindAll(ctx *gin.Context) {
 	groupID, nsID, _ := getIDs(ctx)
 
+	h.findAll(ctx, getKey(groupID, nsID, "")+"/")
+}
+
+// FindAllInCluster は全てのグループ・ネームスペースのVirtualRouterを返す
+func (h *VirtualRouterHandler) FindAllInCluster(ctx *gin.Context) {
+	h.findAll(ctx, getKey("", "", "")+"/")
+}
+
+func (h *VirtualRouterHandler) findAll(ctx *gin.Context, prefix string) {
 	vrList := []*system.VirtualRouter{}
 	f := func(n int) []interface{} {
 		m := []interface{}{}
@@ -40,10 +51,21 @@ func (h *VirtualRouterHandler) FindAll(ctx *gin.Context) {
 		return m
 	}
 
-	h.store.List(getKey(groupID, nsID, ""), f)
+	options, err := meta.GetListOptions(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+	next, err := h.store.ListPage(prefix, options.StartKey, options.Limit, f)
+	if err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+	options.Filter(&vrList)
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualrouters": vrList,
+		"continue":       meta.EncodeContinueToken(next),
 	})
 }
 
@@ -52,8 +74,12 @@ func (h *VirtualRouterHandler) Find(ctx *gin.Context) {
 
 	var vr system.VirtualRouter
 	err := h.store.Get(getKey(groupID, nsID, vrID), &vr)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualRouter `%s` is not found.", nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("VirtualRouter `%s` is not found.", nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
@@ -73,6 +99,11 @@ func (h *VirtualRouterHandler) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if request.ID == "" {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: id is empty."), nil)
 		return
@@ -80,25 +111,48 @@ func (h *VirtualRouterHandler) Create(ctx *gin.Context) {
 
 	var ns core.Namespace
 	err = h.store.Get(filepath.Join("namespace", groupID, nsID), &ns)
-	if err != nil && err.Error() == "Not Found" {
-		log.Println("error")
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			log.Println("error")
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: namespace is not found."), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
-	key := getKey(groupID, nsID, request.ID)
-	var vr system.VirtualRouter
-	err = h.store.Get(key, &vr)
-	if err == nil {
-		meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualRouter `%s` is already exists.", request.Name), nil)
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeVirtualRouterV0,
+		Operation: admission.OperationCreate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
 		return
 	}
 
+	key := getKey(groupID, nsID, request.ID)
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
 	request.APIType = meta.APITypeVirtualRouterV0
-	h.store.Put(key, request)
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareNotExists(key)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualRouter `%s` is already exists.", request.Name), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusCreated, nil, gin.H{
 		"virtualrouter": request,
@@ -115,26 +169,129 @@ func (h *VirtualRouterHandler) Update(ctx *gin.Context) {
 		return
 	}
 
+	if err := request.SetScope(groupID, nsID); err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	if vrID != request.ID {
 		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change VirtualRouter Name."), nil)
 		return
 	}
 
 	key := getKey(groupID, nsID, vrID)
+
+	h.store.Lock(key)
+	defer h.store.Unlock(key)
+
 	var vr system.VirtualRouter
 	err = h.store.Get(key, &vr)
-	if err != nil && err.Error() == "Not Found" {
-		meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: VirtualRouter `%s` is not found in Namespace `%s`.", vrID, nsID), nil)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: VirtualRouter `%s` is not found in Namespace `%s`.", vrID, nsID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = vr.ResourceVersion
+	}
+
+	// statusは/statusからのみ更新する
+	request.Status = vr.Status
+
+	admissionRequest := &admission.Request{
+		APIType:   meta.APITypeVirtualRouterV0,
+		Operation: admission.OperationUpdate,
+		Group:     groupID,
+		Namespace: nsID,
+		Object:    &request,
+		OldObject: &vr,
+	}
+	if !admission.Admit(ctx, h.store, admissionRequest) {
+		return
+	}
+
+	err = admission.Txn(h.store, admissionRequest,
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &request)},
+	)
+	if err != nil {
+		if errors.Is(err, admission.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, err, nil)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualRouter `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
 		return
 	}
 
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"virtualrouter": request,
+	})
+}
+
+func (h *VirtualRouterHandler) UpdateStatus(ctx *gin.Context) {
+	groupID, nsID, vrID := getIDs(ctx)
+
+	var request system.VirtualRouter
+	err := ctx.Bind(&request)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if vrID != request.ID {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, fmt.Errorf("Error: Can't change VirtualRouter Name."), nil)
+		return
+	}
+
+	key := getKey(groupID, nsID, vrID)
+
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Put(key, request)
+	var vr system.VirtualRouter
+	err = h.store.Get(key, &vr)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			meta.ResponseJSON(ctx, http.StatusNotFound, fmt.Errorf("Error: VirtualRouter `%s` is not found.", vrID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
+
+	// resourceVersionが省略された場合は読み込んだ時点から変更されていないことを条件にする
+	if request.ResourceVersion == 0 {
+		request.ResourceVersion = vr.ResourceVersion
+	}
+
+	// specは変更せずにstatusとagentが書き込むresourceHash, annotationsのみ更新する
+	vr.Status = request.Status
+	vr.ResourceHash = request.ResourceHash
+	vr.MergeAnnotations(request.Annotations)
+	err = h.store.Txn(
+		[]store.Compare{store.CompareResourceVersion(key, request.ResourceVersion)},
+		[]store.Op{store.OpPut(key, &vr)},
+	)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			meta.ResponseJSON(ctx, http.StatusConflict, fmt.Errorf("Error: VirtualRouter `%s` has been modified. resourceVersion is stale.", request.ID), nil)
+			return
+		}
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
-		"virtualrouter": request,
+		"virtualrouter": vr,
 	})
 }
 
@@ -145,7 +302,10 @@ func (h *VirtualRouterHandler) Delete(ctx *gin.Context) {
 	h.store.Lock(key)
 	defer h.store.Unlock(key)
 
-	h.store.Delete(key)
+	if err := h.store.Delete(key); err != nil {
+		meta.ResponseStoreError(ctx, err)
+		return
+	}
 	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
 		"virtualrouter": nil,
 	})
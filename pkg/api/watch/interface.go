@@ -6,6 +6,7 @@ import (
 
 type WatchHandlerInterface interface {
 	Watch(ctx *gin.Context)
+	Metrics(ctx *gin.Context)
 }
 
 type WatchHandler struct {
@@ -28,5 +29,6 @@ func (h *WatchHandler) RegisterHandlers() {
 	ns := h.router.Group(basePath)
 	{
 		ns.GET("", h.whi.Watch)
+		ns.GET("/metrics", h.whi.Metrics)
 	}
 }
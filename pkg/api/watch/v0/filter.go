@@ -0,0 +1,70 @@
+package v0
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+// watchFilter はクエリで指定された条件でイベントを絞り込む
+// 変更前後のどちらかが条件を満たせば送る
+type watchFilter struct {
+	apiType       string
+	group         string
+	namespace     string
+	id            string
+	labelSelector meta.LabelSelector
+}
+
+func newWatchFilter(ctx *gin.Context) (*watchFilter, error) {
+	labelSelector, err := meta.ParseLabelSelector(ctx.Query("labelSelector"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &watchFilter{
+		apiType:       ctx.Query("apiType"),
+		group:         ctx.Query("group"),
+		namespace:     ctx.Query("namespace"),
+		id:            ctx.Query("id"),
+		labelSelector: labelSelector,
+	}, nil
+}
+
+func (f *watchFilter) match(noticeData *leveldb.NoticeData) bool {
+	if f.apiType != "" && f.apiType != string(noticeData.APIType) {
+		return false
+	}
+
+	if f.group == "" && f.namespace == "" && f.id == "" && len(f.labelSelector) == 0 {
+		return true
+	}
+
+	return f.matchObject(noticeData.Before) || f.matchObject(noticeData.After)
+}
+
+func (f *watchFilter) matchObject(dataJSON string) bool {
+	if dataJSON == "" {
+		return false
+	}
+
+	obj := struct {
+		Meta meta.Meta `json:"meta"`
+	}{}
+	if err := json.Unmarshal([]byte(dataJSON), &obj); err != nil {
+		return false
+	}
+
+	if f.group != "" && f.group != obj.Meta.Group {
+		return false
+	}
+	if f.namespace != "" && f.namespace != obj.Meta.Namespace {
+		return false
+	}
+	if f.id != "" && f.id != obj.Meta.ID {
+		return false
+	}
+	return f.labelSelector.Matches(obj.Meta.Labels)
+}
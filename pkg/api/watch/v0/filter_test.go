@@ -0,0 +1,44 @@
+package v0
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+func TestWatchFilter(t *testing.T) {
+	prodVM := `{"meta":{"id":"vm1","group":"gr1","namespace":"ns1","apiType":"systemv0/virtualmachine","labels":{"env":"prod","tier":"web"}}}`
+	devVM := `{"meta":{"id":"vm1","group":"gr1","namespace":"ns1","apiType":"systemv0/virtualmachine","labels":{"env":"dev"}}}`
+
+	tests := []struct {
+		query  string
+		notice leveldb.NoticeData
+		want   bool
+	}{
+		{"", leveldb.NoticeData{APIType: "corev0/group"}, true},
+		{"apiType=systemv0/virtualmachine", leveldb.NoticeData{APIType: "corev0/group"}, false},
+		{"group=gr1&namespace=ns1&id=vm1", leveldb.NoticeData{After: prodVM}, true},
+		{"group=gr2", leveldb.NoticeData{After: prodVM}, false},
+		{"namespace=ns2", leveldb.NoticeData{After: prodVM}, false},
+		{"labelSelector=env%3Dprod%2Ctier%21%3Ddb", leveldb.NoticeData{After: prodVM}, true},
+		{"labelSelector=env%3Dprod", leveldb.NoticeData{After: devVM}, false},
+		// ラベルが外れた変更や削除も通知する
+		{"labelSelector=env%3Dprod", leveldb.NoticeData{Before: prodVM, After: devVM}, true},
+		{"labelSelector=env%3Dprod", leveldb.NoticeData{Before: prodVM}, true},
+	}
+
+	for _, tt := range tests {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("GET", "/api/v0/watches?"+tt.query, nil)
+
+		filter, err := newWatchFilter(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := filter.match(&tt.notice); got != tt.want {
+			t.Errorf("%s: want: %v, got: %v", tt.query, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package v0
+
+import (
+	"sync"
+)
+
+type Event struct {
+	Revision int64
+	Data     string
+}
+
+// EventHistory は直近のイベントを保持するリングバッファ
+type EventHistory struct {
+	locker       *sync.RWMutex
+	events       []Event
+	start        int
+	size         int
+	lastRevision int64
+}
+
+// NewEventHistory は最大capacity件のイベントを保持するEventHistoryを作成する
+// baseRevisionには作成時点でのstoreのrevisionを渡す
+func NewEventHistory(capacity int, baseRevision int64) *EventHistory {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &EventHistory{
+		locker:       &sync.RWMutex{},
+		events:       make([]Event, capacity),
+		lastRevision: baseRevision,
+	}
+}
+
+func (h *EventHistory) Add(event Event) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	if h.size < len(h.events) {
+		h.events[(h.start+h.size)%len(h.events)] = event
+		h.size++
+	} else {
+		// 一番古いイベントを上書きする
+		h.events[h.start] = event
+		h.start = (h.start + 1) % len(h.events)
+	}
+	h.lastRevision = event.Revision
+}
+
+// Since はsinceRevisionより後のイベントを古い順に返す
+// 保持しているイベントより古いrevisionや未来のrevisionが指定された場合は
+// 取りこぼしなく再送できないためfalseを返す
+func (h *EventHistory) Since(sinceRevision int64) ([]Event, bool) {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+
+	if sinceRevision > h.lastRevision {
+		return nil, false
+	}
+
+	oldestRevision := h.lastRevision + 1
+	if h.size > 0 {
+		oldestRevision = h.events[h.start].Revision
+	}
+	if sinceRevision < oldestRevision-1 {
+		return nil, false
+	}
+
+	events := []Event{}
+	for i := 0; i < h.size; i++ {
+		e := h.events[(h.start+i)%len(h.events)]
+		if e.Revision > sinceRevision {
+			events = append(events, e)
+		}
+	}
+	return events, true
+}
+
+// LastRevision は最後に追加されたイベントのrevisionを返す
+func (h *EventHistory) LastRevision() int64 {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+	return h.lastRevision
+}
+
+// Reset は保持しているイベントを破棄してbaseRevisionから記録し直す
+// storeの内容が通知なしで置き換えられた場合に使う
+func (h *EventHistory) Reset(baseRevision int64) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	h.start = 0
+	h.size = 0
+	h.lastRevision = baseRevision
+}
@@ -0,0 +1,42 @@
+package v0
+
+import (
+	"testing"
+)
+
+func TestEventHistorySince(t *testing.T) {
+	h := NewEventHistory(3, 10)
+
+	if events, ok := h.Since(10); !ok || len(events) != 0 {
+		t.Fatalf("want: no events, got: %v %v", events, ok)
+	}
+	if _, ok := h.Since(11); ok {
+		t.Fatal("want: resync required for future revision")
+	}
+
+	for rev := int64(11); rev <= 15; rev++ {
+		h.Add(Event{Revision: rev, Data: "data"})
+	}
+
+	// 11, 12 は溢れている
+	if _, ok := h.Since(11); ok {
+		t.Fatal("want: resync required for overflowed revision")
+	}
+
+	events, ok := h.Since(12)
+	if !ok {
+		t.Fatal("want: ok")
+	}
+	if len(events) != 3 || events[0].Revision != 13 || events[2].Revision != 15 {
+		t.Fatalf("want: revisions 13..15, got: %v", events)
+	}
+
+	events, ok = h.Since(14)
+	if !ok || len(events) != 1 || events[0].Revision != 15 {
+		t.Fatalf("want: revision 15, got: %v %v", events, ok)
+	}
+
+	if h.LastRevision() != 15 {
+		t.Fatalf("want: 15, got: %d", h.LastRevision())
+	}
+}
@@ -0,0 +1,159 @@
+package v0
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+// Subscriber はHubに登録されたwatcher
+// Hubから切り離されるとCがcloseされる
+type Subscriber struct {
+	ID string
+	C  <-chan string
+
+	ch     chan string
+	lagged bool
+}
+
+// Lagged は受信が追いつかずにHubから切り離されたかどうかを返す
+// Cがcloseされた後に呼び出すこと
+func (s *Subscriber) Lagged() bool {
+	return s.lagged
+}
+
+type HubMetrics struct {
+	ActiveSubscribers    int   `json:"activeSubscribers"`
+	LaggedSubscribers    int64 `json:"laggedSubscribers"`
+	BroadcastedEvents    int64 `json:"broadcastedEvents"`
+	LastRevision         int64 `json:"lastRevision"`
+	SubscriberBufferSize int   `json:"subscriberBufferSize"`
+}
+
+// Hub はstoreからの通知を履歴に残しつつ登録されたwatcherに配信する
+type Hub struct {
+	locker      *sync.RWMutex
+	subscribers map[string]*Subscriber
+	history     *EventHistory
+	bufferSize  int
+
+	laggedSubscribers int64
+	broadcastedEvents int64
+}
+
+// NewHub はwatcherごとにbufferSize件までイベントをバッファするHubを作成する
+func NewHub(history *EventHistory, bufferSize int) *Hub {
+	return &Hub{
+		locker:      &sync.RWMutex{},
+		subscribers: map[string]*Subscriber{},
+		history:     history,
+		bufferSize:  bufferSize,
+	}
+}
+
+// Run はnotifierがcloseされるまで通知を配信する
+func (h *Hub) Run(notifier <-chan string) {
+	for n := range notifier {
+		noticeData := leveldb.NoticeData{}
+		if err := json.Unmarshal([]byte(n), &noticeData); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if noticeData.Reset {
+			h.Reset(noticeData.Revision)
+			continue
+		}
+
+		h.Broadcast(Event{
+			Revision: noticeData.Revision,
+			Data:     n,
+		})
+	}
+}
+
+// Broadcast はイベントを履歴に追加し全てのwatcherに送る
+// バッファが溢れたwatcherは他のwatcherを止めないように切り離す
+func (h *Hub) Broadcast(event Event) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	h.history.Add(event)
+	h.broadcastedEvents++
+
+	for id, sub := range h.subscribers {
+		select {
+		case sub.ch <- event.Data:
+		default:
+			sub.lagged = true
+			h.laggedSubscribers++
+			delete(h.subscribers, id)
+			close(sub.ch)
+		}
+	}
+}
+
+// Reset は履歴をrevisionから記録し直し、全てのwatcherを切り離す
+// 切り離されたwatcherが再開しようとすると履歴にないため410が返り、Listし直すことになる
+func (h *Hub) Reset(revision int64) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	h.history.Reset(revision)
+	for id, sub := range h.subscribers {
+		delete(h.subscribers, id)
+		close(sub.ch)
+	}
+}
+
+func (h *Hub) Register() (*Subscriber, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string, h.bufferSize)
+	sub := &Subscriber{
+		ID: id.String(),
+		C:  ch,
+		ch: ch,
+	}
+
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.subscribers[sub.ID] = sub
+	return sub, nil
+}
+
+// Unregister はwatcherをHubから切り離す
+// すでに切り離されている場合は何もしない
+func (h *Hub) Unregister(sub *Subscriber) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	if _, ok := h.subscribers[sub.ID]; !ok {
+		return
+	}
+	delete(h.subscribers, sub.ID)
+	close(sub.ch)
+}
+
+func (h *Hub) History() *EventHistory {
+	return h.history
+}
+
+func (h *Hub) Metrics() HubMetrics {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+
+	return HubMetrics{
+		ActiveSubscribers:    len(h.subscribers),
+		LaggedSubscribers:    h.laggedSubscribers,
+		BroadcastedEvents:    h.broadcastedEvents,
+		LastRevision:         h.history.LastRevision(),
+		SubscriberBufferSize: h.bufferSize,
+	}
+}
@@ -0,0 +1,85 @@
+package v0
+
+import (
+	"testing"
+)
+
+func TestHubDropLaggedSubscriber(t *testing.T) {
+	hub := NewHub(NewEventHistory(10, 0), 2)
+
+	fast, err := hub.Register()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := hub.Register()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := hub.Metrics(); m.ActiveSubscribers != 2 {
+		t.Fatalf("want: 2 active subscribers, got: %d", m.ActiveSubscribers)
+	}
+
+	for rev := int64(1); rev <= 3; rev++ {
+		hub.Broadcast(Event{Revision: rev, Data: "data"})
+		// fastだけ受信する
+		<-fast.C
+	}
+
+	// slowはバッファが溢れたので切り離されている
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("want: 2 buffered events, got: %d", received)
+	}
+	if !slow.Lagged() {
+		t.Fatal("want: slow subscriber is lagged")
+	}
+
+	m := hub.Metrics()
+	if m.ActiveSubscribers != 1 || m.LaggedSubscribers != 1 || m.BroadcastedEvents != 3 || m.LastRevision != 3 {
+		t.Fatalf("unexpected metrics: %+v", m)
+	}
+
+	// 切り離し済みのsubscriberをUnregisterしても問題ない
+	hub.Unregister(slow)
+	hub.Unregister(fast)
+	if _, ok := <-fast.C; ok {
+		t.Fatal("want: closed channel")
+	}
+	if m := hub.Metrics(); m.ActiveSubscribers != 0 {
+		t.Fatalf("want: 0 active subscribers, got: %d", m.ActiveSubscribers)
+	}
+}
+
+func TestHubReset(t *testing.T) {
+	hub := NewHub(NewEventHistory(10, 0), 10)
+	for rev := int64(1); rev <= 3; rev++ {
+		hub.Broadcast(Event{Revision: rev, Data: "data"})
+	}
+
+	sub, err := hub.Register()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub.Reset(10)
+
+	// 全てのwatcherが切り離される
+	if _, ok := <-sub.C; ok {
+		t.Fatal("want: closed channel")
+	}
+	if sub.Lagged() {
+		t.Fatal("want: not lagged")
+	}
+
+	// Reset前のrevisionからは再開できない
+	if _, ok := hub.History().Since(3); ok {
+		t.Fatal("want: resync required")
+	}
+	if events, ok := hub.History().Since(10); !ok || len(events) != 0 {
+		t.Fatalf("want: no events, got: %v %v", events, ok)
+	}
+}
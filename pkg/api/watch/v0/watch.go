@@ -3,9 +3,12 @@ package v0
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/watch"
 	"github.com/ophum/humstack/pkg/store/leveldb"
 )
@@ -13,47 +16,118 @@ import (
 type WatchHandler struct {
 	watch.WatchHandlerInterface
 
-	notifiers map[string](chan string)
+	hub *Hub
 }
 
-func NewWatchHandler(notifiers map[string](chan string)) *WatchHandler {
+func NewWatchHandler(hub *Hub) *WatchHandler {
 	return &WatchHandler{
-		notifiers: notifiers,
+		hub: hub,
 	}
 }
 
 func (h *WatchHandler) Watch(ctx *gin.Context) {
-	id, err := uuid.NewRandom()
+	sinceRevision, resume, err := getSinceRevision(ctx)
 	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
 		return
 	}
 
-	idString := id.String()
-	h.notifiers[idString] = make(chan string)
+	filter, err := newWatchFilter(ctx)
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// 取りこぼしがないように履歴を取得する前に登録しておく
+	sub, err := h.hub.Register()
+	if err != nil {
+		meta.ResponseJSON(ctx, http.StatusInternalServerError, err, nil)
+		return
+	}
+	defer h.hub.Unregister(sub)
+
+	replayEvents := []Event{}
+	if resume {
+		events, ok := h.hub.History().Since(sinceRevision)
+		if !ok {
+			meta.ResponseJSON(ctx, http.StatusGone,
+				fmt.Errorf("resync required: revision %d is not available", sinceRevision),
+				gin.H{
+					"revision": h.hub.History().LastRevision(),
+				})
+			return
+		}
+		replayEvents = events
+	}
 
 	ctx.Header("Content-Type", "text/event-stream")
 	ctx.Header("Cache-Control", "no-cache")
 	ctx.Header("Connection", "keep-alive")
 	ctx.Header("Access-Control-Allow-Origin", "*")
 
-	apiType := ctx.DefaultQuery("apiType", "")
-
 	w := ctx.Writer
-	go func() {
+	lastRevision := sinceRevision
+	send := func(s string) {
+		noticeData := leveldb.NoticeData{}
+		json.Unmarshal([]byte(s), &noticeData)
+
+		// 再送済みのイベントは送らない
+		if noticeData.Revision <= lastRevision {
+			return
+		}
+		lastRevision = noticeData.Revision
 
-		for s := range h.notifiers[idString] {
-			noticeData := leveldb.NoticeData{}
-			json.Unmarshal([]byte(s), &noticeData)
+		if filter.match(&noticeData) {
 
-			if apiType == "" || apiType == string(noticeData.APIType) {
+			w.Write([]byte(fmt.Sprintf("id: %d\ndata: %s\n\n", noticeData.Revision, s)))
+			w.Flush()
+		}
+	}
 
-				w.Write([]byte(fmt.Sprintf("data: %s\n\n", s)))
-				w.Flush()
+	for _, e := range replayEvents {
+		send(e.Data)
+	}
+	w.Flush()
+
+	// gin.Context.Done()はnilを返すのでリクエストのcontextで切断を検知する
+	done := ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case s, ok := <-sub.C:
+			if !ok {
+				// 受信が追いつかずにHubから切り離された
+				// クライアントはLast-Event-IDを付けて再接続すれば履歴から再開できる
+				if sub.Lagged() {
+					log.Println("watch: drop lagged subscriber", sub.ID, "at revision", lastRevision)
+				}
+				return
 			}
-
+			send(s)
 		}
+	}
+}
 
-	}()
-	<-ctx.Done()
+func (h *WatchHandler) Metrics(ctx *gin.Context) {
+	meta.ResponseJSON(ctx, http.StatusOK, nil, gin.H{
+		"metrics": h.hub.Metrics(),
+	})
+}
 
+// getSinceRevision は再開するrevisionをクエリまたはLast-Event-IDヘッダから取得する
+func getSinceRevision(ctx *gin.Context) (int64, bool, error) {
+	sinceRevision := ctx.Query("sinceRevision")
+	if sinceRevision == "" {
+		sinceRevision = ctx.GetHeader("Last-Event-ID")
+	}
+	if sinceRevision == "" {
+		return 0, false, nil
+	}
+
+	revision, err := strconv.ParseInt(sinceRevision, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("Error: invalid revision `%s`.", sinceRevision)
+	}
+	return revision, true, nil
 }
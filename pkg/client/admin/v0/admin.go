@@ -0,0 +1,114 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/admin"
+)
+
+type AdminClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type BackupResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Archive admin.Archive `json:"archive"`
+	} `json:"data"`
+}
+
+type RestoreResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Revision int64 `json:"revision"`
+		Entries  int   `json:"entries"`
+	} `json:"data"`
+}
+
+const (
+	basePath = "api/v0/admin"
+)
+
+func NewAdminClient(scheme, apiServerAddress string, apiServerPort int32) *AdminClient {
+	return &AdminClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accepted":     "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *AdminClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *AdminClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+// Backup はapiserverの全てのデータをArchiveとして取得する
+func (c *AdminClient) Backup() (*admin.Archive, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath("backup"))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	backupResp := BackupResponse{}
+	err = json.Unmarshal(body, &backupResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", backupResp.Error)
+	}
+
+	return &backupResp.Data.Archive, nil
+}
+
+// Restore はarchiveを空のapiserverに読み込み、読み込んだkeyの数とリストア後のrevisionを返す
+func (c *AdminClient) Restore(archive *admin.Archive) (int, int64, error) {
+	body, err := json.Marshal(archive)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath("restore"))
+	if err != nil {
+		return 0, 0, err
+	}
+	body = resp.Body()
+
+	restoreResp := RestoreResponse{}
+	err = json.Unmarshal(body, &restoreResp)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if resp.IsError() {
+		return 0, 0, fmt.Errorf("%v", restoreResp.Error)
+	}
+
+	return restoreResp.Data.Entries, restoreResp.Data.Revision, nil
+}
+
+func (c *AdminClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+}
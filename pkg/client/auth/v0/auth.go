@@ -0,0 +1,132 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/auth"
+)
+
+type AuthClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type LoginResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	} `json:"data"`
+}
+
+type WhoAmIResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Subject auth.Subject `json:"subject"`
+	} `json:"data"`
+}
+
+const (
+	basePath = "api/v0/auth"
+)
+
+func NewAuthClient(scheme, apiServerAddress string, apiServerPort int32) *AuthClient {
+	return &AuthClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accept":       "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *AuthClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *AuthClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+// Login はユーザーのIDとパスワードでトークンを発行する
+func (c *AuthClient) Login(id, password string) (string, time.Time, error) {
+	body, err := json.Marshal(auth.LoginRequest{
+		ID:       id,
+		Password: password,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath("login"))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	loginResp := LoginResponse{}
+	if err := json.Unmarshal(resp.Body(), &loginResp); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.IsError() {
+		return "", time.Time{}, fmt.Errorf("%v", loginResp.Error)
+	}
+
+	return loginResp.Data.Token, loginResp.Data.ExpiresAt, nil
+}
+
+// Logout はSetTokenで設定したトークンを無効にする
+func (c *AuthClient) Logout() error {
+	resp, err := c.client.R().SetHeaders(c.headers).Post(c.getPath("logout"))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to logout: %s", resp.Status())
+	}
+	return nil
+}
+
+// WhoAmI はSetTokenで設定したトークンで認証されるSubjectを返す
+func (c *AuthClient) WhoAmI() (*auth.Subject, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath("whoami"))
+	if err != nil {
+		return nil, err
+	}
+
+	whoAmIResp := WhoAmIResponse{}
+	if err := json.Unmarshal(resp.Body(), &whoAmIResp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusUnauthorized {
+		return nil, fmt.Errorf("Error: unauthorized: %v", whoAmIResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", whoAmIResp.Error)
+	}
+
+	return &whoAmIResp.Data.Subject, nil
+}
+
+func (c *AuthClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+}
@@ -1,28 +1,45 @@
 package client
 
 import (
+	"crypto/tls"
+
+	adminv0 "github.com/ophum/humstack/pkg/client/admin/v0"
+	authv0 "github.com/ophum/humstack/pkg/client/auth/v0"
 	"github.com/ophum/humstack/pkg/client/core"
 	"github.com/ophum/humstack/pkg/client/system"
 	watchv0 "github.com/ophum/humstack/pkg/client/watch/v0"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 type Clients struct {
 	coreV0           *core.CoreV0Clients
 	systemV0         *system.SystemV0Clients
 	watchV0          *watchv0.WatchClient
+	adminV0          *adminv0.AdminClient
+	authV0           *authv0.AuthClient
 	apiServerAddress string
 	apiServerPort    int32
 }
 
-func NewClients(apiServerAddress string, apiServerPort int32) *Clients {
-	return &Clients{
+// NewClients はtlsConfigがnilの場合はHTTP、それ以外はHTTPSでapiserverにアクセスするクライアントを作成する
+func NewClients(apiServerAddress string, apiServerPort int32, tlsConfig *tls.Config) *Clients {
+	scheme := tlsutil.Scheme(tlsConfig)
+	c := &Clients{
 		apiServerAddress: apiServerAddress,
 		apiServerPort:    apiServerPort,
 
-		coreV0:   core.NewCoreV0Clients(apiServerAddress, apiServerPort),
-		systemV0: system.NewSystemV0Clients(apiServerAddress, apiServerPort),
-		watchV0:  watchv0.NewWatchClient("http", apiServerAddress, apiServerPort),
+		coreV0:   core.NewCoreV0Clients(apiServerAddress, apiServerPort, tlsConfig),
+		systemV0: system.NewSystemV0Clients(apiServerAddress, apiServerPort, tlsConfig),
+		watchV0:  watchv0.NewWatchClient(scheme, apiServerAddress, apiServerPort),
+		adminV0:  adminv0.NewAdminClient(scheme, apiServerAddress, apiServerPort),
+		authV0:   authv0.NewAuthClient(scheme, apiServerAddress, apiServerPort),
+	}
+	if tlsConfig != nil {
+		c.watchV0.SetTLSConfig(tlsConfig)
+		c.adminV0.SetTLSConfig(tlsConfig)
+		c.authV0.SetTLSConfig(tlsConfig)
 	}
+	return c
 }
 
 func (c *Clients) CoreV0() *core.CoreV0Clients {
@@ -35,3 +52,20 @@ func (c *Clients) SystemV0() *system.SystemV0Clients {
 func (c *Clients) WatchV0() *watchv0.WatchClient {
 	return c.watchV0
 }
+
+func (c *Clients) AdminV0() *adminv0.AdminClient {
+	return c.adminV0
+}
+
+func (c *Clients) AuthV0() *authv0.AuthClient {
+	return c.authV0
+}
+
+// SetToken は全てのクライアントのリクエストに付けるBearerトークンを設定する
+func (c *Clients) SetToken(token string) {
+	c.coreV0.SetToken(token)
+	c.systemV0.SetToken(token)
+	c.watchV0.SetToken(token)
+	c.adminV0.SetToken(token)
+	c.authV0.SetToken(token)
+}
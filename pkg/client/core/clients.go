@@ -1,11 +1,18 @@
 package core
 
 import (
+	"crypto/tls"
+
 	eipv0 "github.com/ophum/humstack/pkg/client/core/externalip/v0"
 	eippoolv0 "github.com/ophum/humstack/pkg/client/core/externalippool/v0"
 	grv0 "github.com/ophum/humstack/pkg/client/core/group/v0"
 	nsv0 "github.com/ophum/humstack/pkg/client/core/namespace/v0"
 	netv0 "github.com/ophum/humstack/pkg/client/core/network/v0"
+	rqv0 "github.com/ophum/humstack/pkg/client/core/resourcequota/v0"
+	rbv0 "github.com/ophum/humstack/pkg/client/core/rolebinding/v0"
+	sav0 "github.com/ophum/humstack/pkg/client/core/serviceaccount/v0"
+	userv0 "github.com/ophum/humstack/pkg/client/core/user/v0"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 type CoreV0Clients struct {
@@ -17,19 +24,33 @@ type CoreV0Clients struct {
 	eippoolClient   *eippoolv0.ExternalIPPoolClient
 	eipClient       *eipv0.ExternalIPClient
 	networkClient   *netv0.NetworkClient
+	userClient      *userv0.UserClient
+	saClient        *sav0.ServiceAccountClient
+	rbClient        *rbv0.RoleBindingClient
+	rqClient        *rqv0.ResourceQuotaClient
 }
 
-func NewCoreV0Clients(apiServerAddress string, apiServerPort int32) *CoreV0Clients {
-	return &CoreV0Clients{
+// NewCoreV0Clients はtlsConfigがnilの場合はHTTP、それ以外はHTTPSでapiserverにアクセスする
+func NewCoreV0Clients(apiServerAddress string, apiServerPort int32, tlsConfig *tls.Config) *CoreV0Clients {
+	scheme := tlsutil.Scheme(tlsConfig)
+	c := &CoreV0Clients{
 		apiServerAddress: apiServerAddress,
 		apiServerPort:    apiServerPort,
 
-		namespaceClient: nsv0.NewNamespaceClient("http", apiServerAddress, apiServerPort),
-		groupClient:     grv0.NewGroupClient("http", apiServerAddress, apiServerPort),
-		eipClient:       eipv0.NewExternalIPClient("http", apiServerAddress, apiServerPort),
-		eippoolClient:   eippoolv0.NewExternalIPPoolClient("http", apiServerAddress, apiServerPort),
-		networkClient:   netv0.NewNetworkClient("http", apiServerAddress, apiServerPort),
+		namespaceClient: nsv0.NewNamespaceClient(scheme, apiServerAddress, apiServerPort),
+		groupClient:     grv0.NewGroupClient(scheme, apiServerAddress, apiServerPort),
+		eipClient:       eipv0.NewExternalIPClient(scheme, apiServerAddress, apiServerPort),
+		eippoolClient:   eippoolv0.NewExternalIPPoolClient(scheme, apiServerAddress, apiServerPort),
+		networkClient:   netv0.NewNetworkClient(scheme, apiServerAddress, apiServerPort),
+		userClient:      userv0.NewUserClient(scheme, apiServerAddress, apiServerPort),
+		saClient:        sav0.NewServiceAccountClient(scheme, apiServerAddress, apiServerPort),
+		rbClient:        rbv0.NewRoleBindingClient(scheme, apiServerAddress, apiServerPort),
+		rqClient:        rqv0.NewResourceQuotaClient(scheme, apiServerAddress, apiServerPort),
+	}
+	if tlsConfig != nil {
+		c.setTLSConfig(tlsConfig)
 	}
+	return c
 }
 
 func (c *CoreV0Clients) Namespace() *nsv0.NamespaceClient {
@@ -51,3 +72,44 @@ func (c *CoreV0Clients) ExternalIP() *eipv0.ExternalIPClient {
 func (c *CoreV0Clients) Network() *netv0.NetworkClient {
 	return c.networkClient
 }
+
+func (c *CoreV0Clients) User() *userv0.UserClient {
+	return c.userClient
+}
+
+func (c *CoreV0Clients) ServiceAccount() *sav0.ServiceAccountClient {
+	return c.saClient
+}
+
+func (c *CoreV0Clients) RoleBinding() *rbv0.RoleBindingClient {
+	return c.rbClient
+}
+
+func (c *CoreV0Clients) ResourceQuota() *rqv0.ResourceQuotaClient {
+	return c.rqClient
+}
+
+// SetToken は全てのクライアントのリクエストに付けるBearerトークンを設定する
+func (c *CoreV0Clients) SetToken(token string) {
+	c.namespaceClient.SetToken(token)
+	c.groupClient.SetToken(token)
+	c.eippoolClient.SetToken(token)
+	c.eipClient.SetToken(token)
+	c.networkClient.SetToken(token)
+	c.userClient.SetToken(token)
+	c.saClient.SetToken(token)
+	c.rbClient.SetToken(token)
+	c.rqClient.SetToken(token)
+}
+
+func (c *CoreV0Clients) setTLSConfig(tlsConfig *tls.Config) {
+	c.namespaceClient.SetTLSConfig(tlsConfig)
+	c.groupClient.SetTLSConfig(tlsConfig)
+	c.eippoolClient.SetTLSConfig(tlsConfig)
+	c.eipClient.SetTLSConfig(tlsConfig)
+	c.networkClient.SetTLSConfig(tlsConfig)
+	c.userClient.SetTLSConfig(tlsConfig)
+	c.saClient.SetTLSConfig(tlsConfig)
+	c.rbClient.SetTLSConfig(tlsConfig)
+	c.rqClient.SetTLSConfig(tlsConfig)
+}
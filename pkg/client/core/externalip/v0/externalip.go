@@ -1,12 +1,15 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 )
 
 type ExternalIPClient struct {
@@ -30,6 +33,7 @@ type ExternalIPListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		ExternalIPList []*core.ExternalIP `json:"externalips"`
+		Continue       string             `json:"continue"`
 	} `json:"data"`
 }
 
@@ -50,6 +54,16 @@ func NewExternalIPClient(scheme, apiServerAddress string, apiServerPort int32) *
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ExternalIPClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ExternalIPClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *ExternalIPClient) Get(eipID string) (*core.ExternalIP, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(eipID))
 	if err != nil {
@@ -63,22 +77,87 @@ func (c *ExternalIPClient) Get(eipID string) (*core.ExternalIP, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(eipResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", eipResp.Error)
+	}
+
 	return &eipResp.Data.ExternalIP, nil
 }
 
+// List は全てのExternalIPを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *ExternalIPClient) List() ([]*core.ExternalIP, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのExternalIPを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *ExternalIPClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.ExternalIP, error) {
+	eipList := []*core.ExternalIP{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(eip *core.ExternalIP) error {
+		eipList = append(eipList, eip)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return eipList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのExternalIPに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ExternalIPClient) ListEach(query meta.ListQuery, f func(eip *core.ExternalIP) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		eipList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, eip := range eipList {
+			if err := f(eip); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するExternalIPを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ExternalIPClient) ListPage(query meta.ListQuery) ([]*core.ExternalIP, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
 	body := resp.Body()
 
-	eipResp := ExternalIPListResponse{}
-	err = json.Unmarshal(body, &eipResp)
+	listResp := ExternalIPListResponse{}
+	err = json.Unmarshal(body, &listResp)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return eipResp.Data.ExternalIPList, nil
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ExternalIPList, listResp.Data.Continue, nil
 }
 
 func (c *ExternalIPClient) Create(eip *core.ExternalIP) (*core.ExternalIP, error) {
@@ -124,15 +203,30 @@ func (c *ExternalIPClient) Update(eip *core.ExternalIP) (*core.ExternalIP, error
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(eipResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", eipResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	eip.ResourceVersion = eipResp.Data.ExternalIP.ResourceVersion
+
 	return &eipResp.Data.ExternalIP, nil
 }
 
 func (c *ExternalIPClient) Delete(eipID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eipID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eipID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
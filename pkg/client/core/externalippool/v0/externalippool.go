@@ -1,12 +1,15 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 )
 
 type ExternalIPPoolClient struct {
@@ -30,6 +33,7 @@ type ExternalIPPoolListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		ExternalIPPoolList []*core.ExternalIPPool `json:"externalippools"`
+		Continue           string                 `json:"continue"`
 	} `json:"data"`
 }
 
@@ -50,6 +54,16 @@ func NewExternalIPPoolClient(scheme, apiServerAddress string, apiServerPort int3
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ExternalIPPoolClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ExternalIPPoolClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *ExternalIPPoolClient) Get(eippoolID string) (*core.ExternalIPPool, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(eippoolID))
 	if err != nil {
@@ -63,22 +77,87 @@ func (c *ExternalIPPoolClient) Get(eippoolID string) (*core.ExternalIPPool, erro
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(eippoolResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", eippoolResp.Error)
+	}
+
 	return &eippoolResp.Data.ExternalIPPool, nil
 }
 
+// List は全てのExternalIPPoolを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *ExternalIPPoolClient) List() ([]*core.ExternalIPPool, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのExternalIPPoolを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *ExternalIPPoolClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.ExternalIPPool, error) {
+	eippoolList := []*core.ExternalIPPool{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(eippool *core.ExternalIPPool) error {
+		eippoolList = append(eippoolList, eippool)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return eippoolList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのExternalIPPoolに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ExternalIPPoolClient) ListEach(query meta.ListQuery, f func(eippool *core.ExternalIPPool) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		eippoolList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, eippool := range eippoolList {
+			if err := f(eippool); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するExternalIPPoolを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ExternalIPPoolClient) ListPage(query meta.ListQuery) ([]*core.ExternalIPPool, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
 	body := resp.Body()
 
-	eippoolResp := ExternalIPPoolListResponse{}
-	err = json.Unmarshal(body, &eippoolResp)
+	listResp := ExternalIPPoolListResponse{}
+	err = json.Unmarshal(body, &listResp)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return eippoolResp.Data.ExternalIPPoolList, nil
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ExternalIPPoolList, listResp.Data.Continue, nil
 }
 
 func (c *ExternalIPPoolClient) Create(eippool *core.ExternalIPPool) (*core.ExternalIPPool, error) {
@@ -124,15 +203,30 @@ func (c *ExternalIPPoolClient) Update(eippool *core.ExternalIPPool) (*core.Exter
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(eippoolResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", eippoolResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	eippool.ResourceVersion = eippoolResp.Data.ExternalIPPool.ResourceVersion
+
 	return &eippoolResp.Data.ExternalIPPool, nil
 }
 
 func (c *ExternalIPPoolClient) Delete(eippoolID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eippoolID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(eippoolID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,6 +33,7 @@ type GroupListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		GroupList []*core.Group `json:"groups"`
+		Continue  string        `json:"continue"`
 	} `json:"data"`
 }
 
@@ -51,6 +54,16 @@ func NewGroupClient(scheme, apiServerAddress string, apiServerPort int32) *Group
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *GroupClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *GroupClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *GroupClient) Get(groupID string) (*core.Group, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID))
 	if err != nil {
@@ -64,22 +77,87 @@ func (c *GroupClient) Get(groupID string) (*core.Group, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(groupResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", groupResp.Error)
+	}
+
 	return &groupResp.Data.Group, nil
 }
 
+// List は全てのGroupを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *GroupClient) List() ([]*core.Group, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのGroupを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *GroupClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.Group, error) {
+	groupList := []*core.Group{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(group *core.Group) error {
+		groupList = append(groupList, group)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return groupList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのGroupに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *GroupClient) ListEach(query meta.ListQuery, f func(group *core.Group) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		groupList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, group := range groupList {
+			if err := f(group); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するGroupを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *GroupClient) ListPage(query meta.ListQuery) ([]*core.Group, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
 	body := resp.Body()
 
-	groupResp := GroupListResponse{}
-	err = json.Unmarshal(body, &groupResp)
+	listResp := GroupListResponse{}
+	err = json.Unmarshal(body, &listResp)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return groupResp.Data.GroupList, nil
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.GroupList, listResp.Data.Continue, nil
 }
 
 func (c *GroupClient) Create(group *core.Group) (*core.Group, error) {
@@ -125,15 +203,30 @@ func (c *GroupClient) Update(group *core.Group) (*core.Group, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(groupResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", groupResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	group.ResourceVersion = groupResp.Data.Group.ResourceVersion
+
 	return &groupResp.Data.Group, nil
 }
 
 func (c *GroupClient) Delete(groupID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
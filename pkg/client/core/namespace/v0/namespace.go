@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type NamespaceListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		NamespaceList []*core.Namespace `json:"namespaces"`
+		Continue      string            `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces"
+	clusterPath    = "api/v0/namespaces"
 )
 
 func NewNamespaceClient(scheme, apiServerAddress string, apiServerPort int32) *NamespaceClient {
@@ -51,6 +55,16 @@ func NewNamespaceClient(scheme, apiServerAddress string, apiServerPort int32) *N
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *NamespaceClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *NamespaceClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *NamespaceClient) Get(groupID, namespaceID string) (*core.Namespace, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID))
 	if err != nil {
@@ -64,22 +78,117 @@ func (c *NamespaceClient) Get(groupID, namespaceID string) (*core.Namespace, err
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(namespaceResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", namespaceResp.Error)
+	}
+
 	return &namespaceResp.Data.Namespace, nil
 }
 
+// List は全てのNamespaceを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *NamespaceClient) List(groupID string) ([]*core.Namespace, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
+	return c.ListWithSelector(groupID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのNamespaceを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *NamespaceClient) ListWithSelector(groupID string, labelSelector, fieldSelector string) ([]*core.Namespace, error) {
+	nsList := []*core.Namespace{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, query, func(ns *core.Namespace) error {
+		nsList = append(nsList, ns)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := resp.Body()
+	return nsList, nil
+}
 
-	namespaceResp := NamespaceListResponse{}
-	err = json.Unmarshal(body, &namespaceResp)
+// ListInCluster は全てのグループからlabelSelectorとfieldSelectorに一致するNamespaceを取得する
+func (c *NamespaceClient) ListInCluster(labelSelector, fieldSelector string) ([]*core.Namespace, error) {
+	nsList := []*core.Namespace{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(ns *core.Namespace) error {
+		nsList = append(nsList, ns)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return namespaceResp.Data.NamespaceList, nil
+	return nsList, nil
+}
+
+// ListEachInCluster は全てのグループからqueryに一致するNamespaceに対してfを呼び出す
+func (c *NamespaceClient) ListEachInCluster(query meta.ListQuery, f func(ns *core.Namespace) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのNamespaceに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *NamespaceClient) ListEach(groupID string, query meta.ListQuery, f func(ns *core.Namespace) error) error {
+	return c.listEach(c.getPath(groupID, ""), query, f)
+}
+
+func (c *NamespaceClient) listEach(path string, query meta.ListQuery, f func(ns *core.Namespace) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		nsList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, ns := range nsList {
+			if err := f(ns); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するNamespaceを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *NamespaceClient) ListPage(groupID string, query meta.ListQuery) ([]*core.Namespace, string, error) {
+	return c.listPage(c.getPath(groupID, ""), query)
+}
+
+func (c *NamespaceClient) listPage(path string, query meta.ListQuery) ([]*core.Namespace, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := NamespaceListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.NamespaceList, listResp.Data.Continue, nil
 }
 
 func (c *NamespaceClient) Create(namespace *core.Namespace) (*core.Namespace, error) {
@@ -125,15 +234,30 @@ func (c *NamespaceClient) Update(namespace *core.Namespace) (*core.Namespace, er
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(namespaceResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", namespaceResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	namespace.ResourceVersion = namespaceResp.Data.Namespace.ResourceVersion
+
 	return &namespaceResp.Data.Namespace, nil
 }
 
 func (c *NamespaceClient) Delete(groupID, namespaceID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
@@ -160,3 +284,12 @@ func (c *NamespaceClient) getPath(groupID, path string) string {
 			fmt.Sprintf(basePathFormat, groupID),
 			path))
 }
+
+func (c *NamespaceClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type NetworkListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		NetworkList []*core.Network `json:"networks"`
+		Continue    string          `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces/%s/networks"
+	clusterPath    = "api/v0/networks"
 )
 
 func NewNetworkClient(scheme, apiServerAddress string, apiServerPort int32) *NetworkClient {
@@ -51,6 +55,16 @@ func NewNetworkClient(scheme, apiServerAddress string, apiServerPort int32) *Net
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *NetworkClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *NetworkClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *NetworkClient) Get(groupID, namespaceID, networkID string) (*core.Network, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, networkID))
 	if err != nil {
@@ -64,22 +78,117 @@ func (c *NetworkClient) Get(groupID, namespaceID, networkID string) (*core.Netwo
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
 	return &nodeResp.Data.Network, nil
 }
 
+// List は全てのNetworkを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *NetworkClient) List(groupID, namespaceID string) ([]*core.Network, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのNetworkを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *NetworkClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*core.Network, error) {
+	netList := []*core.Network{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(net *core.Network) error {
+		netList = append(netList, net)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := resp.Body()
+	return netList, nil
+}
 
-	nodeResp := NetworkListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するNetworkを取得する
+func (c *NetworkClient) ListInCluster(labelSelector, fieldSelector string) ([]*core.Network, error) {
+	netList := []*core.Network{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(net *core.Network) error {
+		netList = append(netList, net)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return nodeResp.Data.NetworkList, nil
+	return netList, nil
+}
+
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するNetworkに対してfを呼び出す
+func (c *NetworkClient) ListEachInCluster(query meta.ListQuery, f func(net *core.Network) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのNetworkに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *NetworkClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(net *core.Network) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *NetworkClient) listEach(path string, query meta.ListQuery, f func(net *core.Network) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		netList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, net := range netList {
+			if err := f(net); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するNetworkを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *NetworkClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*core.Network, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *NetworkClient) listPage(path string, query meta.ListQuery) ([]*core.Network, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := NetworkListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.NetworkList, listResp.Data.Continue, nil
 }
 
 func (c *NetworkClient) Create(network *core.Network) (*core.Network, error) {
@@ -125,15 +234,63 @@ func (c *NetworkClient) Update(network *core.Network) (*core.Network, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	network.ResourceVersion = nodeResp.Data.Network.ResourceVersion
+
+	return &nodeResp.Data.Network, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *NetworkClient) UpdateStatus(network *core.Network) (*core.Network, error) {
+	body, err := json.Marshal(network)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(network.Group, network.Namespace, filepath.Join(network.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	nodeResp := NetworkResponse{}
+	err = json.Unmarshal(body, &nodeResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	network.ResourceVersion = nodeResp.Data.Network.ResourceVersion
+
 	return &nodeResp.Data.Network, nil
 }
 
 func (c *NetworkClient) Delete(groupID, namespaceID, networkID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, networkID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, networkID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
@@ -158,3 +315,12 @@ func (c *NetworkClient) getPath(groupID, namespaceID, networkID string) string {
 			fmt.Sprintf(basePathFormat, groupID, namespaceID),
 			networkID))
 }
+
+func (c *NetworkClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
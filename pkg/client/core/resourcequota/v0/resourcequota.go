@@ -0,0 +1,289 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type ResourceQuotaClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type ResourceQuotaResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ResourceQuota core.ResourceQuota `json:"resourcequota"`
+	} `json:"data"`
+}
+
+type ResourceQuotaListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ResourceQuotaList []*core.ResourceQuota `json:"resourcequotas"`
+		Continue          string                `json:"continue"`
+	} `json:"data"`
+}
+
+const (
+	groupPathFormat     = "api/v0/groups/%s/resourcequotas"
+	namespacePathFormat = "api/v0/groups/%s/namespaces/%s/resourcequotas"
+	clusterPath         = "api/v0/resourcequotas"
+)
+
+func NewResourceQuotaClient(scheme, apiServerAddress string, apiServerPort int32) *ResourceQuotaClient {
+	return &ResourceQuotaClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accepted":     "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ResourceQuotaClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ResourceQuotaClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+// Get はnamespaceIDが空の場合はgroup全体のResourceQuotaを取得する
+func (c *ResourceQuotaClient) Get(groupID, namespaceID, rqID string) (*core.ResourceQuota, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, rqID))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	rqResp := ResourceQuotaResponse{}
+	err = json.Unmarshal(body, &rqResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(rqResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", rqResp.Error)
+	}
+
+	return &rqResp.Data.ResourceQuota, nil
+}
+
+// List はgroupIDとnamespaceIDのResourceQuotaを取得する
+// namespaceIDが空の場合はgroup内の全てのnamespaceのものも含む
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
+func (c *ResourceQuotaClient) List(groupID, namespaceID string) ([]*core.ResourceQuota, error) {
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのResourceQuotaを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *ResourceQuotaClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*core.ResourceQuota, error) {
+	rqList := []*core.ResourceQuota{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(rq *core.ResourceQuota) error {
+		rqList = append(rqList, rq)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rqList, nil
+}
+
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するResourceQuotaを取得する
+func (c *ResourceQuotaClient) ListInCluster(labelSelector, fieldSelector string) ([]*core.ResourceQuota, error) {
+	rqList := []*core.ResourceQuota{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(rq *core.ResourceQuota) error {
+		rqList = append(rqList, rq)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rqList, nil
+}
+
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するResourceQuotaに対してfを呼び出す
+func (c *ResourceQuotaClient) ListEachInCluster(query meta.ListQuery, f func(rq *core.ResourceQuota) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのResourceQuotaに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ResourceQuotaClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(rq *core.ResourceQuota) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *ResourceQuotaClient) listEach(path string, query meta.ListQuery, f func(rq *core.ResourceQuota) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		rqList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, rq := range rqList {
+			if err := f(rq); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するResourceQuotaを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ResourceQuotaClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*core.ResourceQuota, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *ResourceQuotaClient) listPage(path string, query meta.ListQuery) ([]*core.ResourceQuota, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := ResourceQuotaListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ResourceQuotaList, listResp.Data.Continue, nil
+}
+
+func (c *ResourceQuotaClient) Create(rq *core.ResourceQuota) (*core.ResourceQuota, error) {
+	body, err := json.Marshal(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(rq.Group, rq.Namespace, ""))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	rqResp := ResourceQuotaResponse{}
+	err = json.Unmarshal(body, &rqResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error: %+v", rqResp.Error)
+	}
+
+	return &rqResp.Data.ResourceQuota, nil
+}
+
+func (c *ResourceQuotaClient) Update(rq *core.ResourceQuota) (*core.ResourceQuota, error) {
+	body, err := json.Marshal(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(rq.Group, rq.Namespace, rq.ID))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	rqResp := ResourceQuotaResponse{}
+	err = json.Unmarshal(body, &rqResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(rqResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", rqResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	rq.ResourceVersion = rqResp.Data.ResourceQuota.ResourceVersion
+
+	return &rqResp.Data.ResourceQuota, nil
+}
+
+func (c *ResourceQuotaClient) Delete(groupID, namespaceID, rqID string) error {
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, rqID))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
+	return nil
+}
+
+// getPath はnamespaceIDが空の場合はgroup全体のResourceQuotaのパスを返す
+func (c *ResourceQuotaClient) getPath(groupID, namespaceID, rqID string) string {
+	basePath := fmt.Sprintf(groupPathFormat, groupID)
+	if namespaceID != "" {
+		basePath = fmt.Sprintf(namespacePathFormat, groupID, namespaceID)
+	}
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d",
+				c.apiServerAddress, c.apiServerPort),
+			basePath,
+			rqID))
+}
+
+func (c *ResourceQuotaClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
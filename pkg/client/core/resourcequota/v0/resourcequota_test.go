@@ -0,0 +1,102 @@
+package v0
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+const (
+	groupID = "test-group-00"
+	rqID    = "test-resourcequota-00"
+)
+
+func TestResourceQuotaCreate(t *testing.T) {
+
+	client := NewResourceQuotaClient("http", "localhost", 8080)
+
+	rq, err := client.Create(&core.ResourceQuota{
+		Meta: meta.Meta{
+			ID:    rqID,
+			Name:  "TEST0",
+			Group: groupID,
+		},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{
+				RequestVcpus:    "8",
+				VirtualMachines: "4",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(rq)
+	log.Println(string(buf))
+}
+
+func TestResourceQuotaList(t *testing.T) {
+	client := NewResourceQuotaClient("http", "localhost", 8080)
+
+	rqList, err := client.List(groupID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.MarshalIndent(rqList, "", "  ")
+	fmt.Println(string(buf))
+
+}
+
+func TestResourceQuotaGet(t *testing.T) {
+	client := NewResourceQuotaClient("http", "localhost", 8080)
+
+	rq, err := client.Get(groupID, "", rqID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.MarshalIndent(rq, "", "  ")
+	fmt.Println(string(buf))
+
+}
+
+func TestResourceQuotaUpdate(t *testing.T) {
+	client := NewResourceQuotaClient("http", "localhost", 8080)
+
+	rq, err := client.Update(&core.ResourceQuota{
+		Meta: meta.Meta{
+			Name:  "TEST00-changed",
+			ID:    rqID,
+			Group: groupID,
+		},
+		Spec: core.ResourceQuotaSpec{
+			Hard: core.ResourceQuotaResources{
+				RequestVcpus:    "16",
+				VirtualMachines: "8",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(rq)
+	log.Println(string(buf))
+
+}
+
+func TestResourceQuotaDelete(t *testing.T) {
+	client := NewResourceQuotaClient("http", "localhost", 8080)
+
+	err := client.Delete(groupID, "", rqID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+}
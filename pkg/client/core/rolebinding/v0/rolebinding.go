@@ -0,0 +1,243 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type RoleBindingClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type RoleBindingResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		RoleBinding core.RoleBinding `json:"rolebinding"`
+	} `json:"data"`
+}
+
+type RoleBindingListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		RoleBindingList []*core.RoleBinding `json:"rolebindings"`
+		Continue        string              `json:"continue"`
+	} `json:"data"`
+}
+
+const (
+	basePathFormat = "api/v0/rolebindings"
+)
+
+func NewRoleBindingClient(scheme, apiServerAddress string, apiServerPort int32) *RoleBindingClient {
+	return &RoleBindingClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accepted":     "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *RoleBindingClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *RoleBindingClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+func (c *RoleBindingClient) Get(rbID string) (*core.RoleBinding, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(rbID))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	rbResp := RoleBindingResponse{}
+	err = json.Unmarshal(body, &rbResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(rbResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", rbResp.Error)
+	}
+
+	return &rbResp.Data.RoleBinding, nil
+}
+
+// List は全てのRoleBindingを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
+func (c *RoleBindingClient) List() ([]*core.RoleBinding, error) {
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのRoleBindingを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *RoleBindingClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.RoleBinding, error) {
+	rbList := []*core.RoleBinding{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(rb *core.RoleBinding) error {
+		rbList = append(rbList, rb)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rbList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのRoleBindingに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *RoleBindingClient) ListEach(query meta.ListQuery, f func(rb *core.RoleBinding) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		rbList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, rb := range rbList {
+			if err := f(rb); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するRoleBindingを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *RoleBindingClient) ListPage(query meta.ListQuery) ([]*core.RoleBinding, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := RoleBindingListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.RoleBindingList, listResp.Data.Continue, nil
+}
+
+func (c *RoleBindingClient) Create(rb *core.RoleBinding) (*core.RoleBinding, error) {
+	body, err := json.Marshal(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	rbResp := RoleBindingResponse{}
+	err = json.Unmarshal(body, &rbResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error: %+v", rbResp.Error)
+	}
+
+	return &rbResp.Data.RoleBinding, nil
+}
+
+func (c *RoleBindingClient) Update(rb *core.RoleBinding) (*core.RoleBinding, error) {
+	body, err := json.Marshal(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(rb.ID))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	rbResp := RoleBindingResponse{}
+	err = json.Unmarshal(body, &rbResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(rbResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", rbResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	rb.ResourceVersion = rbResp.Data.RoleBinding.ResourceVersion
+
+	return &rbResp.Data.RoleBinding, nil
+}
+
+func (c *RoleBindingClient) Delete(rbID string) error {
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(rbID))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
+	return nil
+}
+
+func (c *RoleBindingClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d",
+				c.apiServerAddress,
+				c.apiServerPort,
+			),
+			basePathFormat,
+			path))
+}
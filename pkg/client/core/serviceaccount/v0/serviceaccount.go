@@ -0,0 +1,235 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type ServiceAccountClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type ServiceAccountResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ServiceAccount core.ServiceAccount `json:"serviceaccount"`
+		Token          string              `json:"token"`
+	} `json:"data"`
+}
+
+type ServiceAccountListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		ServiceAccountList []*core.ServiceAccount `json:"serviceaccounts"`
+		Continue           string                 `json:"continue"`
+	} `json:"data"`
+}
+
+const (
+	basePath = "api/v0/serviceaccounts"
+)
+
+func NewServiceAccountClient(scheme, apiServerAddress string, apiServerPort int32) *ServiceAccountClient {
+	return &ServiceAccountClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accept":       "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ServiceAccountClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ServiceAccountClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+func (c *ServiceAccountClient) Get(serviceAccountID string) (*core.ServiceAccount, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(serviceAccountID))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	saResp := ServiceAccountResponse{}
+	err = json.Unmarshal(body, &saResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(saResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", saResp.Error)
+	}
+
+	return &saResp.Data.ServiceAccount, nil
+}
+
+// List は全てのServiceAccountを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
+func (c *ServiceAccountClient) List() ([]*core.ServiceAccount, error) {
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのServiceAccountを取得する
+func (c *ServiceAccountClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.ServiceAccount, error) {
+	saList := []*core.ServiceAccount{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(sa *core.ServiceAccount) error {
+		saList = append(saList, sa)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのServiceAccountに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ServiceAccountClient) ListEach(query meta.ListQuery, f func(sa *core.ServiceAccount) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		saList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, sa := range saList {
+			if err := f(sa); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するServiceAccountを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ServiceAccountClient) ListPage(query meta.ListQuery) ([]*core.ServiceAccount, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := ServiceAccountListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ServiceAccountList, listResp.Data.Continue, nil
+}
+
+// Create はサービスアカウントを作成し、発行されたトークンを返す
+// トークンはapiserverに保存されないので作成時にのみ取得できる
+func (c *ServiceAccountClient) Create(sa *core.ServiceAccount) (*core.ServiceAccount, string, error) {
+	body, err := json.Marshal(sa)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
+	body = resp.Body()
+
+	saResp := ServiceAccountResponse{}
+	err = json.Unmarshal(body, &saResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, "", meta.NewConflictError(saResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", saResp.Error)
+	}
+
+	return &saResp.Data.ServiceAccount, saResp.Data.Token, nil
+}
+
+// RotateToken はトークンを再発行し、新しいトークンを返す
+// 以前のトークンは使えなくなる
+func (c *ServiceAccountClient) RotateToken(serviceAccountID string) (*core.ServiceAccount, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Post(c.getPath(filepath.Join(serviceAccountID, "token")))
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	saResp := ServiceAccountResponse{}
+	err = json.Unmarshal(body, &saResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, "", meta.NewNotFoundError(saResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", saResp.Error)
+	}
+
+	return &saResp.Data.ServiceAccount, saResp.Data.Token, nil
+}
+
+func (c *ServiceAccountClient) Delete(serviceAccountID string) error {
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(serviceAccountID))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
+	return nil
+}
+
+func (c *ServiceAccountClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+}
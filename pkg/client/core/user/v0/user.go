@@ -0,0 +1,234 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+type UserClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type UserResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		User core.User `json:"user"`
+	} `json:"data"`
+}
+
+type UserListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		UserList []*core.User `json:"users"`
+		Continue string       `json:"continue"`
+	} `json:"data"`
+}
+
+const (
+	basePath = "api/v0/users"
+)
+
+func NewUserClient(scheme, apiServerAddress string, apiServerPort int32) *UserClient {
+	return &UserClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accept":       "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *UserClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *UserClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+func (c *UserClient) Get(userID string) (*core.User, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(userID))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	userResp := UserResponse{}
+	err = json.Unmarshal(body, &userResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(userResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", userResp.Error)
+	}
+
+	return &userResp.Data.User, nil
+}
+
+// List は全てのUserを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
+func (c *UserClient) List() ([]*core.User, error) {
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのUserを取得する
+func (c *UserClient) ListWithSelector(labelSelector, fieldSelector string) ([]*core.User, error) {
+	userList := []*core.User{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(user *core.User) error {
+		userList = append(userList, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのUserに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *UserClient) ListEach(query meta.ListQuery, f func(user *core.User) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		userList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, user := range userList {
+			if err := f(user); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するUserを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *UserClient) ListPage(query meta.ListQuery) ([]*core.User, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := UserListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.UserList, listResp.Data.Continue, nil
+}
+
+func (c *UserClient) Create(user *core.User) (*core.User, error) {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	userResp := UserResponse{}
+	err = json.Unmarshal(body, &userResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error: %+v", userResp.Error)
+	}
+
+	return &userResp.Data.User, nil
+}
+
+func (c *UserClient) Update(user *core.User) (*core.User, error) {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	userResp := UserResponse{}
+	err = json.Unmarshal(body, &userResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(userResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", userResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	user.ResourceVersion = userResp.Data.User.ResourceVersion
+
+	return &userResp.Data.User, nil
+}
+
+func (c *UserClient) Delete(userID string) error {
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(userID))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
+	return nil
+}
+
+func (c *UserClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+}
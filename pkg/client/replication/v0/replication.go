@@ -0,0 +1,120 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/replication"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+type ReplicationClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type TxnResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Revision int64 `json:"revision"`
+	} `json:"data"`
+}
+
+type SnapshotResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		Snapshot store.Snapshot `json:"snapshot"`
+	} `json:"data"`
+}
+
+const (
+	basePath = "api/v0/store"
+)
+
+func NewReplicationClient(scheme, apiServerAddress string, apiServerPort int32) *ReplicationClient {
+	return &ReplicationClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accepted":     "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ReplicationClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ReplicationClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+// Txn は書き込みをapiserverのstoreに反映し、反映後のrevisionを返す
+func (c *ReplicationClient) Txn(request *replication.TxnRequest) (int64, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath("txn"))
+	if err != nil {
+		return 0, err
+	}
+	body = resp.Body()
+
+	txnResp := TxnResponse{}
+	err = json.Unmarshal(body, &txnResp)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return 0, meta.NewConflictError(txnResp.Error)
+	}
+
+	if resp.IsError() {
+		return 0, fmt.Errorf("%v", txnResp.Error)
+	}
+
+	return txnResp.Data.Revision, nil
+}
+
+// Snapshot はapiserverのstoreの全てのデータを取得する
+func (c *ReplicationClient) Snapshot() (*store.Snapshot, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath("snapshot"))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	snapshotResp := SnapshotResponse{}
+	err = json.Unmarshal(body, &snapshotResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", snapshotResp.Error)
+	}
+
+	return &snapshotResp.Data.Snapshot, nil
+}
+
+func (c *ReplicationClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s", c.scheme, filepath.Join(fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort), basePath, path))
+}
@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type BlockStorageListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		BlockStorageList []*system.BlockStorage `json:"blockstorages"`
+		Continue         string                 `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces/%s/blockstorages"
+	clusterPath    = "api/v0/blockstorages"
 )
 
 func NewBlockStorageClient(scheme, apiServerAddress string, apiServerPort int32) *BlockStorageClient {
@@ -51,6 +55,16 @@ func NewBlockStorageClient(scheme, apiServerAddress string, apiServerPort int32)
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *BlockStorageClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *BlockStorageClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *BlockStorageClient) Get(groupID, namespaceID, blockStorageID string) (*system.BlockStorage, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, blockStorageID))
 	if err != nil {
@@ -64,23 +78,117 @@ func (c *BlockStorageClient) Get(groupID, namespaceID, blockStorageID string) (*
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(bsRes.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", bsRes.Error)
+	}
+
 	return &bsRes.Data.BlockStorage, nil
 }
 
+// List は全てのBlockStorageを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *BlockStorageClient) List(groupID, namespaceID string) ([]*system.BlockStorage, error) {
-	res, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのBlockStorageを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *BlockStorageClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*system.BlockStorage, error) {
+	bsList := []*system.BlockStorage{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(bs *system.BlockStorage) error {
+		bsList = append(bsList, bs)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := res.Body()
+	return bsList, nil
+}
 
-	bsListRes := BlockStorageListResponse{}
-	err = json.Unmarshal(body, &bsListRes)
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するBlockStorageを取得する
+func (c *BlockStorageClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.BlockStorage, error) {
+	bsList := []*system.BlockStorage{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(bs *system.BlockStorage) error {
+		bsList = append(bsList, bs)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return bsList, nil
+}
 
-	return bsListRes.Data.BlockStorageList, nil
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するBlockStorageに対してfを呼び出す
+func (c *BlockStorageClient) ListEachInCluster(query meta.ListQuery, f func(bs *system.BlockStorage) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのBlockStorageに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *BlockStorageClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(bs *system.BlockStorage) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *BlockStorageClient) listEach(path string, query meta.ListQuery, f func(bs *system.BlockStorage) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		bsList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, bs := range bsList {
+			if err := f(bs); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するBlockStorageを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *BlockStorageClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*system.BlockStorage, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *BlockStorageClient) listPage(path string, query meta.ListQuery) ([]*system.BlockStorage, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := BlockStorageListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.BlockStorageList, listResp.Data.Continue, nil
 }
 
 func (c *BlockStorageClient) Create(blockstorage *system.BlockStorage) (*system.BlockStorage, error) {
@@ -126,14 +234,62 @@ func (c *BlockStorageClient) Update(blockstorage *system.BlockStorage) (*system.
 		return nil, err
 	}
 
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(bsRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", bsRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	blockstorage.ResourceVersion = bsRes.Data.BlockStorage.ResourceVersion
+
+	return &bsRes.Data.BlockStorage, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *BlockStorageClient) UpdateStatus(blockstorage *system.BlockStorage) (*system.BlockStorage, error) {
+	body, err := json.Marshal(blockstorage)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(blockstorage.Group, blockstorage.Namespace, filepath.Join(blockstorage.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = res.Body()
+
+	bsRes := BlockStorageResponse{}
+	err = json.Unmarshal(body, &bsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(bsRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", bsRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	blockstorage.ResourceVersion = bsRes.Data.BlockStorage.ResourceVersion
+
 	return &bsRes.Data.BlockStorage, nil
 }
 
 func (c *BlockStorageClient) Delete(groupID, namespaceID, blockStorageID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, blockStorageID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, blockStorageID))
 	if err != nil {
 		return err
 	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
 	return err
 
 }
@@ -159,3 +315,12 @@ func (c *BlockStorageClient) getPath(groupID, namespaceID, blockStorageID string
 			blockStorageID,
 		))
 }
+
+func (c *BlockStorageClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
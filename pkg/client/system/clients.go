@@ -1,13 +1,17 @@
 package system
 
 import (
+	"crypto/tls"
+
 	bsv0 "github.com/ophum/humstack/pkg/client/system/blockstorage/v0"
 	imv0 "github.com/ophum/humstack/pkg/client/system/image/v0"
 	iev0 "github.com/ophum/humstack/pkg/client/system/imageentity/v0"
 	nodev0 "github.com/ophum/humstack/pkg/client/system/node/v0"
 	nodenetv0 "github.com/ophum/humstack/pkg/client/system/nodenetwork/v0"
+	scv0 "github.com/ophum/humstack/pkg/client/system/storageclass/v0"
 	vmv0 "github.com/ophum/humstack/pkg/client/system/virtualmachine/v0"
 	vrv0 "github.com/ophum/humstack/pkg/client/system/virtualrouter/v0"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 )
 
 type SystemV0Clients struct {
@@ -21,22 +25,30 @@ type SystemV0Clients struct {
 	virtualrouterClient  *vrv0.VirtualRouterClient
 	imageClient          *imv0.ImageClient
 	imageEntityClient    *iev0.ImageEntityClient
+	storageClassClient   *scv0.StorageClassClient
 }
 
-func NewSystemV0Clients(apiServerAddress string, apiServerPort int32) *SystemV0Clients {
-	nodeClient := nodev0.NewNodeClient("http", apiServerAddress, apiServerPort)
-	return &SystemV0Clients{
+// NewSystemV0Clients はtlsConfigがnilの場合はHTTP、それ以外はHTTPSでapiserverにアクセスする
+func NewSystemV0Clients(apiServerAddress string, apiServerPort int32, tlsConfig *tls.Config) *SystemV0Clients {
+	scheme := tlsutil.Scheme(tlsConfig)
+	nodeClient := nodev0.NewNodeClient(scheme, apiServerAddress, apiServerPort)
+	c := &SystemV0Clients{
 		apiServerAddress: apiServerAddress,
 		apiServerPort:    apiServerPort,
 
 		nodeClient:           nodeClient,
-		nodeNetworkClient:    nodenetv0.NewNodeNetworkClient("http", apiServerAddress, apiServerPort),
-		blockstorageClient:   bsv0.NewBlockStorageClient("http", apiServerAddress, apiServerPort),
-		virtualmachineClient: vmv0.NewVirtualMachineClient("http", apiServerAddress, apiServerPort),
-		virtualrouterClient:  vrv0.NewVirtualRouterClient("http", apiServerAddress, apiServerPort),
-		imageClient:          imv0.NewImageClient("http", apiServerAddress, apiServerPort),
-		imageEntityClient:    iev0.NewImageEntityClient("http", apiServerAddress, apiServerPort),
+		nodeNetworkClient:    nodenetv0.NewNodeNetworkClient(scheme, apiServerAddress, apiServerPort),
+		blockstorageClient:   bsv0.NewBlockStorageClient(scheme, apiServerAddress, apiServerPort),
+		virtualmachineClient: vmv0.NewVirtualMachineClient(scheme, apiServerAddress, apiServerPort),
+		virtualrouterClient:  vrv0.NewVirtualRouterClient(scheme, apiServerAddress, apiServerPort),
+		imageClient:          imv0.NewImageClient(scheme, apiServerAddress, apiServerPort),
+		imageEntityClient:    iev0.NewImageEntityClient(scheme, apiServerAddress, apiServerPort),
+		storageClassClient:   scv0.NewStorageClassClient(scheme, apiServerAddress, apiServerPort),
+	}
+	if tlsConfig != nil {
+		c.setTLSConfig(tlsConfig)
 	}
+	return c
 }
 
 func (c *SystemV0Clients) Node() *nodev0.NodeClient {
@@ -66,3 +78,30 @@ func (c *SystemV0Clients) Image() *imv0.ImageClient {
 func (c *SystemV0Clients) ImageEntity() *iev0.ImageEntityClient {
 	return c.imageEntityClient
 }
+
+func (c *SystemV0Clients) StorageClass() *scv0.StorageClassClient {
+	return c.storageClassClient
+}
+
+// SetToken は全てのクライアントのリクエストに付けるBearerトークンを設定する
+func (c *SystemV0Clients) SetToken(token string) {
+	c.nodeClient.SetToken(token)
+	c.nodeNetworkClient.SetToken(token)
+	c.blockstorageClient.SetToken(token)
+	c.virtualmachineClient.SetToken(token)
+	c.virtualrouterClient.SetToken(token)
+	c.imageClient.SetToken(token)
+	c.imageEntityClient.SetToken(token)
+	c.storageClassClient.SetToken(token)
+}
+
+func (c *SystemV0Clients) setTLSConfig(tlsConfig *tls.Config) {
+	c.nodeClient.SetTLSConfig(tlsConfig)
+	c.nodeNetworkClient.SetTLSConfig(tlsConfig)
+	c.blockstorageClient.SetTLSConfig(tlsConfig)
+	c.virtualmachineClient.SetTLSConfig(tlsConfig)
+	c.virtualrouterClient.SetTLSConfig(tlsConfig)
+	c.imageClient.SetTLSConfig(tlsConfig)
+	c.imageEntityClient.SetTLSConfig(tlsConfig)
+	c.storageClassClient.SetTLSConfig(tlsConfig)
+}
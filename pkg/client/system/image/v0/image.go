@@ -1,6 +1,7 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 )
 
@@ -33,11 +35,13 @@ type ImageListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		ImageList []*system.Image `json:"images"`
+		Continue  string          `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/images"
+	clusterPath    = "api/v0/images"
 )
 
 func NewImageClient(scheme, apiServerAddress string, apiServerPort int32) *ImageClient {
@@ -53,6 +57,16 @@ func NewImageClient(scheme, apiServerAddress string, apiServerPort int32) *Image
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ImageClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ImageClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *ImageClient) Get(groupID, imageID string) (*system.Image, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, imageID))
 	if err != nil {
@@ -66,22 +80,117 @@ func (c *ImageClient) Get(groupID, imageID string) (*system.Image, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
 	return &nodeResp.Data.Image, nil
 }
 
+// List は全てのImageを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *ImageClient) List(groupID string) ([]*system.Image, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
+	return c.ListWithSelector(groupID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのImageを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *ImageClient) ListWithSelector(groupID string, labelSelector, fieldSelector string) ([]*system.Image, error) {
+	imageList := []*system.Image{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, query, func(image *system.Image) error {
+		imageList = append(imageList, image)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := resp.Body()
+	return imageList, nil
+}
 
-	nodeResp := ImageListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
+// ListInCluster は全てのグループからlabelSelectorとfieldSelectorに一致するImageを取得する
+func (c *ImageClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.Image, error) {
+	imageList := []*system.Image{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(image *system.Image) error {
+		imageList = append(imageList, image)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return nodeResp.Data.ImageList, nil
+	return imageList, nil
+}
+
+// ListEachInCluster は全てのグループからqueryに一致するImageに対してfを呼び出す
+func (c *ImageClient) ListEachInCluster(query meta.ListQuery, f func(image *system.Image) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのImageに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ImageClient) ListEach(groupID string, query meta.ListQuery, f func(image *system.Image) error) error {
+	return c.listEach(c.getPath(groupID, ""), query, f)
+}
+
+func (c *ImageClient) listEach(path string, query meta.ListQuery, f func(image *system.Image) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		imageList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, image := range imageList {
+			if err := f(image); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するImageを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ImageClient) ListPage(groupID string, query meta.ListQuery) ([]*system.Image, string, error) {
+	return c.listPage(c.getPath(groupID, ""), query)
+}
+
+func (c *ImageClient) listPage(path string, query meta.ListQuery) ([]*system.Image, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := ImageListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ImageList, listResp.Data.Continue, nil
 }
 
 func (c *ImageClient) Create(image *system.Image) (*system.Image, error) {
@@ -130,29 +239,46 @@ func (c *ImageClient) Update(image *system.Image) (*system.Image, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	image.ResourceVersion = nodeResp.Data.Image.ResourceVersion
+
 	return &nodeResp.Data.Image, nil
 }
 
 func (c *ImageClient) Delete(groupID, imageID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
 func (c *ImageClient) Download(groupID, imageID, tag string) (io.ReadCloser, int, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/tags/%s/download", c.getPath(groupID, imageID), tag))
+	// トークンとTLSの設定を使うためrestyでリクエストする
+	resp, err := c.client.R().SetDoNotParseResponse(true).Get(fmt.Sprintf("%s/tags/%s/download", c.getPath(groupID, imageID), tag))
 	if err != nil {
 		return nil, 0, err
 	}
 
-	if resp.StatusCode/100 != 2 {
+	if resp.StatusCode()/100 != 2 {
+		resp.RawBody().Close()
 		return nil, 0, fmt.Errorf("not found")
 	}
 
-	return resp.Body, int(resp.ContentLength), nil
+	return resp.RawBody(), int(resp.RawResponse.ContentLength), nil
 }
 
 func (c *ImageClient) getPath(groupID, imageID string) string {
@@ -164,3 +290,12 @@ func (c *ImageClient) getPath(groupID, imageID string) string {
 			fmt.Sprintf(basePathFormat, groupID),
 			imageID))
 }
+
+func (c *ImageClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
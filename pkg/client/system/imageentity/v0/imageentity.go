@@ -1,11 +1,14 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 )
 
@@ -30,11 +33,13 @@ type ImageEntityListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		ImageEntityList []*system.ImageEntity `json:"imageentities"`
+		Continue        string                `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/imageentities"
+	clusterPath    = "api/v0/imageentities"
 )
 
 func NewImageEntityClient(scheme, apiServerAddress string, apiServerPort int32) *ImageEntityClient {
@@ -50,6 +55,16 @@ func NewImageEntityClient(scheme, apiServerAddress string, apiServerPort int32)
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *ImageEntityClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *ImageEntityClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *ImageEntityClient) Get(groupID, imageEntityID string) (*system.ImageEntity, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, imageEntityID))
 	if err != nil {
@@ -63,22 +78,117 @@ func (c *ImageEntityClient) Get(groupID, imageEntityID string) (*system.ImageEnt
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
 	return &nodeResp.Data.ImageEntity, nil
 }
 
+// List は全てのImageEntityを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *ImageEntityClient) List(groupID string) ([]*system.ImageEntity, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, ""))
+	return c.ListWithSelector(groupID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのImageEntityを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *ImageEntityClient) ListWithSelector(groupID string, labelSelector, fieldSelector string) ([]*system.ImageEntity, error) {
+	imageEntityList := []*system.ImageEntity{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, query, func(imageEntity *system.ImageEntity) error {
+		imageEntityList = append(imageEntityList, imageEntity)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := resp.Body()
+	return imageEntityList, nil
+}
 
-	nodeResp := ImageEntityListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
+// ListInCluster は全てのグループからlabelSelectorとfieldSelectorに一致するImageEntityを取得する
+func (c *ImageEntityClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.ImageEntity, error) {
+	imageEntityList := []*system.ImageEntity{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(imageEntity *system.ImageEntity) error {
+		imageEntityList = append(imageEntityList, imageEntity)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return nodeResp.Data.ImageEntityList, nil
+	return imageEntityList, nil
+}
+
+// ListEachInCluster は全てのグループからqueryに一致するImageEntityに対してfを呼び出す
+func (c *ImageEntityClient) ListEachInCluster(query meta.ListQuery, f func(imageEntity *system.ImageEntity) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのImageEntityに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *ImageEntityClient) ListEach(groupID string, query meta.ListQuery, f func(imageEntity *system.ImageEntity) error) error {
+	return c.listEach(c.getPath(groupID, ""), query, f)
+}
+
+func (c *ImageEntityClient) listEach(path string, query meta.ListQuery, f func(imageEntity *system.ImageEntity) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		imageEntityList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, imageEntity := range imageEntityList {
+			if err := f(imageEntity); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するImageEntityを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *ImageEntityClient) ListPage(groupID string, query meta.ListQuery) ([]*system.ImageEntity, string, error) {
+	return c.listPage(c.getPath(groupID, ""), query)
+}
+
+func (c *ImageEntityClient) listPage(path string, query meta.ListQuery) ([]*system.ImageEntity, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := ImageEntityListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.ImageEntityList, listResp.Data.Continue, nil
 }
 
 func (c *ImageEntityClient) Create(imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
@@ -124,15 +234,63 @@ func (c *ImageEntityClient) Update(imageEntity *system.ImageEntity) (*system.Ima
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	imageEntity.ResourceVersion = nodeResp.Data.ImageEntity.ResourceVersion
+
+	return &nodeResp.Data.ImageEntity, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *ImageEntityClient) UpdateStatus(imageEntity *system.ImageEntity) (*system.ImageEntity, error) {
+	body, err := json.Marshal(imageEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(imageEntity.Group, filepath.Join(imageEntity.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	nodeResp := ImageEntityResponse{}
+	err = json.Unmarshal(body, &nodeResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	imageEntity.ResourceVersion = nodeResp.Data.ImageEntity.ResourceVersion
+
 	return &nodeResp.Data.ImageEntity, nil
 }
 
 func (c *ImageEntityClient) Delete(groupID, imageEntityID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageEntityID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, imageEntityID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
@@ -145,3 +303,12 @@ func (c *ImageEntityClient) getPath(groupID, imageEntityID string) string {
 			fmt.Sprintf(basePathFormat, groupID),
 			imageEntityID))
 }
+
+func (c *ImageEntityClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
@@ -1,12 +1,15 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 )
 
@@ -31,6 +34,7 @@ type NodeListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		NodeList []*system.Node `json:"nodes"`
+		Continue string         `json:"continue"`
 	} `json:"data"`
 }
 
@@ -51,6 +55,16 @@ func NewNodeClient(scheme, apiServerAddress string, apiServerPort int32) *NodeCl
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *NodeClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *NodeClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *NodeClient) Get(nodeID string) (*system.Node, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(nodeID))
 	if err != nil {
@@ -64,22 +78,87 @@ func (c *NodeClient) Get(nodeID string) (*system.Node, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
 	return &nodeResp.Data.Node, nil
 }
 
+// List は全てのNodeを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *NodeClient) List() ([]*system.Node, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(""))
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのNodeを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *NodeClient) ListWithSelector(labelSelector, fieldSelector string) ([]*system.Node, error) {
+	nodeList := []*system.Node{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(node *system.Node) error {
+		nodeList = append(nodeList, node)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return nodeList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのNodeに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *NodeClient) ListEach(query meta.ListQuery, f func(node *system.Node) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		nodeList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodeList {
+			if err := f(node); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するNodeを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *NodeClient) ListPage(query meta.ListQuery) ([]*system.Node, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
 	body := resp.Body()
 
-	nodeResp := NodeListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
+	listResp := NodeListResponse{}
+	err = json.Unmarshal(body, &listResp)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return nodeResp.Data.NodeList, nil
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.NodeList, listResp.Data.Continue, nil
 }
 
 func (c *NodeClient) Create(node *system.Node) (*system.Node, error) {
@@ -126,15 +205,63 @@ func (c *NodeClient) Update(node *system.Node) (*system.Node, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	node.ResourceVersion = nodeResp.Data.Node.ResourceVersion
+
+	return &nodeResp.Data.Node, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *NodeClient) UpdateStatus(node *system.Node) (*system.Node, error) {
+	body, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(filepath.Join(node.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	nodeResp := NodeResponse{}
+	err = json.Unmarshal(body, &nodeResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	node.ResourceVersion = nodeResp.Data.Node.ResourceVersion
+
 	return &nodeResp.Data.Node, nil
 }
 
 func (c *NodeClient) Delete(nodeID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(nodeID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(nodeID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
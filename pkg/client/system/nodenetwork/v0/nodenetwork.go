@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type NodeNetworkListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		NodeNetworkList []*system.NodeNetwork `json:"nodenetworks"`
+		Continue        string                `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces/%s/nodenetworks"
+	clusterPath    = "api/v0/nodenetworks"
 )
 
 func NewNodeNetworkClient(scheme, apiServerAddress string, apiServerPort int32) *NodeNetworkClient {
@@ -51,6 +55,16 @@ func NewNodeNetworkClient(scheme, apiServerAddress string, apiServerPort int32)
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *NodeNetworkClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *NodeNetworkClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *NodeNetworkClient) Get(groupID, namespaceID, nodenetworkID string) (*system.NodeNetwork, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, nodenetworkID))
 	if err != nil {
@@ -64,22 +78,117 @@ func (c *NodeNetworkClient) Get(groupID, namespaceID, nodenetworkID string) (*sy
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
 	return &nodeResp.Data.NodeNetwork, nil
 }
 
+// List は全てのNodeNetworkを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *NodeNetworkClient) List(groupID, namespaceID string) ([]*system.NodeNetwork, error) {
-	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのNodeNetworkを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *NodeNetworkClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*system.NodeNetwork, error) {
+	nodeNetworkList := []*system.NodeNetwork{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(nodeNetwork *system.NodeNetwork) error {
+		nodeNetworkList = append(nodeNetworkList, nodeNetwork)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := resp.Body()
+	return nodeNetworkList, nil
+}
 
-	nodeResp := NodeNetworkListResponse{}
-	err = json.Unmarshal(body, &nodeResp)
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するNodeNetworkを取得する
+func (c *NodeNetworkClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.NodeNetwork, error) {
+	nodeNetworkList := []*system.NodeNetwork{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(nodeNetwork *system.NodeNetwork) error {
+		nodeNetworkList = append(nodeNetworkList, nodeNetwork)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return nodeResp.Data.NodeNetworkList, nil
+	return nodeNetworkList, nil
+}
+
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するNodeNetworkに対してfを呼び出す
+func (c *NodeNetworkClient) ListEachInCluster(query meta.ListQuery, f func(nodeNetwork *system.NodeNetwork) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのNodeNetworkに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *NodeNetworkClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(nodeNetwork *system.NodeNetwork) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *NodeNetworkClient) listEach(path string, query meta.ListQuery, f func(nodeNetwork *system.NodeNetwork) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		nodeNetworkList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, nodeNetwork := range nodeNetworkList {
+			if err := f(nodeNetwork); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するNodeNetworkを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *NodeNetworkClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*system.NodeNetwork, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *NodeNetworkClient) listPage(path string, query meta.ListQuery) ([]*system.NodeNetwork, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := NodeNetworkListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.NodeNetworkList, listResp.Data.Continue, nil
 }
 
 func (c *NodeNetworkClient) Create(nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error) {
@@ -125,15 +234,63 @@ func (c *NodeNetworkClient) Update(nodenetwork *system.NodeNetwork) (*system.Nod
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	nodenetwork.ResourceVersion = nodeResp.Data.NodeNetwork.ResourceVersion
+
+	return &nodeResp.Data.NodeNetwork, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *NodeNetworkClient) UpdateStatus(nodenetwork *system.NodeNetwork) (*system.NodeNetwork, error) {
+	body, err := json.Marshal(nodenetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(nodenetwork.Group, nodenetwork.Namespace, filepath.Join(nodenetwork.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	nodeResp := NodeNetworkResponse{}
+	err = json.Unmarshal(body, &nodeResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(nodeResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", nodeResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	nodenetwork.ResourceVersion = nodeResp.Data.NodeNetwork.ResourceVersion
+
 	return &nodeResp.Data.NodeNetwork, nil
 }
 
 func (c *NodeNetworkClient) Delete(groupID, namespaceID, nodenetworkID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, nodenetworkID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, nodenetworkID))
 	if err != nil {
 		return err
 	}
 
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
 	return nil
 }
 
@@ -158,3 +315,12 @@ func (c *NodeNetworkClient) getPath(groupID, namespaceID, nodenetworkID string)
 			fmt.Sprintf(basePathFormat, groupID, namespaceID),
 			nodenetworkID))
 }
+
+func (c *NodeNetworkClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
@@ -0,0 +1,243 @@
+package v0
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+type StorageClassClient struct {
+	scheme           string
+	apiServerAddress string
+	apiServerPort    int32
+	client           *resty.Client
+	headers          map[string]string
+}
+
+type StorageClassResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		StorageClass system.StorageClass `json:"storageclass"`
+	} `json:"data"`
+}
+
+type StorageClassListResponse struct {
+	Code  int32       `json:"code"`
+	Error interface{} `json:"error"`
+	Data  struct {
+		StorageClassList []*system.StorageClass `json:"storageclasses"`
+		Continue         string                 `json:"continue"`
+	} `json:"data"`
+}
+
+const (
+	basePathFormat = "api/v0/storageclasses"
+)
+
+func NewStorageClassClient(scheme, apiServerAddress string, apiServerPort int32) *StorageClassClient {
+	return &StorageClassClient{
+		scheme:           scheme,
+		apiServerAddress: apiServerAddress,
+		apiServerPort:    apiServerPort,
+		client:           resty.New(),
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accepted":     "application/json",
+		},
+	}
+}
+
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *StorageClassClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *StorageClassClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
+func (c *StorageClassClient) Get(scID string) (*system.StorageClass, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(scID))
+	if err != nil {
+		return nil, err
+	}
+	body := resp.Body()
+
+	scResp := StorageClassResponse{}
+	err = json.Unmarshal(body, &scResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(scResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", scResp.Error)
+	}
+
+	return &scResp.Data.StorageClass, nil
+}
+
+// List は全てのStorageClassを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
+func (c *StorageClassClient) List() ([]*system.StorageClass, error) {
+	return c.ListWithSelector("", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのStorageClassを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *StorageClassClient) ListWithSelector(labelSelector, fieldSelector string) ([]*system.StorageClass, error) {
+	scList := []*system.StorageClass{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(query, func(sc *system.StorageClass) error {
+		scList = append(scList, sc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scList, nil
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのStorageClassに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *StorageClassClient) ListEach(query meta.ListQuery, f func(sc *system.StorageClass) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		scList, next, err := c.ListPage(query)
+		if err != nil {
+			return err
+		}
+		for _, sc := range scList {
+			if err := f(sc); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するStorageClassを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *StorageClassClient) ListPage(query meta.ListQuery) ([]*system.StorageClass, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(c.getPath(""))
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := StorageClassListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.StorageClassList, listResp.Data.Continue, nil
+}
+
+func (c *StorageClassClient) Create(sc *system.StorageClass) (*system.StorageClass, error) {
+	body, err := json.Marshal(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Post(c.getPath(""))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	scResp := StorageClassResponse{}
+	err = json.Unmarshal(body, &scResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error: %+v", scResp.Error)
+	}
+
+	return &scResp.Data.StorageClass, nil
+}
+
+func (c *StorageClassClient) Update(sc *system.StorageClass) (*system.StorageClass, error) {
+	body, err := json.Marshal(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(sc.ID))
+	if err != nil {
+		return nil, err
+	}
+	body = resp.Body()
+
+	scResp := StorageClassResponse{}
+	err = json.Unmarshal(body, &scResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(scResp.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", scResp.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	sc.ResourceVersion = scResp.Data.StorageClass.ResourceVersion
+
+	return &scResp.Data.StorageClass, nil
+}
+
+func (c *StorageClassClient) Delete(scID string) error {
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(scID))
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
+
+	return nil
+}
+
+func (c *StorageClassClient) getPath(path string) string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d",
+				c.apiServerAddress,
+				c.apiServerPort,
+			),
+			basePathFormat,
+			path))
+}
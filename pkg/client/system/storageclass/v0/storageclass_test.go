@@ -0,0 +1,95 @@
+package v0
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+)
+
+const (
+	scID = "test-storageclass-00"
+)
+
+func TestStorageClassCreate(t *testing.T) {
+
+	client := NewStorageClassClient("http", "localhost", 8080)
+
+	sc, err := client.Create(&system.StorageClass{
+		Meta: meta.Meta{
+			ID:   scID,
+			Name: "TEST0",
+		},
+		Spec: system.StorageClassSpec{
+			Driver:       system.StorageClassDriverLocal,
+			Provisioning: system.StorageClassProvisioningThin,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(sc)
+	log.Println(string(buf))
+}
+
+func TestStorageClassList(t *testing.T) {
+	client := NewStorageClassClient("http", "localhost", 8080)
+
+	scList, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.MarshalIndent(scList, "", "  ")
+	fmt.Println(string(buf))
+
+}
+
+func TestStorageClassGet(t *testing.T) {
+	client := NewStorageClassClient("http", "localhost", 8080)
+
+	sc, err := client.Get(scID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.MarshalIndent(sc, "", "  ")
+	fmt.Println(string(buf))
+
+}
+
+func TestStorageClassUpdate(t *testing.T) {
+	client := NewStorageClassClient("http", "localhost", 8080)
+
+	sc, err := client.Update(&system.StorageClass{
+		Meta: meta.Meta{
+			Name: "TEST00-changed",
+			ID:   scID,
+		},
+		Spec: system.StorageClassSpec{
+			Driver:       system.StorageClassDriverLocal,
+			Provisioning: system.StorageClassProvisioningThick,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(sc)
+	log.Println(string(buf))
+
+}
+
+func TestStorageClassDelete(t *testing.T) {
+	client := NewStorageClassClient("http", "localhost", 8080)
+
+	err := client.Delete(scID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+}
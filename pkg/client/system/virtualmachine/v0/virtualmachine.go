@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type VirtualMachineListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		VirtualMachineList []*system.VirtualMachine `json:"virtualMachines"`
+		Continue           string                   `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces/%s/virtualmachines"
+	clusterPath    = "api/v0/virtualmachines"
 )
 
 func NewVirtualMachineClient(scheme, apiServerAddress string, apiServerPort int32) *VirtualMachineClient {
@@ -51,6 +55,16 @@ func NewVirtualMachineClient(scheme, apiServerAddress string, apiServerPort int3
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *VirtualMachineClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *VirtualMachineClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *VirtualMachineClient) Get(groupID, namespaceID, virtualMachineID string) (*system.VirtualMachine, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, virtualMachineID))
 	if err != nil {
@@ -64,23 +78,117 @@ func (c *VirtualMachineClient) Get(groupID, namespaceID, virtualMachineID string
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(vmRes.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
 	return &vmRes.Data.VirtualMachine, nil
 }
 
+// List は全てのVirtualMachineを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *VirtualMachineClient) List(groupID, namespaceID string) ([]*system.VirtualMachine, error) {
-	res, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのVirtualMachineを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *VirtualMachineClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*system.VirtualMachine, error) {
+	vmList := []*system.VirtualMachine{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(vm *system.VirtualMachine) error {
+		vmList = append(vmList, vm)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := res.Body()
+	return vmList, nil
+}
 
-	vmListRes := VirtualMachineListResponse{}
-	err = json.Unmarshal(body, &vmListRes)
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するVirtualMachineを取得する
+func (c *VirtualMachineClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.VirtualMachine, error) {
+	vmList := []*system.VirtualMachine{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(vm *system.VirtualMachine) error {
+		vmList = append(vmList, vm)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return vmList, nil
+}
 
-	return vmListRes.Data.VirtualMachineList, nil
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するVirtualMachineに対してfを呼び出す
+func (c *VirtualMachineClient) ListEachInCluster(query meta.ListQuery, f func(vm *system.VirtualMachine) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのVirtualMachineに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *VirtualMachineClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(vm *system.VirtualMachine) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *VirtualMachineClient) listEach(path string, query meta.ListQuery, f func(vm *system.VirtualMachine) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		vmList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, vm := range vmList {
+			if err := f(vm); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するVirtualMachineを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *VirtualMachineClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*system.VirtualMachine, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *VirtualMachineClient) listPage(path string, query meta.ListQuery) ([]*system.VirtualMachine, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := VirtualMachineListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.VirtualMachineList, listResp.Data.Continue, nil
 }
 
 func (c *VirtualMachineClient) Create(vm *system.VirtualMachine) (*system.VirtualMachine, error) {
@@ -126,14 +234,62 @@ func (c *VirtualMachineClient) Update(vm *system.VirtualMachine) (*system.Virtua
 		return nil, err
 	}
 
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(vmRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	vm.ResourceVersion = vmRes.Data.VirtualMachine.ResourceVersion
+
+	return &vmRes.Data.VirtualMachine, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *VirtualMachineClient) UpdateStatus(vm *system.VirtualMachine) (*system.VirtualMachine, error) {
+	body, err := json.Marshal(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(vm.Group, vm.Namespace, filepath.Join(vm.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = res.Body()
+
+	vmRes := VirtualMachineResponse{}
+	err = json.Unmarshal(body, &vmRes)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(vmRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	vm.ResourceVersion = vmRes.Data.VirtualMachine.ResourceVersion
+
 	return &vmRes.Data.VirtualMachine, nil
 }
 
 func (c *VirtualMachineClient) Delete(groupID, namespaceID, virtualMachineID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, virtualMachineID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, virtualMachineID))
 	if err != nil {
 		return err
 	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
 	return err
 
 }
@@ -159,3 +315,12 @@ func (c *VirtualMachineClient) getPath(groupID, namespaceID, virtualMachineID st
 			virtualMachineID,
 		))
 }
+
+func (c *VirtualMachineClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
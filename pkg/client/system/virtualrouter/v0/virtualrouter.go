@@ -1,8 +1,10 @@
 package v0
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"path/filepath"
 
 	"github.com/go-resty/resty/v2"
@@ -31,11 +33,13 @@ type VirtualRouterListResponse struct {
 	Error interface{} `json:"error"`
 	Data  struct {
 		VirtualRouterList []*system.VirtualRouter `json:"virtualRouters"`
+		Continue          string                  `json:"continue"`
 	} `json:"data"`
 }
 
 const (
 	basePathFormat = "api/v0/groups/%s/namespaces/%s/virtualrouters"
+	clusterPath    = "api/v0/virtualrouters"
 )
 
 func NewVirtualRouterClient(scheme, apiServerAddress string, apiServerPort int32) *VirtualRouterClient {
@@ -51,6 +55,16 @@ func NewVirtualRouterClient(scheme, apiServerAddress string, apiServerPort int32
 	}
 }
 
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *VirtualRouterClient) SetToken(token string) {
+	c.client.SetAuthToken(token)
+}
+
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *VirtualRouterClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.client.SetTLSClientConfig(tlsConfig)
+}
+
 func (c *VirtualRouterClient) Get(groupID, namespaceID, virtualRouterID string) (*system.VirtualRouter, error) {
 	resp, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, virtualRouterID))
 	if err != nil {
@@ -64,23 +78,117 @@ func (c *VirtualRouterClient) Get(groupID, namespaceID, virtualRouterID string)
 		return nil, err
 	}
 
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, meta.NewNotFoundError(vmRes.Error)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
 	return &vmRes.Data.VirtualRouter, nil
 }
 
+// List は全てのVirtualRouterを取得する
+// 大きなレスポンスにならないようにmeta.DefaultListLimit件ずつ取得する
 func (c *VirtualRouterClient) List(groupID, namespaceID string) ([]*system.VirtualRouter, error) {
-	res, err := c.client.R().SetHeaders(c.headers).Get(c.getPath(groupID, namespaceID, ""))
+	return c.ListWithSelector(groupID, namespaceID, "", "")
+}
+
+// ListWithSelector はlabelSelectorとfieldSelectorに一致する全てのVirtualRouterを取得する
+// 例: labelSelector: env in (prod,stg), fieldSelector: status.state=Running
+func (c *VirtualRouterClient) ListWithSelector(groupID, namespaceID string, labelSelector, fieldSelector string) ([]*system.VirtualRouter, error) {
+	vrList := []*system.VirtualRouter{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEach(groupID, namespaceID, query, func(vr *system.VirtualRouter) error {
+		vrList = append(vrList, vr)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	body := res.Body()
+	return vrList, nil
+}
 
-	vmListRes := VirtualRouterListResponse{}
-	err = json.Unmarshal(body, &vmListRes)
+// ListInCluster は全てのグループ・ネームスペースからlabelSelectorとfieldSelectorに一致するVirtualRouterを取得する
+func (c *VirtualRouterClient) ListInCluster(labelSelector, fieldSelector string) ([]*system.VirtualRouter, error) {
+	vrList := []*system.VirtualRouter{}
+	query := meta.ListQuery{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+	err := c.ListEachInCluster(query, func(vr *system.VirtualRouter) error {
+		vrList = append(vrList, vr)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return vrList, nil
+}
 
-	return vmListRes.Data.VirtualRouterList, nil
+// ListEachInCluster は全てのグループ・ネームスペースからqueryに一致するVirtualRouterに対してfを呼び出す
+func (c *VirtualRouterClient) ListEachInCluster(query meta.ListQuery, f func(vr *system.VirtualRouter) error) error {
+	return c.listEach(c.getClusterPath(), query, f)
+}
+
+// ListEach はquery.Limit件ずつ取得しながらqueryに一致する全てのVirtualRouterに対してfを呼び出す
+// query.Limitが0の場合はmeta.DefaultListLimit件ずつ取得する
+// fがエラーを返した場合はそこで終了してそのエラーを返す
+func (c *VirtualRouterClient) ListEach(groupID, namespaceID string, query meta.ListQuery, f func(vr *system.VirtualRouter) error) error {
+	return c.listEach(c.getPath(groupID, namespaceID, ""), query, f)
+}
+
+func (c *VirtualRouterClient) listEach(path string, query meta.ListQuery, f func(vr *system.VirtualRouter) error) error {
+	if query.Limit == 0 {
+		query.Limit = meta.DefaultListLimit
+	}
+	for {
+		vrList, next, err := c.listPage(path, query)
+		if err != nil {
+			return err
+		}
+		for _, vr := range vrList {
+			if err := f(vr); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		query.Continue = next
+	}
+}
+
+// ListPage はqueryに一致するVirtualRouterを1ページ分と、続きを取得するためのtokenを返す
+// 続きがない場合tokenは空になる
+func (c *VirtualRouterClient) ListPage(groupID, namespaceID string, query meta.ListQuery) ([]*system.VirtualRouter, string, error) {
+	return c.listPage(c.getPath(groupID, namespaceID, ""), query)
+}
+
+func (c *VirtualRouterClient) listPage(path string, query meta.ListQuery) ([]*system.VirtualRouter, string, error) {
+	resp, err := c.client.R().SetHeaders(c.headers).
+		SetQueryParams(query.Params()).
+		Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	body := resp.Body()
+
+	listResp := VirtualRouterListResponse{}
+	err = json.Unmarshal(body, &listResp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%v", listResp.Error)
+	}
+
+	return listResp.Data.VirtualRouterList, listResp.Data.Continue, nil
 }
 
 func (c *VirtualRouterClient) Create(vm *system.VirtualRouter) (*system.VirtualRouter, error) {
@@ -126,14 +234,62 @@ func (c *VirtualRouterClient) Update(vm *system.VirtualRouter) (*system.VirtualR
 		return nil, err
 	}
 
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(vmRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	vm.ResourceVersion = vmRes.Data.VirtualRouter.ResourceVersion
+
+	return &vmRes.Data.VirtualRouter, nil
+}
+
+// UpdateStatus はstatusのみを更新する
+func (c *VirtualRouterClient) UpdateStatus(vm *system.VirtualRouter) (*system.VirtualRouter, error) {
+	body, err := json.Marshal(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.R().SetHeaders(c.headers).SetBody(body).Put(c.getPath(vm.Group, vm.Namespace, filepath.Join(vm.ID, "status")))
+	if err != nil {
+		return nil, err
+	}
+	body = res.Body()
+
+	vmRes := VirtualRouterResponse{}
+	err = json.Unmarshal(body, &vmRes)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode() == http.StatusConflict {
+		return nil, meta.NewConflictError(vmRes.Error)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("%v", vmRes.Error)
+	}
+
+	// 同じオブジェクトで続けて更新できるようにresourceVersionを反映する
+	vm.ResourceVersion = vmRes.Data.VirtualRouter.ResourceVersion
+
 	return &vmRes.Data.VirtualRouter, nil
 }
 
 func (c *VirtualRouterClient) Delete(groupID, namespaceID, virtualRouterID string) error {
-	_, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, virtualRouterID))
+	resp, err := c.client.R().SetHeaders(c.headers).Delete(c.getPath(groupID, namespaceID, virtualRouterID))
 	if err != nil {
 		return err
 	}
+
+	if resp.IsError() {
+		return fmt.Errorf("Error: failed to delete: %s", resp.Status())
+	}
 	return err
 
 }
@@ -159,3 +315,12 @@ func (c *VirtualRouterClient) getPath(groupID, namespaceID, virtualRouterID stri
 			virtualRouterID,
 		))
 }
+
+func (c *VirtualRouterClient) getClusterPath() string {
+	return fmt.Sprintf("%s://%s",
+		c.scheme,
+		filepath.Join(
+			fmt.Sprintf("%s:%d", c.apiServerAddress, c.apiServerPort),
+			clusterPath,
+		))
+}
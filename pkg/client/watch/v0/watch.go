@@ -1,14 +1,22 @@
 package v0
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/ophum/humstack/pkg/store/leveldb"
 	"github.com/r3labs/sse"
+	backoff "gopkg.in/cenkalti/backoff.v1"
 )
 
 type WatchClient struct {
@@ -17,12 +25,33 @@ type WatchClient struct {
 	apiServerPort    int32
 	client           *resty.Client
 	headers          map[string]string
+	token            string
+	tlsConfig        *tls.Config
 }
 
 const (
 	basePathFormat = "api/v0/watches%s"
 )
 
+// WatchOptions はWatchで受け取るイベントの条件
+// 空の項目は絞り込みに使われない
+type WatchOptions struct {
+	APIType       string
+	Group         string
+	Namespace     string
+	ID            string
+	LabelSelector string
+
+	// SinceRevision が0より大きい場合はそのrevisionより後のイベントから受け取る
+	SinceRevision int64
+	// Resume がtrueの場合はSinceRevisionが0でも履歴から再開する
+	Resume bool
+}
+
+// ErrResyncRequired は再開しようとしたrevisionのイベントがapiserverに残っていない場合に返る
+// 受け取った場合はListし直してから再度Watchする必要がある
+var ErrResyncRequired = errors.New("resync required")
+
 func NewWatchClient(scheme, apiServerAddress string, apiServerPort int32) *WatchClient {
 	return &WatchClient{
 		scheme:           scheme,
@@ -36,24 +65,110 @@ func NewWatchClient(scheme, apiServerAddress string, apiServerPort int32) *Watch
 	}
 }
 
-func (c *WatchClient) Watch(apiType string, f func(before interface{}, after interface{})) error {
-	log.Println("start")
-	client := sse.NewClient(c.getPath(apiType))
+// SetToken はリクエストに付けるBearerトークンを設定する
+func (c *WatchClient) SetToken(token string) {
+	c.token = token
+	c.client.SetAuthToken(token)
+}
 
-	client.Subscribe("", func(msg *sse.Event) {
-		var noticeData leveldb.NoticeData
-		json.Unmarshal(msg.Data, &noticeData)
+// SetTLSConfig はHTTPSでアクセスする場合のTLSの設定をする
+func (c *WatchClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+	c.client.SetTLSClientConfig(tlsConfig)
+}
 
+// Watch はoptionsの条件に合うイベントを受け取るたびにfを呼び出す
+// 切断された場合は最後に受け取ったrevisionから自動で再開する
+func (c *WatchClient) Watch(options WatchOptions, f func(before interface{}, after interface{})) error {
+	return c.WatchNotice(context.Background(), options, func(noticeData *leveldb.NoticeData) {
 		f(noticeData.Before, noticeData.After)
 	})
+}
+
+// WatchNotice はWatchと同様にイベントを受け取り、revisionなどを含むNoticeDataのままfに渡す
+// ctxがキャンセルされた場合はctx.Err()を返して終了する
+func (c *WatchClient) WatchNotice(ctx context.Context, options WatchOptions, f func(noticeData *leveldb.NoticeData)) error {
+	log.Println("start")
+	client := sse.NewClient(c.getPath(options))
+	if c.tlsConfig != nil {
+		client.Connection = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: c.tlsConfig,
+			},
+		}
+	}
+	if c.token != "" {
+		client.Headers["Authorization"] = "Bearer " + c.token
+	}
+	if options.SinceRevision > 0 || options.Resume {
+		client.EventID = strconv.FormatInt(options.SinceRevision, 10)
+	}
+
+	reconnectStrategy := backoff.NewExponentialBackOff()
+	// 再接続を諦めない
+	reconnectStrategy.MaxElapsedTime = 0
+	client.ReconnectStrategy = backoff.WithContext(reconnectStrategy, ctx)
+	client.ResponseValidator = func(client *sse.Client, resp *http.Response) error {
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusGone:
+			return backoff.Permanent(ErrResyncRequired)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			// 再接続しても成功しない
+			return backoff.Permanent(fmt.Errorf("could not connect to stream: %s", resp.Status))
+		}
+		return fmt.Errorf("could not connect to stream: %s", resp.Status)
+	}
 
-	return nil
+	for {
+		err := client.SubscribeWithContext(ctx, "", func(msg *sse.Event) {
+			var noticeData leveldb.NoticeData
+			if err := json.Unmarshal(msg.Data, &noticeData); err != nil {
+				log.Println("watch client:", "Failed to unmarshal notice data", err.Error())
+				return
+			}
+
+			f(&noticeData)
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		// apiserver側から切断された場合はLast-Event-IDを付けて再接続する
+		log.Println("reconnect from revision", client.EventID)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
 }
 
-func (c *WatchClient) getPath(apiType string) string {
+func (c *WatchClient) getPath(options WatchOptions) string {
+	values := url.Values{}
+	if options.APIType != "" {
+		values.Set("apiType", options.APIType)
+	}
+	if options.Group != "" {
+		values.Set("group", options.Group)
+	}
+	if options.Namespace != "" {
+		values.Set("namespace", options.Namespace)
+	}
+	if options.ID != "" {
+		values.Set("id", options.ID)
+	}
+	if options.LabelSelector != "" {
+		values.Set("labelSelector", options.LabelSelector)
+	}
+
 	query := ""
-	if apiType != "" {
-		query = "?apiType=" + apiType
+	if len(values) > 0 {
+		query = "?" + values.Encode()
 	}
 	return fmt.Sprintf("%s://%s",
 		c.scheme,
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	adminCmd.AddCommand(adminBackupCmd)
+}
+
+var adminBackupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "backup all data of apiserver. print to stdout if file is not specified",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clients := newClients()
+
+		archive, err := clients.AdminV0().Backup()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		archiveJSON, err := json.MarshalIndent(archive, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if len(args) == 0 {
+			fmt.Println(string(archiveJSON))
+			return
+		}
+
+		if err := ioutil.WriteFile(args[0], archiveJSON, 0600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("backup %d keys at revision %d to %s\n", len(archive.Snapshot.Entries), archive.Snapshot.Revision, args[0])
+	},
+}
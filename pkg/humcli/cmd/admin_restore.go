@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/ophum/humstack/pkg/api/admin"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	adminCmd.AddCommand(adminRestoreCmd)
+}
+
+var adminRestoreCmd = &cobra.Command{
+	Use:   "restore file",
+	Short: "restore backup file to a newly started apiserver. users and tokens are also replaced, so login again after restore",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clients := newClients()
+
+		archiveJSON, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		archive := admin.Archive{}
+		if err := json.Unmarshal(archiveJSON, &archive); err != nil {
+			log.Fatal(err)
+		}
+
+		n, revision, err := clients.AdminV0().Restore(&archive)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("restore %d keys at revision %d\n", n, revision)
+	},
+}
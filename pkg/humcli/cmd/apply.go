@@ -19,7 +19,7 @@ func init() {
 var applyCmd = &cobra.Command{
 	Use: "apply",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
+		clients := newClients()
 		applyFuncMap := map[meta.APIType]func(d *yaml.Decoder, clients *client.Clients, debug bool) error{
 			meta.APITypeGroupV0:          apply.ApplyGroup,
 			meta.APITypeNamespaceV0:      apply.ApplyNamespace,
@@ -32,6 +32,9 @@ var applyCmd = &cobra.Command{
 			meta.APITypeVirtualMachineV0: apply.ApplyVirtualMachine,
 			meta.APITypeVirtualRouterV0:  apply.ApplyVirtualRouter,
 			meta.APITypeNodeNetworkV0:    apply.ApplyNodeNetwork,
+			meta.APITypeRoleBindingV0:    apply.ApplyRoleBinding,
+			meta.APITypeStorageClassV0:   apply.ApplyStorageClass,
+			meta.APITypeResourceQuotaV0:  apply.ApplyResourceQuota,
 		}
 
 		for _, file := range args {
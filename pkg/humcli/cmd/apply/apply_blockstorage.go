@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
@@ -14,12 +16,13 @@ func ApplyBlockStorage(d *yaml.Decoder, clients *client.Clients, debug bool) err
 		return err
 	}
 
-	old, err := clients.SystemV0().BlockStorage().Get(bs.Group, bs.Namespace, bs.ID)
-	if err != nil {
+	_, err := clients.SystemV0().BlockStorage().Get(bs.Group, bs.Namespace, bs.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		bs, err = clients.SystemV0().BlockStorage().Create(bs)
 		if err != nil {
 			return err
@@ -1,9 +1,11 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
 )
@@ -14,12 +16,13 @@ func ApplyExternalIPPool(d *yaml.Decoder, clients *client.Clients, debug bool) e
 		return err
 	}
 
-	old, err := clients.CoreV0().ExternalIPPool().Get(eippool.ID)
-	if err != nil {
+	_, err := clients.CoreV0().ExternalIPPool().Get(eippool.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		eippool, err = clients.CoreV0().ExternalIPPool().Create(eippool)
 		if err != nil {
 			return err
@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
@@ -15,11 +16,13 @@ func ApplyGroup(d *yaml.Decoder, clients *client.Clients, debug bool) error {
 		log.Fatal(errors.Wrap(err, "decode").Error())
 	}
 
-	old, err := clients.CoreV0().Group().Get(gr.ID)
-	if err != nil {
+	_, err := clients.CoreV0().Group().Get(gr.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
-	if old.ID == "" {
+
+	if notFound {
 		gr, err = clients.CoreV0().Group().Create(gr)
 		if err != nil {
 			return err
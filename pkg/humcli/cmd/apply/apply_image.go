@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
@@ -14,12 +16,13 @@ func ApplyImage(d *yaml.Decoder, clients *client.Clients, debug bool) error {
 		return err
 	}
 
-	old, err := clients.SystemV0().Image().Get(image.Group, image.ID)
-	if err != nil {
+	_, err := clients.SystemV0().Image().Get(image.Group, image.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		image, err = clients.SystemV0().Image().Create(image)
 		if err != nil {
 			return err
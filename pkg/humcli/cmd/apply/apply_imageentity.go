@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
@@ -14,12 +16,13 @@ func ApplyImageEntity(d *yaml.Decoder, clients *client.Clients, debug bool) erro
 		return err
 	}
 
-	old, err := clients.SystemV0().ImageEntity().Get(imageEntity.Group, imageEntity.ID)
-	if err != nil {
+	_, err := clients.SystemV0().ImageEntity().Get(imageEntity.Group, imageEntity.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		imageEntity, err = clients.SystemV0().ImageEntity().Create(imageEntity)
 		if err != nil {
 			return err
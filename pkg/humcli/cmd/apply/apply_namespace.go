@@ -1,9 +1,11 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
 )
@@ -14,12 +16,13 @@ func ApplyNamespace(d *yaml.Decoder, clients *client.Clients, debug bool) error
 		return err
 	}
 
-	old, err := clients.CoreV0().Namespace().Get(ns.Group, ns.ID)
-	if err != nil {
+	_, err := clients.CoreV0().Namespace().Get(ns.Group, ns.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		ns, err = clients.CoreV0().Namespace().Create(ns)
 		if err != nil {
 			return err
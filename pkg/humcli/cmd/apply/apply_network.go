@@ -1,9 +1,11 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
 	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
 )
@@ -14,12 +16,13 @@ func ApplyNetwork(d *yaml.Decoder, clients *client.Clients, debug bool) error {
 		return err
 	}
 
-	old, err := clients.CoreV0().Network().Get(net.Group, net.Namespace, net.ID)
-	if err != nil {
+	_, err := clients.CoreV0().Network().Get(net.Group, net.Namespace, net.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		net, err = clients.CoreV0().Network().Create(net)
 		if err != nil {
 			return err
@@ -0,0 +1,43 @@
+package apply
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+func ApplyResourceQuota(d *yaml.Decoder, clients *client.Clients, debug bool) error {
+	rq := &core.ResourceQuota{}
+	if err := d.Decode(rq); err != nil {
+		return err
+	}
+
+	_, err := clients.CoreV0().ResourceQuota().Get(rq.Group, rq.Namespace, rq.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
+		return err
+	}
+
+	if notFound {
+		rq, err = clients.CoreV0().ResourceQuota().Create(rq)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s/corev0/resourcequota/%s created\n", rq.Group, rq.ID)
+	} else {
+		rq, err = clients.CoreV0().ResourceQuota().Update(rq)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s/corev0/resourcequota/%s updated\n", rq.Group, rq.ID)
+	}
+
+	if debug {
+		printYAML(rq)
+	}
+	return nil
+}
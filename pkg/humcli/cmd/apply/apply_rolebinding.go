@@ -0,0 +1,43 @@
+package apply
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+func ApplyRoleBinding(d *yaml.Decoder, clients *client.Clients, debug bool) error {
+	rb := &core.RoleBinding{}
+	if err := d.Decode(rb); err != nil {
+		return err
+	}
+
+	_, err := clients.CoreV0().RoleBinding().Get(rb.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
+		return err
+	}
+
+	if notFound {
+		rb, err = clients.CoreV0().RoleBinding().Create(rb)
+		if err != nil {
+			return err
+		}
+		log.Printf("corev0/rolebinding/%s created\n", rb.ID)
+	} else {
+		rb, err = clients.CoreV0().RoleBinding().Update(rb)
+		if err != nil {
+			return err
+		}
+		log.Printf("corev0/rolebinding/%s updated\n", rb.ID)
+	}
+
+	if debug {
+		printYAML(rb)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package apply
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+func ApplyStorageClass(d *yaml.Decoder, clients *client.Clients, debug bool) error {
+	sc := &system.StorageClass{}
+	if err := d.Decode(sc); err != nil {
+		return err
+	}
+
+	_, err := clients.SystemV0().StorageClass().Get(sc.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
+		return err
+	}
+
+	if notFound {
+		sc, err = clients.SystemV0().StorageClass().Create(sc)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s/systemv0/storageclass/%s created\n", sc.Group, sc.ID)
+	} else {
+		sc, err = clients.SystemV0().StorageClass().Update(sc)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s/systemv0/storageclass/%s updated\n", sc.Group, sc.ID)
+	}
+
+	if debug {
+		printYAML(sc)
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
@@ -14,12 +16,13 @@ func ApplyVirtualMachine(d *yaml.Decoder, clients *client.Clients, debug bool) e
 		return err
 	}
 
-	old, err := clients.SystemV0().VirtualMachine().Get(vm.Group, vm.Namespace, vm.ID)
-	if err != nil {
+	_, err := clients.SystemV0().VirtualMachine().Get(vm.Group, vm.Namespace, vm.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		vm, err = clients.SystemV0().VirtualMachine().Create(vm)
 		if err != nil {
 			return err
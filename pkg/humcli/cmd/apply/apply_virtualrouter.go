@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"log"
 
+	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
 	"github.com/ophum/humstack/pkg/client"
 	"gopkg.in/yaml.v2"
@@ -14,12 +16,13 @@ func ApplyVirtualRouter(d *yaml.Decoder, clients *client.Clients, debug bool) er
 		return err
 	}
 
-	old, err := clients.SystemV0().VirtualRouter().Get(vr.Group, vr.Namespace, vr.ID)
-	if err != nil {
+	_, err := clients.SystemV0().VirtualRouter().Get(vr.Group, vr.Namespace, vr.ID)
+	notFound := errors.Is(err, meta.ErrNotFound)
+	if err != nil && !notFound {
 		return err
 	}
 
-	if old.ID == "" {
+	if notFound {
 		vr, err = clients.SystemV0().VirtualRouter().Create(vr)
 		if err != nil {
 			return err
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ophum/humstack/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// Credential はloginで発行されたトークン
+type Credential struct {
+	UserID    string    `yaml:"userID"`
+	Token     string    `yaml:"token"`
+	ExpiresAt time.Time `yaml:"expiresAt"`
+}
+
+// Credentials はapiserverのaddress:portごとのCredential
+type Credentials map[string]Credential
+
+func credentialsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".humcli", "credentials.yaml"), nil
+}
+
+func credentialKey() string {
+	return fmt.Sprintf("%s:%d", apiServerAddress, apiServerPort)
+}
+
+func loadCredentials() (Credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := Credentials{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return creds, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func saveCredentials(creds Credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// トークンを含むので所有者のみ読み書きできるようにする
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// newClients は--tokenまたは保存されているトークンを設定したクライアントを作成する
+func newClients() *client.Clients {
+	clients := newClientsWithoutToken()
+	if token != "" {
+		clients.SetToken(token)
+		return clients
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return clients
+	}
+	if cred, ok := creds[credentialKey()]; ok && time.Now().Before(cred.ExpiresAt) {
+		clients.SetToken(cred.Token)
+	}
+	return clients
+}
+
+// newClientsWithoutToken はトークンを設定していないクライアントを作成する
+func newClientsWithoutToken() *client.Clients {
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return client.NewClients(apiServerAddress, apiServerPort, tlsConfig)
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 
 	"github.com/ophum/humstack/pkg/api/meta"
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
@@ -19,7 +18,7 @@ func init() {
 var deleteCmd = &cobra.Command{
 	Use: "delete",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
+		clients := newClients()
 		for _, file := range args {
 			f, err := os.Open(file)
 			if err != nil {
@@ -54,6 +53,16 @@ var deleteCmd = &cobra.Command{
 					if err != nil {
 						log.Fatal(err)
 					}
+				case meta.APITypeRoleBindingV0:
+					err = clients.CoreV0().RoleBinding().Delete(item.Meta.ID)
+					if err != nil {
+						log.Fatal(err)
+					}
+				case meta.APITypeResourceQuotaV0:
+					err = clients.CoreV0().ResourceQuota().Delete(item.Meta.Group, item.Meta.Namespace, item.Meta.ID)
+					if err != nil {
+						log.Fatal(err)
+					}
 				case meta.APITypeNetworkV0:
 					err = clients.CoreV0().Network().DeleteState(item.Meta.Group, item.Meta.Namespace, item.Meta.ID)
 					if err != nil {
@@ -89,6 +98,11 @@ var deleteCmd = &cobra.Command{
 					if err != nil {
 						log.Fatal(err)
 					}
+				case meta.APITypeStorageClassV0:
+					err = clients.SystemV0().StorageClass().Delete(item.Meta.ID)
+					if err != nil {
+						log.Fatal(err)
+					}
 				}
 			}
 		}
@@ -4,8 +4,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	labelSelector string
+	fieldSelector string
+)
+
 func init() {
 	rootCmd.AddCommand(getCmd)
+
+	getCmd.PersistentFlags().StringVarP(&labelSelector, "selector", "l", "", "label selector (e.g. env=prod,tier in (web,db))")
+	getCmd.PersistentFlags().StringVar(&fieldSelector, "field-selector", "", "field selector (e.g. status.state=Running)")
 }
 
 var getCmd = &cobra.Command{
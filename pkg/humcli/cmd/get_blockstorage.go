@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -24,8 +23,8 @@ var getBlockStorageCmd = &cobra.Command{
 		"bs",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		bsList, err := clients.SystemV0().BlockStorage().List(group, namespace)
+		clients := newClients()
+		bsList, err := clients.SystemV0().BlockStorage().ListWithSelector(group, namespace, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -52,6 +51,7 @@ var getBlockStorageCmd = &cobra.Command{
 				"LimitSize",
 				"NodeName",
 				"Type",
+				"StorageClass",
 				"FromType",
 				"Status",
 			})
@@ -67,6 +67,7 @@ var getBlockStorageCmd = &cobra.Command{
 					bs.Spec.LimitSize,
 					bs.Annotations[agentbsv0.BlockStorageV0AnnotationNodeName],
 					bs.Annotations[agentbsv0.BlockStorageV0AnnotationType],
+					bs.Spec.StorageClassName,
 					string(bs.Spec.From.Type),
 					state,
 				})
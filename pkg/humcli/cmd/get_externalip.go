@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getExternalIPCmd = &cobra.Command{
 		"eip",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		eipList, err := clients.CoreV0().ExternalIP().List()
+		clients := newClients()
+		eipList, err := clients.CoreV0().ExternalIP().ListWithSelector(labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
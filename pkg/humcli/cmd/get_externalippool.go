@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getExternalIPPoolCmd = &cobra.Command{
 		"eippool",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		eippoolList, err := clients.CoreV0().ExternalIPPool().List()
+		clients := newClients()
+		eippoolList, err := clients.CoreV0().ExternalIPPool().ListWithSelector(labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
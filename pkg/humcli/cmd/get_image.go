@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -21,8 +20,8 @@ var getImageCmd = &cobra.Command{
 	Use:     "image",
 	Aliases: []string{},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		imList, err := clients.SystemV0().Image().List(group)
+		clients := newClients()
+		imList, err := clients.SystemV0().Image().ListWithSelector(group, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
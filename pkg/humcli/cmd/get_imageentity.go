@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getImageEntityCmd = &cobra.Command{
 		"ie",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		ieList, err := clients.SystemV0().ImageEntity().List(group)
+		clients := newClients()
+		ieList, err := clients.SystemV0().ImageEntity().ListWithSelector(group, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getNamespaceCmd = &cobra.Command{
 		"ns",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		nsList, err := clients.CoreV0().Namespace().List(group)
+		clients := newClients()
+		nsList, err := clients.CoreV0().Namespace().ListWithSelector(group, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
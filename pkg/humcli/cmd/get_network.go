@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getNetworkCmd = &cobra.Command{
 		"net",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		netList, err := clients.CoreV0().Network().List(group, namespace)
+		clients := newClients()
+		netList, err := clients.CoreV0().Network().ListWithSelector(group, namespace, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -20,8 +19,8 @@ func init() {
 var getNodeCmd = &cobra.Command{
 	Use: "node",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		nodeList, err := clients.SystemV0().Node().List()
+		clients := newClients()
+		nodeList, err := clients.SystemV0().Node().ListWithSelector(labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -43,14 +42,29 @@ var getNodeCmd = &cobra.Command{
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetHeader([]string{
 				"Name",
-				"LimitVcpus",
-				"LimitMemory",
+				"Vcpus",
+				"Memory",
+				"Disk",
+				"FreeDisk",
 			})
+			// requested/allocatable で表示する
+			// allocatableを報告していないagentの場合はspecのlimitを表示する
+			usage := func(requested, allocatable, limit string) string {
+				if allocatable == "" {
+					allocatable = limit
+				}
+				if allocatable == "" {
+					allocatable = "-"
+				}
+				return fmt.Sprintf("%s/%s", requested, allocatable)
+			}
 			for _, n := range nodeList {
 				table.Append([]string{
 					n.Name,
-					n.Spec.LimitVcpus,
-					n.Spec.LimitMemory,
+					usage(n.Status.RequestedVcpus, n.Status.Allocatable.Vcpus, n.Spec.LimitVcpus),
+					usage(n.Status.RequestedMemory, n.Status.Allocatable.Memory, n.Spec.LimitMemory),
+					usage(n.Status.RequestedDisk, n.Status.Allocatable.Disk, n.Spec.LimitDisk),
+					n.Status.FreeDisk,
 				})
 			}
 
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -23,8 +22,8 @@ var getNodeNetworkCmd = &cobra.Command{
 		"nodenet",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		netList, err := clients.SystemV0().NodeNetwork().List(group, namespace)
+		clients := newClients()
+		netList, err := clients.SystemV0().NodeNetwork().ListWithSelector(group, namespace, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ophum/humstack/pkg/api/core"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func init() {
+	getCmd.AddCommand(getResourceQuotaCmd)
+}
+
+var getResourceQuotaCmd = &cobra.Command{
+	Use: "resourcequota",
+	Aliases: []string{
+		"quota",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		clients := newClients()
+		// groupが明示的に指定された場合のみ絞り込み、それ以外は全てのResourceQuotaを取得する
+		var rqList []*core.ResourceQuota
+		var err error
+		if cmd.Flags().Changed("group") {
+			ns := ""
+			if cmd.Flags().Changed("namespace") {
+				ns = namespace
+			}
+			rqList, err = clients.CoreV0().ResourceQuota().ListWithSelector(group, ns, labelSelector, fieldSelector)
+		} else {
+			rqList, err = clients.CoreV0().ResourceQuota().ListInCluster(labelSelector, fieldSelector)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch output {
+		case "json":
+			out, err := json.MarshalIndent(rqList, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		case "yaml":
+			out, err := yaml.Marshal(rqList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{
+				"Name",
+				"Group",
+				"Namespace",
+				"Vcpus",
+				"Memory",
+				"Size",
+				"VMs",
+				"BSs",
+			})
+			// 使用量/制限で表示する
+			usage := func(used, hard string) string {
+				if hard == "" {
+					hard = "-"
+				}
+				return fmt.Sprintf("%s/%s", used, hard)
+			}
+			for _, rq := range rqList {
+				used, hard := rq.Status.Used, rq.Spec.Hard
+				table.Append([]string{
+					rq.Name,
+					rq.Group,
+					rq.Namespace,
+					usage(used.RequestVcpus, hard.RequestVcpus),
+					usage(used.RequestMemory, hard.RequestMemory),
+					usage(used.RequestSize, hard.RequestSize),
+					usage(used.VirtualMachines, hard.VirtualMachines),
+					usage(used.BlockStorages, hard.BlockStorages),
+				})
+			}
+
+			table.Render()
+		}
+	},
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func init() {
+	getCmd.AddCommand(getStorageClassCmd)
+}
+
+var getStorageClassCmd = &cobra.Command{
+	Use: "storageclass",
+	Aliases: []string{
+		"sc",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		clients := newClients()
+		scList, err := clients.SystemV0().StorageClass().ListWithSelector(labelSelector, fieldSelector)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch output {
+		case "json":
+			out, err := json.MarshalIndent(scList, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		case "yaml":
+			out, err := yaml.Marshal(scList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(out))
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{
+				"Name",
+				"Driver",
+				"Provisioning",
+			})
+			for _, sc := range scList {
+				table.Append([]string{
+					sc.Name,
+					string(sc.Spec.Driver),
+					string(sc.Spec.Provisioning),
+				})
+			}
+
+			table.Render()
+		}
+	},
+}
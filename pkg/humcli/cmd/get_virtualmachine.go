@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -25,8 +24,8 @@ var getVirtualMachineCmd = &cobra.Command{
 		"vmachine",
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		vmList, err := clients.SystemV0().VirtualMachine().List(group, namespace)
+		clients := newClients()
+		vmList, err := clients.SystemV0().VirtualMachine().ListWithSelector(group, namespace, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
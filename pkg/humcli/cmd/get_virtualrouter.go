@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 
@@ -27,8 +26,8 @@ var getVirtualRouterCmd = &cobra.Command{
 	},
 
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
-		vrList, err := clients.SystemV0().VirtualRouter().List(group, namespace)
+		clients := newClients()
+		vrList, err := clients.SystemV0().VirtualRouter().ListWithSelector(group, namespace, labelSelector, fieldSelector)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	loginUserID   string
+	loginPassword string
+)
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+
+	loginCmd.Flags().StringVarP(&loginUserID, "user", "u", "", "user id")
+	loginCmd.Flags().StringVarP(&loginPassword, "password", "p", "", "password. read from stdin if not specified")
+	loginCmd.MarkFlagRequired("user")
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "login to apiserver and save token",
+	Run: func(cmd *cobra.Command, args []string) {
+		password := loginPassword
+		if password == "" {
+			fmt.Fprint(os.Stderr, "Password: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				log.Fatal(err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+
+		clients := newClientsWithoutToken()
+		token, expiresAt, err := clients.AuthV0().Login(loginUserID, password)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		creds, err := loadCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+		creds[credentialKey()] = Credential{
+			UserID:    loginUserID,
+			Token:     token,
+			ExpiresAt: expiresAt,
+		}
+		if err := saveCredentials(creds); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("logged in to %s as %s (expires at %s)\n", credentialKey(), loginUserID, expiresAt.Local())
+	},
+}
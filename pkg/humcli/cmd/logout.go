@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "revoke token and remove it from saved credentials",
+	Run: func(cmd *cobra.Command, args []string) {
+		creds, err := loadCredentials()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cred, ok := creds[credentialKey()]
+		if !ok {
+			fmt.Printf("not logged in to %s\n", credentialKey())
+			return
+		}
+
+		// 期限切れなどでトークンを無効にできなくても保存されたトークンは削除する
+		clients := newClients()
+		if err := clients.AuthV0().Logout(); err != nil {
+			log.Println(err)
+		}
+
+		delete(creds, credentialKey())
+		if err := saveCredentials(creds); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("logged out %s from %s\n", cred.UserID, credentialKey())
+	},
+}
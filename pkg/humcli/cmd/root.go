@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"fmt"
 
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -19,6 +21,11 @@ var (
 	namespace        string
 	debug            bool
 	output           string
+	token            string
+	useTLS           bool
+	tlsCAFile        string
+	tlsCertFile      string
+	tlsKeyFile       string
 )
 
 func Execute() error {
@@ -35,9 +42,22 @@ func init() {
 	rootCmd.PersistentFlags().Int32Var(&apiServerPort, "api-server-port", 8080, "apiserver Port")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug mode")
 	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "output format, `table` or `json` or `yaml`")
+	rootCmd.PersistentFlags().StringVar(&token, "token", "", "bearer token. use the token saved by login if not specified")
+	rootCmd.PersistentFlags().BoolVar(&useTLS, "tls", false, "use https to access apiserver")
+	rootCmd.PersistentFlags().StringVar(&tlsCAFile, "tls-ca-file", "", "CA certificate file to verify apiserver. implies --tls")
+	rootCmd.PersistentFlags().StringVar(&tlsCertFile, "tls-cert-file", "", "client certificate file")
+	rootCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls-key-file", "", "client key file")
 
 }
 
+// newTLSConfig は--tlsまたは--tls-ca-fileが指定された場合にTLSの設定を作成する
+func newTLSConfig() (*tls.Config, error) {
+	if !useTLS && tlsCAFile == "" {
+		return nil, nil
+	}
+	return tlsutil.NewClientTLSConfig(tlsCAFile, tlsCertFile, tlsKeyFile)
+}
+
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -9,7 +9,6 @@ import (
 	"github.com/ophum/humstack/pkg/api/core"
 	"github.com/ophum/humstack/pkg/api/meta"
 	"github.com/ophum/humstack/pkg/api/system"
-	"github.com/ophum/humstack/pkg/client"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
@@ -22,7 +21,7 @@ func init() {
 var updateCmd = &cobra.Command{
 	Use: "update",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients(apiServerAddress, apiServerPort)
+		clients := newClients()
 		for _, file := range args {
 			f, err := os.Open(file)
 			if err != nil {
@@ -186,6 +185,30 @@ var updateCmd = &cobra.Command{
 					}
 
 					printYAML(imageEntity)
+				case meta.APITypeStorageClassV0:
+					sc := &system.StorageClass{}
+					if err := d.Decode(sc); err != nil {
+						log.Fatal(err)
+					}
+
+					sc, err = clients.SystemV0().StorageClass().Update(sc)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					printYAML(sc)
+				case meta.APITypeResourceQuotaV0:
+					rq := &core.ResourceQuota{}
+					if err := d.Decode(rq); err != nil {
+						log.Fatal(err)
+					}
+
+					rq, err = clients.CoreV0().ResourceQuota().Update(rq)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					printYAML(rq)
 				}
 				r.Close()
 			}
@@ -3,28 +3,52 @@ package cmd
 import (
 	"log"
 
-	"github.com/ophum/humstack/pkg/client"
+	watchv0 "github.com/ophum/humstack/pkg/client/watch/v0"
 	"github.com/spf13/cobra"
 )
 
+var (
+	watchID            string
+	watchLabelSelector string
+	watchSinceRevision int64
+)
+
 func init() {
 	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringVar(&watchID, "id", "", "resource id")
+	watchCmd.Flags().StringVarP(&watchLabelSelector, "selector", "l", "", "label selector, e.g. `env=prod,tier!=db`")
+	watchCmd.Flags().Int64Var(&watchSinceRevision, "since-revision", 0, "receive events after this revision")
 }
 
 var watchCmd = &cobra.Command{
-	Use: "watch",
+	Use: "watch [apiType]",
 	Run: func(cmd *cobra.Command, args []string) {
-		clients := client.NewClients("localhost", 8080)
+		clients := newClients()
 
-		apiType := ""
+		options := watchv0.WatchOptions{
+			ID:            watchID,
+			LabelSelector: watchLabelSelector,
+			SinceRevision: watchSinceRevision,
+		}
 		if len(args) > 0 {
-			apiType = args[0]
+			options.APIType = args[0]
+		}
+		// group, namespaceは明示的に指定された場合のみ絞り込む
+		if cmd.Flags().Changed("group") {
+			options.Group = group
 		}
-		clients.WatchV0().Watch(apiType, func(before, after interface{}) {
+		if cmd.Flags().Changed("namespace") {
+			options.Namespace = namespace
+		}
+
+		err := clients.WatchV0().Watch(options, func(before, after interface{}) {
 			log.Println("WATCH")
 			log.Printf("BEFORE: %+v", before)
 			log.Printf("AFTER: %+v", after)
 		})
-
+		if err != nil {
+			log.Fatal(err)
+		}
 	},
 }
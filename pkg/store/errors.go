@@ -0,0 +1,20 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// storeが返すエラー
+// 呼び出し側はerrors.Isで判定する
+var (
+	// ErrNotFound はkeyが存在しない場合に返る
+	ErrNotFound = errors.New("Not Found")
+	// ErrConflict は書き込みの条件が成り立たなかった場合に返る
+	ErrConflict = errors.New("Conflict")
+	// ErrUnavailable はstoreが閉じられているなどで読み書きできない場合に返る
+	ErrUnavailable = errors.New("Unavailable")
+)
+
+// ErrCompareFailed はTxnの条件が成り立たずにcommitされなかった場合に返る
+var ErrCompareFailed = fmt.Errorf("%w: compare failed", ErrConflict)
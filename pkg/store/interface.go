@@ -2,9 +2,16 @@ package store
 
 type Store interface {
 	List(prefix string, f func(n int) []interface{}) error
+	// ListPage はprefixに一致するデータをstartKeyから最大limit件取得し、続きの先頭のkeyを返す
+	// limitが0以下の場合は全て取得する。続きがない場合は空を返す
+	ListPage(prefix, startKey string, limit int64, f func(n int) []interface{}) (string, error)
 	Get(key string, v interface{}) error
-	Put(key string, data interface{})
-	Delete(key string)
+	Put(key string, data interface{}) error
+	Delete(key string) error
+	// Txn はcomparesが全て成り立つ場合のみopsをまとめて反映する
+	// 成り立たない場合は何も反映せずにErrCompareFailedを返す
+	// opsは順番に1つずつrevisionを進める
+	Txn(compares []Compare, ops []Op) error
 	Lock(key string)
 	Unlock(key string)
 }
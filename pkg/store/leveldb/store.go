@@ -2,31 +2,46 @@ package leveldb
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
 	"github.com/syndtr/goleveldb/leveldb"
 	leveldbErrors "github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 type NoticeData struct {
-	Key     string       `json:"key"`
-	APIType meta.APIType `json:"apiType"`
-	Before  string       `json:"before"`
-	After   string       `json:"after"`
+	Key      string       `json:"key"`
+	APIType  meta.APIType `json:"apiType"`
+	Revision int64        `json:"revision"`
+	Before   string       `json:"before"`
+	After    string       `json:"after"`
+
+	// Reset はstoreの内容がRestoreで置き換えられたことを表す
+	// Revisionは置き換えた後のrevisionになる
+	Reset bool `json:"reset,omitempty"`
 }
 
+const (
+	// revisionKey はstoreのrevisionを永続化するためのkey
+	revisionKey = "_system/revision"
+)
+
 type LevelDBStore struct {
 	db              *leveldb.DB
 	lockTableLocker *sync.RWMutex
 	lockTable       map[string]*sync.RWMutex
 	notifier        chan string
 	isDebug         bool
+
+	// writeLocker はrevisionの採番と通知の順序を揃えるために書き込みを直列化する
+	writeLocker *sync.Mutex
+	revision    int64
 }
 
 func NewLevelDBStore(dirPath string, notifier chan string, isDebug bool) (*LevelDBStore, error) {
@@ -35,24 +50,69 @@ func NewLevelDBStore(dirPath string, notifier chan string, isDebug bool) (*Level
 		return nil, err
 	}
 
+	revision, err := loadRevision(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return &LevelDBStore{
 		db:              db,
 		lockTableLocker: &sync.RWMutex{},
 		lockTable:       map[string]*sync.RWMutex{},
 		notifier:        notifier,
 		isDebug:         isDebug,
+		writeLocker:     &sync.Mutex{},
+		revision:        revision,
 	}, nil
 }
 
+func loadRevision(db *leveldb.DB) (int64, error) {
+	revisionBytes, err := db.Get([]byte(revisionKey), nil)
+	if err != nil {
+		if err == leveldbErrors.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(revisionBytes), 10, 64)
+}
+
+// Revision は最後に書き込まれたrevisionを返す
+func (s *LevelDBStore) Revision() int64 {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
+	return s.revision
+}
+
 func (s *LevelDBStore) Close() error {
 	return s.db.Close()
 }
 
 func (s *LevelDBStore) List(prefix string, f func(n int) []interface{}) error {
+	_, err := s.ListPage(prefix, "", 0, f)
+	return err
+}
+
+// ListPage はiteratorをstartKeyまでseekしてからlimit件読み込む
+func (s *LevelDBStore) ListPage(prefix, startKey string, limit int64, f func(n int) []interface{}) (string, error) {
 	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
 	defer iter.Release()
+
+	ok := iter.First()
+	if startKey != "" {
+		ok = iter.Seek([]byte(startKey))
+	}
+
 	listJSON := [][]byte{}
-	for iter.Next() {
+	nextKey := ""
+	for ; ok; ok = iter.Next() {
+		if limit > 0 && int64(len(listJSON)) >= limit {
+			nextKey = string(iter.Key())
+			break
+		}
+
 		v := make([]byte, len(iter.Value()))
 		copy(v, iter.Value())
 		listJSON = append(listJSON, v)
@@ -60,112 +120,406 @@ func (s *LevelDBStore) List(prefix string, f func(n int) []interface{}) error {
 
 	err := iter.Error()
 	if err != nil {
-		return err
+		return "", toStoreError(err)
 	}
 
 	m := f(len(listJSON))
 	for i, dataJSON := range listJSON {
 		err := json.Unmarshal(dataJSON, m[i])
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
 
-	return nil
+	return nextKey, nil
 }
 
 func (s *LevelDBStore) Get(key string, v interface{}) error {
 	dataJSON, err := s.db.Get([]byte(key), nil)
 	if err != nil {
-		if err == leveldbErrors.ErrNotFound {
-			return errors.New("Not Found")
-		}
-		return err
+		return toStoreError(err)
 	}
 
 	return json.Unmarshal(dataJSON, v)
 }
 
-func (s *LevelDBStore) Put(key string, data interface{}) {
-	dataJSON, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		log.Println("leveldb store:", "Failed to marshal error ", err.Error())
-		return
+// Put はdataを保存する
+// dataのresourceVersionは書き込み時のrevisionになる
+func (s *LevelDBStore) Put(key string, data interface{}) error {
+	if err := s.Txn(nil, []store.Op{store.OpPut(key, data)}); err != nil {
+		log.Println("leveldb store:", "Failed to put ", err.Error())
+		return err
 	}
+	return nil
+}
+
+func (s *LevelDBStore) Delete(key string) error {
+	if err := s.Txn(nil, []store.Op{store.OpDelete(key)}); err != nil {
+		log.Println("leveldb store:", "Failed to delete ", err.Error())
+		return err
+	}
+	return nil
+}
+
+type notice struct {
+	key      string
+	revision int64
+	before   []byte
+	after    []byte
+}
+
+// Txn はcomparesが全て成り立つ場合のみopsを1つのleveldbのtransactionで反映する
+// 存在しないkeyの削除はrevisionを進めず通知もしない
+func (s *LevelDBStore) Txn(compares []store.Compare, ops []store.Op) error {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
 
 	tr, err := s.db.OpenTransaction()
 	if err != nil {
-		log.Println("leveldb store:", "Failed to open transaction ", err.Error())
-		return
+		return toStoreError(err)
 	}
 
-	err = tr.Put([]byte(key), dataJSON, nil)
-	if err != nil {
+	for _, c := range compares {
+		dataJSON, err := getFromTransaction(tr, c.Key)
+		if err != nil {
+			tr.Discard()
+			return err
+		}
+		if !c.Match(dataJSON) {
+			tr.Discard()
+			return fmt.Errorf("%w: %s", store.ErrCompareFailed, c.Key)
+		}
+	}
+
+	revision := s.revision
+	notices := []notice{}
+	for _, op := range ops {
+		before, err := getFromTransaction(tr, op.Key)
+		if err != nil {
+			tr.Discard()
+			return err
+		}
+
+		switch op.Type {
+		case store.OpTypePut:
+			dataJSON, err := store.MarshalWithResourceVersion(op.Data, revision+1)
+			if err != nil {
+				tr.Discard()
+				return err
+			}
+			if err := tr.Put([]byte(op.Key), dataJSON, nil); err != nil {
+				tr.Discard()
+				return err
+			}
+			revision++
+			notices = append(notices, notice{op.Key, revision, before, dataJSON})
+		case store.OpTypeDelete:
+			if before == nil {
+				continue
+			}
+			if err := tr.Delete([]byte(op.Key), nil); err != nil {
+				tr.Discard()
+				return err
+			}
+			revision++
+			notices = append(notices, notice{op.Key, revision, before, nil})
+		default:
+			tr.Discard()
+			return fmt.Errorf("Error: unknown op type `%s`.", op.Type)
+		}
+	}
+
+	if revision == s.revision {
 		tr.Discard()
-		log.Println("leveldb store", "Failed to put ", err.Error())
-		return
+		return nil
+	}
+
+	if err := putRevision(tr, revision); err != nil {
+		tr.Discard()
+		return err
 	}
 	if err := tr.Commit(); err != nil {
-		log.Println("leveldb store", "Failed to commit transaction ", err.Error())
+		return toStoreError(err)
+	}
+	s.revision = revision
+
+	for _, n := range notices {
+		s.notify(n.key, n.revision, n.before, n.after)
 	}
 
 	if s.isDebug {
-		fmt.Println("=============== PUT  ==================")
+		fmt.Println("=============== TXN  ==================")
 		s.printDB()
 	}
+	return nil
 }
 
-func (s *LevelDBStore) Delete(key string) {
+// Apply は他のstoreで書き込まれた変更をそのまま反映する
+// valueがnilの場合はkeyを削除する
+// revisionが現在のrevision以下の場合は反映済みとして何もしない
+func (s *LevelDBStore) Apply(revision int64, key string, value []byte) error {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
+
+	if revision <= s.revision {
+		return nil
+	}
+
 	tr, err := s.db.OpenTransaction()
 	if err != nil {
-		log.Println("leveldb store:", "Failed to open transaction", err.Error())
-		return
+		return toStoreError(err)
 	}
-	if err := tr.Delete([]byte(key), nil); err != nil {
-		log.Println("leveldb store:", "Failed to delete", err.Error())
+
+	before, err := getFromTransaction(tr, key)
+	if err != nil {
 		tr.Discard()
-		return
+		return err
+	}
+
+	if value == nil {
+		err = tr.Delete([]byte(key), nil)
+	} else {
+		err = tr.Put([]byte(key), value, nil)
+	}
+	if err != nil {
+		tr.Discard()
+		return err
+	}
+
+	if err := putRevision(tr, revision); err != nil {
+		tr.Discard()
+		return err
 	}
 	if err := tr.Commit(); err != nil {
-		log.Println("leveldb store:", "Failed to commit", err.Error())
-		return
+		return toStoreError(err)
 	}
+	s.revision = revision
+
+	s.notify(key, revision, before, value)
+	return nil
+}
+
+// Snapshot は現在のrevisionと全てのデータを返す
+func (s *LevelDBStore) Snapshot() (*store.Snapshot, error) {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
+
+	dbSnapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, toStoreError(err)
+	}
+	defer dbSnapshot.Release()
+
+	snapshot := &store.Snapshot{
+		Revision: s.revision,
+		Entries:  []store.SnapshotEntry{},
+	}
+	iter := dbSnapshot.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := string(iter.Key())
+		if key == revisionKey {
+			continue
+		}
+
+		v := make([]byte, len(iter.Value()))
+		copy(v, iter.Value())
+		snapshot.Entries = append(snapshot.Entries, store.SnapshotEntry{
+			Key:     key,
+			APIType: getAPIType(v),
+			Value:   v,
+		})
+	}
+	if err := iter.Error(); err != nil {
+		return nil, toStoreError(err)
+	}
+
+	return snapshot, nil
+}
+
+// Restore は全てのデータをsnapshotの内容で置き換える
+// 個々の変更は通知せずにResetを通知するため、watchしている側はListし直す必要がある
+// revisionは戻さずに現在とsnapshotの大きい方から1つ進めるので、
+// 置き換える前のrevisionからwatchを再開しようとすると履歴にないため410が返る
+func (s *LevelDBStore) Restore(snapshot *store.Snapshot) error {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
+
+	revision := s.revision
+	if snapshot.Revision > revision {
+		revision = snapshot.Revision
+	}
+	return s.restore(snapshot, revision+1)
+}
+
+// Resync はleaderのsnapshotで全てのデータを置き換え、revisionもsnapshotと同じにする
+// followerはこの後leaderの変更を同じrevisionでApplyする
+func (s *LevelDBStore) Resync(snapshot *store.Snapshot) error {
+	s.writeLocker.Lock()
+	defer s.writeLocker.Unlock()
+
+	return s.restore(snapshot, snapshot.Revision)
+}
+
+// restore はsnapshotの内容で置き換えてrevisionをrevisionにする
+// 呼び出し側でwriteLockerをロックする
+func (s *LevelDBStore) restore(snapshot *store.Snapshot, revision int64) error {
+
+	tr, err := s.db.OpenTransaction()
+	if err != nil {
+		return toStoreError(err)
+	}
+
+	keys := [][]byte{}
+	iter := tr.NewIterator(nil, nil)
+	for iter.Next() {
+		k := make([]byte, len(iter.Key()))
+		copy(k, iter.Key())
+		keys = append(keys, k)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		tr.Discard()
+		return toStoreError(err)
+	}
+
+	for _, k := range keys {
+		if err := tr.Delete(k, nil); err != nil {
+			tr.Discard()
+			return err
+		}
+	}
+
+	for _, entry := range snapshot.Entries {
+		if err := tr.Put([]byte(entry.Key), entry.Value, nil); err != nil {
+			tr.Discard()
+			return err
+		}
+	}
+
+	if err := putRevision(tr, revision); err != nil {
+		tr.Discard()
+		return err
+	}
+	if err := tr.Commit(); err != nil {
+		return toStoreError(err)
+	}
+	s.revision = revision
+	s.notifyReset(revision)
 
 	if s.isDebug {
-		fmt.Println("============== DELETE ================")
+		fmt.Println("=============== RESTORE ===============")
 		s.printDB()
 	}
+	return nil
 }
 
-func (s *LevelDBStore) isExistsLocker(key string) bool {
-	s.lockTableLocker.RLock()
-	defer s.lockTableLocker.RUnlock()
-	_, ok := s.lockTable[key]
-	return ok
+func getFromTransaction(tr *leveldb.Transaction, key string) ([]byte, error) {
+	data, err := tr.Get([]byte(key), nil)
+	if err != nil {
+		if err == leveldbErrors.ErrNotFound {
+			return nil, nil
+		}
+		return nil, toStoreError(err)
+	}
+	return data, nil
 }
 
-func (s *LevelDBStore) acquireLocker(key string) {
-	s.lockTableLocker.Lock()
-	defer s.lockTableLocker.Unlock()
-	s.lockTable[key] = &sync.RWMutex{}
+// toStoreError はleveldbのエラーをerrors.Isで判定できるstoreのエラーに変換する
+func toStoreError(err error) error {
+	switch {
+	case err == leveldbErrors.ErrNotFound:
+		return store.ErrNotFound
+	case err == leveldb.ErrClosed, leveldbErrors.IsCorrupted(err):
+		return fmt.Errorf("%w: %s", store.ErrUnavailable, err.Error())
+	}
+	return err
+}
+
+func putRevision(tr *leveldb.Transaction, revision int64) error {
+	return tr.Put([]byte(revisionKey), []byte(strconv.FormatInt(revision, 10)), nil)
+}
+
+// notify は変更前後のJSONをNoticeDataとしてnotifierへ送る
+// 呼び出し側でwriteLockerを取得しておくこと
+func (s *LevelDBStore) notify(key string, revision int64, before, after []byte) {
+	if s.notifier == nil {
+		return
+	}
+
+	noticeData := NoticeData{
+		Key:      key,
+		APIType:  getAPIType(after),
+		Revision: revision,
+		Before:   string(before),
+		After:    string(after),
+	}
+	if noticeData.APIType == "" {
+		noticeData.APIType = getAPIType(before)
+	}
+
+	noticeJSON, err := json.Marshal(noticeData)
+	if err != nil {
+		log.Println("leveldb store:", "Failed to marshal notice data", err.Error())
+		return
+	}
+
+	s.notifier <- string(noticeJSON)
+}
+
+// notifyReset はRestoreでstoreの内容が置き換えられたことを通知する
+// 呼び出し側でwriteLockerを取得しておくこと
+func (s *LevelDBStore) notifyReset(revision int64) {
+	if s.notifier == nil {
+		return
+	}
+
+	noticeJSON, err := json.Marshal(NoticeData{
+		Revision: revision,
+		Reset:    true,
+	})
+	if err != nil {
+		log.Println("leveldb store:", "Failed to marshal notice data", err.Error())
+		return
+	}
+
+	s.notifier <- string(noticeJSON)
+}
+
+func getAPIType(dataJSON []byte) meta.APIType {
+	if len(dataJSON) == 0 {
+		return ""
+	}
+
+	obj := struct {
+		Meta meta.Meta `json:"meta"`
+	}{}
+	if err := json.Unmarshal(dataJSON, &obj); err != nil {
+		return ""
+	}
+	return obj.Meta.APIType
 }
 
+// Lock はkeyごとのロックを取得する
+// 読み込みから書き込みまでを他のリクエストと競合させないために使う
 func (s *LevelDBStore) Lock(key string) {
-	//if !s.isExistsLocker(key) {
-	//	s.acquireLocker(key)
-	//}
+	s.lockTableLocker.Lock()
+	locker, ok := s.lockTable[key]
+	if !ok {
+		locker = &sync.RWMutex{}
+		s.lockTable[key] = locker
+	}
+	s.lockTableLocker.Unlock()
 
-	//s.lockTableLocker.RLock()
-	//defer s.lockTableLocker.RUnlock()
-	//s.lockTable[key].Lock()
+	locker.Lock()
 }
 
 func (s *LevelDBStore) Unlock(key string) {
-	//s.lockTableLocker.RLock()
-	//defer s.lockTableLocker.RUnlock()
-	//if _, ok := s.lockTable[key]; ok {
-	//	s.lockTable[key].Unlock()
-	//}
+	s.lockTableLocker.RLock()
+	defer s.lockTableLocker.RUnlock()
+	if locker, ok := s.lockTable[key]; ok {
+		locker.Unlock()
+	}
 }
 
 func (s *LevelDBStore) printDB() {
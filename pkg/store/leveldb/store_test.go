@@ -1,19 +1,33 @@
 package leveldb_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
 	"github.com/ophum/humstack/pkg/store/leveldb"
 )
 
 func TestLevelDBStore(t *testing.T) {
 	noti := make(chan string, 1000000)
 	count := 0
-	s, err := leveldb.NewLevelDBStore("./test", noti, false)
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, noti, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,7 +62,13 @@ func TestLevelDBStore(t *testing.T) {
 
 func TestDeleteStore(t *testing.T) {
 	noti := make(chan string, 1000000)
-	s, err := leveldb.NewLevelDBStore("./test", noti, false)
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, noti, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -74,3 +94,407 @@ func TestDeleteStore(t *testing.T) {
 		t.Fatal("want: not exists, expect: exists")
 	}
 }
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	noti := make(chan string, 10)
+	s, err := leveldb.NewLevelDBStore(dir, noti, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	type data struct {
+		meta.Meta `json:"meta"`
+		Data      string `json:"data"`
+	}
+
+	s.Put("test/a", data{Meta: meta.Meta{ID: "a", APIType: meta.APITypeGroupV0}, Data: "before"})
+	s.Put("test/a", data{Meta: meta.Meta{ID: "a", APIType: meta.APITypeGroupV0}, Data: "after"})
+	s.Delete("test/a")
+	// 存在しないkeyの削除は通知されない
+	s.Delete("test/b")
+
+	notices := []leveldb.NoticeData{}
+	for i := 0; i < 3; i++ {
+		n := leveldb.NoticeData{}
+		if err := json.Unmarshal([]byte(<-noti), &n); err != nil {
+			t.Fatal(err)
+		}
+		notices = append(notices, n)
+	}
+	if len(noti) != 0 {
+		t.Fatalf("want: 0 remaining notices, got: %d", len(noti))
+	}
+
+	for i, n := range notices {
+		if n.Key != "test/a" {
+			t.Errorf("notices[%d].Key want: test/a, got: %s", i, n.Key)
+		}
+		if n.APIType != meta.APITypeGroupV0 {
+			t.Errorf("notices[%d].APIType want: %s, got: %s", i, meta.APITypeGroupV0, n.APIType)
+		}
+		if n.Revision != int64(i+1) {
+			t.Errorf("notices[%d].Revision want: %d, got: %d", i, i+1, n.Revision)
+		}
+	}
+
+	if notices[0].Before != "" {
+		t.Errorf("create notice want empty before, got: %s", notices[0].Before)
+	}
+	if notices[1].Before != notices[0].After {
+		t.Errorf("update notice before want: %s, got: %s", notices[0].After, notices[1].Before)
+	}
+	if notices[2].Before != notices[1].After || notices[2].After != "" {
+		t.Errorf("delete notice want before: %s after: empty, got: %+v", notices[1].After, notices[2])
+	}
+
+	// revisionは再オープン後も引き継がれる
+	s.Close()
+	reopened, err := leveldb.NewLevelDBStore(dir, noti, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Revision() != 3 {
+		t.Fatalf("want revision: 3, got: %d", reopened.Revision())
+	}
+}
+
+func TestResourceVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	type data struct {
+		meta.Meta `json:"meta"`
+		Data      string `json:"data"`
+	}
+
+	a := data{Meta: meta.Meta{ID: "a"}, Data: "a"}
+	s.Put("test/a", &a)
+	if a.ResourceVersion != 1 {
+		t.Errorf("want: 1, got: %d", a.ResourceVersion)
+	}
+
+	// ポインタでない場合も保存されたデータのresourceVersionは更新される
+	s.Put("test/b", data{Meta: meta.Meta{ID: "b"}, Data: "b"})
+	b := data{}
+	if err := s.Get("test/b", &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.ResourceVersion != 2 || b.Data != "b" {
+		t.Errorf("want: resourceVersion 2 data b, got: %+v", b)
+	}
+
+	s.Put("test/a", &a)
+	got := data{}
+	if err := s.Get("test/a", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ResourceVersion != 3 || a.ResourceVersion != 3 {
+		t.Errorf("want: 3, got: stored %d, data %d", got.ResourceVersion, a.ResourceVersion)
+	}
+}
+
+func TestTxn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	noti := make(chan string, 10)
+	s, err := leveldb.NewLevelDBStore(dir, noti, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	type data struct {
+		meta.Meta `json:"meta"`
+		Data      string `json:"data"`
+	}
+
+	pool := data{Meta: meta.Meta{ID: "pool"}, Data: ""}
+	s.Put("test/pool", &pool)
+	s.Put("test/old", data{Meta: meta.Meta{ID: "old"}})
+	<-noti
+	<-noti
+
+	// 条件を満たす場合は全ての操作が反映される
+	pool.Data = "10.0.0.1"
+	eip := data{Meta: meta.Meta{ID: "eip"}, Data: "10.0.0.1"}
+	err = s.Txn(
+		[]store.Compare{
+			store.CompareResourceVersion("test/pool", pool.ResourceVersion),
+			store.CompareNotExists("test/eip"),
+		},
+		[]store.Op{
+			store.OpPut("test/pool", &pool),
+			store.OpPut("test/eip", &eip),
+			store.OpDelete("test/old"),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.ResourceVersion != 3 || eip.ResourceVersion != 4 {
+		t.Errorf("want: pool 3 eip 4, got: pool %d eip %d", pool.ResourceVersion, eip.ResourceVersion)
+	}
+	if s.Revision() != 5 {
+		t.Errorf("revision want: 5, got: %d", s.Revision())
+	}
+	for i, key := range []string{"test/pool", "test/eip", "test/old"} {
+		n := leveldb.NoticeData{}
+		if err := json.Unmarshal([]byte(<-noti), &n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Key != key || n.Revision != int64(i+3) {
+			t.Errorf("notices[%d] want: %s %d, got: %s %d", i, key, i+3, n.Key, n.Revision)
+		}
+	}
+
+	// 条件を満たさない場合は何も反映されない
+	stale := pool
+	stale.ResourceVersion = 1
+	err = s.Txn(
+		[]store.Compare{
+			store.CompareResourceVersion("test/pool", stale.ResourceVersion),
+		},
+		[]store.Op{
+			store.OpPut("test/pool", &data{Meta: meta.Meta{ID: "pool"}, Data: "10.0.0.2"}),
+			store.OpDelete("test/eip"),
+		},
+	)
+	if !errors.Is(err, store.ErrCompareFailed) {
+		t.Fatalf("want: %v, got: %v", store.ErrCompareFailed, err)
+	}
+	err = s.Txn(
+		[]store.Compare{store.CompareNotExists("test/eip")},
+		[]store.Op{store.OpDelete("test/eip")},
+	)
+	if !errors.Is(err, store.ErrCompareFailed) {
+		t.Fatalf("want: %v, got: %v", store.ErrCompareFailed, err)
+	}
+	if len(noti) != 0 {
+		t.Errorf("want: no notices, got: %d", len(noti))
+	}
+	if s.Revision() != 5 {
+		t.Errorf("revision want: 5, got: %d", s.Revision())
+	}
+
+	got := data{}
+	if err := s.Get("test/pool", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data != "10.0.0.1" || got.ResourceVersion != 3 {
+		t.Errorf("want: 10.0.0.1 3, got: %s %d", got.Data, got.ResourceVersion)
+	}
+	if err := s.Get("test/eip", &got); err != nil {
+		t.Errorf("want: eip exists, got: %v", err)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := struct{}{}
+	if err := s.Get("test/notfound", &d); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want: %v, got: %v", store.ErrNotFound, err)
+	}
+
+	// 閉じた後の読み書きはUnavailableになる
+	s.Close()
+	if err := s.Get("test/notfound", &d); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("want: %v, got: %v", store.ErrUnavailable, err)
+	}
+	if err := s.Put("test/a", &d); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("want: %v, got: %v", store.ErrUnavailable, err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	noti := make(chan string, 10)
+	s, err := leveldb.NewLevelDBStore(filepath.Join(dir, "src"), noti, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	type data struct {
+		meta.Meta `json:"meta"`
+		Data      string `json:"data"`
+	}
+
+	s.Put("test/a", &data{Meta: meta.Meta{ID: "a"}, Data: "a"})
+	s.Put("test/b", &data{Meta: meta.Meta{ID: "b"}, Data: "b"})
+	<-noti
+	<-noti
+
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Revision != 2 || len(snapshot.Entries) != 2 {
+		t.Fatalf("want: revision 2 with 2 entries, got: revision %d with %d entries", snapshot.Revision, len(snapshot.Entries))
+	}
+
+	dstNoti := make(chan string, 10)
+	dst, err := leveldb.NewLevelDBStore(filepath.Join(dir, "dst"), dstNoti, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// Restoreで元々あったデータは消える
+	dst.Put("test/c", &data{Meta: meta.Meta{ID: "c"}})
+	dst.Put("test/d", &data{Meta: meta.Meta{ID: "d"}})
+	dst.Put("test/e", &data{Meta: meta.Meta{ID: "e"}})
+	<-dstNoti
+	<-dstNoti
+	<-dstNoti
+
+	// revisionはsnapshotのrevisionまで戻さずに進める
+	if err := dst.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Revision() != 4 {
+		t.Fatalf("want: 4, got: %d", dst.Revision())
+	}
+	if err := dst.Get("test/c", &data{}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("want: %v, got: %v", store.ErrNotFound, err)
+	}
+	b := data{}
+	if err := dst.Get("test/b", &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Data != "b" || b.ResourceVersion != 2 {
+		t.Fatalf("want: b at revision 2, got: %s at revision %d", b.Data, b.ResourceVersion)
+	}
+
+	noticeData := leveldb.NoticeData{}
+	json.Unmarshal([]byte(<-dstNoti), &noticeData)
+	if !noticeData.Reset || noticeData.Revision != 4 {
+		t.Fatalf("want: reset notice at revision 4, got: %+v", noticeData)
+	}
+
+	// Resyncはfollowerがleaderと同じrevisionになるようにsnapshotのrevisionにする
+	if err := dst.Resync(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Revision() != 2 {
+		t.Fatalf("want: 2, got: %d", dst.Revision())
+	}
+	noticeData = leveldb.NoticeData{}
+	json.Unmarshal([]byte(<-dstNoti), &noticeData)
+	if !noticeData.Reset || noticeData.Revision != 2 {
+		t.Fatalf("want: reset notice at revision 2, got: %+v", noticeData)
+	}
+
+	// Applyは同じrevisionでそのまま反映する
+	s.Delete("test/a")
+	noticeData = leveldb.NoticeData{}
+	json.Unmarshal([]byte(<-noti), &noticeData)
+	if err := dst.Apply(noticeData.Revision, noticeData.Key, nil); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Revision() != 3 {
+		t.Fatalf("want: 3, got: %d", dst.Revision())
+	}
+	if err := dst.Get("test/a", &data{}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("want: %v, got: %v", store.ErrNotFound, err)
+	}
+	<-dstNoti
+}
+
+func TestListPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	type data struct {
+		meta.Meta `json:"meta"`
+	}
+	for _, id := range []string{"e", "a", "c", "b", "d"} {
+		s.Put("test/"+id, &data{Meta: meta.Meta{ID: id}})
+	}
+	s.Put("test2/a", &data{Meta: meta.Meta{ID: "a"}})
+
+	list := func(startKey string, limit int64) ([]string, string) {
+		dataList := []*data{}
+		next, err := s.ListPage("test/", startKey, limit, func(n int) []interface{} {
+			m := []interface{}{}
+			for i := 0; i < n; i++ {
+				d := &data{}
+				dataList = append(dataList, d)
+				m = append(m, d)
+			}
+			return m
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, d := range dataList {
+			ids = append(ids, d.ID)
+		}
+		return ids, next
+	}
+
+	ids, next := list("", 2)
+	if strings.Join(ids, ",") != "a,b" || next != "test/c" {
+		t.Fatalf("want: 2 items and next test/c, got: %v %s", ids, next)
+	}
+	ids, next = list(next, 2)
+	if strings.Join(ids, ",") != "c,d" || next != "test/e" {
+		t.Fatalf("want: 2 items and next test/e, got: %v %s", ids, next)
+	}
+	ids, next = list(next, 2)
+	if strings.Join(ids, ",") != "e" || next != "" {
+		t.Fatalf("want: 1 item and no next, got: %v %s", ids, next)
+	}
+
+	// limitが0の場合は全て取得する
+	ids, next = list("", 0)
+	if len(ids) != 5 || next != "" {
+		t.Fatalf("want: 5 items and no next, got: %v %s", ids, next)
+	}
+}
@@ -2,77 +2,172 @@ package memory
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"reflect"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/ophum/humstack/pkg/store"
 )
 
 type MemoryStore struct {
-	data      map[string]interface{}
-	lockTable map[string]*sync.RWMutex
+	locker          *sync.RWMutex
+	data            map[string][]byte
+	revision        int64
+	lockTableLocker *sync.Mutex
+	lockTable       map[string]*sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		data:      map[string]interface{}{},
-		lockTable: map[string]*sync.RWMutex{},
+		locker:          &sync.RWMutex{},
+		data:            map[string][]byte{},
+		lockTableLocker: &sync.Mutex{},
+		lockTable:       map[string]*sync.RWMutex{},
 	}
 }
 
 func (s *MemoryStore) List(prefix string, f func(n int) []interface{}) error {
-	list := []interface{}{}
-	for k, obj := range s.data {
-		if strings.HasPrefix(k, prefix) {
-			list = append(list, obj)
+	_, err := s.ListPage(prefix, "", 0, f)
+	return err
+}
+
+func (s *MemoryStore) ListPage(prefix, startKey string, limit int64, f func(n int) []interface{}) (string, error) {
+	s.locker.RLock()
+	keys := []string{}
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) && k >= startKey {
+			keys = append(keys, k)
 		}
 	}
+	sort.Strings(keys)
+
+	nextKey := ""
+	if limit > 0 && int64(len(keys)) > limit {
+		nextKey = keys[limit]
+		keys = keys[:limit]
+	}
+
+	list := [][]byte{}
+	for _, k := range keys {
+		list = append(list, s.data[k])
+	}
+	s.locker.RUnlock()
 
 	m := f(len(list))
-	for i, o := range list {
-		a := reflect.Indirect(reflect.ValueOf(m[i]))
-		a.Set(reflect.ValueOf(o))
+	for i, dataJSON := range list {
+		if err := json.Unmarshal(dataJSON, m[i]); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	return nextKey, nil
 }
 
 func (s *MemoryStore) Get(key string, v interface{}) error {
-	if d, ok := s.data[key]; ok {
-		a := reflect.Indirect(reflect.ValueOf(v))
-		a.Set(reflect.ValueOf(d))
-		log.Println(v)
-		return nil
+	s.locker.RLock()
+	dataJSON, ok := s.data[key]
+	s.locker.RUnlock()
+
+	if !ok {
+		return store.ErrNotFound
+	}
+	return json.Unmarshal(dataJSON, v)
+}
+
+// Put はdataを保存する
+// dataのresourceVersionは書き込み時のrevisionになる
+func (s *MemoryStore) Put(key string, data interface{}) error {
+	if err := s.Txn(nil, []store.Op{store.OpPut(key, data)}); err != nil {
+		log.Println("memory store:", "Failed to put ", err.Error())
+		return err
 	}
-	return errors.New("Not Found")
+	return nil
 }
 
-func (s *MemoryStore) Put(key string, data interface{}) {
-	s.data[key] = data
-	buf, _ := json.MarshalIndent(s.data, "", "  ")
-	fmt.Println("============= PUT DATA ==============")
-	fmt.Println(string(buf))
+func (s *MemoryStore) Delete(key string) error {
+	if err := s.Txn(nil, []store.Op{store.OpDelete(key)}); err != nil {
+		log.Println("memory store:", "Failed to delete ", err.Error())
+		return err
+	}
+	return nil
 }
 
-func (s *MemoryStore) Delete(key string) {
-	delete(s.data, key)
-	buf, _ := json.MarshalIndent(s.data, "", "  ")
-	fmt.Println("============= DEL DATA ==============")
-	fmt.Println(string(buf))
+// Txn はcomparesが全て成り立つ場合のみopsをまとめて反映する
+// 途中で失敗した場合に何も反映しないように変更をchangesに溜めてから最後に反映する
+func (s *MemoryStore) Txn(compares []store.Compare, ops []store.Op) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	for _, c := range compares {
+		if !c.Match(s.data[c.Key]) {
+			return fmt.Errorf("%w: %s", store.ErrCompareFailed, c.Key)
+		}
+	}
+
+	revision := s.revision
+	changes := map[string][]byte{}
+	for _, op := range ops {
+		_, exists := s.data[op.Key]
+		if v, ok := changes[op.Key]; ok {
+			exists = v != nil
+		}
+
+		switch op.Type {
+		case store.OpTypePut:
+			dataJSON, err := store.MarshalWithResourceVersion(op.Data, revision+1)
+			if err != nil {
+				return err
+			}
+			changes[op.Key] = dataJSON
+			revision++
+		case store.OpTypeDelete:
+			if !exists {
+				continue
+			}
+			changes[op.Key] = nil
+			revision++
+		default:
+			return fmt.Errorf("Error: unknown op type `%s`.", op.Type)
+		}
+	}
+
+	for key, dataJSON := range changes {
+		if dataJSON == nil {
+			delete(s.data, key)
+			continue
+		}
+		s.data[key] = dataJSON
+	}
+	s.revision = revision
+
+	if len(changes) > 0 {
+		fmt.Println("============= TXN DATA ==============")
+		for key, dataJSON := range changes {
+			fmt.Println(key)
+			fmt.Println(string(dataJSON))
+		}
+	}
+	return nil
 }
 
 func (s *MemoryStore) Lock(key string) {
-	if _, ok := s.lockTable[key]; !ok {
-		s.lockTable[key] = &sync.RWMutex{}
+	s.lockTableLocker.Lock()
+	locker, ok := s.lockTable[key]
+	if !ok {
+		locker = &sync.RWMutex{}
+		s.lockTable[key] = locker
 	}
+	s.lockTableLocker.Unlock()
 
-	s.lockTable[key].Lock()
+	locker.Lock()
 }
 
 func (s *MemoryStore) Unlock(key string) {
-	if _, ok := s.lockTable[key]; ok {
-		s.lockTable[key].Unlock()
+	s.lockTableLocker.Lock()
+	defer s.lockTableLocker.Unlock()
+	if locker, ok := s.lockTable[key]; ok {
+		locker.Unlock()
 	}
 }
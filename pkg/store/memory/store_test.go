@@ -0,0 +1,137 @@
+package memory_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/memory"
+)
+
+type data struct {
+	meta.Meta `json:"meta"`
+	Data      string `json:"data"`
+}
+
+func TestTxn(t *testing.T) {
+	s := memory.NewMemoryStore()
+
+	pool := data{Meta: meta.Meta{ID: "pool"}}
+	s.Put("test/pool", &pool)
+	s.Put("test/old", data{Meta: meta.Meta{ID: "old"}})
+
+	pool.Data = "10.0.0.1"
+	eip := data{Meta: meta.Meta{ID: "eip"}, Data: "10.0.0.1"}
+	err := s.Txn(
+		[]store.Compare{
+			store.CompareResourceVersion("test/pool", pool.ResourceVersion),
+			store.CompareNotExists("test/eip"),
+		},
+		[]store.Op{
+			store.OpPut("test/pool", &pool),
+			store.OpPut("test/eip", &eip),
+			store.OpDelete("test/old"),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.ResourceVersion != 3 || eip.ResourceVersion != 4 {
+		t.Errorf("want: pool 3 eip 4, got: pool %d eip %d", pool.ResourceVersion, eip.ResourceVersion)
+	}
+	if err := s.Get("test/old", &data{}); err == nil {
+		t.Errorf("want: test/old is deleted")
+	}
+
+	// 条件を満たさない場合は何も反映されない
+	err = s.Txn(
+		[]store.Compare{
+			store.CompareResourceVersion("test/pool", 1),
+		},
+		[]store.Op{
+			store.OpPut("test/pool", &data{Meta: meta.Meta{ID: "pool"}, Data: "10.0.0.2"}),
+			store.OpDelete("test/eip"),
+		},
+	)
+	if !errors.Is(err, store.ErrCompareFailed) {
+		t.Fatalf("want: %v, got: %v", store.ErrCompareFailed, err)
+	}
+	if !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("want: %v, got: %v", store.ErrConflict, err)
+	}
+
+	got := data{}
+	if err := s.Get("test/pool", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data != "10.0.0.1" || got.ResourceVersion != 3 {
+		t.Errorf("want: 10.0.0.1 3, got: %s %d", got.Data, got.ResourceVersion)
+	}
+	if err := s.Get("test/eip", &got); err != nil {
+		t.Errorf("want: eip exists, got: %v", err)
+	}
+
+	// 同じTxnの中でPutしたkeyはDeleteできる
+	err = s.Txn(nil, []store.Op{
+		store.OpPut("test/tmp", &data{Meta: meta.Meta{ID: "tmp"}}),
+		store.OpDelete("test/tmp"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get("test/tmp", &got); err == nil {
+		t.Errorf("want: test/tmp is deleted")
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	s := memory.NewMemoryStore()
+
+	if err := s.Get("test/notfound", &data{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("want: %v, got: %v", store.ErrNotFound, err)
+	}
+}
+
+func TestListPage(t *testing.T) {
+	s := memory.NewMemoryStore()
+	for _, id := range []string{"e", "a", "c", "b", "d"} {
+		s.Put("test/"+id, &data{Meta: meta.Meta{ID: id}})
+	}
+	s.Put("other/a", &data{Meta: meta.Meta{ID: "a"}})
+
+	ids := []string{}
+	startKey := ""
+	pages := 0
+	for {
+		list := []*data{}
+		next, err := s.ListPage("test/", startKey, 2, func(n int) []interface{} {
+			m := []interface{}{}
+			for i := 0; i < n; i++ {
+				d := &data{}
+				list = append(list, d)
+				m = append(m, d)
+			}
+			return m
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, d := range list {
+			ids = append(ids, d.ID)
+		}
+		if next == "" {
+			break
+		}
+		startKey = next
+	}
+
+	if pages != 3 {
+		t.Fatalf("want: 3 pages, got: %d", pages)
+	}
+	if strings.Join(ids, ",") != "a,b,c,d,e" {
+		t.Fatalf("want: a,b,c,d,e, got: %s", strings.Join(ids, ","))
+	}
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// Migration はAPITypeのデータをFromVersionからFromVersion+1のスキーマに書き換える
+type Migration struct {
+	APIType     meta.APIType
+	FromVersion int64
+	Description string
+
+	// Migrate は保存されているJSONをデコードしたobjを書き換える
+	// 数値はjson.Numberとしてデコードされる
+	Migrate func(obj map[string]interface{}) error
+}
+
+// MigrationRegistry はAPITypeごとのMigrationを管理する
+// APITypeの現在のスキーマのバージョンは登録されているMigrationの数になる
+type MigrationRegistry struct {
+	locker     *sync.RWMutex
+	migrations map[meta.APIType][]Migration
+}
+
+// DefaultMigrationRegistry はstoreへの書き込み時にschemaVersionを決めるために使う
+var DefaultMigrationRegistry = NewMigrationRegistry()
+
+func NewMigrationRegistry() *MigrationRegistry {
+	return &MigrationRegistry{
+		locker:     &sync.RWMutex{},
+		migrations: map[meta.APIType][]Migration{},
+	}
+}
+
+// Register はmigrationを登録する
+// 同じAPITypeのMigrationはFromVersionが0から順番になるように登録する
+func (r *MigrationRegistry) Register(migration Migration) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if migration.APIType == "" || migration.Migrate == nil {
+		return fmt.Errorf("Error: migration requires apiType and migrate func.")
+	}
+
+	current := int64(len(r.migrations[migration.APIType]))
+	if migration.FromVersion != current {
+		return fmt.Errorf("Error: migration of `%s` must be from version %d, got %d.",
+			migration.APIType, current, migration.FromVersion)
+	}
+
+	r.migrations[migration.APIType] = append(r.migrations[migration.APIType], migration)
+	return nil
+}
+
+// MustRegister はRegisterに失敗した場合にpanicする
+func (r *MigrationRegistry) MustRegister(migration Migration) {
+	if err := r.Register(migration); err != nil {
+		panic(err)
+	}
+}
+
+// SchemaVersion はapiTypeの現在のスキーマのバージョンを返す
+func (r *MigrationRegistry) SchemaVersion(apiType meta.APIType) int64 {
+	r.locker.RLock()
+	defer r.locker.RUnlock()
+	return int64(len(r.migrations[apiType]))
+}
+
+// Migrate はdataJSONを現在のスキーマに書き換える
+// 書き換える必要がない場合はnilを返す
+func (r *MigrationRegistry) Migrate(dataJSON []byte) ([]byte, []Migration, error) {
+	decoder := json.NewDecoder(bytes.NewReader(dataJSON))
+	decoder.UseNumber()
+	obj := map[string]interface{}{}
+	if err := decoder.Decode(&obj); err != nil {
+		// オブジェクトでないデータはmigrationの対象外
+		return nil, nil, nil
+	}
+
+	m, ok := obj["meta"].(map[string]interface{})
+	if !ok {
+		return nil, nil, nil
+	}
+	apiType, _ := m["apiType"].(string)
+
+	version := int64(0)
+	if v, ok := m["schemaVersion"].(json.Number); ok {
+		var err error
+		version, err = v.Int64()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error: invalid schemaVersion `%s`.", v)
+		}
+	}
+
+	r.locker.RLock()
+	migrations := r.migrations[meta.APIType(apiType)]
+	r.locker.RUnlock()
+
+	current := int64(len(migrations))
+	if version > current {
+		return nil, nil, fmt.Errorf("Error: schemaVersion %d of `%s` is newer than supported version %d.",
+			version, apiType, current)
+	}
+	if version == current {
+		return nil, nil, nil
+	}
+
+	applied := migrations[version:]
+	for _, migration := range applied {
+		if err := migration.Migrate(obj); err != nil {
+			return nil, nil, fmt.Errorf("Error: failed to migrate `%s` from version %d: %s",
+				apiType, migration.FromVersion, err.Error())
+		}
+	}
+
+	// Migrateでmetaが置き換えられている場合もあるので取得し直す
+	m, ok = obj["meta"].(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("Error: migration of `%s` removed meta.", apiType)
+	}
+	m["schemaVersion"] = current
+
+	migratedJSON, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return migratedJSON, applied, nil
+}
+
+// MigrationStore はRunMigrationsで書き換えるstore
+type MigrationStore interface {
+	Snapshot() (*Snapshot, error)
+	Txn(compares []Compare, ops []Op) error
+}
+
+// MigrationResult はkeyごとに適用した(dry-runの場合は適用する)Migration
+type MigrationResult struct {
+	Key          string
+	APIType      meta.APIType
+	FromVersion  int64
+	ToVersion    int64
+	Descriptions []string
+}
+
+// RunMigrations はstoreの全てのデータを現在のスキーマに書き換える
+// 1つでも書き換えられないデータがある場合は何も書き換えない
+// dryRunがtrueの場合は書き換えずに結果だけを返す
+// 書き込みと競合しないようにapiserverがリクエストを受け付ける前に実行する
+func (r *MigrationRegistry) RunMigrations(s MigrationStore, dryRun bool) ([]MigrationResult, error) {
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MigrationResult{}
+	ops := []Op{}
+	for _, entry := range snapshot.Entries {
+		migratedJSON, applied, err := r.Migrate(entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Key, err)
+		}
+		if migratedJSON == nil {
+			continue
+		}
+
+		result := MigrationResult{
+			Key:         entry.Key,
+			APIType:     entry.APIType,
+			FromVersion: applied[0].FromVersion,
+			ToVersion:   applied[len(applied)-1].FromVersion + 1,
+		}
+		for _, migration := range applied {
+			result.Descriptions = append(result.Descriptions, migration.Description)
+		}
+		results = append(results, result)
+		ops = append(ops, OpPut(entry.Key, json.RawMessage(migratedJSON)))
+	}
+
+	if dryRun || len(ops) == 0 {
+		return results, nil
+	}
+
+	if err := s.Txn(nil, ops); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// setSchemaVersion は書き込むデータのmetaにapiTypeの現在のスキーマのバージョンを設定する
+// Migrationが登録されていないAPITypeの場合は何もしない
+func setSchemaVersion(m map[string]interface{}) {
+	apiType, _ := m["apiType"].(string)
+	version := DefaultMigrationRegistry.SchemaVersion(meta.APIType(apiType))
+	if version == 0 {
+		return
+	}
+	m["schemaVersion"] = version
+}
@@ -0,0 +1,172 @@
+package store_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+const testAPIType = meta.APIType("testv0/data")
+
+type data struct {
+	meta.Meta `json:"meta"`
+	Data      string `json:"data"`
+	Count     int64  `json:"count"`
+}
+
+func newTestRegistry(t *testing.T) *store.MigrationRegistry {
+	r := store.NewMigrationRegistry()
+	err := r.Register(store.Migration{
+		APIType:     testAPIType,
+		FromVersion: 0,
+		Description: "rename value to data",
+		Migrate: func(obj map[string]interface{}) error {
+			obj["data"] = obj["value"]
+			delete(obj, "value")
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Register(store.Migration{
+		APIType:     testAPIType,
+		FromVersion: 1,
+		Description: "set default count",
+		Migrate: func(obj map[string]interface{}) error {
+			if _, ok := obj["count"]; !ok {
+				obj["count"] = 1
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMigrationRegistry(t *testing.T) {
+	r := newTestRegistry(t)
+
+	// FromVersionが飛んでいるMigrationは登録できない
+	err := r.Register(store.Migration{
+		APIType:     testAPIType,
+		FromVersion: 3,
+		Migrate:     func(obj map[string]interface{}) error { return nil },
+	})
+	if err == nil {
+		t.Fatal("want: error")
+	}
+	if v := r.SchemaVersion(testAPIType); v != 2 {
+		t.Fatalf("want: 2, got: %d", v)
+	}
+
+	// schemaVersionがないデータは0から順番に適用する
+	migratedJSON, applied, err := r.Migrate([]byte(`{"meta":{"id":"a","apiType":"testv0/data","resourceVersion":10},"value":"a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("want: 2 migrations, got: %d", len(applied))
+	}
+	got := data{}
+	if err := json.Unmarshal(migratedJSON, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data != "a" || got.Count != 1 || got.SchemaVersion != 2 || got.ResourceVersion != 10 {
+		t.Fatalf("unexpected migrated data: %+v", got)
+	}
+
+	// 途中のバージョンからは残りだけを適用する
+	migratedJSON, applied, err = r.Migrate([]byte(`{"meta":{"id":"a","apiType":"testv0/data","schemaVersion":1},"data":"a","count":5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("want: 1 migration, got: %d", len(applied))
+	}
+	got = data{}
+	json.Unmarshal(migratedJSON, &got)
+	if got.Count != 5 || got.SchemaVersion != 2 {
+		t.Fatalf("unexpected migrated data: %+v", got)
+	}
+
+	// 現在のバージョンやMigrationがないAPITypeは書き換えない
+	for _, dataJSON := range []string{
+		`{"meta":{"id":"a","apiType":"testv0/data","schemaVersion":2},"data":"a"}`,
+		`{"meta":{"id":"a","apiType":"testv0/other"}}`,
+		`"not object"`,
+	} {
+		migratedJSON, _, err := r.Migrate([]byte(dataJSON))
+		if err != nil || migratedJSON != nil {
+			t.Fatalf("want: no migration for %s, got: %s %v", dataJSON, migratedJSON, err)
+		}
+	}
+
+	// 対応しているより新しいバージョンはエラーにする
+	if _, _, err := r.Migrate([]byte(`{"meta":{"id":"a","apiType":"testv0/data","schemaVersion":3}}`)); err == nil {
+		t.Fatal("want: error")
+	}
+}
+
+func TestRunMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := leveldb.NewLevelDBStore(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Put("test/old", json.RawMessage(`{"meta":{"id":"old","apiType":"testv0/data"},"value":"old"}`))
+	s.Put("test/new", json.RawMessage(`{"meta":{"id":"new","apiType":"testv0/data","schemaVersion":2},"data":"new","count":3}`))
+
+	r := newTestRegistry(t)
+
+	// dry-runでは書き換えない
+	results, err := r.RunMigrations(s, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != "test/old" || results[0].FromVersion != 0 || results[0].ToVersion != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if s.Revision() != 2 {
+		t.Fatalf("want: 2, got: %d", s.Revision())
+	}
+
+	results, err = r.RunMigrations(s, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("want: 1, got: %d", len(results))
+	}
+
+	old := data{}
+	if err := s.Get("test/old", &old); err != nil {
+		t.Fatal(err)
+	}
+	if old.Data != "old" || old.Count != 1 || old.SchemaVersion != 2 || old.ResourceVersion != 3 {
+		t.Fatalf("unexpected migrated data: %+v", old)
+	}
+
+	// 書き換えた後は何もしない
+	results, err = r.RunMigrations(s, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 || s.Revision() != 3 {
+		t.Fatalf("want: no migrations, got: %+v at revision %d", results, s.Revision())
+	}
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+const (
+	imageEntityTypeLocal = "Local"
+)
+
+func init() {
+	store.DefaultMigrationRegistry.MustRegister(imageEntityV0ToV1)
+}
+
+// imageEntityV0ToV1 はSpec.TypeとSpec.Source.Typeが追加される前のImageEntityに
+// agentが空の場合に使っていたデフォルト値を設定する
+var imageEntityV0ToV1 = store.Migration{
+	APIType:     meta.APITypeImageEntityV0,
+	FromVersion: 0,
+	Description: "set default spec.type and spec.source.type",
+	Migrate: func(obj map[string]interface{}) error {
+		spec, err := getOrCreateMap(obj, "spec")
+		if err != nil {
+			return err
+		}
+		if t, _ := spec["type"].(string); t == "" {
+			spec["type"] = imageEntityTypeLocal
+		}
+
+		source, err := getOrCreateMap(spec, "source")
+		if err != nil {
+			return err
+		}
+		if t, _ := source["type"].(string); t == "" {
+			source["type"] = string(system.ImageEntitySourceTypeBlockStorage)
+		}
+		return nil
+	},
+}
+
+func getOrCreateMap(obj map[string]interface{}, key string) (map[string]interface{}, error) {
+	v, ok := obj[key]
+	if !ok || v == nil {
+		m := map[string]interface{}{}
+		obj[key] = m
+		return m, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Error: `%s` is not an object.", key)
+	}
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/store"
+)
+
+func TestImageEntityV0ToV1(t *testing.T) {
+	tests := []struct {
+		name           string
+		dataJSON       string
+		wantType       string
+		wantSourceType system.ImageEntitySourceType
+	}{
+		{
+			name:           "without type",
+			dataJSON:       `{"meta":{"id":"ie","apiType":"systemv0/imageentity"},"spec":{"hash":"abc","source":{"namespace":"ns","blockStorageID":"bs"}}}`,
+			wantType:       "Local",
+			wantSourceType: system.ImageEntitySourceTypeBlockStorage,
+		},
+		{
+			name:           "without spec",
+			dataJSON:       `{"meta":{"id":"ie","apiType":"systemv0/imageentity"}}`,
+			wantType:       "Local",
+			wantSourceType: system.ImageEntitySourceTypeBlockStorage,
+		},
+		{
+			name:           "keep type",
+			dataJSON:       `{"meta":{"id":"ie","apiType":"systemv0/imageentity"},"spec":{"type":"Ceph","source":{"type":"Image","imageName":"ubuntu"}}}`,
+			wantType:       "Ceph",
+			wantSourceType: system.ImageEntitySourceTypeImage,
+		},
+	}
+
+	for _, tt := range tests {
+		migratedJSON, applied, err := store.DefaultMigrationRegistry.Migrate([]byte(tt.dataJSON))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(applied) != 1 {
+			t.Fatalf("%s: want: 1 migration, got: %d", tt.name, len(applied))
+		}
+
+		ie := system.ImageEntity{}
+		if err := json.Unmarshal(migratedJSON, &ie); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ie.Spec.Type != tt.wantType || ie.Spec.Source.Type != tt.wantSourceType || ie.SchemaVersion != 1 {
+			t.Fatalf("%s: unexpected migrated imageentity: %+v", tt.name, ie)
+		}
+	}
+}
+
+func TestImageEntitySchemaVersionOnWrite(t *testing.T) {
+	ie := &system.ImageEntity{}
+	ie.APIType = meta.APITypeImageEntityV0
+
+	// 書き込むデータには現在のschemaVersionが設定される
+	dataJSON, err := store.MarshalWithResourceVersion(ie, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := system.ImageEntity{}
+	if err := json.Unmarshal(dataJSON, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != 1 {
+		t.Fatalf("want: 1, got: %d", got.SchemaVersion)
+	}
+}
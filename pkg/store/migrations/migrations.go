@@ -0,0 +1,4 @@
+// Package migrations は保存されているデータのスキーマのMigrationを
+// store.DefaultMigrationRegistryに登録する
+// apiserverでimportすると書き込み時のschemaVersionと起動時のmigrationに使われる
+package migrations
@@ -0,0 +1,321 @@
+package replicated
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/replication"
+	replicationv0 "github.com/ophum/humstack/pkg/client/replication/v0"
+	watchv0 "github.com/ophum/humstack/pkg/client/watch/v0"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+)
+
+// ReplicatedStore は1台のleaderのstoreを複数のapiserverで共有するためのstore
+//
+// leaderはローカルのLevelDBStoreにそのまま読み書きする
+// followerは書き込みをleaderのapiserverに転送し、leaderのwatchから受け取った変更を
+// 同じrevisionでローカルのLevelDBStoreに反映する
+// 読み込みとLock/Unlockはどちらもローカルで行う
+//
+// leaderの選出やfailoverは行わないため、leaderが停止している間は書き込めない
+// またLockはapiserverごとなので、別のapiserverとの競合はhandlerがTxnの条件にした
+// resourceVersionをleaderが確認して検知する
+type ReplicatedStore struct {
+	local *leveldb.LevelDBStore
+
+	// leaderの場合はnil
+	replicationClient *replicationv0.ReplicationClient
+	watchClient       *watchv0.WatchClient
+
+	// WaitTimeout は転送した書き込みがローカルに反映されるまで待つ時間
+	WaitTimeout time.Duration
+}
+
+// errReplicationGap はleaderから受け取ったrevisionが飛んでいて順番に反映できない場合に返る
+var errReplicationGap = errors.New("replication gap")
+
+// NewReplicatedStore はlocalをleaderAddressのapiserverに追従させるReplicatedStoreを作成する
+// leaderAddressが空の場合はleaderとして動作する
+func NewReplicatedStore(local *leveldb.LevelDBStore, leaderAddress string) (*ReplicatedStore, error) {
+	s := &ReplicatedStore{
+		local:       local,
+		WaitTimeout: 10 * time.Second,
+	}
+	if leaderAddress == "" {
+		return s, nil
+	}
+
+	u, err := url.Parse(leaderAddress)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("Error: invalid leader address `%s`. use scheme://host:port.", leaderAddress)
+	}
+	port, err := strconv.ParseInt(u.Port(), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.replicationClient = replicationv0.NewReplicationClient(u.Scheme, u.Hostname(), int32(port))
+	s.watchClient = watchv0.NewWatchClient(u.Scheme, u.Hostname(), int32(port))
+	return s, nil
+}
+
+func (s *ReplicatedStore) IsLeader() bool {
+	return s.replicationClient == nil
+}
+
+// SetToken はleaderへのリクエストに付けるBearerトークンを設定する
+func (s *ReplicatedStore) SetToken(token string) {
+	if s.IsLeader() {
+		return
+	}
+	s.replicationClient.SetToken(token)
+	s.watchClient.SetToken(token)
+}
+
+// SetTLSConfig はhttpsのleaderにアクセスする場合のTLSの設定をする
+func (s *ReplicatedStore) SetTLSConfig(tlsConfig *tls.Config) {
+	if s.IsLeader() {
+		return
+	}
+	s.replicationClient.SetTLSConfig(tlsConfig)
+	s.watchClient.SetTLSConfig(tlsConfig)
+}
+
+// Revision はローカルに反映済みのrevisionを返す
+func (s *ReplicatedStore) Revision() int64 {
+	return s.local.Revision()
+}
+
+func (s *ReplicatedStore) Snapshot() (*store.Snapshot, error) {
+	return s.local.Snapshot()
+}
+
+// Restore は全てのデータをsnapshotの内容で置き換える
+// followerはleaderから複製するため、leaderでのみ実行できる
+func (s *ReplicatedStore) Restore(snapshot *store.Snapshot) error {
+	if !s.IsLeader() {
+		return fmt.Errorf("Error: restore must be run on the leader apiserver.")
+	}
+	return s.local.Restore(snapshot)
+}
+
+func (s *ReplicatedStore) List(prefix string, f func(n int) []interface{}) error {
+	return s.local.List(prefix, f)
+}
+
+func (s *ReplicatedStore) ListPage(prefix, startKey string, limit int64, f func(n int) []interface{}) (string, error) {
+	return s.local.ListPage(prefix, startKey, limit, f)
+}
+
+func (s *ReplicatedStore) Get(key string, v interface{}) error {
+	return s.local.Get(key, v)
+}
+
+func (s *ReplicatedStore) Put(key string, data interface{}) error {
+	if err := s.Txn(nil, []store.Op{store.OpPut(key, data)}); err != nil {
+		log.Println("replicated store:", "Failed to put ", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s *ReplicatedStore) Delete(key string) error {
+	if err := s.Txn(nil, []store.Op{store.OpDelete(key)}); err != nil {
+		log.Println("replicated store:", "Failed to delete ", err.Error())
+		return err
+	}
+	return nil
+}
+
+// Txn はleaderの場合はローカルに反映し、followerの場合はleaderに転送する
+// 転送した場合はleaderでの反映後のrevisionがローカルに反映されるまで待つので
+// 書き込みが成功した後のGetやListには書き込んだ内容が含まれる
+func (s *ReplicatedStore) Txn(compares []store.Compare, ops []store.Op) error {
+	if s.IsLeader() {
+		return s.local.Txn(compares, ops)
+	}
+
+	request := &replication.TxnRequest{
+		Compares: compares,
+		Ops:      []replication.TxnOp{},
+	}
+	for _, op := range ops {
+		txnOp := replication.TxnOp{
+			Type: op.Type,
+			Key:  op.Key,
+		}
+		if op.Type == store.OpTypePut {
+			dataJSON, err := json.Marshal(op.Data)
+			if err != nil {
+				return err
+			}
+			txnOp.Data = dataJSON
+		}
+		request.Ops = append(request.Ops, txnOp)
+	}
+
+	revision, err := s.replicationClient.Txn(request)
+	if err != nil {
+		if errors.Is(err, meta.ErrConflict) {
+			return fmt.Errorf("%w: %s", store.ErrCompareFailed, err.Error())
+		}
+		return fmt.Errorf("%w: failed to forward to leader: %s", store.ErrUnavailable, err.Error())
+	}
+
+	if err := s.waitRevision(revision); err != nil {
+		return err
+	}
+
+	// leader側で採番されたresourceVersionをdataに反映する
+	for _, op := range ops {
+		v, ok := op.Data.(meta.ResourceVersioner)
+		if op.Type != store.OpTypePut || !ok {
+			continue
+		}
+
+		obj := struct {
+			Meta struct {
+				ResourceVersion int64 `json:"resourceVersion"`
+			} `json:"meta"`
+		}{}
+		if err := s.local.Get(op.Key, &obj); err != nil {
+			return err
+		}
+		v.SetResourceVersion(obj.Meta.ResourceVersion)
+	}
+
+	return nil
+}
+
+func (s *ReplicatedStore) waitRevision(revision int64) error {
+	timeout := time.After(s.WaitTimeout)
+	for s.local.Revision() < revision {
+		select {
+		case <-timeout:
+			return fmt.Errorf("%w: timed out waiting for revision %d to be replicated", store.ErrUnavailable, revision)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (s *ReplicatedStore) Lock(key string) {
+	s.local.Lock(key)
+}
+
+func (s *ReplicatedStore) Unlock(key string) {
+	s.local.Unlock(key)
+}
+
+// Run はctxがキャンセルされるまでleaderの変更をローカルに反映し続ける
+// leaderの場合は何もせずに返る
+func (s *ReplicatedStore) Run(ctx context.Context) error {
+	if s.IsLeader() {
+		return nil
+	}
+
+	// 一度も同期していない場合はsnapshotから始める
+	needsResync := s.local.Revision() == 0
+	for {
+		if needsResync {
+			if err := s.resync(); err != nil {
+				log.Println("replicated store:", "Failed to resync", err.Error())
+				if !sleepWithContext(ctx, time.Second) {
+					return ctx.Err()
+				}
+				continue
+			}
+			needsResync = false
+		}
+
+		err := s.follow(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, watchv0.ErrResyncRequired) || errors.Is(err, errReplicationGap) {
+			log.Println("replicated store:", "resync required at revision", s.local.Revision())
+			needsResync = true
+			continue
+		}
+		if err != nil {
+			log.Println("replicated store:", "Failed to follow leader", err.Error())
+		}
+
+		if !sleepWithContext(ctx, time.Second) {
+			return ctx.Err()
+		}
+	}
+}
+
+// follow はローカルのrevisionからleaderのwatchを再開し、受け取った変更を順番に反映する
+func (s *ReplicatedStore) follow(ctx context.Context) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var applyErr error
+	err := s.watchClient.WatchNotice(watchCtx, watchv0.WatchOptions{
+		SinceRevision: s.local.Revision(),
+		Resume:        true,
+	}, func(noticeData *leveldb.NoticeData) {
+		if applyErr != nil {
+			return
+		}
+		if err := s.apply(noticeData); err != nil {
+			applyErr = err
+			cancel()
+		}
+	})
+	if applyErr != nil {
+		return applyErr
+	}
+	return err
+}
+
+func (s *ReplicatedStore) apply(noticeData *leveldb.NoticeData) error {
+	revision := s.local.Revision()
+	if noticeData.Revision <= revision {
+		return nil
+	}
+	// leaderの書き込みは1つずつrevisionが進むので、飛んでいる場合は取りこぼしている
+	if noticeData.Revision != revision+1 {
+		return fmt.Errorf("%w: want revision %d, got %d", errReplicationGap, revision+1, noticeData.Revision)
+	}
+
+	var value []byte
+	if noticeData.After != "" {
+		value = []byte(noticeData.After)
+	}
+	return s.local.Apply(noticeData.Revision, noticeData.Key, value)
+}
+
+// resync はleaderのsnapshotでローカルのデータを置き換える
+func (s *ReplicatedStore) resync() error {
+	snapshot, err := s.replicationClient.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	log.Println("replicated store:", "restore snapshot at revision", snapshot.Revision)
+	return s.local.Resync(snapshot)
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,219 @@
+package replicated_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/replication"
+	replicationv0 "github.com/ophum/humstack/pkg/api/replication/v0"
+	"github.com/ophum/humstack/pkg/api/watch"
+	watchv0 "github.com/ophum/humstack/pkg/api/watch/v0"
+	"github.com/ophum/humstack/pkg/store"
+	"github.com/ophum/humstack/pkg/store/leveldb"
+	"github.com/ophum/humstack/pkg/store/replicated"
+)
+
+type data struct {
+	meta.Meta `json:"meta"`
+	Data      string `json:"data"`
+}
+
+// testNode はlocalhostで動かすapiserver
+type testNode struct {
+	local  *leveldb.LevelDBStore
+	store  *replicated.ReplicatedStore
+	hub    *watchv0.Hub
+	server *httptest.Server
+	cancel context.CancelFunc
+}
+
+func newTestNode(t *testing.T, dir, leaderAddress string, historySize int) *testNode {
+	notifier := make(chan string, 100)
+	local, err := leveldb.NewLevelDBStore(dir, notifier, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := replicated.NewReplicatedStore(local, leaderAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WaitTimeout = 5 * time.Second
+
+	hub := watchv0.NewHub(watchv0.NewEventHistory(historySize, s.Revision()), 100)
+	go hub.Run(notifier)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	v0 := r.Group("/api/v0")
+	watch.NewWatchHandler(v0, watchv0.NewWatchHandler(hub)).RegisterHandlers()
+	replication.NewReplicationHandler(v0, replicationv0.NewReplicationHandler(s)).RegisterHandlers()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx)
+
+	return &testNode{
+		local:  local,
+		store:  s,
+		hub:    hub,
+		server: httptest.NewServer(r),
+		cancel: cancel,
+	}
+}
+
+func (n *testNode) close() {
+	n.cancel()
+	n.server.CloseClientConnections()
+	n.server.Close()
+	n.local.Close()
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	timeout := time.After(5 * time.Second)
+	for !f() {
+		select {
+		case <-timeout:
+			t.Fatal("timed out")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "humstack-replicated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReplicatedStore(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	leader := newTestNode(t, filepath.Join(dir, "leader"), "", 100)
+	followers := []*testNode{
+		newTestNode(t, filepath.Join(dir, "follower1"), leader.server.URL, 100),
+		newTestNode(t, filepath.Join(dir, "follower2"), leader.server.URL, 100),
+	}
+	defer leader.close()
+	for _, f := range followers {
+		defer f.close()
+	}
+	nodes := append([]*testNode{leader}, followers...)
+
+	sub, err := followers[1].hub.Register()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// followerへの書き込みはleaderに転送されて全てのapiserverに反映される
+	a := data{Meta: meta.Meta{ID: "a"}, Data: "a"}
+	if err := followers[0].store.Put("test/a", &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.ResourceVersion != 1 {
+		t.Fatalf("want: 1, got: %d", a.ResourceVersion)
+	}
+	for i, n := range nodes {
+		waitFor(t, func() bool { return n.store.Revision() == 1 })
+
+		got := data{}
+		if err := n.store.Get("test/a", &got); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		if got.Data != "a" || got.ResourceVersion != 1 {
+			t.Fatalf("node %d: want: a at revision 1, got: %s at revision %d", i, got.Data, got.ResourceVersion)
+		}
+	}
+
+	// followerのwatchにも複製された変更が通知される
+	select {
+	case <-sub.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notice on follower")
+	}
+
+	// 別のfollowerからの古いresourceVersionでの書き込みは競合になる
+	a.Data = "updated"
+	if err := followers[0].store.Put("test/a", &a); err != nil {
+		t.Fatal(err)
+	}
+	stale := data{Meta: meta.Meta{ID: "a", ResourceVersion: 1}, Data: "stale"}
+	err = followers[1].store.Txn(
+		[]store.Compare{store.CompareResourceVersion("test/a", stale.ResourceVersion)},
+		[]store.Op{store.OpPut("test/a", &stale)},
+	)
+	if !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("want: %v, got: %v", store.ErrConflict, err)
+	}
+
+	if err := followers[1].store.Delete("test/a"); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		waitFor(t, func() bool { return n.store.Revision() == 3 })
+		if err := n.store.Get("test/a", &data{}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("node %d: want: %v, got: %v", i, store.ErrNotFound, err)
+		}
+	}
+}
+
+func TestReplicatedStoreCatchUp(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	leader := newTestNode(t, filepath.Join(dir, "leader"), "", 2)
+	defer leader.close()
+
+	put := func(from, to int) {
+		for i := from; i < to; i++ {
+			id := fmt.Sprintf("%d", i)
+			if err := leader.store.Put("test/"+id, &data{Meta: meta.Meta{ID: id}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	assertReplicated := func(n *testNode, count int) {
+		waitFor(t, func() bool { return n.store.Revision() == leader.store.Revision() })
+
+		list := []*data{}
+		err := n.store.List("test/", func(num int) []interface{} {
+			list = make([]*data, num)
+			ret := make([]interface{}, num)
+			for i := range list {
+				list[i] = &data{}
+				ret[i] = list[i]
+			}
+			return ret
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != count {
+			t.Fatalf("want: %d, got: %d", count, len(list))
+		}
+	}
+
+	// 起動前に書き込まれたデータはsnapshotから取得する
+	put(0, 5)
+	followerDir := filepath.Join(dir, "follower")
+	follower := newTestNode(t, followerDir, leader.server.URL, 100)
+	assertReplicated(follower, 5)
+	follower.close()
+
+	// 停止中の変更がleaderの履歴に残っていない場合もsnapshotから追いつく
+	put(5, 10)
+	follower = newTestNode(t, followerDir, leader.server.URL, 100)
+	defer follower.close()
+	assertReplicated(follower, 10)
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// MarshalWithResourceVersion はdataのmeta.resourceVersionをresourceVersionに書き換えてJSONにする
+// meta.schemaVersionはDefaultMigrationRegistryの現在のバージョンになる
+// dataがmeta.ResourceVersionerを満たす場合はdata自体のresourceVersionも更新する
+func MarshalWithResourceVersion(data interface{}, resourceVersion int64) ([]byte, error) {
+	if v, ok := data.(meta.ResourceVersioner); ok {
+		v.SetResourceVersion(resourceVersion)
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(dataJSON, &obj); err != nil {
+		// オブジェクトでない場合はそのまま保存する
+		return json.MarshalIndent(data, "", "  ")
+	}
+
+	metaJSON, ok := obj["meta"]
+	if !ok {
+		return json.MarshalIndent(data, "", "  ")
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(metaJSON, &m); err != nil {
+		return nil, err
+	}
+	m["resourceVersion"] = resourceVersion
+	setSchemaVersion(m)
+
+	metaJSON, err = json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	obj["meta"] = metaJSON
+
+	return json.MarshalIndent(obj, "", "  ")
+}
@@ -0,0 +1,20 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/ophum/humstack/pkg/api/meta"
+)
+
+// Snapshot はある時点のstoreの全てのデータ
+// replicaの追従やバックアップに使う
+type Snapshot struct {
+	Revision int64           `json:"revision"`
+	Entries  []SnapshotEntry `json:"entries"`
+}
+
+type SnapshotEntry struct {
+	Key     string          `json:"key"`
+	APIType meta.APIType    `json:"apiType"`
+	Value   json.RawMessage `json:"value"`
+}
@@ -0,0 +1,79 @@
+package store
+
+import "encoding/json"
+
+// Compare はTxnをcommitする条件
+// keyに保存されているデータのresourceVersionがResourceVersionと一致する場合に成り立つ
+// ResourceVersionが0の場合はkeyが存在しないことを条件にする
+type Compare struct {
+	Key             string `json:"key"`
+	ResourceVersion int64  `json:"resourceVersion"`
+}
+
+// CompareResourceVersion はkeyのresourceVersionがresourceVersionと一致することを条件にする
+func CompareResourceVersion(key string, resourceVersion int64) Compare {
+	return Compare{
+		Key:             key,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+// CompareNotExists はkeyが存在しないことを条件にする
+func CompareNotExists(key string) Compare {
+	return Compare{
+		Key: key,
+	}
+}
+
+// Match はkeyに保存されているJSONが条件を満たすかを返す
+// keyが存在しない場合dataJSONはnilを渡す
+func (c Compare) Match(dataJSON []byte) bool {
+	if dataJSON == nil {
+		return c.ResourceVersion == 0
+	}
+	if c.ResourceVersion == 0 {
+		return false
+	}
+
+	obj := struct {
+		Meta struct {
+			ResourceVersion int64 `json:"resourceVersion"`
+		} `json:"meta"`
+	}{}
+	if err := json.Unmarshal(dataJSON, &obj); err != nil {
+		return false
+	}
+	return obj.Meta.ResourceVersion == c.ResourceVersion
+}
+
+type OpType string
+
+const (
+	OpTypePut    OpType = "Put"
+	OpTypeDelete OpType = "Delete"
+)
+
+// Op はTxnでまとめて反映する操作
+type Op struct {
+	Type OpType
+	Key  string
+	Data interface{}
+}
+
+// OpPut はkeyにdataを保存する
+// dataのresourceVersionは書き込み時のrevisionになる
+func OpPut(key string, data interface{}) Op {
+	return Op{
+		Type: OpTypePut,
+		Key:  key,
+		Data: data,
+	}
+}
+
+// OpDelete はkeyを削除する
+func OpDelete(key string) Op {
+	return Op{
+		Type: OpTypeDelete,
+		Key:  key,
+	}
+}
@@ -1,6 +1,10 @@
 package cloudinit
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
 
 func TestOutput(t *testing.T) {
 	metaData := MetaData{
@@ -31,8 +35,14 @@ func TestOutput(t *testing.T) {
 		},
 	}
 
+	dir, err := ioutil.TempDir("", "humstack-cloudinit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
 	ci := NewCloudInit(metaData, userData, networkConfig)
-	err := ci.Output("./")
+	err = ci.Output(dir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,74 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var (
+	// VcpusUnits は1000mを1vcpuとするミリ単位での倍率
+	VcpusUnits = map[string]int64{
+		"":  1000,
+		"m": 1,
+	}
+	// BytesUnits はバイト単位での倍率
+	BytesUnits = map[string]int64{
+		"":  1,
+		"K": 1024,
+		"M": 1024 * 1024,
+		"G": 1024 * 1024 * 1024,
+	}
+)
+
+// Parse は8000mや8Gのような単位付きの数値をunitsの倍率で整数に変換する
+// 負の値や小数、unitsにない単位はエラーにする
+func Parse(value string, units map[string]int64) (int64, error) {
+	number, unit := value, ""
+	if value != "" && (value[len(value)-1] < '0' || value[len(value)-1] > '9') {
+		number, unit = value[:len(value)-1], value[len(value)-1:]
+	}
+
+	scale, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("Error: unknown unit of `%s`.", value)
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("Error: invalid quantity `%s`.", value)
+	}
+	return n * scale, nil
+}
+
+// ParseVcpus は1500mや2のようなvcpusをミリ単位に変換する
+func ParseVcpus(value string) (int64, error) {
+	return Parse(value, VcpusUnits)
+}
+
+// ParseBytes は8Gや512Mのようなサイズをバイト単位に変換する
+func ParseBytes(value string) (int64, error) {
+	return Parse(value, BytesUnits)
+}
+
+// Format はnを割り切れる最も大きい単位で表す
+func Format(n int64, units map[string]int64) string {
+	if n == 0 {
+		return "0"
+	}
+
+	names := []string{}
+	for unit := range units {
+		names = append(names, unit)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return units[names[i]] > units[names[j]]
+	})
+
+	for _, unit := range names {
+		if n%units[unit] == 0 {
+			return fmt.Sprintf("%d%s", n/units[unit], unit)
+		}
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,54 @@
+package quantity
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		units map[string]int64
+		want  int64
+		ok    bool
+	}{
+		{"8000m", VcpusUnits, 8000, true},
+		{"8", VcpusUnits, 8000, true},
+		{"8G", VcpusUnits, 0, false},
+		{"8G", BytesUnits, 8 * 1024 * 1024 * 1024, true},
+		{"512M", BytesUnits, 512 * 1024 * 1024, true},
+		{"1024", BytesUnits, 1024, true},
+		{"1.5G", BytesUnits, 0, false},
+		{"-1G", BytesUnits, 0, false},
+		{"-1", BytesUnits, 0, false},
+		{"G", BytesUnits, 0, false},
+		{"", BytesUnits, 0, false},
+		// 倍率をかけるとint64を超える
+		{"9223372036854775807G", BytesUnits, 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.value, test.units)
+		if got != test.want || (err == nil) != test.ok {
+			t.Errorf("%s: want: %d, %v, got: %d, %v", test.value, test.want, test.ok, got, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		n     int64
+		units map[string]int64
+		want  string
+	}{
+		{0, BytesUnits, "0"},
+		{8 * 1024 * 1024 * 1024, BytesUnits, "8G"},
+		{1536 * 1024, BytesUnits, "1536K"},
+		{1000, BytesUnits, "1000"},
+		{2000, VcpusUnits, "2"},
+		{1500, VcpusUnits, "1500m"},
+	}
+
+	for _, test := range tests {
+		if got := Format(test.n, test.units); got != test.want {
+			t.Errorf("%d: want: %s, got: %s", test.n, test.want, got)
+		}
+	}
+}
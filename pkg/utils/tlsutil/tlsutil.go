@@ -0,0 +1,136 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ServerConfig はHTTPSで公開するサーバーの証明書の設定
+type ServerConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile を指定した場合はこのCAで署名されたクライアント証明書を要求する
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// ClientConfig はHTTPSでサーバーにアクセスするクライアントの設定
+type ClientConfig struct {
+	// CAFile が空の場合はシステムのCAでサーバーの証明書を検証する
+	CAFile string `yaml:"caFile"`
+	// CertFile とKeyFile はクライアント証明書を要求するサーバーにアクセスする場合に指定する
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// NewServerTLSConfig はcertFileとkeyFileの証明書で公開するサーバーのtls.Configを作成する
+// clientCAFileを指定した場合はclientAuthに従ってクライアント証明書を検証する
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string, clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = clientAuth
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig はクライアントのtls.Configを作成する
+// 空のファイルは使わない
+func NewClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// TLSConfig はServerConfigからtls.Configを作成する
+// CertFileが空の場合はnilを返す
+func (c *ServerConfig) TLSConfig() (*tls.Config, error) {
+	if c == nil || c.CertFile == "" {
+		return nil, nil
+	}
+	return NewServerTLSConfig(c.CertFile, c.KeyFile, c.ClientCAFile, tls.RequireAndVerifyClientCert)
+}
+
+// Scheme はHTTPSで公開する場合はhttps、それ以外はhttpを返す
+func (c *ServerConfig) Scheme() string {
+	if c == nil || c.CertFile == "" {
+		return "http"
+	}
+	return "https"
+}
+
+// TLSConfig はClientConfigからtls.Configを作成する
+// cがnilの場合はnilを返す
+func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return NewClientTLSConfig(c.CAFile, c.CertFile, c.KeyFile)
+}
+
+// Scheme はtlsConfigがnilの場合はhttp、それ以外はhttpsを返す
+func Scheme(tlsConfig *tls.Config) string {
+	if tlsConfig == nil {
+		return "http"
+	}
+	return "https"
+}
+
+// ListenAndServe はtlsConfigがnilの場合はHTTP、それ以外はHTTPSでhandlerを公開する
+func ListenAndServe(addr string, handler http.Handler, tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	// 証明書はtlsConfigに設定済み
+	return server.ListenAndServeTLS("", "")
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("Error: no certificate found in `%s`.", caFile)
+	}
+	return pool, nil
+}
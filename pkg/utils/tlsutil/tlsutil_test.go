@@ -0,0 +1,187 @@
+package tlsutil_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ophum/humstack/pkg/api/auth"
+	"github.com/ophum/humstack/pkg/api/meta"
+	"github.com/ophum/humstack/pkg/api/system"
+	"github.com/ophum/humstack/pkg/api/system/node"
+	nodev0 "github.com/ophum/humstack/pkg/api/system/node/v0"
+	"github.com/ophum/humstack/pkg/client"
+	"github.com/ophum/humstack/pkg/store/memory"
+	"github.com/ophum/humstack/pkg/utils/tlsutil"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "humstack-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{cert: cert, key: key, dir: dir}
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// issue はCAで署名した証明書と鍵を書き出してファイル名を返す
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(ca.dir, name+".pem")
+	keyFile := filepath.Join(ca.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "humstack-tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	serverCert, serverKey := ca.issue(t, "apiserver", 2, x509.ExtKeyUsageServerAuth)
+	agentCert, agentKey := ca.issue(t, "agent", 3, x509.ExtKeyUsageClientAuth)
+
+	serverTLSConfig, err := tlsutil.NewServerTLSConfig(serverCert, serverKey, caFile, tls.VerifyClientCertIfGiven)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// Nodeの登録にはクライアント証明書が必要
+	api := r.Group("/api/v0")
+	api.Use(func(ctx *gin.Context) {
+		if ctx.Request.Method == http.MethodPost {
+			auth.RequireClientCert(ctx)
+			return
+		}
+		ctx.Next()
+	})
+	node.NewNodeHandler(api, nodev0.NewNodeHandler(memory.NewMemoryStore())).RegisterHandlers()
+
+	server := httptest.NewUnstartedServer(r)
+	server.TLS = serverTLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.ParseInt(portStr, 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newClients := func(t *testing.T, caFile, certFile, keyFile string) *client.Clients {
+		tlsConfig, err := tlsutil.NewClientTLSConfig(caFile, certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.NewClients(host, int32(port), tlsConfig)
+	}
+	newNode := func(id string) *system.Node {
+		return &system.Node{Meta: meta.Meta{ID: id}}
+	}
+
+	// サーバーの証明書を検証できない
+	if _, err := newClients(t, "", "", "").SystemV0().Node().List(); err == nil {
+		t.Fatal("want: error without CA, got: nil")
+	}
+
+	// クライアント証明書がなくても取得はできるが登録はできない
+	user := newClients(t, caFile, "", "")
+	if _, err := user.SystemV0().Node().List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := user.SystemV0().Node().Create(newNode("node1")); err == nil {
+		t.Fatal("want: error without client certificate, got: nil")
+	}
+
+	agent := newClients(t, caFile, agentCert, agentKey)
+	if _, err := agent.SystemV0().Node().Create(newNode("node1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 別のCAで署名されたクライアント証明書は使えない
+	otherDir := filepath.Join(dir, "other")
+	if err := os.Mkdir(otherDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	otherCert, otherKey := newTestCA(t, otherDir).issue(t, "agent", 2, x509.ExtKeyUsageClientAuth)
+	if _, err := newClients(t, caFile, otherCert, otherKey).SystemV0().Node().Create(newNode("node2")); err == nil {
+		t.Fatal("want: error with unknown client certificate, got: nil")
+	}
+}